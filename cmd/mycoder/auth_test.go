@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/config"
+)
+
+func TestAPIClientSendsBearerToken(t *testing.T) {
+	oldTok, oldURL := apiTokenFlag, serverURLFlag
+	t.Cleanup(func() { apiTokenFlag, serverURLFlag = oldTok, oldURL })
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	t.Setenv("MYCODER_API_TOKEN", "env-secret")
+	resp, err := apiClient.Get(srv.URL + "/projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != "Bearer env-secret" {
+		t.Fatalf("Authorization=%q, want bearer from env", got)
+	}
+
+	// --token wins over the env
+	rest, err := extractValueFlag([]string{"--token=flag-secret", "projects", "list"}, "token", &apiTokenFlag)
+	if err != nil || len(rest) != 2 {
+		t.Fatalf("rest=%v err=%v", rest, err)
+	}
+	serverURLFlag = srv.URL
+	projectsCmd(rest[1:])
+	if got != "Bearer flag-secret" {
+		t.Fatalf("Authorization=%q, want bearer from --token", got)
+	}
+}
+
+func TestAPIClientWithoutToken(t *testing.T) {
+	old := apiTokenFlag
+	t.Cleanup(func() { apiTokenFlag = old })
+	apiTokenFlag = ""
+	t.Setenv("MYCODER_API_TOKEN", "")
+
+	got := "unset"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+	resp, err := newAPIClient(0).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != "" {
+		t.Fatalf("expected no Authorization header, got %q", got)
+	}
+}
+
+func TestAPITokenFromConfigFile(t *testing.T) {
+	old := apiTokenFlag
+	t.Cleanup(func() { apiTokenFlag = old })
+	apiTokenFlag = ""
+	t.Setenv("MYCODER_API_TOKEN", "")
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(cfg, []byte(`{"MYCODER_API_TOKEN":"file-secret"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = config.Load(empty) })
+	if got := apiToken(); got != "file-secret" {
+		t.Fatalf("apiToken()=%q, want value from config file", got)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/server"
+	"mycoder/internal/store"
+)
+
+func TestChatContextOnly(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("func Alpha() {}\n"), 0o644)
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "a.go", "func Alpha() {}\n")
+	srv := httptest.NewServer(server.NewHandler(st, nil))
+	defer srv.Close()
+
+	body := `{"messages":[{"role":"user","content":"Alpha"}],"projectID":"` + p.ID + `","retrievalOnly":true}`
+	var buf bytes.Buffer
+	if code := runChatContext(&buf, srv.URL, body); code != 0 {
+		t.Fatalf("code=%d out=%q", code, buf.String())
+	}
+	out := buf.String()
+	if !strings.Contains(out, "func Alpha() {}") || !strings.Contains(out, "citations (1):\n  a.go:1-1\n") {
+		t.Fatalf("out=%q", out)
+	}
+}
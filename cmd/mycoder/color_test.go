@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func withColorState(t *testing.T, mode string, tty bool) {
+	t.Helper()
+	oldMode, oldTTY := colorMode, stdoutIsTerminal
+	t.Cleanup(func() { colorMode, stdoutIsTerminal = oldMode, oldTTY })
+	colorMode = mode
+	stdoutIsTerminal = func() bool { return tty }
+}
+
+func TestWantColorAutoSuppressedWithoutTTY(t *testing.T) {
+	withColorState(t, "auto", false)
+	if wantColor(true) {
+		t.Fatalf("auto mode should not color non-TTY output even when --color is set")
+	}
+
+	withColorState(t, "auto", true)
+	if !wantColor(true) || wantColor(false) {
+		t.Fatalf("auto mode on a TTY should follow the command's --color flag")
+	}
+
+	withColorState(t, "always", false)
+	if !wantColor(false) {
+		t.Fatalf("always should force color in pipes")
+	}
+
+	withColorState(t, "never", true)
+	if wantColor(true) {
+		t.Fatalf("never should disable color on a TTY")
+	}
+}
+
+func TestExtractColorArgs(t *testing.T) {
+	withColorState(t, "auto", false)
+	got := extractColorArgs([]string{"fs", "diff", "--color=always", "--color", "--project", "p"})
+	if strings.Join(got, " ") != "fs diff --color --project p" || colorMode != "always" {
+		t.Fatalf("args=%v mode=%s", got, colorMode)
+	}
+	got = extractColorArgs([]string{"exec", "--no-color", "--", "ls", "--no-color"})
+	if strings.Join(got, " ") != "exec -- ls --no-color" || colorMode != "never" {
+		t.Fatalf("args after -- must be kept: args=%v mode=%s", got, colorMode)
+	}
+	if err := setColorMode("rainbow"); err == nil {
+		t.Fatalf("invalid MYCODER_COLOR should be rejected")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCommandRegistryUsage(t *testing.T) {
+	var buf bytes.Buffer
+	usage(&buf)
+	help := buf.String()
+	seen := map[string]bool{}
+	for _, c := range commands() {
+		if c.name == "" || c.run == nil {
+			t.Fatalf("incomplete command entry: %+v", c)
+		}
+		if seen[c.name] {
+			t.Fatalf("duplicate command %q", c.name)
+		}
+		seen[c.name] = true
+		if len(c.usage) == 0 {
+			t.Fatalf("command %q has no usage", c.name)
+		}
+		for _, u := range c.usage {
+			if strings.TrimSpace(u) == "" {
+				t.Fatalf("command %q has an empty usage line", c.name)
+			}
+			if !strings.HasPrefix(u, c.name) {
+				t.Fatalf("usage %q does not start with %q", u, c.name)
+			}
+		}
+		if !strings.Contains(help, "  mycoder "+c.name) {
+			t.Fatalf("help does not list %q:\n%s", c.name, help)
+		}
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	if _, ok := findCommand("search"); !ok {
+		t.Fatalf("search should be registered")
+	}
+	if _, ok := findCommand("nope"); ok {
+		t.Fatalf("unknown command should not resolve")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mycoder/internal/server"
+	"mycoder/internal/store"
+)
+
+func TestDoctorChecklist(t *testing.T) {
+	st := store.New()
+	p := st.CreateProject("doc", t.TempDir(), nil)
+	st.AddDocument(p.ID, "a.go", "package a")
+	srv := httptest.NewServer(server.NewHandler(st, nil))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	failed := runDoctor(&buf, srv.URL, false)
+	out := buf.String()
+	if !strings.Contains(out, "✓ server reachable") || !strings.Contains(out, "✓ project indexed (1 projects, 1 documents)") {
+		t.Fatalf("missing passing checks:\n%s", out)
+	}
+	// no LLM, no embeddings and the in-memory store
+	if failed != 3 || !strings.Contains(out, "✗ LLM reachable") || !strings.Contains(out, "MYCODER_SQLITE_PATH") {
+		t.Fatalf("failed=%d\n%s", failed, out)
+	}
+}
+
+func TestDoctorServerDown(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	url := srv.URL
+	srv.Close()
+	var buf bytes.Buffer
+	if failed := runDoctor(&buf, url, false); failed != 1 || !strings.Contains(buf.String(), "mycoder serve") {
+		t.Fatalf("failed=%d\n%s", failed, buf.String())
+	}
+}
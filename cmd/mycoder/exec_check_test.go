@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"mycoder/internal/server"
+	"mycoder/internal/store"
+)
+
+func TestExecCheck(t *testing.T) {
+	os.Setenv("MYCODER_SHELL_DENY_REGEX", `(?i)rm\s+-rf`)
+	defer os.Unsetenv("MYCODER_SHELL_DENY_REGEX")
+	srv := httptest.NewServer(server.NewHandler(store.New(), nil))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	if code := runExecCheck(&buf, srv.URL, "rm", []string{"-rf", "build"}); code != 1 || !strings.Contains(buf.String(), "denied: rm -rf build (command denied by policy)") {
+		t.Fatalf("code=%d out=%q", code, buf.String())
+	}
+	buf.Reset()
+	if code := runExecCheck(&buf, srv.URL, "go", []string{"test", "./..."}); code != 0 || buf.String() != "allowed: go test ./...\n" {
+		t.Fatalf("code=%d out=%q", code, buf.String())
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadIndexStreamPrintsPercentageAndETA(t *testing.T) {
+	stream := strings.Join([]string{
+		"event: job", "data: job-1", "",
+		"event: start", `data: {"total":40,"startedAt":"2024-01-01T00:00:00Z"}`, "",
+		"event: progress", `data: {"indexed":10,"total":40,"elapsedMs":2000}`, "",
+		"event: progress", `data: {"indexed":20,"total":40,"elapsedMs":4000}`, "",
+		"event: progress", `data: {"indexed":40,"total":40,"elapsedMs":8000}`, "",
+		"event: completed", `data: {"documents":40}`, "",
+	}, "\n")
+	var out, errOut bytes.Buffer
+	if err := readIndexStream(strings.NewReader(stream), &out, &errOut, ""); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"job: job-1",
+		"progress: 10/40 (25.0%) elapsed 2s eta 6s",
+		"progress: 20/40 (50.0%) elapsed 4s eta 4s",
+		"progress: 40/40 (100.0%) elapsed 8s eta 0s",
+		"completed",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestIndexProgressWithoutElapsed(t *testing.T) {
+	// older servers send only counts: keep percentage, skip ETA
+	if got := newIndexProgress().line(3, 12, 0); got != "progress: 3/12 (25.0%)" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestIndexProgressRollingRate(t *testing.T) {
+	p := newIndexProgress()
+	// slow start, then much faster: the ETA should follow the recent rate
+	p.line(10, 1000, 10*time.Second)
+	for i := 1; i <= indexProgressWindow; i++ {
+		p.line(10+i*100, 1000, 10*time.Second+time.Duration(i)*time.Second)
+	}
+	got := p.line(610, 1000, 16*time.Second)
+	if !strings.HasSuffix(got, "eta 4s") {
+		t.Fatalf("expected ETA from the recent rate, got %q", got)
+	}
+}
@@ -19,127 +19,281 @@ import (
 	"time"
 
 	"mycoder/internal/config"
+	oai "mycoder/internal/llm/openai"
 	mylog "mycoder/internal/log"
+	"mycoder/internal/patch"
 	"mycoder/internal/server"
 	"mycoder/internal/version"
 )
 
 func main() {
 	// load config file and apply env (env has precedence)
-	_ = config.LoadAndApply()
+	if err := config.LoadAndApply(); err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(1)
+	}
+	// global color policy: MYCODER_COLOR, overridden by --color=<mode>/--no-color
+	if err := setColorMode(config.Get("MYCODER_COLOR")); err != nil {
+		fmt.Fprintln(os.Stderr, "MYCODER_COLOR:", err)
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], extractColorArgs(os.Args[1:])...)
+	rest := os.Args[1:]
+	for _, g := range []struct {
+		name string
+		dst  *string
+	}{{"server-url", &serverURLFlag}, {"token", &apiTokenFlag}} {
+		var err error
+		if rest, err = extractValueFlag(rest, g.name, g.dst); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	os.Args = append(os.Args[:1], rest...)
 	if len(os.Args) < 2 {
 		// No arguments provided - start interactive chat mode
 		interactiveChatMode()
 		return
 	}
 
-	switch os.Args[1] {
-	case "serve":
-		fs := flag.NewFlagSet("serve", flag.ExitOnError)
-		addr := fs.String("addr", ":8089", "listen address")
-		_ = fs.Parse(os.Args[2:])
-		// structured startup log
-		{
-			lg := mylog.New()
-			lg.Info("server.start", "addr", *addr)
-		}
-		if err := server.Run(*addr); err != nil {
-			fmt.Fprintf(os.Stderr, "server error: %v\n", err)
-			os.Exit(1)
-		}
-	case "version":
-		fmt.Println(version.String())
-	case "projects":
-		projectsCmd(os.Args[2:])
-	case "index":
-		indexCmd(os.Args[2:])
-	case "search":
-		searchCmd(os.Args[2:])
-	case "ask":
-		askCmd(os.Args[2:])
-	case "chat":
-		chatCmd(os.Args[2:])
-	case "models":
-		modelsCmd(os.Args[2:])
-	case "metrics":
-		metricsCmd(os.Args[2:])
-	case "explain":
-		explainCmd(os.Args[2:])
-	case "edit":
-		editCmd(os.Args[2:])
-	case "hooks":
-		hooksCmd(os.Args[2:])
-	case "test":
-		testCmd(os.Args[2:])
-	case "exec":
-		execCmd(os.Args[2:])
-	case "knowledge":
-		knowledgeCmd(os.Args[2:])
-	case "fs":
-		fsCmd(os.Args[2:])
-	case "mcp":
-		mcpCmd(os.Args[2:])
-	case "seed":
-		seedCmd(os.Args[2:])
-	case "help", "-h", "--help":
-		usage()
+	name := os.Args[1]
+	if name == "-h" || name == "--help" {
+		name = "help"
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		usage(os.Stderr)
+		os.Exit(1)
+	}
+	cmd.run(os.Args[2:])
+}
 
-	default:
-		// basic placeholders for planned commands
-		cmd := os.Args[1]
-		if isKnownStub(cmd) {
-			fmt.Printf("%s: not implemented yet\n", cmd)
-			os.Exit(0)
+// command is one top-level subcommand; usage holds its help lines without
+// the leading "mycoder ".
+type command struct {
+	name  string
+	usage []string
+	run   func(args []string)
+}
+
+// commands returns the registry used for both dispatch and usage output.
+// It is a function rather than a package var because help refers back to it.
+func commands() []command {
+	return []command{
+		{"serve", []string{"serve [--addr :8089] [--skip-llm-probe] [--readonly]"}, serveCmd},
+		{"version", []string{"version"}, func([]string) { fmt.Println(version.String()) }},
+		{"projects", []string{"projects list | create --name <n> [--root .] [--ignore 'gen/*,*.pb.go']"}, projectsCmd},
+		{"index", []string{
+			"index --project <id> [--mode full|incremental] [--dry-run] [--max-total-bytes N]",
+			"index stats --project <id> [--json]",
+		}, indexCmd},
+		{"search", []string{"search \"<query>\" [--project <id>] [--mode fts|literal|regex] [--group] [--with-content]"}, searchCmd},
+		{"ask", []string{"ask [--project <id>] [--k 5] [--max-tokens N] \"<question>\""}, askCmd},
+		{"chat", []string{"chat [--project <id>] [--k 5] [--max-tokens N] [--system <text>|--system-file <path>] [--context-only] \"<prompt>\""}, chatCmd},
+		{"models", []string{"models [--base-url <url>] [--format table|json|raw] [--filter s]"}, modelsCmd},
+		{"metrics", []string{"metrics"}, metricsCmd},
+		{"doctor", []string{"doctor [--color]"}, doctorCmd},
+		{"config", []string{"config show [--json] [--server]"}, configCmd},
+		{"conversations", []string{"conversations list [--project <id>] [--json] | show <id> [--json] | pin|unpin <id>"}, conversationsCmd},
+		{"knowledge", []string{"knowledge [add|list|get|update|delete|export|import|vet|promote|approve|approvals|reverify|decay|gc]"}, knowledgeCmd},
+		{"fs", []string{
+			"fs [read|write|delete|patch] --project <id> --path <p> [--content ...] [--start N --length N --replace ...]",
+			"fs read --project <id> --path <p> [--start N] [--end M]",
+			"fs mkdir --project <id> --path <dir>",
+			"fs move --project <id> --from <p> --to <p> [--dry-run|--yes]",
+			"fs diff --project <id> --path <p> --new-file <file> [--context 3] [--ignore-crlf] [--color] [--word-diff]",
+			"fs patch-unified --project <id> --file <diff.patch> [--dry-run|--yes] [--fuzz N] [--color]",
+			"fs patch-unified-rollback --project <id> --patch-id <id> [--dry-run|--yes]",
+			"fs patch-list --project <id> [--json]",
+		}, fsCmd},
+		{"exec", []string{
+			"exec -- -- <cmd> [args...]",
+			"exec --check -- <cmd> [args...]   # shell policy preview, does not run",
+		}, execCmd},
+		{"explain", []string{"explain --project <id> [--max-tokens N] <path|symbol>"}, explainCmd},
+		{"edit", []string{"edit --project <id> --goal \"<설명>\" [--files a.go,b.go] [--stream] [--max-tokens N]"}, editCmd},
+		{"hooks", []string{"hooks run [--project <id>] [--targets fmt-check,test,lint] [--runner make|npm|just|raw] [--parallel] [--timeout 60]"}, hooksCmd},
+		{"mcp", []string{
+			"mcp tools|call --name <tool> --json '<params>'",
+			"mcp calls [--tool <name>] [--limit 20] [--json]   # recent /mcp/call audit history",
+			"mcp serve [--token <t>]   # MCP JSON-RPC over stdio",
+		}, mcpCmd},
+		{"test", []string{"test --project <id> [--timeout 60] [--verbose]"}, testCmd},
+		{"seed", []string{"seed rag --project <id> [--docs] [--code] [--web-json <file>] [--dry-run] [--pin]"}, seedCmd},
+		{"help", []string{"help"}, func([]string) { usage(os.Stdout) }},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
 		}
-		usage()
+	}
+	return command{}, false
+}
+
+func serveCmd(args []string) {
+	addr := applyServeFlags(args)
+	// structured startup log
+	{
+		lg := mylog.New()
+		lg.Info("server.start", "addr", addr)
+	}
+	if err := server.Run(addr); err != nil {
+		fmt.Fprintf(os.Stderr, "server error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func usage() {
-	fmt.Println("mycoder - project-aware coding CLI")
-	fmt.Println("usage:")
-	fmt.Println("  mycoder                           - Interactive chat mode (like Claude Code)")
-	fmt.Println("  mycoder serve [--addr :8089]")
-	fmt.Println("  mycoder version")
-	fmt.Println("  mycoder projects [list|create]")
-	fmt.Println("  mycoder index --project <id> [--mode full|incremental]")
-	fmt.Println("  mycoder search \"<query>\" [--project <id>]")
-	fmt.Println("  mycoder ask [--project <id>] [--k 5] \"<question>\"")
-	fmt.Println("  mycoder chat [--project <id>] [--k 5] \"<prompt>\"")
-	fmt.Println("  mycoder models")
-	fmt.Println("  mycoder metrics")
-	fmt.Println("  mycoder knowledge [add|list|vet|promote|reverify|gc]")
-	fmt.Println("  mycoder fs [read|write|delete|patch] --project <id> --path <p> [--content ...] [--start N --length N --replace ...]")
-	fmt.Println("  mycoder fs diff --project <id> --path <p> --new-file <file> [--context 3] [--ignore-crlf] [--color]")
-	fmt.Println("  mycoder fs patch-unified --project <id> --file <diff.patch> [--dry-run|--yes] [--color]")
-	fmt.Println("  mycoder fs patch-unified-rollback --project <id> --patch-id <id> [--dry-run|--yes]")
-	fmt.Println("  mycoder exec -- -- <cmd> [args...]")
-	fmt.Println("  mycoder explain --project <id> <path|symbol>")
-	fmt.Println("  mycoder edit --project <id> --goal \"<설명>\" [--files a.go,b.go] [--stream]")
-	fmt.Println("  mycoder mcp tools|call --name <tool> --json '<params>'")
-	fmt.Println("  mycoder test --project <id> [--timeout 60] [--verbose]")
-	fmt.Println("  mycoder seed rag --project <id> [--docs] [--code] [--web-json <file>] [--dry-run] [--pin]")
-	fmt.Println("  mycoder <command> (coming soon): edit | hooks | fs | exec | mcp")
-}
-
-func isKnownStub(cmd string) bool {
-	known := []string{"ask", "chat", "hooks", "fs", "exec"}
-	for _, k := range known {
-		if strings.EqualFold(k, cmd) {
-			return true
+// applyServeFlags parses serve flags, maps the process-level switches onto their
+// env equivalents (read once by the server at startup) and returns the listen address.
+func applyServeFlags(args []string) string {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8089", "listen address")
+	skipProbe := fs.Bool("skip-llm-probe", false, "skip the startup LLM reachability check")
+	readOnly := fs.Bool("readonly", false, "reject mutating endpoints (same as MYCODER_READONLY=1)")
+	_ = fs.Parse(args)
+	if *skipProbe {
+		_ = os.Setenv("MYCODER_LLM_PROBE_DISABLE", "1")
+	}
+	if *readOnly {
+		_ = os.Setenv("MYCODER_READONLY", "1")
+	}
+	return *addr
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "mycoder - project-aware coding CLI")
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintln(w, "  mycoder                           - Interactive chat mode (like Claude Code)")
+	for _, c := range commands() {
+		for _, u := range c.usage {
+			fmt.Fprintln(w, "  mycoder "+u)
 		}
 	}
-	return false
+	fmt.Fprintln(w, "global flags: --color=auto|always|never, --no-color (env MYCODER_COLOR; auto = only on a terminal)")
+	fmt.Fprintln(w, "              --server-url <url> (overrides MYCODER_SERVER_URL), --token <t> (overrides MYCODER_API_TOKEN)")
 }
 
+// serverURLFlag is the global --server-url override; it wins over MYCODER_SERVER_URL.
+var serverURLFlag string
+
 func serverURL() string {
+	if serverURLFlag != "" {
+		return strings.TrimRight(serverURLFlag, "/")
+	}
 	if v := os.Getenv("MYCODER_SERVER_URL"); v != "" {
 		return v
 	}
 	return "http://localhost:8089"
 }
 
+// apiTokenFlag is the global --token override; it wins over MYCODER_API_TOKEN
+// from the environment or the config file.
+var apiTokenFlag string
+
+func apiToken() string {
+	if apiTokenFlag != "" {
+		return apiTokenFlag
+	}
+	return config.Get("MYCODER_API_TOKEN")
+}
+
+// authTransport adds "Authorization: Bearer <token>" to requests that do not
+// already carry one, so every server call works when auth is enabled.
+type authTransport struct{ base http.RoundTripper }
+
+func (t authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if tok := apiToken(); tok != "" && r.Header.Get("Authorization") == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return base.RoundTrip(r)
+}
+
+// apiClient is used for all calls to the mycoder server; use newAPIClient
+// when a request needs its own timeout.
+var apiClient = newAPIClient(0)
+
+func newAPIClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: authTransport{}}
+}
+
+// extractValueFlag removes a global "--<name> <v>" (or "--<name>=<v>") flag from
+// args (anywhere before a "--") and stores the value in dst.
+func extractValueFlag(args []string, name string, dst *string) ([]string, error) {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(out, args[i:]...), nil
+		}
+		switch {
+		case a == "--"+name || a == "-"+name:
+			if i+1 >= len(args) || args[i+1] == "" {
+				return nil, fmt.Errorf("--%s requires a value", name)
+			}
+			*dst = args[i+1]
+			i++
+			continue
+		case strings.HasPrefix(a, "--"+name+"=") || strings.HasPrefix(a, "-"+name+"="):
+			v := a[strings.IndexByte(a, '=')+1:]
+			if v == "" {
+				return nil, fmt.Errorf("--%s requires a value", name)
+			}
+			*dst = v
+			continue
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// exitFunc and stderr are swapped out by tests to observe failures.
+var (
+	exitFunc           = os.Exit
+	stderr   io.Writer = os.Stderr
+)
+
+// responseError returns nil for a 2xx response. Otherwise it consumes the body
+// and describes the failure, using the server's {"error","message"} JSON when present.
+func responseError(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+		if apiErr.Message != "" {
+			return fmt.Errorf("http %d %s: %s", resp.StatusCode, apiErr.Error, apiErr.Message)
+		}
+		return fmt.Errorf("http %d %s", resp.StatusCode, apiErr.Error)
+	}
+	if body := strings.TrimSpace(string(data)); body != "" {
+		return fmt.Errorf("http %d: %s", resp.StatusCode, body)
+	}
+	return fmt.Errorf("http %d", resp.StatusCode)
+}
+
+// checkResponse reports whether resp succeeded; on failure it prints the error
+// to stderr and exits 1 so scripts see a non-zero status.
+func checkResponse(resp *http.Response) bool {
+	if err := responseError(resp); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		exitFunc(1)
+		return false
+	}
+	return true
+}
+
 func projectsCmd(args []string) {
 	if len(args) == 0 {
 		fmt.Println("usage: mycoder projects [list|create]")
@@ -147,29 +301,45 @@ func projectsCmd(args []string) {
 	}
 	switch args[0] {
 	case "list":
-		resp, err := http.Get(serverURL() + "/projects")
+		resp, err := apiClient.Get(serverURL() + "/projects")
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "create":
 		fs := flag.NewFlagSet("projects create", flag.ExitOnError)
 		name := fs.String("name", "", "project name")
 		root := fs.String("root", ".", "project root path")
+		ignore := fs.String("ignore", "", "comma-separated globs (relative to root) to skip when indexing")
 		_ = fs.Parse(args[1:])
 		if *name == "" || *root == "" {
 			fmt.Println("--name and --root required")
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"name":"%s","rootPath":"%s"}`, *name, *root)
-		resp, err := http.Post(serverURL()+"/projects", "application/json", strings.NewReader(body))
+		if strings.TrimSpace(*ignore) != "" {
+			var globs []string
+			for _, g := range strings.Split(*ignore, ",") {
+				if g = strings.TrimSpace(g); g != "" {
+					globs = append(globs, g)
+				}
+			}
+			body = withJSONField(body, "ignore", globs)
+		}
+		resp, err := apiClient.Post(serverURL()+"/projects", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	default:
 		fmt.Println("usage: mycoder projects [list|create]")
@@ -177,7 +347,95 @@ func projectsCmd(args []string) {
 	}
 }
 
+// readIndexStream prints /index/run/stream events from r: job id, progress
+// with percentage and a rolling ETA, completion and errors.
+func readIndexStream(r io.Reader, out, errOut io.Writer, save string) error {
+	rd := bufio.NewScanner(r)
+	lastEvent := ""
+	prog := newIndexProgress()
+	for rd.Scan() {
+		line := rd.Text()
+		if strings.HasPrefix(line, "event:") {
+			lastEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if save != "" {
+			_ = appendLog(save, fmt.Sprintf("%s %s\n", lastEvent, data))
+		}
+		switch lastEvent {
+		case "job":
+			fmt.Fprintf(out, "job: %s\n", data)
+		case "start":
+			prog = newIndexProgress()
+		case "progress":
+			var p struct {
+				Indexed   int   `json:"indexed"`
+				Total     int   `json:"total"`
+				ElapsedMs int64 `json:"elapsedMs"`
+			}
+			_ = json.Unmarshal([]byte(data), &p)
+			fmt.Fprintln(out, prog.line(p.Indexed, p.Total, time.Duration(p.ElapsedMs)*time.Millisecond))
+		case "completed":
+			fmt.Fprintln(out, "completed")
+		case "error":
+			fmt.Fprintln(errOut, data)
+		}
+	}
+	return rd.Err()
+}
+
+// indexProgressWindow is how many recent progress samples feed the ETA rate.
+const indexProgressWindow = 5
+
+type progressSample struct {
+	elapsed time.Duration
+	indexed int
+}
+
+// indexProgress renders progress lines; the ETA uses the rate over the last
+// few samples so it follows speed changes during long runs.
+type indexProgress struct {
+	samples []progressSample
+}
+
+func newIndexProgress() *indexProgress {
+	return &indexProgress{samples: []progressSample{{}}}
+}
+
+// line formats "progress: n/total (p%)" plus elapsed time and ETA when the
+// server reports elapsedMs.
+func (p *indexProgress) line(indexed, total int, elapsed time.Duration) string {
+	pct := 0.0
+	if total > 0 {
+		pct = float64(indexed) * 100 / float64(total)
+	}
+	s := fmt.Sprintf("progress: %d/%d (%.1f%%)", indexed, total, pct)
+	if elapsed <= 0 {
+		return s
+	}
+	p.samples = append(p.samples, progressSample{elapsed: elapsed, indexed: indexed})
+	if len(p.samples) > indexProgressWindow {
+		p.samples = p.samples[len(p.samples)-indexProgressWindow:]
+	}
+	s += " elapsed " + elapsed.Round(time.Second).String()
+	first, last := p.samples[0], p.samples[len(p.samples)-1]
+	dn, dt := last.indexed-first.indexed, last.elapsed-first.elapsed
+	if dn > 0 && dt > 0 && total >= indexed {
+		eta := time.Duration(float64(dt) / float64(dn) * float64(total-indexed))
+		s += " eta " + eta.Round(time.Second).String()
+	}
+	return s
+}
+
 func indexCmd(args []string) {
+	if len(args) > 0 && args[0] == "stats" {
+		indexStatsCmd(args[1:])
+		return
+	}
 	fs := flag.NewFlagSet("index", flag.ExitOnError)
 	project := fs.String("project", "", "project ID")
 	mode := fs.String("mode", "full", "full|incremental")
@@ -186,8 +444,10 @@ func indexCmd(args []string) {
 	save := fs.String("save-log", "", "save stream lines to file")
 	maxFiles := fs.Int("max-files", 0, "max files to index")
 	maxBytes := fs.Int("max-bytes", 0, "max file size bytes")
+	maxTotal := fs.Int64("max-total-bytes", 0, "stop collecting once indexed content exceeds this many bytes")
 	include := fs.String("include", "", "comma-separated glob patterns to include")
 	exclude := fs.String("exclude", "", "comma-separated glob patterns to exclude")
+	dryRun := fs.Bool("dry-run", false, "list files that would be indexed without ingesting")
 	_ = fs.Parse(args)
 	if *project == "" {
 		fmt.Println("--project required")
@@ -195,13 +455,20 @@ func indexCmd(args []string) {
 	}
 	body := fmt.Sprintf(`{"projectID":"%s","mode":"%s","maxFiles":%d,"maxBytes":%d,"include":[%s],"exclude":[%s]}`,
 		*project, *mode, *maxFiles, *maxBytes, toJSONStringArray(*include), toJSONStringArray(*exclude))
+	if *maxTotal > 0 {
+		body = withJSONField(body, "maxTotalBytes", *maxTotal)
+	}
+	if *dryRun {
+		indexDryRun(withJSONField(body, "dryRun", true))
+		return
+	}
 	if *stream {
 		attempts := *retries + 1
 		for i := 0; i < attempts; i++ {
 			ctx, cancel := signalContext()
 			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, serverURL()+"/index/run/stream", strings.NewReader(body))
 			req.Header.Set("Content-Type", "application/json")
-			resp, err := http.DefaultClient.Do(req)
+			resp, err := apiClient.Do(req)
 			if err != nil {
 				cancel()
 				if i == attempts-1 {
@@ -210,40 +477,10 @@ func indexCmd(args []string) {
 				}
 				continue
 			}
-			rd := bufio.NewScanner(resp.Body)
-			lastEvent := ""
-			total, indexed := 0, 0
-			var jobID string
-			for rd.Scan() {
-				line := rd.Text()
-				if strings.HasPrefix(line, "event:") {
-					lastEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-					continue
-				}
-				if strings.HasPrefix(line, "data:") {
-					data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
-					if *save != "" {
-						_ = appendLog(*save, fmt.Sprintf("%s %s\n", lastEvent, data))
-					}
-					switch lastEvent {
-					case "job":
-						jobID = data
-						fmt.Printf("job: %s\n", jobID)
-					case "progress":
-						var p struct{ Indexed, Total int }
-						_ = json.Unmarshal([]byte(data), &p)
-						total, indexed = p.Total, p.Indexed
-						fmt.Printf("progress: %d/%d\n", indexed, total)
-					case "completed":
-						fmt.Println("completed")
-					case "error":
-						fmt.Fprintln(os.Stderr, data)
-					}
-				}
-			}
+			err = readIndexStream(resp.Body, os.Stdout, os.Stderr, *save)
 			resp.Body.Close()
 			cancel()
-			if err := rd.Err(); err != nil && i < attempts-1 {
+			if err != nil && i < attempts-1 {
 				fmt.Fprintf(os.Stderr, "[streaming] error: %v (retrying)\n", err)
 				continue
 			}
@@ -251,7 +488,7 @@ func indexCmd(args []string) {
 		}
 		return
 	}
-	resp, err := http.Post(serverURL()+"/index/run", "application/json", strings.NewReader(body))
+	resp, err := apiClient.Post(serverURL()+"/index/run", "application/json", strings.NewReader(body))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -260,20 +497,72 @@ func indexCmd(args []string) {
 	io.Copy(os.Stdout, resp.Body)
 }
 
+// indexDryRun posts a dryRun index request and prints the collected files
+// with a count/total-bytes summary.
+func indexDryRun(body string) {
+	resp, err := apiClient.Post(serverURL()+"/index/run", "application/json", strings.NewReader(body))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		io.Copy(os.Stderr, resp.Body)
+		os.Exit(1)
+	}
+	var res struct {
+		Files []struct {
+			Path string `json:"path"`
+			Size int64  `json:"size"`
+			Lang string `json:"lang"`
+		} `json:"files"`
+		Count           int   `json:"count"`
+		TotalBytes      int64 `json:"totalBytes"`
+		SkippedTotalCap int   `json:"skippedTotalCap"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, f := range res.Files {
+		lang := f.Lang
+		if lang == "" {
+			lang = "-"
+		}
+		fmt.Printf("%8d  %-10s %s\n", f.Size, lang, f.Path)
+	}
+	fmt.Printf("%d files, %d bytes\n", res.Count, res.TotalBytes)
+	if res.SkippedTotalCap > 0 {
+		fmt.Printf("%d files skipped by --max-total-bytes\n", res.SkippedTotalCap)
+	}
+}
+
 func searchCmd(args []string) {
 	if len(args) == 0 {
-		fmt.Println("usage: mycoder search \"<query>\" [--project <id>]")
+		fmt.Println("usage: mycoder search \"<query>\" [--project <id>] [--mode fts|literal|regex]")
 		os.Exit(1)
 	}
 	query := args[0]
 	fs := flag.NewFlagSet("search", flag.ExitOnError)
 	project := fs.String("project", "", "project ID")
+	mode := fs.String("mode", "", "fts|literal|regex (default fts)")
+	group := fs.Bool("group", false, "one result per file with its matching line ranges")
+	withContent := fs.Bool("with-content", false, "print the full content of each matched file")
 	_ = fs.Parse(args[1:])
 	url := serverURL() + "/search?q=" + urlQueryEscape(query)
 	if *project != "" {
 		url += "&projectID=" + urlQueryEscape(*project)
 	}
-	resp, err := http.Get(url)
+	if *mode != "" {
+		url += "&mode=" + urlQueryEscape(*mode)
+	}
+	if *group {
+		url += "&groupByFile=1"
+	}
+	if *withContent {
+		url += "&includeContent=1"
+	}
+	resp, err := apiClient.Get(url)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -286,6 +575,12 @@ func searchCmd(args []string) {
 			Preview   string  `json:"preview"`
 			StartLine int     `json:"startLine"`
 			EndLine   int     `json:"endLine"`
+			Ranges    []struct {
+				StartLine int `json:"startLine"`
+				EndLine   int `json:"endLine"`
+			} `json:"ranges"`
+			Content        string `json:"content"`
+			ContentOmitted bool   `json:"contentOmitted"`
 		} `json:"results"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
@@ -295,7 +590,19 @@ func searchCmd(args []string) {
 	}
 	for _, r := range res.Results {
 		loc := r.Path
-		if r.StartLine > 0 {
+		if *group {
+			spans := make([]string, 0, len(r.Ranges))
+			for _, rg := range r.Ranges {
+				if rg.EndLine > rg.StartLine {
+					spans = append(spans, fmt.Sprintf("%d-%d", rg.StartLine, rg.EndLine))
+				} else {
+					spans = append(spans, strconv.Itoa(rg.StartLine))
+				}
+			}
+			if len(spans) > 0 {
+				loc += ":" + strings.Join(spans, ",")
+			}
+		} else if r.StartLine > 0 {
 			if r.EndLine > 0 && r.EndLine != r.StartLine {
 				loc = fmt.Sprintf("%s:%d-%d", r.Path, r.StartLine, r.EndLine)
 			} else {
@@ -303,6 +610,11 @@ func searchCmd(args []string) {
 			}
 		}
 		fmt.Printf("%s  score=%.3f\n  %s\n", loc, r.Score, r.Preview)
+		if r.ContentOmitted {
+			fmt.Println("  (content omitted: file too large)")
+		} else if r.Content != "" {
+			fmt.Printf("----- %s -----\n%s\n", r.Path, strings.TrimRight(r.Content, "\n"))
+		}
 	}
 }
 
@@ -313,6 +625,7 @@ func urlQueryEscape(s string) string {
 
 func askCmd(args []string) {
 	fs := flag.NewFlagSet("ask", flag.ExitOnError)
+	maxTokens := fs.Int("max-tokens", 0, "cap on generated tokens (0 = provider default)")
 	project := fs.String("project", "", "project ID")
 	k := fs.Int("k", 5, "retrieval top K")
 	_ = fs.Parse(args)
@@ -323,7 +636,13 @@ func askCmd(args []string) {
 	}
 	q := strings.Join(rest, " ")
 	body := fmt.Sprintf(`{"messages":[{"role":"user","content":%q}],"stream":false,"projectID":"%s","retrieval":{"k":%d}}`, q, *project, *k)
-	resp, err := http.Post(serverURL()+"/chat", "application/json", strings.NewReader(body))
+	body = withMaxTokens(body, *maxTokens)
+	// Ctrl-C cancels the request, which also aborts generation on the server
+	ctx, cancel := signalContext()
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, serverURL()+"/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := apiClient.Do(req)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -339,21 +658,69 @@ func askCmd(args []string) {
 	fmt.Println(res.Content)
 }
 
+// withMaxTokens adds "maxTokens" to a /chat JSON body when n > 0.
+func withMaxTokens(body string, n int) string {
+	if n <= 0 {
+		return body
+	}
+	return withJSONField(body, "maxTokens", n)
+}
+
+// withJSONField appends "key":v to a JSON object literal.
+func withJSONField(body, key string, v any) string {
+	if !strings.HasSuffix(body, "}") {
+		return body
+	}
+	b, _ := json.Marshal(v)
+	return fmt.Sprintf(`%s,%q:%s}`, strings.TrimSuffix(body, "}"), key, b)
+}
+
+// readSystemPrompt returns text, or the contents of file when text is empty.
+func readSystemPrompt(text, file string) (string, error) {
+	if text != "" || file == "" {
+		return text, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
 func chatCmd(args []string) {
 	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	maxTokens := fs.Int("max-tokens", 0, "cap on generated tokens (0 = provider default)")
 	project := fs.String("project", "", "project ID")
 	k := fs.Int("k", 5, "retrieval top K")
 	retries := fs.Int("retries", 0, "auto-retry times on stream error")
 	tty := fs.Bool("tty", false, "print lightweight stream status to stderr")
 	save := fs.String("save-log", "", "save stream lines to file")
+	system := fs.String("system", "", "system prompt placed before RAG context")
+	systemFile := fs.String("system-file", "", "read the system prompt from a file")
+	contextOnly := fs.Bool("context-only", false, "print the retrieval context that would be sent, without calling the LLM")
 	_ = fs.Parse(args)
 	rest := fs.Args()
 	if len(rest) == 0 {
-		fmt.Println("usage: mycoder chat [--project <id>] [--k 5] [--retries 0] [--tty] \"<prompt>\"")
+		fmt.Println("usage: mycoder chat [--project <id>] [--k 5] [--retries 0] [--tty] [--system <text>|--system-file <path>] [--context-only] \"<prompt>\"")
+		os.Exit(1)
+	}
+	sysPrompt, err := readSystemPrompt(*system, *systemFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 	q := strings.Join(rest, " ")
 	body := fmt.Sprintf(`{"messages":[{"role":"user","content":%q}],"stream":true,"projectID":"%s","retrieval":{"k":%d}}`, q, *project, *k)
+	body = withMaxTokens(body, *maxTokens)
+	if sysPrompt != "" {
+		body = withJSONField(body, "systemPrompt", sysPrompt)
+	}
+	if *contextOnly {
+		// the later key wins when the server decodes the body
+		body = withJSONField(body, "stream", false)
+		body = withJSONField(body, "retrievalOnly", true)
+		os.Exit(runChatContext(os.Stdout, serverURL(), body))
+	}
 	attempts := *retries + 1
 	for i := 0; i < attempts; i++ {
 		if *tty {
@@ -366,7 +733,8 @@ func chatCmd(args []string) {
 		ctx, cancel := signalContext()
 		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, serverURL()+"/chat", strings.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
-		resp, err := http.DefaultClient.Do(req)
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := apiClient.Do(req)
 		if err != nil {
 			cancel()
 			if i == attempts-1 {
@@ -421,6 +789,43 @@ func chatCmd(args []string) {
 	}
 }
 
+// runChatContext posts a retrieval-only chat body and prints the context block
+// followed by its citations.
+func runChatContext(w io.Writer, base, body string) int {
+	resp, err := apiClient.Post(base+"/chat", "application/json", strings.NewReader(body))
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	var out struct {
+		Context   string `json:"context"`
+		Citations []struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"startLine"`
+			EndLine   int    `json:"endLine"`
+		} `json:"citations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		fmt.Fprintln(w, "decode:", err)
+		return 1
+	}
+	if out.Context == "" {
+		fmt.Fprintln(w, "(no context)")
+		return 0
+	}
+	fmt.Fprintln(w, strings.TrimRight(out.Context, "\n"))
+	fmt.Fprintf(w, "\ncitations (%d):\n", len(out.Citations))
+	for _, c := range out.Citations {
+		fmt.Fprintf(w, "  %s:%d-%d\n", c.Path, c.StartLine, c.EndLine)
+	}
+	return 0
+}
+
 // appendLog appends a line to a file, creating it if needed.
 func appendLog(path, s string) error {
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
@@ -436,55 +841,28 @@ func modelsCmd(args []string) {
 	format := fs.String("format", "table", "output format: table|json|raw")
 	filter := fs.String("filter", "", "substring filter for model id")
 	color := fs.Bool("color", false, "enable ANSI colors for table")
+	baseURL := fs.String("base-url", "", "OpenAI-compatible base URL (overrides MYCODER_OPENAI_BASE_URL)")
 	_ = fs.Parse(args)
-	base := os.Getenv("MYCODER_OPENAI_BASE_URL")
-	if base == "" {
-		base = "http://210.126.109.57:3620/v1"
-	}
-	url := strings.TrimRight(base, "/") + "/models"
-	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	if key := os.Getenv("MYCODER_OPENAI_API_KEY"); key != "" {
-		req.Header.Set("Authorization", "Bearer "+key)
+	*color = wantColor(*color)
+	c := oai.NewFromEnv()
+	if *baseURL != "" {
+		c = c.WithBaseURL(*baseURL)
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
+	ctx, cancel := signalContext()
+	defer cancel()
 	if *format == "raw" {
-		io.Copy(os.Stdout, resp.Body)
-		return
-	}
-	var obj map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
-		// fallback raw
-		fmt.Fprintln(os.Stderr, "warning: non-JSON response; printing raw")
-		req2, _ := http.NewRequest(http.MethodGet, url, nil)
-		if key := os.Getenv("MYCODER_OPENAI_API_KEY"); key != "" {
-			req2.Header.Set("Authorization", "Bearer "+key)
-		}
-		resp2, err2 := http.DefaultClient.Do(req2)
-		if err2 == nil {
-			defer resp2.Body.Close()
-			io.Copy(os.Stdout, resp2.Body)
-		} else {
-			fmt.Fprintln(os.Stderr, err2)
+		data, err := c.ModelsRaw(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		_, _ = os.Stdout.Write(data)
 		return
 	}
-	// collect model ids from OpenAI-like schema {data:[{id:..}]}
-	ids := make([]string, 0)
-	if v, ok := obj["data"]; ok {
-		if arr, ok := v.([]any); ok {
-			for _, it := range arr {
-				if m, ok := it.(map[string]any); ok {
-					if id, ok := m["id"].(string); ok {
-						ids = append(ids, id)
-					}
-				}
-			}
-		}
+	ids, err := c.ListModels(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 	sort.Strings(ids)
 	if *filter != "" {
@@ -511,21 +889,161 @@ func modelsCmd(args []string) {
 	}
 }
 
+func configCmd(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Println("config show [--json] [--server]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	remote := fs.Bool("server", false, "show the running server's settings (GET /config)")
+	_ = fs.Parse(args[1:])
+	settings := config.Effective()
+	if *remote {
+		resp, err := apiClient.Get(serverURL() + "/config")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		var res struct {
+			Settings []config.Setting `json:"settings"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		settings = res.Settings
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(map[string]any{"settings": settings})
+		return
+	}
+	w := 0
+	for _, s := range settings {
+		if len(s.Key) > w {
+			w = len(s.Key)
+		}
+	}
+	for _, s := range settings {
+		fmt.Printf("%-*s  %-7s  %s\n", w, s.Key, s.Source, s.Value)
+	}
+}
+
+// doctorCmd prints a checklist of server, LLM, embedding, storage and index
+// health with remediation hints, exiting 1 when any check fails.
+func doctorCmd(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	color := fs.Bool("color", true, "color the checklist (terminal only)")
+	_ = fs.Parse(args)
+	if failed := runDoctor(os.Stdout, serverURL(), wantColor(*color)); failed > 0 {
+		exitFunc(1)
+	}
+}
+
+// runDoctor writes the checklist to w and returns the number of failed checks.
+func runDoctor(w io.Writer, base string, color bool) int {
+	failed := 0
+	check := func(ok bool, label, hint string) {
+		mark := "✓"
+		if !ok {
+			mark = "✗"
+			failed++
+		}
+		if color {
+			if ok {
+				mark = colorGreen(mark)
+			} else {
+				mark = colorRed(mark)
+			}
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, label)
+		if !ok && hint != "" {
+			fmt.Fprintf(w, "    hint: %s\n", hint)
+		}
+	}
+	client := newAPIClient(5 * time.Second)
+	resp, err := client.Get(base + "/readyz")
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode != http.StatusOK {
+		check(false, "server reachable ("+base+")", "start it with `mycoder serve` or point --server-url/MYCODER_SERVER_URL at a running server")
+		return failed
+	}
+	check(true, "server reachable ("+base+")", "")
+	resp, err = client.Get(base + "/diagnostics")
+	if err != nil {
+		check(false, "server diagnostics", err.Error())
+		return failed
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		check(false, "server diagnostics", err.Error()+" (set --token/MYCODER_API_TOKEN if the server requires auth)")
+		return failed
+	}
+	var d struct {
+		Components map[string]bool `json:"components"`
+		LLMURL     string          `json:"llmURL"`
+		LLMError   string          `json:"llmError"`
+		Store      string          `json:"store"`
+		SQLitePath string          `json:"sqlitePath"`
+		StoreError string          `json:"storeError"`
+		Projects   int             `json:"projects"`
+		Documents  int             `json:"documents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		check(false, "server diagnostics", "invalid response: "+err.Error())
+		return failed
+	}
+	llmHint := "check MYCODER_LLM_PROVIDER and the provider base URL (MYCODER_OPENAI_BASE_URL, MYCODER_OLLAMA_BASE_URL)"
+	if d.LLMError != "" {
+		llmHint = d.LLMError + "; " + llmHint
+	}
+	llmLabel := "LLM reachable"
+	if d.LLMURL != "" {
+		llmLabel += " (" + d.LLMURL + ")"
+	}
+	check(d.Components["llm"], llmLabel, llmHint)
+	check(d.Components["embeddings"], "embeddings enabled", "set MYCODER_EMBEDDING_MODEL to a model the provider serves and unset MYCODER_DISABLE_EMBEDDINGS; search falls back to lexical only")
+	if d.Store == "sqlite" {
+		hint := "make the database file and its directory writable or change MYCODER_SQLITE_PATH"
+		if d.StoreError != "" {
+			hint = d.StoreError + "; " + hint
+		}
+		check(d.Components["storeWritable"], "SQLite path writable ("+d.SQLitePath+")", hint)
+	} else {
+		check(false, "SQLite storage", "the server uses the in-memory store; set MYCODER_SQLITE_PATH to persist projects and indexes")
+	}
+	check(d.Components["indexed"], fmt.Sprintf("project indexed (%d projects, %d documents)", d.Projects, d.Documents),
+		"create a project with `mycoder projects create --name <n> --root .` and run `mycoder index --project <id>`")
+	return failed
+}
+
 func metricsCmd(args []string) {
 	fs := flag.NewFlagSet("metrics", flag.ExitOnError)
 	asJSON := fs.Bool("json", false, "fetch and pretty-print JSON")
 	color := fs.Bool("color", false, "colorize keys (text mode)")
 	_ = fs.Parse(args)
+	*color = wantColor(*color)
 	url := serverURL() + "/metrics"
 	if *asJSON {
 		url += "?format=json"
 	}
-	resp, err := http.Get(url)
+	resp, err := apiClient.Get(url)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
+	if !checkResponse(resp) {
+		return
+	}
 	if !*asJSON {
 		// text mode: passthrough
 		if *color {
@@ -575,6 +1093,120 @@ func metricsCmd(args []string) {
 	}
 }
 
+func conversationsCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: mycoder conversations list [--project <id>] [--json] | show <id> [--json] | pin|unpin <id>")
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("conversations list", flag.ExitOnError)
+		project := fs.String("project", "", "project ID (default: all projects)")
+		asJSON := fs.Bool("json", false, "print raw JSON")
+		_ = fs.Parse(args[1:])
+		url := serverURL() + "/conversations"
+		if *project != "" {
+			url += "?projectID=" + urlQueryEscape(*project)
+		}
+		resp, err := apiClient.Get(url)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		if *asJSON {
+			io.Copy(os.Stdout, resp.Body)
+			return
+		}
+		var res struct {
+			Conversations []struct {
+				ID           string `json:"id"`
+				Title        string `json:"title"`
+				Pinned       bool   `json:"pinned"`
+				UpdatedAt    string `json:"updatedAt"`
+				MessageCount int    `json:"messageCount"`
+			} `json:"conversations"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(res.Conversations) == 0 {
+			fmt.Println("no conversations")
+			return
+		}
+		for _, c := range res.Conversations {
+			pin := " "
+			if c.Pinned {
+				pin = "*"
+			}
+			fmt.Printf("%s %s  %s  msgs=%d  %s\n", pin, c.ID, c.UpdatedAt, c.MessageCount, c.Title)
+		}
+	case "show":
+		fs := flag.NewFlagSet("conversations show", flag.ExitOnError)
+		asJSON := fs.Bool("json", false, "print raw JSON")
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			fmt.Println("usage: mycoder conversations show <id> [--json]")
+			os.Exit(1)
+		}
+		id := args[1]
+		_ = fs.Parse(args[2:])
+		resp, err := apiClient.Get(serverURL() + "/conversations/" + urlQueryEscape(id))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		if *asJSON {
+			io.Copy(os.Stdout, resp.Body)
+			return
+		}
+		var res struct {
+			Conversation struct {
+				ID    string `json:"id"`
+				Title string `json:"title"`
+			} `json:"conversation"`
+			Messages []struct {
+				Role      string `json:"role"`
+				Content   string `json:"content"`
+				CreatedAt string `json:"createdAt"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("# %s %s\n", res.Conversation.ID, res.Conversation.Title)
+		for _, m := range res.Messages {
+			fmt.Printf("\n[%s] %s\n%s\n", m.Role, m.CreatedAt, m.Content)
+		}
+	case "pin", "unpin":
+		if len(args) < 2 {
+			fmt.Printf("usage: mycoder conversations %s <id>\n", args[0])
+			os.Exit(1)
+		}
+		resp, err := apiClient.Post(serverURL()+"/conversations/"+urlQueryEscape(args[1])+"/"+args[0], "application/json", nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		fmt.Printf("%sned %s\n", args[0], args[1])
+	default:
+		fmt.Println("usage: mycoder conversations list [--project <id>] [--json] | show <id> [--json] | pin|unpin <id>")
+		os.Exit(1)
+	}
+}
+
 func knowledgeCmd(args []string) {
 	if len(args) == 0 {
 		fmt.Println("usage: mycoder knowledge [add|list|vet] ...")
@@ -597,28 +1229,188 @@ func knowledgeCmd(args []string) {
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","sourceType":"%s","pathOrURL":"%s","title":"%s","text":%q,"trustScore":%f,"pinned":%v}`,
 			*project, *typ, *url, *title, *text, *trust, *pinned)
-		resp, err := http.Post(serverURL()+"/knowledge", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/knowledge", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "list":
 		fs := flag.NewFlagSet("knowledge list", flag.ExitOnError)
 		project := fs.String("project", "", "project ID")
+		tags := fs.String("tag", "", "comma-separated tag filters key:value (all must match)")
+		limit := fs.Int("limit", 0, "page size (0 = all)")
+		offset := fs.Int("offset", 0, "items to skip")
 		_ = fs.Parse(args[1:])
 		if *project == "" {
 			fmt.Println("--project required")
 			os.Exit(1)
 		}
 		url := serverURL() + "/knowledge?projectID=" + urlQueryEscape(*project)
-		resp, err := http.Get(url)
+		for _, t := range strings.Split(*tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				url += "&tag=" + urlQueryEscape(t)
+			}
+		}
+		if *limit > 0 {
+			url += "&limit=" + strconv.Itoa(*limit)
+		}
+		if *offset > 0 {
+			url += "&offset=" + strconv.Itoa(*offset)
+		}
+		resp, err := apiClient.Get(url)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "get":
+		fs := flag.NewFlagSet("knowledge get", flag.ExitOnError)
+		id := fs.String("id", "", "knowledge ID")
+		_ = fs.Parse(args[1:])
+		if *id == "" {
+			fmt.Println("--id required")
+			os.Exit(1)
+		}
+		resp, err := apiClient.Get(serverURL() + "/knowledge/" + urlQueryEscape(*id))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "export":
+		fs := flag.NewFlagSet("knowledge export", flag.ExitOnError)
+		project := fs.String("project", "", "project ID")
+		out := fs.String("out", "", "output JSONL file (default stdout)")
+		_ = fs.Parse(args[1:])
+		if *project == "" {
+			fmt.Println("--project required")
+			os.Exit(1)
+		}
+		resp, err := apiClient.Get(serverURL() + "/knowledge/export?projectID=" + urlQueryEscape(*project))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		if *out == "" {
+			io.Copy(os.Stdout, resp.Body)
+			return
+		}
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		if _, err := io.Copy(f, resp.Body); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("exported to %s\n", *out)
+	case "import":
+		fs := flag.NewFlagSet("knowledge import", flag.ExitOnError)
+		file := fs.String("file", "", "input JSONL file")
+		project := fs.String("project", "", "target project ID (remaps all rows)")
+		_ = fs.Parse(args[1:])
+		if *file == "" {
+			fmt.Println("--file required")
+			os.Exit(1)
+		}
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		u := serverURL() + "/knowledge/import"
+		if *project != "" {
+			u += "?projectID=" + urlQueryEscape(*project)
+		}
+		resp, err := apiClient.Post(u, "application/x-ndjson", f)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "update":
+		fs := flag.NewFlagSet("knowledge update", flag.ExitOnError)
+		id := fs.String("id", "", "knowledge ID")
+		title := fs.String("title", "", "new title")
+		text := fs.String("text", "", "new content text")
+		trust := fs.Float64("trust", 0, "new trust score (0..1)")
+		pin := fs.Bool("pin", false, "pin (--pin=false to unpin)")
+		_ = fs.Parse(args[1:])
+		if *id == "" {
+			fmt.Println("--id required")
+			os.Exit(1)
+		}
+		// send only flags given explicitly
+		body := map[string]any{"id": *id}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "title":
+				body["title"] = *title
+			case "text":
+				body["text"] = *text
+			case "trust":
+				body["trustScore"] = *trust
+			case "pin":
+				body["pinned"] = *pin
+			}
+		})
+		if len(body) == 1 {
+			fmt.Println("nothing to update: use --title, --text, --trust or --pin")
+			os.Exit(1)
+		}
+		b, _ := json.Marshal(body)
+		resp, err := apiClient.Post(serverURL()+"/knowledge/update", "application/json", strings.NewReader(string(b)))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "delete":
+		fs := flag.NewFlagSet("knowledge delete", flag.ExitOnError)
+		id := fs.String("id", "", "knowledge ID")
+		_ = fs.Parse(args[1:])
+		if *id == "" {
+			fmt.Println("--id required")
+			os.Exit(1)
+		}
+		body := fmt.Sprintf(`{"id":%q}`, *id)
+		resp, err := apiClient.Post(serverURL()+"/knowledge/delete", "application/json", strings.NewReader(body))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "vet":
 		fs := flag.NewFlagSet("knowledge vet", flag.ExitOnError)
@@ -629,12 +1421,15 @@ func knowledgeCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s"}`, *project)
-		resp, err := http.Post(serverURL()+"/knowledge/vet", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/knowledge/vet", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "promote":
 		fs := flag.NewFlagSet("knowledge promote", flag.ExitOnError)
@@ -653,12 +1448,15 @@ func knowledgeCmd(args []string) {
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","title":"%s","text":%q,"pathOrURL":"%s","commitSHA":"%s","files":"%s","symbols":"%s","pin":%v}`,
 			*project, *title, *text, *url, *commit, *files, *symbols, *pin)
-		resp, err := http.Post(serverURL()+"/knowledge/promote", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/knowledge/promote", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "reverify":
 		fs := flag.NewFlagSet("knowledge reverify", flag.ExitOnError)
@@ -669,12 +1467,15 @@ func knowledgeCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s"}`, *project)
-		resp, err := http.Post(serverURL()+"/knowledge/reverify", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/knowledge/reverify", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "promote-auto":
 		fs := flag.NewFlagSet("knowledge promote-auto", flag.ExitOnError)
@@ -682,19 +1483,72 @@ func knowledgeCmd(args []string) {
 		title := fs.String("title", "", "title")
 		files := fs.String("files", "", "comma-separated file paths")
 		pin := fs.Bool("pin", false, "pin this knowledge")
+		budget := fs.Int("budget-bytes", 0, "total bytes read across files (0 = server default)")
+		perFile := fs.Int("per-file-bytes", 0, "max bytes read per file (0 = server default)")
 		_ = fs.Parse(args[1:])
 		if *project == "" || *files == "" {
 			fmt.Println("--project and --files required")
 			os.Exit(1)
 		}
-		body := fmt.Sprintf(`{"projectID":"%s","title":"%s","files":[%s],"pin":%v}`,
-			*project, *title, toJSONStringArray(*files), *pin)
-		resp, err := http.Post(serverURL()+"/knowledge/promote/auto", "application/json", strings.NewReader(body))
+		body := fmt.Sprintf(`{"projectID":"%s","title":"%s","files":[%s],"pin":%v,"budgetBytes":%d,"perFileBytes":%d}`,
+			*project, *title, toJSONStringArray(*files), *pin, *budget, *perFile)
+		resp, err := apiClient.Post(serverURL()+"/knowledge/promote/auto", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "approvals":
+		fs := flag.NewFlagSet("knowledge approvals", flag.ExitOnError)
+		project := fs.String("project", "", "project ID")
+		_ = fs.Parse(args[1:])
+		if *project == "" {
+			fmt.Println("--project required")
+			os.Exit(1)
+		}
+		resp, err := apiClient.Get(serverURL() + "/knowledge/approvals?projectID=" + urlQueryEscape(*project))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "decay":
+		fs := flag.NewFlagSet("knowledge decay", flag.ExitOnError)
+		project := fs.String("project", "", "project ID")
+		rate := fs.Float64("rate", 0, "trust decrease per pass (default: server MYCODER_KNOWLEDGE_DECAY_RATE)")
+		afterDays := fs.Int("after-days", 0, "only items unverified for this many days (default: server MYCODER_KNOWLEDGE_DECAY_AFTER_DAYS)")
+		_ = fs.Parse(args[1:])
+		if *project == "" {
+			fmt.Println("--project required")
+			os.Exit(1)
+		}
+		body := map[string]any{"projectID": *project}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "rate":
+				body["rate"] = *rate
+			case "after-days":
+				body["afterDays"] = *afterDays
+			}
+		})
+		b, _ := json.Marshal(body)
+		resp, err := apiClient.Post(serverURL()+"/knowledge/decay", "application/json", strings.NewReader(string(b)))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "gc":
 		fs := flag.NewFlagSet("knowledge gc", flag.ExitOnError)
@@ -706,12 +1560,15 @@ func knowledgeCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","Min":%f}`, *project, *min)
-		resp, err := http.Post(serverURL()+"/knowledge/gc", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/knowledge/gc", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 
 	case "approve":
 		fs := flag.NewFlagSet("knowledge approve", flag.ExitOnError)
@@ -719,6 +1576,7 @@ func knowledgeCmd(args []string) {
 		ids := fs.String("ids", "", "comma-separated knowledge IDs")
 		min := fs.Float64("min", 0.8, "min trust score after approve")
 		pin := fs.Bool("pin", true, "pin items on approve")
+		by := fs.String("by", "", "approver name for the audit log (default: token label)")
 		_ = fs.Parse(args[1:])
 		if *project == "" || *ids == "" {
 			fmt.Println("--project and --ids required")
@@ -733,19 +1591,80 @@ func knowledgeCmd(args []string) {
 			}
 			b.WriteString(fmt.Sprintf("%q", strings.TrimSpace(id)))
 		}
-		b.WriteString(fmt.Sprintf(`],"Pin":%v,"MinTrust":%f}`, *pin, *min))
-		resp, err := http.Post(serverURL()+"/knowledge/approve", "application/json", strings.NewReader(b.String()))
+		b.WriteString(fmt.Sprintf(`],"Pin":%v,"MinTrust":%f,"ApprovedBy":%q}`, *pin, *min, *by))
+		resp, err := apiClient.Post(serverURL()+"/knowledge/approve", "application/json", strings.NewReader(b.String()))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 		io.Copy(os.Stdout, resp.Body)
-	default:
-		fmt.Println("usage: mycoder knowledge [add|list|vet] ...")
+	default:
+		fmt.Println("usage: mycoder knowledge [add|list|vet] ...")
+		os.Exit(1)
+	}
+}
+
+// indexStatsCmd prints document/chunk/vector counts for a project.
+func indexStatsCmd(args []string) {
+	fs := flag.NewFlagSet("index stats", flag.ExitOnError)
+	project := fs.String("project", "", "project ID")
+	asJSON := fs.Bool("json", false, "print raw JSON")
+	_ = fs.Parse(args)
+	if *project == "" {
+		fmt.Println("--project required")
+		os.Exit(1)
+	}
+	resp, err := apiClient.Get(serverURL() + "/index/stats?projectID=" + urlQueryEscape(*project))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if !checkResponse(resp) {
+		return
+	}
+	if *asJSON {
+		io.Copy(os.Stdout, resp.Body)
+		return
+	}
+	var res struct {
+		Documents         int    `json:"documents"`
+		Chunks            int    `json:"chunks"`
+		Bytes             int64  `json:"bytes"`
+		Vectors           int    `json:"vectors"`
+		EmbeddingsEnabled bool   `json:"embeddingsEnabled"`
+		EmbeddingModel    string `json:"embeddingModel"`
+		VectorModels      []struct {
+			Model string `json:"model"`
+			Dim   int    `json:"dim"`
+			Count int    `json:"count"`
+		} `json:"vectorModels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	fmt.Printf("documents: %d\nchunks:    %d\nbytes:     %d\nvectors:   %d\n", res.Documents, res.Chunks, res.Bytes, res.Vectors)
+	for _, m := range res.VectorModels {
+		fmt.Printf("  %s (dim %d): %d\n", m.Model, m.Dim, m.Count)
+	}
+	model := res.EmbeddingModel
+	if model == "" {
+		model = "(default)"
+	}
+	if res.EmbeddingsEnabled {
+		fmt.Printf("embeddings: enabled, model %s\n", model)
+	} else {
+		fmt.Println("embeddings: disabled")
+	}
+	if res.Vectors == 0 && res.Documents > 0 {
+		fmt.Println("hint: no vectors stored, so RAG is lexical-only; enable embeddings and re-run index")
+	}
 }
 
 func toJSONStringArray(csv string) string {
@@ -791,7 +1710,7 @@ func seedCmd(args []string) {
 	}
 
 	// internal docs seeds (title -> csv files)
-	docSeeds := []struct{ title, files string }{
+	docSeeds := []seedSpec{
 		{"PRD", "docs/PRD.md"},
 		{"Architecture", "docs/ARCHITECTURE.md"},
 		{"API", "docs/API.md"},
@@ -803,7 +1722,7 @@ func seedCmd(args []string) {
 		{"Roadmap", "docs/ROADMAP.md"},
 	}
 	// code summary seeds
-	codeSeeds := []struct{ title, files string }{
+	codeSeeds := []seedSpec{
 		{"Server Overview", "internal/server/server.go"},
 		{"Indexer", "internal/indexer/indexer.go"},
 		{"Retriever", "internal/rag/retriever/knn.go,internal/rag/retriever/bm25.go,internal/rag/retriever/hybrid.go"},
@@ -811,41 +1730,14 @@ func seedCmd(args []string) {
 		{"CLI Entrypoint", "cmd/mycoder/main.go"},
 	}
 
-	// run promote-auto for each seed
-	runPromote := func(title, files string) error {
-		body := fmt.Sprintf(`{"projectID":"%s","title":"%s","files":[%s],"pin":%v}`,
-			*project, title, toJSONStringArray(files), *pin)
-		if *dry {
-			fmt.Printf("[dry-run] promote-auto: %s <- [%s]\n", title, files)
-			return nil
-		}
-		resp, err := http.Post(serverURL()+"/knowledge/promote/auto", "application/json", strings.NewReader(body))
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		io.Copy(io.Discard, resp.Body)
-		if resp.StatusCode/100 != 2 {
-			return fmt.Errorf("promote-auto failed: %s", resp.Status)
-		}
-		fmt.Printf("seeded: %s\n", title)
-		return nil
-	}
-
+	var seeds []seedSpec
 	if *includeDocs {
-		for _, s := range docSeeds {
-			if err := runPromote(s.title, s.files); err != nil {
-				fmt.Fprintln(os.Stderr, err)
-			}
-		}
+		seeds = append(seeds, docSeeds...)
 	}
 	if *includeCode {
-		for _, s := range codeSeeds {
-			if err := runPromote(s.title, s.files); err != nil {
-				fmt.Fprintln(os.Stderr, err)
-			}
-		}
+		seeds = append(seeds, codeSeeds...)
 	}
+	runSeedPromote(os.Stdout, serverURL(), *project, seeds, *pin, *dry)
 
 	// optional web ingest
 	if strings.TrimSpace(*webJSON) != "" {
@@ -863,16 +1755,77 @@ func seedCmd(args []string) {
 			fmt.Printf("[dry-run] web ingest from %s\n", *webJSON)
 			return
 		}
-		resp, err := http.Post(serverURL()+"/web/ingest", "application/json", strings.NewReader(payload))
+		resp, err := apiClient.Post(serverURL()+"/web/ingest", "application/json", strings.NewReader(payload))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	}
 }
 
+// seedSpec is one `seed rag` knowledge item: a title and csv project-relative files.
+type seedSpec struct{ title, files string }
+
+// seedFileList splits csv seed files, dropping empty entries.
+func seedFileList(files string) []string {
+	var out []string
+	for _, f := range strings.Split(files, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// runSeedPromote calls /knowledge/promote/auto for each seed with all of its
+// files. File existence is checked by the server, which may not share this
+// machine's filesystem: its filesMissing is reported, and seeds whose files
+// are all missing are rejected there and reported as skipped. Returns the
+// number seeded (or planned, with dry).
+func runSeedPromote(w io.Writer, base, projectID string, seeds []seedSpec, pin, dry bool) int {
+	seeded := 0
+	for _, s := range seeds {
+		files := seedFileList(s.files)
+		if len(files) == 0 {
+			fmt.Fprintf(w, "skipped: %s (no seed files)\n", s.title)
+			continue
+		}
+		if dry {
+			fmt.Fprintf(w, "[dry-run] promote-auto: %s <- [%s]\n", s.title, strings.Join(files, ","))
+			seeded++
+			continue
+		}
+		body, _ := json.Marshal(map[string]any{"projectID": projectID, "title": s.title, "files": files, "pin": pin})
+		resp, perr := apiClient.Post(base+"/knowledge/promote/auto", "application/json", strings.NewReader(string(body)))
+		if perr != nil {
+			fmt.Fprintln(w, perr)
+			continue
+		}
+		var res struct {
+			FilesRead    []string `json:"filesRead"`
+			FilesMissing []string `json:"filesMissing"`
+		}
+		if rerr := responseError(resp); rerr != nil {
+			resp.Body.Close()
+			fmt.Fprintf(w, "skipped: %s (%v)\n", s.title, rerr)
+			continue
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		fmt.Fprintf(w, "seeded: %s (read %d/%d files)\n", s.title, len(res.FilesRead), len(files))
+		if len(res.FilesMissing) > 0 {
+			fmt.Fprintf(w, "  missing: %s\n", strings.Join(res.FilesMissing, ", "))
+		}
+		seeded++
+	}
+	return seeded
+}
+
 func tailLines(s string, n int) string {
 	if n <= 0 {
 		return s
@@ -896,6 +1849,66 @@ func tailBytes(s string, max int) string {
 	return s[len(s)-max:]
 }
 
+// colorMode is the global color policy: "auto" (colors only when stdout is a
+// terminal), "always" or "never". Set from MYCODER_COLOR and the global
+// --color=<mode>/--no-color flags.
+var colorMode = "auto"
+
+// stdoutIsTerminal reports whether stdout is a character device; tests override it.
+var stdoutIsTerminal = func() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func setColorMode(v string) error {
+	switch v = strings.ToLower(strings.TrimSpace(v)); v {
+	case "":
+	case "auto", "always", "never":
+		colorMode = v
+	default:
+		return fmt.Errorf("invalid color mode %q (want auto|always|never)", v)
+	}
+	return nil
+}
+
+// wantColor is the central gate for ANSI output. requested is the command's own
+// --color flag: "always" forces color on, "never" off, and "auto" honors the
+// request only when stdout is a terminal.
+func wantColor(requested bool) bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return requested && stdoutIsTerminal()
+}
+
+// extractColorArgs removes the global --no-color and --color=<mode> flags from
+// args (anywhere before a "--") and applies them. A bare --color or
+// --color=true|false is left for the command's own flag set.
+func extractColorArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i, a := range args {
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+		switch {
+		case a == "--no-color" || a == "-no-color":
+			colorMode = "never"
+			continue
+		case strings.HasPrefix(a, "--color=") || strings.HasPrefix(a, "-color="):
+			v := a[strings.IndexByte(a, '=')+1:]
+			if v == "auto" || v == "always" || v == "never" {
+				colorMode = v
+				continue
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
 func colorRed(s string) string    { return "\x1b[31m" + s + "\x1b[0m" }
 func colorGreen(s string) string  { return "\x1b[32m" + s + "\x1b[0m" }
 func colorYellow(s string) string { return "\x1b[33m" + s + "\x1b[0m" }
@@ -911,7 +1924,7 @@ func signalContext() (context.Context, context.CancelFunc) {
 
 func fsCmd(args []string) {
 	if len(args) == 0 {
-		fmt.Println("usage: mycoder fs [read|write|delete|patch] --project <id> --path <p> [--content ...] [--start N --length N --replace ...]")
+		fmt.Println("usage: mycoder fs [read|write|delete|mkdir|move|patch] --project <id> --path <p> [--content ...] [--start N --length N --replace ...]")
 		os.Exit(1)
 	}
 	sub := args[0]
@@ -920,18 +1933,29 @@ func fsCmd(args []string) {
 		fs := flag.NewFlagSet("fs read", flag.ExitOnError)
 		project := fs.String("project", "", "project ID")
 		path := fs.String("path", "", "path")
+		start := fs.Int("start", 0, "first line to read (1-based)")
+		end := fs.Int("end", 0, "last line to read (inclusive, 0 = end of file)")
 		_ = fs.Parse(args[1:])
 		if *project == "" || *path == "" {
 			fmt.Println("--project and --path required")
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","path":"%s"}`, *project, *path)
-		resp, err := http.Post(serverURL()+"/fs/read", "application/json", strings.NewReader(body))
+		if *start > 0 {
+			body = withJSONField(body, "startLine", *start)
+		}
+		if *end > 0 {
+			body = withJSONField(body, "endLine", *end)
+		}
+		resp, err := apiClient.Post(serverURL()+"/fs/read", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "write":
 		fs := flag.NewFlagSet("fs write", flag.ExitOnError)
@@ -960,12 +1984,15 @@ func fsCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","path":"%s","content":%q}`, *project, *path, *content)
-		resp, err := http.Post(serverURL()+"/fs/write", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/fs/write", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "delete":
 		fs := flag.NewFlagSet("fs delete", flag.ExitOnError)
@@ -987,12 +2014,77 @@ func fsCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","path":"%s"}`, *project, *path)
-		resp, err := http.Post(serverURL()+"/fs/delete", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/fs/delete", "application/json", strings.NewReader(body))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		io.Copy(os.Stdout, resp.Body)
+	case "mkdir":
+		fs := flag.NewFlagSet("fs mkdir", flag.ExitOnError)
+		project := fs.String("project", "", "project ID")
+		path := fs.String("path", "", "directory path (parents are created)")
+		_ = fs.Parse(args[1:])
+		if *project == "" || *path == "" {
+			fmt.Println("--project and --path required")
+			os.Exit(1)
+		}
+		b, _ := json.Marshal(map[string]string{"projectID": *project, "path": *path})
+		resp, err := apiClient.Post(serverURL()+"/fs/mkdir", "application/json", strings.NewReader(string(b)))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		var res struct {
+			Existed bool `json:"existed"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if res.Existed {
+			fmt.Printf("exists: %s\n", *path)
+		} else {
+			fmt.Printf("created: %s\n", *path)
+		}
+	case "move":
+		fs := flag.NewFlagSet("fs move", flag.ExitOnError)
+		project := fs.String("project", "", "project ID")
+		from := fs.String("from", "", "current path")
+		to := fs.String("to", "", "new path (an existing file is backed up and replaced)")
+		dryRun := fs.Bool("dry-run", false, "print what would change and exit")
+		yes := fs.Bool("yes", false, "apply without prompt (required unless --dry-run)")
+		_ = fs.Parse(args[1:])
+		if *project == "" || *from == "" || *to == "" {
+			fmt.Println("--project, --from and --to required")
+			os.Exit(1)
+		}
+		if *dryRun {
+			fmt.Printf("[dry-run] move %s -> %s\n", *from, *to)
+			return
+		}
+		if !*yes {
+			fmt.Println("confirmation required: pass --yes to apply or use --dry-run")
+			os.Exit(1)
+		}
+		b, _ := json.Marshal(map[string]string{"projectID": *project, "from": *from, "to": *to})
+		resp, err := apiClient.Post(serverURL()+"/fs/move", "application/json", strings.NewReader(string(b)))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "patch":
 		fs := flag.NewFlagSet("fs patch", flag.ExitOnError)
@@ -1027,12 +2119,15 @@ func fsCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","path":"%s","hunks":[{"start":%d,"length":%d,"replace":%q}]}`, *project, *path, *start, *length, *replace)
-		resp, err := http.Post(serverURL()+"/fs/patch", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/fs/patch", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "patch-unified":
 		fs := flag.NewFlagSet("fs patch-unified", flag.ExitOnError)
@@ -1041,8 +2136,10 @@ func fsCmd(args []string) {
 		dryRun := fs.Bool("dry-run", false, "dry run (preview only)")
 		yes := fs.Bool("yes", false, "apply without prompt (required unless --dry-run)")
 		ignoreWS := fs.Bool("ignore-ws", false, "ignore whitespace when applying (fuzzy)")
+		fuzz := fs.Int("fuzz", 0, "allow hunks to apply up to N lines away from their position")
 		color := fs.Bool("color", false, "colorize diff summary")
 		_ = fs.Parse(args[1:])
+		*color = wantColor(*color)
 		if *project == "" || *file == "" {
 			fmt.Println("--project and --file required")
 			os.Exit(1)
@@ -1052,17 +2149,20 @@ func fsCmd(args []string) {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		body := fmt.Sprintf(`{"projectID":"%s","diffText":%q,"dryRun":%v,"yes":%v}`, *project, string(b), *dryRun, *yes)
+		body := fmt.Sprintf(`{"projectID":"%s","diffText":%q,"dryRun":%v,"yes":%v,"fuzz":%d}`, *project, string(b), *dryRun, *yes, *fuzz)
 		url := serverURL() + "/fs/patch/unified"
 		if *ignoreWS {
 			url += "?ignorews=1"
 		}
-		resp, err := http.Post(url, "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(url, "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		var res struct {
 			Ok           bool   `json:"ok"`
 			DryRun       bool   `json:"dryRun"`
@@ -1074,6 +2174,17 @@ func fsCmd(args []string) {
 				Path                   string
 				Add, Del, WrittenBytes int
 				Conflict               string
+				Hunks                  []struct {
+					OldStart int `json:"oldStart"`
+					Offset   int `json:"offset"`
+				}
+				Rejects []struct {
+					OldStart int      `json:"oldStart"`
+					Reason   string   `json:"reason"`
+					Expected []string `json:"expected"`
+					Actual   []string `json:"actual"`
+				}
+				RejectFile string `json:"rejectFile"`
 			}
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
@@ -1104,6 +2215,23 @@ func fsCmd(args []string) {
 				fmt.Printf(" conflict: %s", f.Conflict)
 			}
 			fmt.Println()
+			for _, h := range f.Hunks {
+				if h.Offset != 0 {
+					fmt.Printf("    hunk @%d applied with offset %+d\n", h.OldStart, h.Offset)
+				}
+			}
+			for _, rj := range f.Rejects {
+				fmt.Printf("    rejected hunk @%d: %s\n", rj.OldStart, rj.Reason)
+				for _, l := range rj.Expected {
+					fmt.Printf("      expected: %s\n", l)
+				}
+				for _, l := range rj.Actual {
+					fmt.Printf("      actual:   %s\n", l)
+				}
+			}
+			if f.RejectFile != "" {
+				fmt.Printf("    rejects saved: %s\n", f.RejectFile)
+			}
 		}
 		if res.PatchID != "" {
 			fmt.Printf("patchID: %s\n", res.PatchID)
@@ -1128,13 +2256,63 @@ func fsCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","patchID":"%s","dryRun":%v,"yes":%v}`, *project, *patchID, *dryRun, *yes)
-		resp, err := http.Post(serverURL()+"/fs/patch/unified/rollback", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/fs/patch/unified/rollback", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
+	case "patch-list":
+		fs := flag.NewFlagSet("fs patch-list", flag.ExitOnError)
+		project := fs.String("project", "", "project ID")
+		asJSON := fs.Bool("json", false, "print raw JSON")
+		_ = fs.Parse(args[1:])
+		if *project == "" {
+			fmt.Println("--project required")
+			os.Exit(1)
+		}
+		resp, err := apiClient.Get(serverURL() + "/fs/patch/list?projectID=" + urlQueryEscape(*project))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		if *asJSON {
+			io.Copy(os.Stdout, resp.Body)
+			return
+		}
+		var res struct {
+			Patches []struct {
+				ID        string `json:"id"`
+				CreatedAt string `json:"createdAt"`
+				Applied   bool   `json:"applied"`
+				Files     int    `json:"files"`
+				TotalAdd  int    `json:"totalAdd"`
+				TotalDel  int    `json:"totalDel"`
+			} `json:"patches"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			_, _ = io.Copy(os.Stdout, resp.Body)
+			return
+		}
+		if len(res.Patches) == 0 {
+			fmt.Println("no patches recorded")
+			return
+		}
+		for _, pt := range res.Patches {
+			status := "applied"
+			if !pt.Applied {
+				status = "rolled-back"
+			}
+			fmt.Printf("%s  %s  %-11s  files=%d  +%d/-%d\n", pt.ID, pt.CreatedAt, status, pt.Files, pt.TotalAdd, pt.TotalDel)
+		}
 	case "diff":
 		fs := flag.NewFlagSet("fs diff", flag.ExitOnError)
 		project := fs.String("project", "", "project ID")
@@ -1143,7 +2321,9 @@ func fsCmd(args []string) {
 		context := fs.Int("context", 3, "context lines")
 		ignoreCRLF := fs.Bool("ignore-crlf", false, "ignore CRLF differences")
 		color := fs.Bool("color", false, "colorize diff")
+		wordDiff := fs.Bool("word-diff", false, "highlight changed words within paired lines (implies --color)")
 		_ = fs.Parse(args[1:])
+		*color = wantColor(*color)
 		if *project == "" || *path == "" || *newFile == "" {
 			fmt.Println("--project, --path and --new-file required")
 			os.Exit(1)
@@ -1154,12 +2334,15 @@ func fsCmd(args []string) {
 			os.Exit(1)
 		}
 		body := fmt.Sprintf(`{"projectID":"%s","path":"%s","newContent":%q,"context":%d,"ignoreCRLF":%v}`, *project, *path, string(b), *context, *ignoreCRLF)
-		resp, err := http.Post(serverURL()+"/fs/diff", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/fs/diff", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		var res struct {
 			Diff string `json:"diffText"`
 		}
@@ -1167,17 +2350,50 @@ func fsCmd(args []string) {
 			_, _ = io.Copy(os.Stdout, resp.Body)
 			return
 		}
-		if *color {
+		if *wordDiff && wantColor(true) {
+			fmt.Print(colorizeUnifiedDiffWords(res.Diff))
+		} else if *color {
 			fmt.Print(colorizeUnifiedDiff(res.Diff))
 		} else {
 			fmt.Print(res.Diff)
 		}
 	default:
-		fmt.Println("usage: mycoder fs [read|write|delete|patch] --project <id> --path <p> [--content ...] [--start N --length N --replace ...]")
+		fmt.Println("usage: mycoder fs [read|write|delete|mkdir|move|patch] --project <id> --path <p> [--content ...] [--start N --length N --replace ...]")
 		os.Exit(1)
 	}
 }
 
+// runExecCheck asks POST /shell/policy/check whether cmd would be allowed,
+// printing the verdict. It returns the exit code: 0 allowed, 1 denied or error.
+func runExecCheck(w io.Writer, base, cmd string, argv []string) int {
+	b, _ := json.Marshal(map[string]any{"cmd": cmd, "args": argv})
+	resp, err := apiClient.Post(base+"/shell/policy/check", "application/json", strings.NewReader(string(b)))
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	var res struct {
+		Allowed bool   `json:"allowed"`
+		Reason  string `json:"reason"`
+		Cmdline string `json:"cmdline"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		fmt.Fprintln(w, "decode:", err)
+		return 1
+	}
+	if !res.Allowed {
+		fmt.Fprintf(w, "denied: %s (%s)\n", res.Cmdline, res.Reason)
+		return 1
+	}
+	fmt.Fprintf(w, "allowed: %s\n", res.Cmdline)
+	return 0
+}
+
 func execCmd(args []string) {
 	fs := flag.NewFlagSet("exec", flag.ExitOnError)
 	project := fs.String("project", "", "project ID")
@@ -1190,12 +2406,26 @@ func execCmd(args []string) {
 	streamTail := fs.Int("stream-tail", 0, "buffer and print only last N lines at end (stream)")
 	retries := fs.Int("retries", 0, "auto-retry times on stream error")
 	save := fs.String("save-log", "", "save stream lines to file")
+	stdinFile := fs.String("stdin-file", "", "send file content as the command's stdin")
+	check := fs.Bool("check", false, "only report whether the server's shell policy allows the command")
 	_ = fs.Parse(args)
 	rest := fs.Args()
+	if *check && len(rest) > 0 {
+		os.Exit(runExecCheck(os.Stdout, serverURL(), rest[0], rest[1:]))
+	}
 	if *project == "" || len(rest) == 0 {
-		fmt.Println("usage: mycoder exec --project <id> [--timeout 30] [--stream] -- <cmd> [args...]")
+		fmt.Println("usage: mycoder exec --project <id> [--timeout 30] [--stream] [--stdin-file <path>] -- <cmd> [args...]")
 		os.Exit(1)
 	}
+	stdin := ""
+	if *stdinFile != "" {
+		data, err := os.ReadFile(*stdinFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		stdin = string(data)
+	}
 	cmd := rest[0]
 	var argv []string
 	if len(rest) > 1 {
@@ -1209,7 +2439,8 @@ func execCmd(args []string) {
 		Timeout   int               `json:"timeoutSec"`
 		Cwd       string            `json:"cwd"`
 		Env       map[string]string `json:"env"`
-	}{ProjectID: *project, Cmd: cmd, Args: argv, Timeout: *timeout, Cwd: *cwd, Env: parseEnvCSV(*envCSV)}
+		Stdin     string            `json:"stdin,omitempty"`
+	}{ProjectID: *project, Cmd: cmd, Args: argv, Timeout: *timeout, Cwd: *cwd, Env: parseEnvCSV(*envCSV), Stdin: stdin}
 	b, _ := json.Marshal(body)
 	if *stream {
 		attempts := *retries + 1
@@ -1217,7 +2448,7 @@ func execCmd(args []string) {
 			ctx2, cancel2 := signalContext()
 			req, _ := http.NewRequestWithContext(ctx2, http.MethodPost, serverURL()+"/shell/exec/stream", strings.NewReader(string(b)))
 			req.Header.Set("Content-Type", "application/json")
-			resp, err := http.DefaultClient.Do(req)
+			resp, err := apiClient.Do(req)
 			if err != nil {
 				cancel2()
 				if i == attempts-1 {
@@ -1298,7 +2529,7 @@ func execCmd(args []string) {
 			return
 		}
 	}
-	resp, err := http.Post(serverURL()+"/shell/exec", "application/json", strings.NewReader(string(b)))
+	resp, err := apiClient.Post(serverURL()+"/shell/exec", "application/json", strings.NewReader(string(b)))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -1331,7 +2562,7 @@ func execCmd(args []string) {
 
 func hooksCmd(args []string) {
 	if len(args) == 0 || args[0] != "run" {
-		fmt.Println("usage: mycoder hooks run [--project <id>] [--targets fmt-check,test,lint] [--timeout 60] [--verbose] [--save <path.json>]")
+		fmt.Println("usage: mycoder hooks run [--project <id>] [--targets fmt-check,test,lint] [--runner make|npm|just|raw] [--command '<tmpl {target}>'] [--parallel [--max-parallel N]] [--timeout 60] [--verbose] [--save <path.json>]")
 		os.Exit(1)
 	}
 	fs := flag.NewFlagSet("hooks run", flag.ExitOnError)
@@ -1341,7 +2572,12 @@ func hooksCmd(args []string) {
 	verbose := fs.Bool("verbose", false, "print each target output")
 	useColor := fs.Bool("color", false, "colorize status and hints")
 	save := fs.String("save", "", "save structured results JSON to project-relative path")
+	runner := fs.String("runner", "", "hook runner: make|npm|just|raw (default: auto-detect)")
+	command := fs.String("command", "", "raw runner command template ({target} is replaced)")
+	parallel := fs.Bool("parallel", false, "run targets concurrently and collect all results")
+	maxParallel := fs.Int("max-parallel", 0, "max concurrent targets with --parallel (server default 4)")
 	_ = fs.Parse(args[1:])
+	*useColor = wantColor(*useColor)
 	if *project == "" {
 		fmt.Println("--project required")
 		os.Exit(1)
@@ -1350,8 +2586,17 @@ func hooksCmd(args []string) {
 	if strings.TrimSpace(*save) != "" {
 		extra = fmt.Sprintf(`,"artifactPath":%q`, *save)
 	}
+	if strings.TrimSpace(*runner) != "" {
+		extra += fmt.Sprintf(`,"runner":%q`, *runner)
+	}
+	if strings.TrimSpace(*command) != "" {
+		extra += fmt.Sprintf(`,"command":%q`, *command)
+	}
+	if *parallel {
+		extra += fmt.Sprintf(`,"parallel":true,"maxParallel":%d`, *maxParallel)
+	}
 	body := fmt.Sprintf(`{"projectID":"%s","targets":[%s],"timeoutSec":%d%s}`, *project, toJSONStringArray(*targets), *timeout, extra)
-	resp, err := http.Post(serverURL()+"/tools/hooks", "application/json", strings.NewReader(body))
+	resp, err := apiClient.Post(serverURL()+"/tools/hooks", "application/json", strings.NewReader(body))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -1463,7 +2708,7 @@ func testCmd(args []string) {
 		os.Exit(1)
 	}
 	body := fmt.Sprintf(`{"projectID":"%s","targets":["test"],"timeoutSec":%d}`, *project, *timeout)
-	resp, err := http.Post(serverURL()+"/tools/hooks", "application/json", strings.NewReader(body))
+	resp, err := apiClient.Post(serverURL()+"/tools/hooks", "application/json", strings.NewReader(body))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -1512,11 +2757,13 @@ func testCmd(args []string) {
 // explainCmd asks the model to explain a path or symbol with citations.
 func explainCmd(args []string) {
 	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	maxTokens := fs.Int("max-tokens", 0, "cap on generated tokens (0 = provider default)")
 	project := fs.String("project", "", "project ID")
 	k := fs.Int("k", 7, "retrieval top K")
 	stream := fs.Bool("stream", false, "stream output")
 	color := fs.Bool("color", false, "colorize citations in output")
 	_ = fs.Parse(args)
+	*color = wantColor(*color)
 	rest := fs.Args()
 	if *project == "" || len(rest) == 0 {
 		fmt.Println("usage: mycoder explain --project <id> [--k 7] [--stream] <path|symbol>")
@@ -1526,12 +2773,14 @@ func explainCmd(args []string) {
 	// craft prompt: instruct explanation with citations
 	prompt := fmt.Sprintf("Explain '%s' in this repository. Summarize purpose, key functions, and important interactions. Cite files with line ranges.", target)
 	body := fmt.Sprintf(`{"messages":[{"role":"user","content":%q}],"stream":%v,"projectID":"%s","retrieval":{"k":%d}}`, prompt, *stream, *project, *k)
+	body = withMaxTokens(body, *maxTokens)
 	if *stream {
 		ctx, cancel := signalContext()
 		defer cancel()
 		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, serverURL()+"/chat", strings.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
-		resp, err := http.DefaultClient.Do(req)
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := apiClient.Do(req)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
@@ -1574,7 +2823,7 @@ func explainCmd(args []string) {
 		return
 	}
 	// non-streaming
-	resp, err := http.Post(serverURL()+"/chat", "application/json", strings.NewReader(body))
+	resp, err := apiClient.Post(serverURL()+"/chat", "application/json", strings.NewReader(body))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -1593,6 +2842,7 @@ func explainCmd(args []string) {
 // editCmd requests an edit plan for the given goal and optional files.
 func editCmd(args []string) {
 	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	maxTokens := fs.Int("max-tokens", 0, "cap on generated tokens (0 = provider default)")
 	project := fs.String("project", "", "project ID")
 	goal := fs.String("goal", "", "edit goal/description")
 	files := fs.String("files", "", "comma-separated files to focus on")
@@ -1600,6 +2850,7 @@ func editCmd(args []string) {
 	stream := fs.Bool("stream", false, "stream output")
 	color := fs.Bool("color", false, "colorize unified diff output")
 	_ = fs.Parse(args)
+	*color = wantColor(*color)
 	if *project == "" || *goal == "" {
 		fmt.Println("usage: mycoder edit --project <id> --goal \"<설명>\" [--files a.go,b.go] [--k 8] [--stream]")
 		os.Exit(1)
@@ -1615,12 +2866,14 @@ func editCmd(args []string) {
 	b.WriteString(*goal)
 	prompt := b.String()
 	body := fmt.Sprintf(`{"messages":[{"role":"user","content":%q}],"stream":%v,"projectID":"%s","retrieval":{"k":%d}}`, prompt, *stream, *project, *k)
+	body = withMaxTokens(body, *maxTokens)
 	if *stream {
 		ctx, cancel := signalContext()
 		defer cancel()
 		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, serverURL()+"/chat", strings.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
-		resp, err := http.DefaultClient.Do(req)
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := apiClient.Do(req)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
@@ -1654,7 +2907,7 @@ func editCmd(args []string) {
 		fmt.Println()
 		return
 	}
-	resp, err := http.Post(serverURL()+"/chat", "application/json", strings.NewReader(body))
+	resp, err := apiClient.Post(serverURL()+"/chat", "application/json", strings.NewReader(body))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -1742,21 +2995,94 @@ func colorizeUnifiedDiff(s string) string {
 	return out.String()
 }
 
+// colorizeUnifiedDiffWords colors a unified diff like colorizeUnifiedDiff, but
+// for runs of removed lines directly followed by added lines it pairs them up
+// and highlights only the words that differ.
+func colorizeUnifiedDiffWords(s string) string {
+	lines := strings.Split(s, "\n")
+	isDel := func(l string) bool { return strings.HasPrefix(l, "-") && !strings.HasPrefix(l, "---") }
+	isAdd := func(l string) bool { return strings.HasPrefix(l, "+") && !strings.HasPrefix(l, "+++") }
+	var out strings.Builder
+	for i := 0; i < len(lines); {
+		if !isDel(lines[i]) {
+			out.WriteString(colorizeUnifiedDiff(lines[i]))
+			i++
+			continue
+		}
+		dStart := i
+		for i < len(lines) && isDel(lines[i]) {
+			i++
+		}
+		aStart := i
+		for i < len(lines) && isAdd(lines[i]) {
+			i++
+		}
+		dels, adds := lines[dStart:aStart], lines[aStart:i]
+		oldRendered := make([]string, len(dels))
+		newRendered := make([]string, len(adds))
+		for k := range dels {
+			oldRendered[k] = colorRed(dels[k])
+		}
+		for k := range adds {
+			newRendered[k] = colorGreen(adds[k])
+		}
+		for k := 0; k < len(dels) && k < len(adds); k++ {
+			oldSegs, newSegs := patch.WordDiff(dels[k][1:], adds[k][1:])
+			oldRendered[k] = colorRed("-") + renderWordSegments(oldSegs, "\x1b[7;31m")
+			newRendered[k] = colorGreen("+") + renderWordSegments(newSegs, "\x1b[7;32m")
+		}
+		for _, l := range oldRendered {
+			out.WriteString(l)
+			out.WriteByte('\n')
+		}
+		for _, l := range newRendered {
+			out.WriteString(l)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+// renderWordSegments emits unchanged spans as-is and changed spans wrapped in the given ANSI style.
+func renderWordSegments(segs []patch.Segment, style string) string {
+	var b strings.Builder
+	for _, sg := range segs {
+		if sg.Changed {
+			b.WriteString(style + sg.Text + "\x1b[0m")
+		} else {
+			b.WriteString(sg.Text)
+		}
+	}
+	return b.String()
+}
+
 // mcpCmd lists tools or calls a tool with JSON params
 func mcpCmd(args []string) {
 	if len(args) == 0 {
-		fmt.Println("usage: mycoder mcp tools|call --name <tool> --json '<params>'")
+		fmt.Println("usage: mycoder mcp tools|call --name <tool> --json '<params>'|calls [--tool <name>] [--limit N] [--json]|serve [--token <t>]")
 		os.Exit(1)
 	}
 	sub := args[0]
 	switch sub {
+	case "serve":
+		// JSON-RPC 2.0 over stdio for MCP clients; stdout is reserved for protocol messages
+		fs := flag.NewFlagSet("mcp serve", flag.ExitOnError)
+		token := fs.String("token", apiToken(), "API token used for tool calls when auth is enabled")
+		_ = fs.Parse(args[1:])
+		if err := server.RunMCPStdio(*token); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	case "tools":
-		resp, err := http.Get(serverURL() + "/mcp/tools")
+		resp, err := apiClient.Get(serverURL() + "/mcp/tools")
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
 	case "call":
 		fs := flag.NewFlagSet("mcp call", flag.ExitOnError)
@@ -1774,8 +3100,11 @@ func mcpCmd(args []string) {
 			os.Exit(1)
 		}
 		// fetch tools schema and validate if available
-		if resp, err := http.Get(serverURL() + "/mcp/tools"); err == nil {
+		if resp, err := apiClient.Get(serverURL() + "/mcp/tools"); err == nil {
 			defer resp.Body.Close()
+			if !checkResponse(resp) {
+				return
+			}
 			var tools struct {
 				Tools []struct {
 					Name         string `json:"name"`
@@ -1810,15 +3139,71 @@ func mcpCmd(args []string) {
 			}
 		}
 		body := fmt.Sprintf(`{"name":%q,"params":%s}`, *name, *jsonParams)
-		resp, err := http.Post(serverURL()+"/mcp/call", "application/json", strings.NewReader(body))
+		resp, err := apiClient.Post(serverURL()+"/mcp/call", "application/json", strings.NewReader(body))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
 		io.Copy(os.Stdout, resp.Body)
+	case "calls":
+		fs := flag.NewFlagSet("mcp calls", flag.ExitOnError)
+		tool := fs.String("tool", "", "only calls to this tool")
+		limit := fs.Int("limit", 20, "max calls to show (newest first)")
+		asJSON := fs.Bool("json", false, "print raw JSON")
+		_ = fs.Parse(args[1:])
+		target := serverURL() + "/mcp/calls?limit=" + strconv.Itoa(*limit)
+		if *tool != "" {
+			target += "&tool=" + urlQueryEscape(*tool)
+		}
+		resp, err := apiClient.Get(target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if !checkResponse(resp) {
+			return
+		}
+		if *asJSON {
+			io.Copy(os.Stdout, resp.Body)
+			return
+		}
+		var res struct {
+			Calls []struct {
+				Tool       string            `json:"tool"`
+				Params     map[string]string `json:"params"`
+				Outcome    string            `json:"outcome"`
+				Error      string            `json:"error"`
+				DurationMs int64             `json:"durationMs"`
+				CalledAt   string            `json:"calledAt"`
+			} `json:"calls"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			fmt.Fprintln(os.Stderr, "decode:", err)
+			os.Exit(1)
+		}
+		if len(res.Calls) == 0 {
+			fmt.Println("no MCP calls recorded")
+			return
+		}
+		for _, c := range res.Calls {
+			keys := make([]string, 0, len(c.Params))
+			for k := range c.Params {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			line := fmt.Sprintf("%s  %-10s  %-6s  %5dms  params=%s", c.CalledAt, c.Tool, c.Outcome, c.DurationMs, strings.Join(keys, ","))
+			if c.Error != "" {
+				line += "  error=" + c.Error
+			}
+			fmt.Println(line)
+		}
 	default:
-		fmt.Println("usage: mycoder mcp tools|call --name <tool> --json '<params>'")
+		fmt.Println("usage: mycoder mcp tools|call --name <tool> --json '<params>'|calls [--tool <name>] [--limit N] [--json]|serve [--token <t>]")
 		os.Exit(1)
 	}
 }
@@ -1830,7 +3215,7 @@ func interactiveChatMode() {
 	fmt.Println("────────────────────────────────────────────────────────────────")
 
 	// Check if server is running
-	serverURL := getServerURL()
+	serverURL := serverURL()
 	if !isServerRunning(serverURL) {
 		fmt.Printf("⚠️  Server not running. Starting server at %s...\n", serverURL)
 		startServerInBackground()
@@ -1903,15 +3288,8 @@ func interactiveChatMode() {
 	}
 }
 
-func getServerURL() string {
-	if url := os.Getenv("MYCODER_SERVER_URL"); url != "" {
-		return url
-	}
-	return "http://localhost:8089"
-}
-
 func isServerRunning(serverURL string) bool {
-	client := &http.Client{Timeout: 2 * time.Second}
+	client := newAPIClient(2 * time.Second)
 	resp, err := client.Get(serverURL + "/healthz")
 	if err != nil {
 		return false
@@ -1944,7 +3322,7 @@ func waitForServerReady(serverURL string, maxSeconds int) {
 }
 
 func getOrCreateDefaultProject(serverURL string) string {
-	client := &http.Client{}
+	client := newAPIClient(0)
 	cwd, _ := os.Getwd()
 	// 1) Try to find existing project with rootPath == cwd
 	if resp, err := client.Get(serverURL + "/projects"); err == nil {
@@ -1984,7 +3362,7 @@ func getOrCreateDefaultProject(serverURL string) string {
 }
 
 func sendChatRequest(serverURL, projectID, message string) string {
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := newAPIClient(30 * time.Second)
 
 	// base retrieval K can be tuned by env; default to a richer value
 	k := 8
@@ -2057,7 +3435,7 @@ func handleProjectCommand(input, serverURL string) {
 	}
 
 	if parts[1] == "list" {
-		client := &http.Client{}
+		client := newAPIClient(0)
 		resp, err := client.Get(serverURL + "/projects")
 		if err != nil {
 			fmt.Printf("❌ Error: %v\n", err)
@@ -2088,7 +3466,7 @@ func handleProjectCommand(input, serverURL string) {
 func handleIndexCommand(input, projectID, serverURL string) {
 	fmt.Printf("🔄 Indexing project %s...\n", projectID)
 
-	client := &http.Client{}
+	client := newAPIClient(0)
 	requestBody := map[string]interface{}{
 		"projectID": projectID,
 		"mode":      "full",
@@ -2112,7 +3490,7 @@ func handleIndexCommand(input, projectID, serverURL string) {
 // shouldIndexProject checks if the project needs indexing
 func shouldIndexProject(serverURL, projectID string) bool {
 	// Check if project has been indexed before
-	client := &http.Client{Timeout: 2 * time.Second}
+	client := newAPIClient(2 * time.Second)
 
 	// Try to search for a test query to see if index exists
 	testQuery := "main"
@@ -2152,7 +3530,7 @@ func shouldIndexProject(serverURL, projectID string) bool {
 
 // indexProjectInBackground indexes the project in the background
 func indexProjectInBackground(serverURL, projectID string) {
-	client := &http.Client{Timeout: 60 * time.Second}
+	client := newAPIClient(60 * time.Second)
 	requestBody := map[string]interface{}{
 		"projectID": projectID,
 		"mode":      "full",
@@ -2180,7 +3558,7 @@ func indexProjectInBackground(serverURL, projectID string) {
 
 // monitorIndexingJob monitors the indexing job status
 func monitorIndexingJob(serverURL, jobID string) {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := newAPIClient(5 * time.Second)
 	maxAttempts := 30 // Monitor for max 30 seconds
 
 	for i := 0; i < maxAttempts; i++ {
@@ -2202,7 +3580,7 @@ func monitorIndexingJob(serverURL, jobID string) {
 			if status == "completed" {
 				// Silently complete - don't interrupt user's input
 				return
-			} else if status == "failed" {
+			} else if status == "failed" || status == "canceled" {
 				// Silently fail - don't interrupt user's input
 				return
 			}
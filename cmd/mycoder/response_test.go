@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckResponseReportsAPIError(t *testing.T) {
+	oldExit, oldErr, oldURL := exitFunc, stderr, serverURLFlag
+	t.Cleanup(func() { exitFunc, stderr, serverURLFlag = oldExit, oldErr, oldURL })
+	var errOut bytes.Buffer
+	code := -1
+	exitFunc = func(c int) { code = c }
+	stderr = &errOut
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_request","message":"name required","code":400}`))
+	}))
+	defer srv.Close()
+	serverURLFlag = srv.URL
+
+	projectsCmd([]string{"list"})
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if got := errOut.String(); !strings.Contains(got, "http 400 invalid_request: name required") {
+		t.Fatalf("unexpected stderr: %q", got)
+	}
+}
+
+func TestResponseErrorPlainBody(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.WriteHeader(http.StatusBadGateway)
+	_, _ = rr.WriteString("upstream down\n")
+	err := responseError(rr.Result())
+	if err == nil || err.Error() != "http 502: upstream down" {
+		t.Fatalf("got %v", err)
+	}
+	ok := httptest.NewRecorder()
+	if err := responseError(ok.Result()); err != nil {
+		t.Fatalf("2xx should not be an error: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/server"
+	"mycoder/internal/store"
+)
+
+func TestSeedRAGReportsMissingFiles(t *testing.T) {
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "docs"), 0o755)
+	_ = os.WriteFile(filepath.Join(root, "docs", "API.md"), []byte("# API\n"), 0o644)
+	st := store.New()
+	p := st.CreateProject("seed", root, nil)
+	srv := httptest.NewServer(server.NewHandler(st, nil))
+	defer srv.Close()
+
+	seeds := []seedSpec{
+		{"API", "docs/API.md"},
+		{"RAG", "docs/RAG.md,docs/MEMORY.md"},
+		{"Mixed", "docs/API.md,docs/NOPE.md"},
+	}
+	var buf bytes.Buffer
+	if n := runSeedPromote(&buf, srv.URL, p.ID, seeds, false, true); n != 3 {
+		t.Fatalf("dry-run planned %d seeds, want 3\n%s", n, buf.String())
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"[dry-run] promote-auto: API <- [docs/API.md]",
+		"[dry-run] promote-auto: RAG <- [docs/RAG.md,docs/MEMORY.md]",
+		"[dry-run] promote-auto: Mixed <- [docs/API.md,docs/NOPE.md]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if ks, _ := st.ListKnowledge(p.ID, 0); len(ks) != 0 {
+		t.Fatalf("dry-run should not promote, got %d items", len(ks))
+	}
+
+	buf.Reset()
+	if n := runSeedPromote(&buf, srv.URL, p.ID, seeds, false, false); n != 2 {
+		t.Fatalf("seeded %d, want 2\n%s", n, buf.String())
+	}
+	out = buf.String()
+	for _, want := range []string{
+		"seeded: Mixed (read 1/2 files)",
+		"missing: docs/NOPE.md",
+		"skipped: RAG",
+		"no readable files: docs/RAG.md, docs/MEMORY.md",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if ks, _ := st.ListKnowledge(p.ID, 0); len(ks) != 2 {
+		t.Fatalf("expected 2 promoted items, got %d", len(ks))
+	}
+}
+
+func TestSeedRAGSendsFilesToRemoteServer(t *testing.T) {
+	// a remote server whose project root does not exist on this machine
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects":
+			_ = json.NewEncoder(w).Encode([]map[string]any{{"id": "p1", "rootPath": "/srv/remote-only"}})
+		case "/knowledge/promote/auto":
+			var req struct {
+				Files []string `json:"files"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			sent = append(sent, req.Files...)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "kn-1", "filesRead": req.Files[:1], "filesMissing": req.Files[1:]})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	if n := runSeedPromote(&buf, srv.URL, "p1", []seedSpec{{"API", "docs/API.md,docs/GONE.md"}}, false, false); n != 1 {
+		t.Fatalf("seeded %d, want 1\n%s", n, buf.String())
+	}
+	if strings.Join(sent, ",") != "docs/API.md,docs/GONE.md" {
+		t.Fatalf("sent files=%v", sent)
+	}
+	if out := buf.String(); !strings.Contains(out, "seeded: API (read 1/2 files)") || !strings.Contains(out, "missing: docs/GONE.md") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mycoder/internal/server"
+	"mycoder/internal/store"
+)
+
+func TestServeReadonlyFlagBlocksWrites(t *testing.T) {
+	t.Setenv("MYCODER_READONLY", "")
+	if addr := applyServeFlags([]string{"--addr", ":0", "--readonly"}); addr != ":0" {
+		t.Fatalf("addr=%q", addr)
+	}
+	h := server.NewHandler(store.New(), nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/projects", bytes.NewReader([]byte(`{"name":"p","rootPath":"."}`))))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 with --readonly, got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
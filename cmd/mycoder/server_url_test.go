@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerURLFlagOverridesEnv(t *testing.T) {
+	old := serverURLFlag
+	t.Cleanup(func() { serverURLFlag = old })
+
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_SERVER_URL", "http://127.0.0.1:1")
+
+	rest, err := extractValueFlag([]string{"projects", "--server-url", srv.URL + "/", "list"}, "server-url", &serverURLFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rest, " ") != "projects list" {
+		t.Fatalf("flag not stripped: %v", rest)
+	}
+	if serverURL() != srv.URL {
+		t.Fatalf("serverURL()=%q, want %q", serverURL(), srv.URL)
+	}
+	projectsCmd(rest[1:])
+	if gotPath != "/projects" {
+		t.Fatalf("request did not reach the flag URL (path %q)", gotPath)
+	}
+}
+
+func TestExtractServerURLArgsForms(t *testing.T) {
+	old := serverURLFlag
+	t.Cleanup(func() { serverURLFlag = old })
+
+	rest, err := extractValueFlag([]string{"--server-url=http://remote:9000", "exec", "--", "--server-url", "x"}, "server-url", &serverURLFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverURLFlag != "http://remote:9000" {
+		t.Fatalf("serverURLFlag=%q", serverURLFlag)
+	}
+	if strings.Join(rest, " ") != "exec -- --server-url x" {
+		t.Fatalf("args after -- must be kept: %v", rest)
+	}
+	if _, err := extractValueFlag([]string{"search", "--server-url"}, "server-url", &serverURLFlag); err == nil {
+		t.Fatalf("expected error for missing value")
+	}
+}
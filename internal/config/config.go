@@ -7,33 +7,76 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // KnownKeys defines environment variable keys that mycoder recognizes.
 var KnownKeys = []string{
+	"MYCODER_CONFIG",
 	"MYCODER_SERVER_URL",
+	"MYCODER_COLOR",
+	"MYCODER_LOG_LEVEL",
+	"MYCODER_LOG_FORMAT",
+	"MYCODER_LOG_BODIES",
 	"MYCODER_SQLITE_PATH",
+	"MYCODER_SQLITE_BUSY_TIMEOUT_MS",
 	"MYCODER_LLM_PROVIDER",
 	"MYCODER_OPENAI_BASE_URL",
 	"MYCODER_OPENAI_API_KEY",
+	"MYCODER_ANTHROPIC_BASE_URL",
+	"MYCODER_ANTHROPIC_API_KEY",
+	"MYCODER_ANTHROPIC_MODEL",
+	"MYCODER_OLLAMA_BASE_URL",
+	"MYCODER_OLLAMA_MODEL",
+	"MYCODER_OLLAMA_EMBEDDING_MODEL",
 	"MYCODER_CHAT_MODEL",
 	"MYCODER_EMBEDDING_MODEL",
+	"MYCODER_EMBEDDING_MODEL_BY_EXT",
+	"MYCODER_EMBED_BATCH_SIZE",
+	"MYCODER_EMBED_TRANSLATE_FALLBACK",
+	"MYCODER_EMBED_TRANSLATE_TIMEOUT_MS",
+	"MYCODER_TRANSLATOR_MODEL",
 	"MYCODER_LLM_MIN_INTERVAL_MS",
+	"MYCODER_LLM_TIMEOUT_MS",
+	"MYCODER_LLM_SHORT_TIMEOUT_MS",
+	"MYCODER_SHELL",
 	"MYCODER_SHELL_ALLOW_REGEX",
 	"MYCODER_SHELL_DENY_REGEX",
 	"MYCODER_FS_ALLOW_REGEX",
 	"MYCODER_FS_DENY_REGEX",
+	"MYCODER_INDEX_TEXT_EXTS",
+	"MYCODER_SEARCH_CONTENT_MAX_BYTES",
+	"MYCODER_MAX_BODY_BYTES",
 	"MYCODER_CURATOR_DISABLE",
 	"MYCODER_CURATOR_INTERVAL",
 	"MYCODER_KNOWLEDGE_MIN_TRUST",
+	"MYCODER_KP_BUDGET_BYTES",
+	"MYCODER_KP_FILE_BYTES",
 	"MYCODER_METRICS_SAMPLE_RATE",
+	"MYCODER_METRICS_BUCKETS",
+	"MYCODER_METRICS_PERSIST",
+	"MYCODER_METRICS_PERSIST_INTERVAL",
+	"MYCODER_MCP_CALLS_MAX",
+	"MYCODER_WEB_SEARCH_PROVIDER",
+	"MYCODER_WEB_SEARCH_URL",
+	"MYCODER_WEB_SEARCH_API_KEY",
 }
 
-// LoadAndApply loads configuration from ~/.mycoder/config.yaml (or .yml/.json)
-// and applies values into the process environment for known keys if they are
-// not already set. Environment variables take precedence over file values.
+// fileValues holds settings read from the config file, keyed by upper-cased name.
+var (
+	fileMu     sync.RWMutex
+	fileValues = map[string]string{}
+)
+
+// LoadAndApply loads the config file named by MYCODER_CONFIG, or else the first of
+// ~/.mycoder/config.yaml|.yml|.toml|.json, so Get can fall back to it. Known keys
+// are also applied to the process environment when not already set, for code
+// and child processes that read the environment directly. Environment
+// variables take precedence over file values.
 // Also loads .env file from current directory if exists.
 func LoadAndApply() error {
 	// First, try to load .env file from current directory
@@ -41,49 +84,177 @@ func LoadAndApply() error {
 		// .env loaded successfully
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil || home == "" {
-		return nil // non-fatal
-	}
-	base := filepath.Join(home, ".mycoder")
-	paths := []string{
-		filepath.Join(base, "config.yaml"),
-		filepath.Join(base, "config.yml"),
-		filepath.Join(base, "config.json"),
-	}
-	var data map[string]any
-	for _, p := range paths {
-		b, err := os.ReadFile(p)
-		if err != nil {
-			continue
+	if p := strings.TrimSpace(os.Getenv("MYCODER_CONFIG")); p != "" {
+		if err := Load(p); err != nil {
+			return fmt.Errorf("MYCODER_CONFIG: %w", err)
 		}
-		if strings.HasSuffix(p, ".json") {
-			if m, err := parseJSON(b); err == nil {
-				data = m
-				break
-			}
-		} else {
-			if m, err := parseYAMLShallow(string(b)); err == nil {
-				data = m
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			return nil // non-fatal
+		}
+		base := filepath.Join(home, ".mycoder")
+		for _, name := range []string{"config.yaml", "config.yml", "config.toml", "config.json"} {
+			if err := Load(filepath.Join(base, name)); err == nil {
 				break
 			}
 		}
 	}
-	if len(data) == 0 {
-		return nil
-	}
 	// Apply to env if not set already
 	for _, key := range KnownKeys {
 		if os.Getenv(key) != "" {
 			continue
 		}
-		if v, ok := lookupInsensitive(data, key); ok {
-			os.Setenv(key, toString(v))
+		if v, ok := fileValue(key); ok {
+			os.Setenv(key, v)
 		}
 	}
 	return nil
 }
 
+// Load parses a YAML (shallow), TOML (shallow) or JSON config file chosen by
+// extension and replaces the file-backed values used by Get.
+func Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var data map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err = parseJSON(b)
+	case ".toml":
+		data, err = parseTOMLShallow(string(b))
+	default:
+		data, err = parseYAMLShallow(string(b))
+	}
+	if err != nil {
+		return err
+	}
+	vals := make(map[string]string, len(data))
+	for k, v := range data {
+		vals[strings.ToUpper(k)] = toString(v)
+	}
+	fileMu.Lock()
+	fileValues = vals
+	fileMu.Unlock()
+	return nil
+}
+
+func fileValue(key string) (string, bool) {
+	fileMu.RLock()
+	defer fileMu.RUnlock()
+	v, ok := fileValues[strings.ToUpper(key)]
+	return v, ok
+}
+
+// Get returns the setting for key: a non-empty environment variable wins, then
+// the config file value, then "" so the caller's default applies.
+func Get(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	v, _ := fileValue(key)
+	return v
+}
+
+// GetInt returns Get(key) parsed as an int, or def when unset or invalid.
+func GetInt(key string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(Get(key))); err == nil {
+		return n
+	}
+	return def
+}
+
+// GetFloat returns Get(key) parsed as a float64, or def when unset or invalid.
+func GetFloat(key string, def float64) float64 {
+	if f, err := strconv.ParseFloat(strings.TrimSpace(Get(key)), 64); err == nil {
+		return f
+	}
+	return def
+}
+
+// GetDuration returns Get(key) parsed as a Go duration, or def when unset or invalid.
+func GetDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(Get(key))); err == nil {
+		return d
+	}
+	return def
+}
+
+// Setting is one resolved configuration entry as reported by Effective.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // env|file|default
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// defaults lists built-in values for keys whose default is not simply empty.
+var defaults = map[string]string{
+	"MYCODER_SERVER_URL":          "http://localhost:8089",
+	"MYCODER_LLM_PROVIDER":        "openai",
+	"MYCODER_CURATOR_INTERVAL":    "10m",
+	"MYCODER_KNOWLEDGE_MIN_TRUST": "0.4",
+}
+
+// IsSecret reports whether key holds a credential that must not be displayed.
+func IsSecret(key string) bool {
+	k := strings.ToUpper(key)
+	for _, suf := range []string{"_API_KEY", "_API_TOKEN", "_API_TOKENS", "_DSN", "_SECRET", "_PASSWORD"} {
+		if strings.HasSuffix(k, suf) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask hides a secret value while keeping whether it is set visible.
+func Mask(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "****"
+}
+
+// Effective returns the resolved settings for all known keys plus any other
+// MYCODER_* key present in the environment or config file, sorted by key.
+// Secret values are masked.
+func Effective() []Setting {
+	keys := map[string]bool{}
+	for _, k := range KnownKeys {
+		keys[k] = true
+	}
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 && strings.HasPrefix(kv[:i], "MYCODER_") {
+			keys[kv[:i]] = true
+		}
+	}
+	fileMu.RLock()
+	for k := range fileValues {
+		if strings.HasPrefix(k, "MYCODER_") {
+			keys[k] = true
+		}
+	}
+	fileMu.RUnlock()
+	out := make([]Setting, 0, len(keys))
+	for k := range keys {
+		s := Setting{Key: k, Source: "default", Value: defaults[k]}
+		if v := os.Getenv(k); v != "" {
+			s.Value, s.Source = v, "env"
+		} else if v, ok := fileValue(k); ok {
+			s.Value, s.Source = v, "file"
+		}
+		if IsSecret(k) {
+			s.Secret = true
+			s.Value = Mask(s.Value)
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
 func parseJSON(b []byte) (map[string]any, error) {
 	var m map[string]any
 	if err := json.Unmarshal(b, &m); err != nil {
@@ -146,17 +317,50 @@ func parseYAMLShallow(s string) (map[string]any, error) {
 	return m, nil
 }
 
-func lookupInsensitive(m map[string]any, key string) (any, bool) {
-	if v, ok := m[key]; ok {
-		return v, true
-	}
-	// allow lower/upper keys
-	for k, v := range m {
-		if strings.EqualFold(k, key) {
-			return v, true
+// parseTOMLShallow parses flat TOML: key = value lines. Table headers are
+// flattened away, so keys grouped under [server]/[rag] for readability apply
+// exactly as if they were written at the top level.
+func parseTOMLShallow(s string) (map[string]any, error) {
+	m := make(map[string]any)
+	rd := bufio.NewScanner(strings.NewReader(s))
+	for rd.Scan() {
+		line := strings.TrimSpace(rd.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
 		}
+		i := strings.IndexRune(line, '=')
+		if i <= 0 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		val := strings.TrimSpace(line[i+1:])
+		if strings.HasPrefix(val, "\"") || strings.HasPrefix(val, "'") {
+			q := val[:1]
+			if j := strings.Index(val[1:], q); j >= 0 {
+				m[key] = val[1 : j+1]
+				continue
+			}
+		}
+		if j := strings.Index(val, " #"); j >= 0 {
+			val = strings.TrimSpace(val[:j])
+		}
+		if b, err := strconv.ParseBool(val); err == nil {
+			m[key] = b
+			continue
+		}
+		if n, err := strconv.ParseFloat(val, 64); err == nil {
+			m[key] = n
+			continue
+		}
+		m[key] = val
+	}
+	if err := rd.Err(); err != nil {
+		return nil, err
 	}
-	return nil, false
+	if len(m) == 0 {
+		return nil, errors.New("empty or unsupported TOML")
+	}
+	return m, nil
 }
 
 func toString(v any) string {
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func resetFileValues(t *testing.T) {
+	t.Cleanup(func() {
+		fileMu.Lock()
+		fileValues = map[string]string{}
+		fileMu.Unlock()
+	})
+}
+
+func TestGetPrecedenceYAML(t *testing.T) {
+	resetFileValues(t)
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	body := "MYCODER_CHAT_MODEL: file-model\nMYCODER_CURATOR_INTERVAL: 5m\nmycoder_rag_budget_bytes: 2048\n"
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(p); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	t.Setenv("MYCODER_CHAT_MODEL", "env-model")
+	if v := Get("MYCODER_CHAT_MODEL"); v != "env-model" {
+		t.Fatalf("env should win, got %q", v)
+	}
+	t.Setenv("MYCODER_CHAT_MODEL", "")
+	if v := Get("MYCODER_CHAT_MODEL"); v != "file-model" {
+		t.Fatalf("file should apply when env empty, got %q", v)
+	}
+	if d := GetDuration("MYCODER_CURATOR_INTERVAL", time.Minute); d != 5*time.Minute {
+		t.Fatalf("duration from file: %v", d)
+	}
+	if n := GetInt("MYCODER_RAG_BUDGET_BYTES", 0); n != 2048 {
+		t.Fatalf("keys are case-insensitive in file, got %d", n)
+	}
+	if v := Get("MYCODER_UNSET_FOR_TEST"); v != "" {
+		t.Fatalf("unset key should be empty, got %q", v)
+	}
+	if f := GetFloat("MYCODER_UNSET_FOR_TEST", 0.4); f != 0.4 {
+		t.Fatalf("default should apply, got %v", f)
+	}
+}
+
+func TestLoadTOMLViaMycoderConfig(t *testing.T) {
+	resetFileValues(t)
+	p := filepath.Join(t.TempDir(), "mycoder.toml")
+	body := "# comment\nMYCODER_KNOWLEDGE_MIN_TRUST = 0.7\nMYCODER_WEB_SEARCH_PROVIDER = \"searxng\"\n\n[rag]\nMYCODER_CHAT_MODEL = \"from-table\"\n"
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MYCODER_CONFIG", p)
+	t.Setenv("MYCODER_WEB_SEARCH_PROVIDER", "")
+	t.Setenv("MYCODER_KNOWLEDGE_MIN_TRUST", "")
+	t.Setenv("MYCODER_CHAT_MODEL", "")
+	if err := LoadAndApply(); err != nil {
+		t.Fatalf("LoadAndApply: %v", err)
+	}
+	if f := GetFloat("MYCODER_KNOWLEDGE_MIN_TRUST", 0.4); f != 0.7 {
+		t.Fatalf("min trust from toml: %v", f)
+	}
+	if v := Get("MYCODER_WEB_SEARCH_PROVIDER"); v != "searxng" {
+		t.Fatalf("quoted toml string: %q", v)
+	}
+	if v := Get("MYCODER_CHAT_MODEL"); v != "from-table" {
+		t.Fatalf("keys under a table header should apply flat, got %q", v)
+	}
+}
+
+func TestEffectiveSourcesAndMasking(t *testing.T) {
+	resetFileValues(t)
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(p, []byte("MYCODER_SQLITE_PATH: /tmp/from-file.db\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(p); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MYCODER_SQLITE_PATH", "")
+	t.Setenv("MYCODER_CHAT_MODEL", "env-model")
+	t.Setenv("MYCODER_OPENAI_API_KEY", "sk-secret")
+	t.Setenv("MYCODER_SERVER_URL", "")
+	got := map[string]Setting{}
+	for _, s := range Effective() {
+		got[s.Key] = s
+	}
+	if s := got["MYCODER_CHAT_MODEL"]; s.Value != "env-model" || s.Source != "env" {
+		t.Fatalf("chat model: %+v", s)
+	}
+	if s := got["MYCODER_SQLITE_PATH"]; s.Value != "/tmp/from-file.db" || s.Source != "file" {
+		t.Fatalf("sqlite path: %+v", s)
+	}
+	if s := got["MYCODER_SERVER_URL"]; s.Value != "http://localhost:8089" || s.Source != "default" {
+		t.Fatalf("server url: %+v", s)
+	}
+	if s := got["MYCODER_OPENAI_API_KEY"]; !s.Secret || s.Value != "****" {
+		t.Fatalf("api key not masked: %+v", s)
+	}
+	if IsSecret("MYCODER_CHUNK_MAX_TOKENS") {
+		t.Fatalf("token count key must not be treated as secret")
+	}
+}
+
+func TestLoadAndApplyMissingConfigFails(t *testing.T) {
+	resetFileValues(t)
+	t.Setenv("MYCODER_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	if err := LoadAndApply(); err == nil {
+		t.Fatalf("expected error for missing MYCODER_CONFIG file")
+	}
+}
@@ -3,8 +3,10 @@ package embedpipe
 import (
 	"context"
 	"os"
+	"strings"
 	"time"
 
+	"mycoder/internal/config"
 	"mycoder/internal/llm"
 	"mycoder/internal/vectorstore"
 )
@@ -28,19 +30,53 @@ type Pipeline struct {
 	cache map[string]struct{}
 	items []item
 	tr    Translator
+	// ctx governs the automatic flushes Add triggers when a batch fills
+	ctx context.Context
 }
 
+// Batch size bounds: MYCODER_EMBED_BATCH_SIZE defaults to 8 and is clamped to
+// [1, maxBatchSize] so a typo can't build one enormous embedding request.
+const (
+	defaultBatchSize = 8
+	maxBatchSize     = 256
+)
+
 func New(emb llm.Embedder, vs vectorstore.VectorStore) *Pipeline {
 	if emb == nil || vs == nil {
 		return nil
 	}
 	m := getDefaultModel()
 	p := getDefaultProvider()
-	return &Pipeline{emb: emb, vs: vs, model: m, prov: p, batch: 8, cache: make(map[string]struct{})}
+	return &Pipeline{emb: emb, vs: vs, model: m, prov: p, batch: batchSizeFromEnv(), cache: make(map[string]struct{}), ctx: context.Background()}
+}
+
+func batchSizeFromEnv() int {
+	n := config.GetInt("MYCODER_EMBED_BATCH_SIZE", defaultBatchSize)
+	switch {
+	case n < 1:
+		return defaultBatchSize
+	case n > maxBatchSize:
+		return maxBatchSize
+	}
+	return n
+}
+
+// WithContext sets the context used by flushes that Add triggers, so a canceled
+// request stops sending batches to the embedding server.
+func (p *Pipeline) WithContext(ctx context.Context) *Pipeline {
+	if p != nil {
+		p.ctx = ctx
+	}
+	return p
 }
 
 // WithTranslator sets an optional translator used for language fallback.
-func (p *Pipeline) WithTranslator(tr Translator) *Pipeline { p.tr = tr; return p }
+func (p *Pipeline) WithTranslator(tr Translator) *Pipeline {
+	if p != nil {
+		p.tr = tr
+	}
+	return p
+}
 
 // Add schedules a document text for embedding. shaKey is used for simple de-dup.
 func (p *Pipeline) Add(projectID, docID, path, sha, text string) {
@@ -64,15 +100,18 @@ func (p *Pipeline) Add(projectID, docID, path, sha, text string) {
 	iprov := pickProviderForPath(path, p.prov)
 	p.items = append(p.items, item{projectID: projectID, docID: docID, path: path, text: text, model: imodel, provider: iprov})
 	if len(p.items) >= p.batch {
-		_ = p.Flush(context.Background())
+		_ = p.Flush(p.ctx)
 	}
 }
 
 // Flush embeds pending items and upserts to the vector store. Retries once on failure.
+// Cancellation is checked before each model batch; pending items are dropped
+// and ctx.Err() returned once ctx is done.
 func (p *Pipeline) Flush(ctx context.Context) error {
 	if p == nil || len(p.items) == 0 {
 		return nil
 	}
+	defer func() { p.items = p.items[:0] }()
 	// group items by (model, provider) to batch per model
 	groups := make(map[string][]int)
 	order := make([]string, 0)
@@ -84,6 +123,9 @@ func (p *Pipeline) Flush(ctx context.Context) error {
 		groups[key] = append(groups[key], i)
 	}
 	for _, key := range order {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		idxs := groups[key]
 		if len(idxs) == 0 {
 			continue
@@ -94,6 +136,9 @@ func (p *Pipeline) Flush(ctx context.Context) error {
 		if err != nil || len(vecs) != len(texts) {
 			// per-item retry
 			for _, i := range idxs {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				it := p.items[i]
 				t1 := p.textsForGroup(ctx, []int{i})
 				v, e := p.emb.Embeddings(ctx, model, t1)
@@ -111,7 +156,6 @@ func (p *Pipeline) Flush(ctx context.Context) error {
 		}
 		_ = p.vs.Upsert(ctx, ups)
 	}
-	p.items = p.items[:0]
 	return nil
 }
 
@@ -123,11 +167,11 @@ type Translator interface {
 // textsForGroup returns the processed texts for given item indexes, applying translation fallback when enabled.
 func (p *Pipeline) textsForGroup(ctx context.Context, idxs []int) []string {
 	out := make([]string, len(idxs))
-	useFallback := os.Getenv("MYCODER_EMBED_TRANSLATE_FALLBACK") == "1"
+	useFallback := config.Get("MYCODER_EMBED_TRANSLATE_FALLBACK") == "1"
 	to := "en"
 	// timeout
 	tmo := 1200 * time.Millisecond
-	if v := os.Getenv("MYCODER_EMBED_TRANSLATE_TIMEOUT_MS"); v != "" {
+	if v := config.Get("MYCODER_EMBED_TRANSLATE_TIMEOUT_MS"); v != "" {
 		if ms, err := atoi(v); err == nil && ms > 0 {
 			tmo = time.Duration(ms) * time.Millisecond
 		}
@@ -178,22 +222,28 @@ func atoi(s string) (int, error) {
 
 // --- helpers for model/provider selection ---
 func getDefaultModel() string {
-	if m := os.Getenv("MYCODER_EMBEDDING_MODEL"); m != "" {
+	if m := config.Get("MYCODER_EMBEDDING_MODEL"); m != "" {
 		return m
 	}
 	return "text-embedding-3-small"
 }
 
 func getDefaultProvider() string {
-	if p := os.Getenv("MYCODER_EMBEDDING_PROVIDER"); p != "" {
+	if p := config.Get("MYCODER_EMBEDDING_PROVIDER"); p != "" {
 		return p
 	}
 	return "openai"
 }
 
+// pickModelForPath routes an item to an embedding model: an extension listed in
+// MYCODER_EMBEDDING_MODEL_BY_EXT wins, then MYCODER_EMBEDDING_MODEL_CODE for
+// code paths, then def.
 func pickModelForPath(path, def string) string {
+	if m := modelForExt(path); m != "" {
+		return m
+	}
 	if isCodePath(path) {
-		if m := os.Getenv("MYCODER_EMBEDDING_MODEL_CODE"); m != "" {
+		if m := config.Get("MYCODER_EMBEDDING_MODEL_CODE"); m != "" {
 			return m
 		}
 	}
@@ -205,7 +255,7 @@ func pickModelForPath(path, def string) string {
 
 func pickProviderForPath(path, def string) string {
 	if isCodePath(path) {
-		if p := os.Getenv("MYCODER_EMBEDDING_PROVIDER_CODE"); p != "" {
+		if p := config.Get("MYCODER_EMBEDDING_PROVIDER_CODE"); p != "" {
 			return p
 		}
 	}
@@ -215,9 +265,30 @@ func pickProviderForPath(path, def string) string {
 	return getDefaultProvider()
 }
 
+// modelForExt looks up path's extension in MYCODER_EMBEDDING_MODEL_BY_EXT, a
+// comma-separated ext=model list such as "go=model-a,md=model-b" (the leading
+// dot is optional and matching ignores case).
+func modelForExt(path string) string {
+	spec := config.Get("MYCODER_EMBEDDING_MODEL_BY_EXT")
+	ext := strings.ToLower(strings.TrimPrefix(extOf(path), "."))
+	if spec == "" || ext == "" {
+		return ""
+	}
+	for _, pair := range splitComma(spec) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if strings.ToLower(strings.TrimPrefix(strings.TrimSpace(k), ".")) == ext {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
 func isCodePath(path string) bool {
 	// allow custom list: comma-separated extensions without dot, e.g. "go,ts,js,py"
-	if ex := os.Getenv("MYCODER_EMBEDDING_CODE_EXTS"); ex != "" {
+	if ex := config.Get("MYCODER_EMBEDDING_CODE_EXTS"); ex != "" {
 		ext := extOf(path)
 		for _, e := range splitComma(ex) {
 			if "."+e == ext {
@@ -2,11 +2,13 @@ package embedpipe
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
 
+	"mycoder/internal/llm"
 	"mycoder/internal/vectorstore"
 )
 
@@ -132,3 +134,115 @@ func TestTranslateFallbackKorean(t *testing.T) {
 		t.Fatalf("expected translated text to be embedded, calls=%v", fe.calls)
 	}
 }
+
+func TestFlushStopsOnCanceledContext(t *testing.T) {
+	fe := &fakeEmb{}
+	fvs := &fakeVS{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := New(fe, fvs).WithContext(ctx)
+	p.Add("p", "d", "a.go", "sha", "package a")
+	if err := p.Flush(ctx); err != context.Canceled {
+		t.Fatalf("Flush err=%v, want context.Canceled", err)
+	}
+	if len(fe.calls) != 0 || len(fvs.upserts) != 0 {
+		t.Fatalf("canceled flush should not embed or upsert: calls=%v upserts=%d", fe.calls, len(fvs.upserts))
+	}
+}
+
+func TestPipelineModelByExtOverridesCodeSplit(t *testing.T) {
+	for _, k := range []string{"MYCODER_EMBEDDING_MODEL", "MYCODER_EMBEDDING_MODEL_CODE", "MYCODER_EMBEDDING_MODEL_BY_EXT"} {
+		old := os.Getenv(k)
+		k := k
+		t.Cleanup(func() { _ = os.Setenv(k, old) })
+	}
+	_ = os.Setenv("MYCODER_EMBEDDING_MODEL", "text-model")
+	_ = os.Setenv("MYCODER_EMBEDDING_MODEL_CODE", "code-model")
+	_ = os.Setenv("MYCODER_EMBEDDING_MODEL_BY_EXT", "go=model-a, .MD=model-b, broken")
+
+	fe := &fakeEmb{}
+	fvs := &fakeVS{}
+	p := New(fe, fvs)
+	p.Add("proj", "doc1", "main.go", "sha1", "package main")
+	p.Add("proj", "doc2", "docs/README.md", "sha2", "# readme")
+	p.Add("proj", "doc3", "app.py", "sha3", "print(1)")
+	p.Add("proj", "doc4", "notes.txt", "sha4", "notes")
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+	// the pipeline stores the path as the vector DocID
+	got := map[string]string{}
+	for _, batch := range fvs.upserts {
+		for _, it := range batch {
+			got[it.DocID] = it.Model
+		}
+	}
+	want := map[string]string{"main.go": "model-a", "docs/README.md": "model-b", "app.py": "code-model", "notes.txt": "text-model"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("models per doc: got=%v want=%v", got, want)
+	}
+}
+
+// fakeChat answers every chat with a fixed reply and records the prompts.
+type fakeChat struct {
+	reply   string
+	prompts []string
+}
+
+func (f *fakeChat) Chat(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+	f.prompts = append(f.prompts, messages[len(messages)-1].Content)
+	return &fakeStream{s: f.reply}, nil
+}
+
+type fakeStream struct{ s string }
+
+func (s *fakeStream) Recv() (string, bool, error) { v := s.s; s.s = ""; return v, v == "", nil }
+func (s *fakeStream) Close() error                { return nil }
+
+func TestLLMTranslatorTranslatesKoreanBeforeEmbedding(t *testing.T) {
+	old := os.Getenv("MYCODER_EMBED_TRANSLATE_FALLBACK")
+	t.Cleanup(func() { _ = os.Setenv("MYCODER_EMBED_TRANSLATE_FALLBACK", old) })
+	_ = os.Setenv("MYCODER_EMBED_TRANSLATE_FALLBACK", "1")
+
+	chat := &fakeChat{reply: " hello world \n"}
+	fe := &fakeEmb{}
+	p := New(fe, &fakeVS{}).WithTranslator(NewLLMTranslator(chat))
+	p.Add("proj", "d1", "README.md", "s1", "안녕하세요 세계")
+	p.Add("proj", "d2", "NOTES.md", "s2", "plain english")
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(chat.prompts) != 1 || chat.prompts[0] != "안녕하세요 세계" {
+		t.Fatalf("only the Korean chunk should be translated, prompts=%q", chat.prompts)
+	}
+	if len(fe.calls) != 1 || !strings.HasSuffix(fe.calls[0], ":hello world,plain english") {
+		t.Fatalf("embedder inputs=%q", fe.calls)
+	}
+}
+
+func TestPipelineBatchSizeFromEnv(t *testing.T) {
+	old := os.Getenv("MYCODER_EMBED_BATCH_SIZE")
+	t.Cleanup(func() { _ = os.Setenv("MYCODER_EMBED_BATCH_SIZE", old) })
+	_ = os.Setenv("MYCODER_EMBED_BATCH_SIZE", "3")
+
+	fe := &fakeEmb{}
+	p := New(fe, &fakeVS{})
+	for i := 0; i < 7; i++ {
+		p.Add("proj", "", fmt.Sprintf("f%d.md", i), fmt.Sprintf("s%d", i), "text")
+	}
+	// two full batches of 3 flushed automatically, one item pending
+	if len(fe.calls) != 2 {
+		t.Fatalf("expected 2 auto flushes at batch size 3, got %d", len(fe.calls))
+	}
+	_ = p.Flush(context.Background())
+	if len(fe.calls) != 3 {
+		t.Fatalf("expected final flush, got %d calls", len(fe.calls))
+	}
+
+	for v, want := range map[string]int{"0": defaultBatchSize, "junk": defaultBatchSize, "100000": maxBatchSize, "64": 64} {
+		_ = os.Setenv("MYCODER_EMBED_BATCH_SIZE", v)
+		if got := New(fe, &fakeVS{}).batch; got != want {
+			t.Fatalf("MYCODER_EMBED_BATCH_SIZE=%s: batch=%d want %d", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package embedpipe
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"mycoder/internal/config"
+	"mycoder/internal/llm"
+)
+
+// LLMTranslator implements Translator with a chat model, for the Korean to
+// English fallback enabled by MYCODER_EMBED_TRANSLATE_FALLBACK=1. The caller's
+// context carries the MYCODER_EMBED_TRANSLATE_TIMEOUT_MS deadline.
+type LLMTranslator struct {
+	chat  llm.ChatProvider
+	model string
+}
+
+// NewLLMTranslator returns a translator using chat with MYCODER_TRANSLATOR_MODEL
+// (default MYCODER_CHAT_MODEL), or nil when chat is nil.
+func NewLLMTranslator(chat llm.ChatProvider) *LLMTranslator {
+	if chat == nil {
+		return nil
+	}
+	model := config.Get("MYCODER_TRANSLATOR_MODEL")
+	if model == "" {
+		model = config.Get("MYCODER_CHAT_MODEL")
+	}
+	return &LLMTranslator{chat: chat, model: model}
+}
+
+// Translate asks the model for a plain translation of text from srcLang to tgtLang.
+func (t *LLMTranslator) Translate(ctx context.Context, srcLang, tgtLang, text string) (string, error) {
+	sys := llm.Message{Role: llm.RoleSystem, Content: "Translate the user's text from " + srcLang + " to " + tgtLang + ". Keep code, identifiers and paths unchanged. Reply with the translation only."}
+	usr := llm.Message{Role: llm.RoleUser, Content: text}
+	st, err := t.chat.Chat(ctx, t.model, []llm.Message{sys, usr}, false, 0)
+	if err != nil {
+		return "", err
+	}
+	defer st.Close()
+	var b strings.Builder
+	for {
+		d, done, err := st.Recv()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(d)
+		if done {
+			break
+		}
+	}
+	out := strings.TrimSpace(b.String())
+	if out == "" {
+		return "", errors.New("empty translation")
+	}
+	return out, nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io/fs"
+	"mycoder/internal/config"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -21,10 +22,18 @@ type FileDoc struct {
 }
 
 type Options struct {
-	MaxFiles    int
-	MaxFileSize int64    // bytes
-	Include     []string // glob patterns relative to root
-	Exclude     []string // glob patterns relative to root
+	MaxFiles      int
+	MaxFileSize   int64    // bytes
+	MaxTotalBytes int64    // cumulative content bytes; 0 means unlimited
+	Include       []string // glob patterns relative to root
+	Exclude       []string // glob patterns relative to root
+}
+
+// Stats reports files skipped during collection.
+type Stats struct {
+	SkippedBinary   int      // denied by extension or sniffed as binary
+	SkippedTotalCap int      // left out once MaxTotalBytes was reached
+	CappedPaths     []string // root-relative paths of the SkippedTotalCap files
 }
 
 var defaultSkips = map[string]struct{}{
@@ -38,6 +47,21 @@ var extDeny = map[string]struct{}{
 
 // Index walks root and returns text file contents up to limits.
 func Index(root string, opt Options) ([]FileDoc, error) {
+	docs, _, err := IndexWithStats(root, opt)
+	return docs, err
+}
+
+// IndexWithStats is Index that also reports skip counts. Extensions listed in
+// MYCODER_INDEX_TEXT_EXTS (comma-separated, e.g. "svg,dat") are always read
+// as text, bypassing the extension deny list and the binary sniff. A root
+// that is missing or not a directory is an error.
+func IndexWithStats(root string, opt Options) ([]FileDoc, Stats, error) {
+	var stats Stats
+	if fi, err := os.Stat(root); err != nil {
+		return nil, stats, fmt.Errorf("index root: %w", err)
+	} else if !fi.IsDir() {
+		return nil, stats, fmt.Errorf("index root %s: not a directory", root)
+	}
 	if opt.MaxFiles <= 0 {
 		opt.MaxFiles = 500
 	}
@@ -49,7 +73,7 @@ func Index(root string, opt Options) ([]FileDoc, error) {
 	// When Include patterns are provided or override env is set, force WalkDir to allow
 	// users to explicitly include files even if .gitignore would exclude them.
 	files := make([]string, 0, opt.MaxFiles)
-	forceWalk := len(opt.Include) > 0 || os.Getenv("MYCODER_INDEX_FORCE_WALK") == "1"
+	forceWalk := len(opt.Include) > 0 || config.Get("MYCODER_INDEX_FORCE_WALK") == "1"
 	if !forceWalk && useGitListing(root) {
 		if lst, err := gitListFiles(root); err == nil && len(lst) > 0 {
 			files = lst
@@ -59,12 +83,25 @@ func Index(root string, opt Options) ([]FileDoc, error) {
 		files = walkListFiles(root, opt.MaxFiles)
 	}
 
+	textExts := parseExtList(config.Get("MYCODER_INDEX_TEXT_EXTS"))
 	var docs []FileDoc
+	var totalBytes int64
+	capped := false
 	for _, path := range files {
 		if len(docs) >= opt.MaxFiles {
 			break
 		}
-		if isDenied(path) {
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if len(opt.Include) > 0 && !matchAny(rel, opt.Include) {
+			continue
+		}
+		if len(opt.Exclude) > 0 && matchAny(rel, opt.Exclude) {
+			continue
+		}
+		_, forceText := textExts[strings.ToLower(filepath.Ext(path))]
+		if !forceText && isDenied(path) {
+			stats.SkippedBinary++
 			continue
 		}
 		info, err := os.Stat(path)
@@ -74,27 +111,31 @@ func Index(root string, opt Options) ([]FileDoc, error) {
 		if info.Size() > opt.MaxFileSize {
 			continue
 		}
-		b, err := os.ReadFile(path)
-		if err != nil || looksBinary(b) {
+		// once the total cap is hit, stop reading and only count what is left
+		if capped || (opt.MaxTotalBytes > 0 && totalBytes+info.Size() > opt.MaxTotalBytes) {
+			capped = true
+			stats.SkippedTotalCap++
+			stats.CappedPaths = append(stats.CappedPaths, rel)
 			continue
 		}
-		rel, _ := filepath.Rel(root, path)
-		rel = filepath.ToSlash(rel)
-		if len(opt.Include) > 0 && !matchAny(rel, opt.Include) {
+		b, err := os.ReadFile(path)
+		if err != nil {
 			continue
 		}
-		if len(opt.Exclude) > 0 && matchAny(rel, opt.Exclude) {
+		if !forceText && looksBinary(b) {
+			stats.SkippedBinary++
 			continue
 		}
 		docs = append(docs, FileDoc{
 			Path:    rel,
 			Content: string(b),
 			SHA:     sha256Hex(b),
-			Lang:    detectLang(path),
+			Lang:    detectLang(path, b),
 			MTime:   info.ModTime().UTC().Format(time.RFC3339),
 		})
+		totalBytes += int64(len(b))
 	}
-	return docs, nil
+	return docs, stats, nil
 }
 
 func isDenied(path string) bool {
@@ -104,17 +145,39 @@ func isDenied(path string) bool {
 }
 
 func looksBinary(b []byte) bool {
-	// Heuristic: reject if contains NUL byte in first 8000 bytes
+	// Heuristic: reject if the first 8000 bytes contain a NUL byte or more
+	// than 10% control characters other than common whitespace
 	n := len(b)
 	if n > 8000 {
 		n = 8000
 	}
+	ctrl := 0
 	for i := 0; i < n; i++ {
-		if b[i] == 0 {
+		switch c := b[i]; {
+		case c == 0:
 			return true
+		case c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v' || c == 0x1b:
+		case c < 0x20 || c == 0x7f:
+			ctrl++
 		}
 	}
-	return false
+	return n > 0 && ctrl*10 > n
+}
+
+// parseExtList turns "svg, .dat" into a set of lower-cased ".ext" keys.
+func parseExtList(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		set[e] = struct{}{}
+	}
+	return set
 }
 
 func sha256Hex(b []byte) string {
@@ -131,7 +194,9 @@ func matchAny(rel string, patterns []string) bool {
 	return false
 }
 
-func detectLang(path string) string {
+// detectLang assigns a language by extension, falling back to well-known
+// filenames and shebang lines for extensionless files.
+func detectLang(path string, content []byte) string {
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".go":
@@ -144,9 +209,74 @@ func detectLang(path string) string {
 		return "py"
 	case ".md":
 		return "md"
-	default:
-		return strings.TrimPrefix(ext, ".")
 	}
+	if lang := SniffLang(path, content); lang != "" {
+		return lang
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+var filenameLangs = map[string]string{
+	"dockerfile":    "dockerfile",
+	"containerfile": "dockerfile",
+	"makefile":      "makefile",
+	"gnumakefile":   "makefile",
+	"jenkinsfile":   "groovy",
+	"vagrantfile":   "ruby",
+	"gemfile":       "ruby",
+	"rakefile":      "ruby",
+}
+
+var shebangLangs = map[string]string{
+	"python": "py", "python2": "py", "python3": "py",
+	"node": "js", "deno": "ts",
+	"sh": "sh", "bash": "sh", "zsh": "sh", "dash": "sh", "ksh": "sh",
+	"ruby": "ruby", "perl": "perl", "php": "php",
+}
+
+// SniffLang detects a language from well-known filenames (Dockerfile,
+// Makefile, Jenkinsfile, ...) or a shebang line at the start of content.
+// It returns "" when neither matches.
+func SniffLang(path string, content []byte) string {
+	base := strings.ToLower(filepath.Base(path))
+	if lang, ok := filenameLangs[base]; ok {
+		return lang
+	}
+	if strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile") {
+		return "dockerfile"
+	}
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line := content[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		// skip env flags such as -S
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interp = f
+				break
+			}
+		}
+	}
+	if lang, ok := shebangLangs[interp]; ok {
+		return lang
+	}
+	// versioned interpreters, e.g. python3.11
+	if i := strings.IndexAny(interp, "0123456789"); i > 0 {
+		if lang, ok := shebangLangs[interp[:i]]; ok {
+			return lang
+		}
+	}
+	return ""
 }
 
 // useGitListing reports whether to try git-based file listing.
@@ -50,3 +50,97 @@ func TestIndexIncludeExclude(t *testing.T) {
 		t.Fatalf("exclude filter failed: %+v", docs)
 	}
 }
+
+func TestIndexDetectsExtensionlessLang(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "manage"), []byte("#!/usr/bin/env python3\nprint('hi')\n"), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM golang:1.21\nRUN go build ./...\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "run"), []byte("#!/bin/bash\necho hi\n"), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "NOTES"), []byte("plain text\n"), 0o644)
+	docs, err := Index(dir, Options{MaxFiles: 10, MaxFileSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, d := range docs {
+		got[d.Path] = d.Lang
+	}
+	want := map[string]string{"manage": "py", "Dockerfile": "dockerfile", "run": "sh", "NOTES": ""}
+	for path, lang := range want {
+		if l, ok := got[path]; !ok || l != lang {
+			t.Fatalf("%s: lang=%q (present=%v), want %q", path, l, ok, lang)
+		}
+	}
+}
+
+func TestSniffLang(t *testing.T) {
+	cases := []struct {
+		path, head, want string
+	}{
+		{"Makefile", "", "makefile"},
+		{"ci/Jenkinsfile", "", "groovy"},
+		{"Dockerfile.dev", "", "dockerfile"},
+		{"tool", "#!/usr/bin/python3.11 -u\n", "py"},
+		{"tool", "#!/usr/bin/env -S node --no-warnings\n", "js"},
+		{"tool", "no shebang\n", ""},
+	}
+	for _, c := range cases {
+		if got := SniffLang(c.path, []byte(c.head)); got != c.want {
+			t.Errorf("SniffLang(%q, %q)=%q, want %q", c.path, c.head, got, c.want)
+		}
+	}
+}
+
+func TestIndexSkipsBinaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "app"), []byte("\x7fELF\x02\x01\x01\x00\x00\x00text"), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "blob.txt"), []byte("abc\x00def"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "noise.log"), []byte("\x01\x02\x03\x04\x05ok"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "logo.png"), []byte("not really a png"), 0o644)
+	docs, stats, err := IndexWithStats(dir, Options{MaxFiles: 10, MaxFileSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Path != "main.go" {
+		t.Fatalf("expected only main.go, got %+v", docs)
+	}
+	if stats.SkippedBinary != 4 {
+		t.Fatalf("skippedBinary=%d, want 4", stats.SkippedBinary)
+	}
+
+	// extension override reads denied/sniffed extensions as text
+	t.Setenv("MYCODER_INDEX_TEXT_EXTS", "png, .log")
+	docs, stats, _ = IndexWithStats(dir, Options{MaxFiles: 10, MaxFileSize: 1024})
+	if len(docs) != 3 || stats.SkippedBinary != 2 {
+		t.Fatalf("override: docs=%d skippedBinary=%d, want 3/2", len(docs), stats.SkippedBinary)
+	}
+}
+
+func TestIndexMaxTotalBytesStopsEarly(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"} {
+		_ = os.WriteFile(filepath.Join(dir, name), []byte("0123456789\n"), 0o644) // 11 bytes
+	}
+	docs, stats, err := IndexWithStats(dir, Options{MaxFiles: 10, MaxFileSize: 1024, MaxTotalBytes: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || docs[0].Path != "a.txt" || docs[1].Path != "b.txt" {
+		t.Fatalf("expected a.txt and b.txt within cap, got %+v", docs)
+	}
+	if stats.SkippedTotalCap != 3 || len(stats.CappedPaths) != 3 || stats.CappedPaths[0] != "c.txt" {
+		t.Fatalf("skippedTotalCap=%d, want 3", stats.SkippedTotalCap)
+	}
+	// no cap collects everything
+	docs, stats, _ = IndexWithStats(dir, Options{MaxFiles: 10, MaxFileSize: 1024})
+	if len(docs) != 5 || stats.SkippedTotalCap != 0 {
+		t.Fatalf("uncapped: docs=%d skippedTotalCap=%d", len(docs), stats.SkippedTotalCap)
+	}
+}
+
+func TestIndexMissingRootErrors(t *testing.T) {
+	if _, err := Index(filepath.Join(t.TempDir(), "missing"), Options{}); err == nil {
+		t.Fatal("expected error for missing root")
+	}
+}
@@ -0,0 +1,231 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"mycoder/internal/config"
+	"mycoder/internal/llm"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	defaultModel     = "claude-3-5-sonnet-latest"
+	defaultMaxTokens = 4096
+	apiVersion       = "2023-06-01"
+)
+
+// Client implements llm.ChatProvider against the Anthropic Messages API.
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// NewFromEnv builds a client from MYCODER_ANTHROPIC_BASE_URL (default
+// https://api.anthropic.com), MYCODER_ANTHROPIC_API_KEY and MYCODER_LLM_TIMEOUT_MS.
+func NewFromEnv() *Client {
+	base := config.Get("MYCODER_ANTHROPIC_BASE_URL")
+	if base == "" {
+		base = defaultBaseURL
+	}
+	timeout := 60 * time.Second
+	if ms := config.GetInt("MYCODER_LLM_TIMEOUT_MS", 0); ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	return &Client{
+		baseURL: strings.TrimRight(base, "/"),
+		apiKey:  config.Get("MYCODER_ANTHROPIC_API_KEY"),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// BaseURL returns the configured API base URL without a trailing slash.
+func (c *Client) BaseURL() string { return c.baseURL }
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Chat implements llm.ChatProvider. System messages are joined into the
+// top-level system prompt since the Messages API has no system role.
+func (c *Client) Chat(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+	if model == "" {
+		model = config.Get("MYCODER_ANTHROPIC_MODEL")
+		if model == "" {
+			model = defaultModel
+		}
+	}
+	var system []string
+	msgs := make([]message, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == llm.RoleSystem {
+			system = append(system, m.Content)
+			continue
+		}
+		msgs = append(msgs, message{Role: string(m.Role), Content: m.Content})
+	}
+	maxTokens := llm.MaxTokens(ctx)
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	reqBody := map[string]any{
+		"model":       model,
+		"messages":    msgs,
+		"max_tokens":  maxTokens,
+		"temperature": temperature,
+		"stream":      stream,
+	}
+	if len(system) > 0 {
+		reqBody["system"] = strings.Join(system, "\n\n")
+	}
+	b, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", apiVersion)
+	if c.apiKey != "" {
+		req.Header.Set("x-api-key", c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("messages http %d: %s", resp.StatusCode, string(data))
+	}
+	if stream {
+		return &chatStream{body: resp.Body, r: bufio.NewReader(resp.Body)}, nil
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage usage `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, blk := range out.Content {
+		if blk.Type == "text" {
+			sb.WriteString(blk.Text)
+		}
+	}
+	return &staticStream{s: sb.String(), usage: out.Usage}, nil
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u usage) toLLM() llm.Usage {
+	return llm.Usage{PromptTokens: u.InputTokens, CompletionTokens: u.OutputTokens}
+}
+
+// chatStream maps Messages API SSE events to llm.ChatStream: text deltas are
+// returned as they arrive and message_stop ends the stream.
+type chatStream struct {
+	body  io.ReadCloser
+	r     *bufio.Reader
+	usage usage
+	seen  bool
+}
+
+func (s *chatStream) Recv() (string, bool, error) {
+	event := ""
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", true, nil
+			}
+			return "", true, err
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "event:") {
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		var evt struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage usage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage *usage `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(payload), &evt); err != nil {
+			continue
+		}
+		if evt.Type == "" {
+			evt.Type = event
+		}
+		switch evt.Type {
+		case "message_start":
+			s.usage.InputTokens = evt.Message.Usage.InputTokens
+			s.seen = true
+		case "content_block_delta":
+			if evt.Delta.Type == "text_delta" && evt.Delta.Text != "" {
+				return evt.Delta.Text, false, nil
+			}
+		case "message_delta":
+			if evt.Usage != nil {
+				s.usage.OutputTokens = evt.Usage.OutputTokens
+				s.seen = true
+			}
+		case "message_stop":
+			return "", true, nil
+		case "error":
+			return "", true, fmt.Errorf("anthropic %s: %s", evt.Error.Type, evt.Error.Message)
+		}
+	}
+}
+
+func (s *chatStream) Close() error { return s.body.Close() }
+
+// Usage implements llm.UsageReporter; counts are complete once the stream is done.
+func (s *chatStream) Usage() (llm.Usage, bool) { return s.usage.toLLM(), s.seen }
+
+type staticStream struct {
+	s     string
+	usage usage
+}
+
+func (s *staticStream) Recv() (string, bool, error) {
+	if s.s == "" {
+		return "", true, nil
+	}
+	v := s.s
+	s.s = ""
+	return v, false, nil
+}
+
+func (s *staticStream) Close() error { return nil }
+
+// Usage implements llm.UsageReporter.
+func (s *staticStream) Usage() (llm.Usage, bool) { return s.usage.toLLM(), true }
@@ -0,0 +1,113 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mycoder/internal/llm"
+)
+
+func TestChatStreamingSSE(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "k1" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`event: ping` + "\n" + `data: {"type":"ping"}`,
+			`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+			`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+			`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}`,
+			`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+			`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "%s\n\n", e)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_ANTHROPIC_BASE_URL", srv.URL)
+	t.Setenv("MYCODER_ANTHROPIC_API_KEY", "k1")
+
+	msgs := []llm.Message{{Role: llm.RoleSystem, Content: "be brief"}, {Role: llm.RoleUser, Content: "hi"}}
+	st, err := NewFromEnv().Chat(context.Background(), "m", msgs, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	var sb strings.Builder
+	for {
+		delta, done, err := st.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sb.WriteString(delta)
+		if done {
+			break
+		}
+	}
+	if sb.String() != "Hello" {
+		t.Fatalf("got %q", sb.String())
+	}
+	if got["system"] != "be brief" || got["stream"] != true {
+		t.Fatalf("unexpected request body: %v", got)
+	}
+	if m := got["messages"].([]any); len(m) != 1 {
+		t.Fatalf("system message should not be sent as a turn: %v", m)
+	}
+	u, ok := st.(llm.UsageReporter).Usage()
+	if !ok || u.PromptTokens != 12 || u.CompletionTokens != 5 {
+		t.Fatalf("usage=%+v ok=%v", u, ok)
+	}
+}
+
+func TestChatStreamingErrorEvent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_ANTHROPIC_BASE_URL", srv.URL)
+	st, err := NewFromEnv().Chat(context.Background(), "m", []llm.Message{{Role: llm.RoleUser, Content: "hi"}}, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, _, err := st.Recv(); err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+}
+
+func TestChatNonStreaming(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": "ok"}},
+			"usage":   map[string]any{"input_tokens": 3, "output_tokens": 1},
+		})
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_ANTHROPIC_BASE_URL", srv.URL)
+	ctx := llm.WithMaxTokens(context.Background(), 64)
+	st, err := NewFromEnv().Chat(ctx, "m", []llm.Message{{Role: llm.RoleUser, Content: "hi"}}, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, done, err := st.Recv()
+	if err != nil || done || s != "ok" {
+		t.Fatalf("unexpected: %q done=%v err=%v", s, done, err)
+	}
+	if got["max_tokens"].(float64) != 64 {
+		t.Fatalf("max_tokens not forwarded: %v", got)
+	}
+}
@@ -0,0 +1,229 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"mycoder/internal/config"
+	"mycoder/internal/llm"
+)
+
+const (
+	defaultBaseURL        = "http://localhost:11434"
+	defaultModel          = "llama3.1"
+	defaultEmbeddingModel = "nomic-embed-text"
+)
+
+// Client implements llm.ChatProvider and llm.Embedder against a local Ollama server.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewFromEnv builds a client from MYCODER_OLLAMA_BASE_URL (default
+// http://localhost:11434) and MYCODER_LLM_TIMEOUT_MS.
+func NewFromEnv() *Client {
+	base := config.Get("MYCODER_OLLAMA_BASE_URL")
+	if base == "" {
+		base = defaultBaseURL
+	}
+	timeout := 60 * time.Second
+	if ms := config.GetInt("MYCODER_LLM_TIMEOUT_MS", 0); ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	return &Client{baseURL: strings.TrimRight(base, "/"), http: &http.Client{Timeout: timeout}}
+}
+
+// BaseURL returns the configured server URL without a trailing slash.
+func (c *Client) BaseURL() string { return c.baseURL }
+
+// chatChunk is one /api/chat response object; streaming sends one per line.
+type chatChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	Error           string `json:"error"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+// Chat implements llm.ChatProvider via POST /api/chat.
+func (c *Client) Chat(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+	if model == "" {
+		model = config.Get("MYCODER_OLLAMA_MODEL")
+		if model == "" {
+			model = defaultModel
+		}
+	}
+	opts := map[string]any{"temperature": temperature}
+	if n := llm.MaxTokens(ctx); n > 0 {
+		opts["num_predict"] = n
+	}
+	b, _ := json.Marshal(map[string]any{
+		"model":    model,
+		"messages": messages,
+		"stream":   stream,
+		"options":  opts,
+	})
+	resp, err := c.post(ctx, "/api/chat", b)
+	if err != nil {
+		return nil, err
+	}
+	if stream {
+		return &chatStream{body: resp.Body, r: bufio.NewReader(resp.Body)}, nil
+	}
+	defer resp.Body.Close()
+	var out chatChunk
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", out.Error)
+	}
+	return &staticStream{s: out.Message.Content, usage: llm.Usage{PromptTokens: out.PromptEvalCount, CompletionTokens: out.EvalCount}}, nil
+}
+
+// Embeddings implements llm.Embedder via POST /api/embeddings, one call per input.
+func (c *Client) Embeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	if model == "" {
+		model = config.Get("MYCODER_OLLAMA_EMBEDDING_MODEL")
+		if model == "" {
+			model = defaultEmbeddingModel
+		}
+	}
+	res := make([][]float32, 0, len(inputs))
+	for _, in := range inputs {
+		b, _ := json.Marshal(map[string]any{"model": model, "prompt": in})
+		resp, err := c.post(ctx, "/api/embeddings", b)
+		if err != nil {
+			return nil, err
+		}
+		var out struct {
+			Embedding []float32 `json:"embedding"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(out.Embedding) == 0 {
+			return nil, errors.New("ollama: empty embedding")
+		}
+		res = append(res, out.Embedding)
+	}
+	return res, nil
+}
+
+// ListModels returns locally available model names via GET /api/tags.
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/tags", nil)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("tags http %d: %s", resp.StatusCode, string(data))
+	}
+	var out struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(out.Models))
+	for _, m := range out.Models {
+		ids = append(ids, m.Name)
+	}
+	return ids, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("ollama %s http %d: %s", path, resp.StatusCode, string(data))
+	}
+	return resp, nil
+}
+
+// chatStream maps newline-delimited /api/chat chunks to llm.ChatStream.
+type chatStream struct {
+	body  io.ReadCloser
+	r     *bufio.Reader
+	usage llm.Usage
+	done  bool
+}
+
+func (s *chatStream) Recv() (string, bool, error) {
+	for {
+		line, err := s.r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return "", true, nil
+				}
+				return "", true, err
+			}
+			continue
+		}
+		var chunk chatChunk
+		if jerr := json.Unmarshal([]byte(line), &chunk); jerr != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			return "", true, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		if chunk.Done {
+			s.done = true
+			s.usage = llm.Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+			return chunk.Message.Content, true, nil
+		}
+		if chunk.Message.Content != "" {
+			return chunk.Message.Content, false, nil
+		}
+	}
+}
+
+func (s *chatStream) Close() error { return s.body.Close() }
+
+// Usage implements llm.UsageReporter; counts arrive with the final chunk.
+func (s *chatStream) Usage() (llm.Usage, bool) { return s.usage, s.done }
+
+type staticStream struct {
+	s     string
+	usage llm.Usage
+}
+
+func (s *staticStream) Recv() (string, bool, error) {
+	if s.s == "" {
+		return "", true, nil
+	}
+	v := s.s
+	s.s = ""
+	return v, false, nil
+}
+
+func (s *staticStream) Close() error { return nil }
+
+// Usage implements llm.UsageReporter.
+func (s *staticStream) Usage() (llm.Usage, bool) { return s.usage, true }
@@ -0,0 +1,119 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mycoder/internal/llm"
+)
+
+func fakeOllama(t *testing.T, chat func(w http.ResponseWriter, body map[string]any)) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		chat(w, body)
+	})
+	mux.HandleFunc("/api/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_ = json.NewEncoder(w).Encode(map[string]any{"embedding": []float32{float32(len(body.Prompt)), 0.5, 0.25}})
+	})
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"models": []any{map[string]any{"name": "llama3.1:latest"}}})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	t.Setenv("MYCODER_OLLAMA_BASE_URL", srv.URL)
+	return srv
+}
+
+func TestChatStreamingChunks(t *testing.T) {
+	var got map[string]any
+	fakeOllama(t, func(w http.ResponseWriter, body map[string]any) {
+		got = body
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, tok := range []string{"Hel", "lo", " there"} {
+			fmt.Fprintf(w, `{"model":"m","message":{"role":"assistant","content":%q},"done":false}`+"\n", tok)
+		}
+		fmt.Fprint(w, `{"model":"m","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":9,"eval_count":3}`+"\n")
+	})
+	ctx := llm.WithMaxTokens(context.Background(), 32)
+	st, err := NewFromEnv().Chat(ctx, "m", []llm.Message{{Role: llm.RoleUser, Content: "hi"}}, true, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	var parts []string
+	for {
+		delta, done, err := st.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delta != "" {
+			parts = append(parts, delta)
+		}
+		if done {
+			break
+		}
+	}
+	if strings.Join(parts, "|") != "Hel|lo| there" {
+		t.Fatalf("unexpected deltas: %v", parts)
+	}
+	opts, _ := got["options"].(map[string]any)
+	if got["stream"] != true || opts["num_predict"].(float64) != 32 {
+		t.Fatalf("unexpected request: %v", got)
+	}
+	u, ok := st.(llm.UsageReporter).Usage()
+	if !ok || u.PromptTokens != 9 || u.CompletionTokens != 3 {
+		t.Fatalf("usage=%+v ok=%v", u, ok)
+	}
+}
+
+func TestChatNonStreamingAndError(t *testing.T) {
+	fail := false
+	fakeOllama(t, func(w http.ResponseWriter, body map[string]any) {
+		if fail {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"model 'x' not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`)
+	})
+	c := NewFromEnv()
+	st, err := c.Chat(context.Background(), "m", []llm.Message{{Role: llm.RoleUser, Content: "hi"}}, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, done, _ := st.Recv(); s != "ok" || done {
+		t.Fatalf("got %q done=%v", s, done)
+	}
+	fail = true
+	if _, err := c.Chat(context.Background(), "x", nil, false, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestEmbeddingsShape(t *testing.T) {
+	fakeOllama(t, nil)
+	vecs, err := NewFromEnv().Embeddings(context.Background(), "", []string{"a", "abcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 2 || len(vecs[0]) != 3 || vecs[0][0] != 1 || vecs[1][0] != 4 {
+		t.Fatalf("unexpected embeddings: %v", vecs)
+	}
+	models, err := NewFromEnv().ListModels(context.Background())
+	if err != nil || len(models) != 1 || models[0] != "llama3.1:latest" {
+		t.Fatalf("models=%v err=%v", models, err)
+	}
+}
@@ -9,36 +9,67 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"mycoder/internal/config"
 	"mycoder/internal/llm"
 )
 
 type Client struct {
 	baseURL string
 	apiKey  string
-	http    *http.Client
+	http    *http.Client // chat/completions (long generations)
+	short   *http.Client // embeddings/models
 	minGap  time.Duration
 	lastReq time.Time
 }
 
+// Default client timeouts; override with MYCODER_LLM_TIMEOUT_MS and
+// MYCODER_LLM_SHORT_TIMEOUT_MS.
+const (
+	defaultTimeout      = 60 * time.Second
+	defaultShortTimeout = 30 * time.Second
+)
+
+// DefaultBaseURL is used when MYCODER_OPENAI_BASE_URL is unset (LM Studio's
+// local server default).
+const DefaultBaseURL = "http://localhost:1234/v1"
+
+// defaultChatModel is sent when neither the request nor MYCODER_CHAT_MODEL names a model.
+const defaultChatModel = "qwen2.5-7b-instruct-1m"
+
 func NewFromEnv() *Client {
-	base := os.Getenv("MYCODER_OPENAI_BASE_URL")
+	base := config.Get("MYCODER_OPENAI_BASE_URL")
 	if base == "" {
-		// 기본을 LM Studio 외부 접근 가능한 호스트로 설정
-		base = "http://210.126.109.57:3620/v1"
+		base = DefaultBaseURL
 	}
-	key := os.Getenv("MYCODER_OPENAI_API_KEY")
+	key := config.Get("MYCODER_OPENAI_API_KEY")
 	gap := time.Duration(0)
-	if ms := os.Getenv("MYCODER_LLM_MIN_INTERVAL_MS"); ms != "" {
+	if ms := config.Get("MYCODER_LLM_MIN_INTERVAL_MS"); ms != "" {
 		if v, err := strconv.Atoi(ms); err == nil && v > 0 {
 			gap = time.Duration(v) * time.Millisecond
 		}
 	}
-	return &Client{baseURL: strings.TrimRight(base, "/"), apiKey: key, http: &http.Client{Timeout: 60 * time.Second}, minGap: gap}
+	timeout := defaultTimeout
+	if ms := config.GetInt("MYCODER_LLM_TIMEOUT_MS", 0); ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	short := defaultShortTimeout
+	if ms := config.GetInt("MYCODER_LLM_SHORT_TIMEOUT_MS", 0); ms > 0 {
+		short = time.Duration(ms) * time.Millisecond
+	}
+	if short > timeout {
+		short = timeout
+	}
+	return &Client{
+		baseURL: strings.TrimRight(base, "/"),
+		apiKey:  key,
+		http:    &http.Client{Timeout: timeout},
+		short:   &http.Client{Timeout: short},
+		minGap:  gap,
+	}
 }
 
 type chatStream struct {
@@ -86,10 +117,9 @@ func (s *chatStream) Close() error { return s.body.Close() }
 // Chat implements llm.ChatProvider using OpenAI-compatible API.
 func (c *Client) Chat(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
 	if model == "" {
-		model = os.Getenv("MYCODER_CHAT_MODEL")
+		model = config.Get("MYCODER_CHAT_MODEL")
 		if model == "" {
-			// 기본 모델 설정
-			model = "qwen2.5-7b-instruct-1m"
+			model = defaultChatModel
 		}
 	}
 	reqBody := map[string]any{
@@ -98,13 +128,16 @@ func (c *Client) Chat(ctx context.Context, model string, messages []llm.Message,
 		"temperature": temperature,
 		"stream":      stream,
 	}
+	if n := llm.MaxTokens(ctx); n > 0 {
+		reqBody["max_tokens"] = n
+	}
 	b, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
 	if c.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
-	resp, err := c.do(req)
+	resp, err := c.do(c.http, req)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +156,10 @@ func (c *Client) Chat(ctx context.Context, model string, messages []llm.Message,
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 	dec := json.NewDecoder(resp.Body)
 	if err := dec.Decode(&out); err != nil {
@@ -134,10 +171,25 @@ func (c *Client) Chat(ctx context.Context, model string, messages []llm.Message,
 	if len(out.Choices) > 0 {
 		content = out.Choices[0].Message.Content
 	}
-	return &staticStream{s: content}, nil
+	ss := &staticStream{s: content}
+	if out.Usage != nil {
+		ss.usage = &llm.Usage{PromptTokens: out.Usage.PromptTokens, CompletionTokens: out.Usage.CompletionTokens}
+	}
+	return ss, nil
+}
+
+type staticStream struct {
+	s     string
+	usage *llm.Usage
 }
 
-type staticStream struct{ s string }
+// Usage implements llm.UsageReporter.
+func (s *staticStream) Usage() (llm.Usage, bool) {
+	if s.usage == nil {
+		return llm.Usage{}, false
+	}
+	return *s.usage, true
+}
 
 func (s *staticStream) Recv() (string, bool, error) {
 	if s.s == "" {
@@ -152,7 +204,7 @@ func (s *staticStream) Close() error { return nil }
 // Embeddings implements llm.Embedder using OpenAI-compatible API.
 func (c *Client) Embeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
 	if model == "" {
-		model = os.Getenv("MYCODER_EMBEDDING_MODEL")
+		model = config.Get("MYCODER_EMBEDDING_MODEL")
 		if model == "" {
 			// 기본 임베딩 모델 설정
 			model = "text-embedding-nomic-embed-text-v1.5"
@@ -168,7 +220,7 @@ func (c *Client) Embeddings(ctx context.Context, model string, inputs []string)
 	if c.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
-	resp, err := c.do(req)
+	resp, err := c.do(c.short, req)
 	if err != nil {
 		return nil, err
 	}
@@ -192,27 +244,49 @@ func (c *Client) Embeddings(ctx context.Context, model string, inputs []string)
 	return res, nil
 }
 
-// ListModels fetches available model IDs via GET /models
-func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+// BaseURL returns the configured API base URL without a trailing slash.
+func (c *Client) BaseURL() string { return c.baseURL }
+
+// WithBaseURL returns a copy of c that talks to base instead of the configured URL.
+func (c *Client) WithBaseURL(base string) *Client {
+	cp := *c
+	cp.baseURL = strings.TrimRight(base, "/")
+	return &cp
+}
+
+// ModelsRaw returns the undecoded GET /models response body.
+func (c *Client) ModelsRaw(ctx context.Context) ([]byte, error) {
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
 	if c.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
-	resp, err := c.do(req)
+	resp, err := c.do(c.short, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 	if resp.StatusCode/100 != 2 {
-		data, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("models http %d: %s", resp.StatusCode, string(data))
 	}
+	return data, nil
+}
+
+// ListModels fetches available model IDs via GET /models
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	data, err := c.ModelsRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var out struct {
 		Data []struct {
 			ID string `json:"id"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := json.Unmarshal(data, &out); err != nil {
 		return nil, err
 	}
 	ids := make([]string, 0, len(out.Data))
@@ -225,16 +299,19 @@ func (c *Client) ListModels(ctx context.Context) ([]string, error) {
 // Completion calls POST /completions (non-chat) and adapts to ChatStream interface.
 func (c *Client) Completion(ctx context.Context, model, prompt string, stream bool, temperature float32) (llm.ChatStream, error) {
 	if model == "" {
-		model = os.Getenv("MYCODER_CHAT_MODEL")
+		model = config.Get("MYCODER_CHAT_MODEL")
 	}
 	body := map[string]any{"model": model, "prompt": prompt, "temperature": temperature, "stream": stream}
+	if n := llm.MaxTokens(ctx); n > 0 {
+		body["max_tokens"] = n
+	}
 	b, _ := json.Marshal(body)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/completions", bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
 	if c.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
-	resp, err := c.do(req)
+	resp, err := c.do(c.http, req)
 	if err != nil {
 		return nil, err
 	}
@@ -263,29 +340,63 @@ func (c *Client) Completion(ctx context.Context, model, prompt string, stream bo
 	return &staticStream{s: s}, nil
 }
 
-// do performs the HTTP request with optional min interval and retries on 429/5xx.
-func (c *Client) do(req *http.Request) (*http.Response, error) {
+// maxAttempts bounds how often do sends a request that keeps failing with 429/5xx.
+const maxAttempts = 4
+
+// do performs the HTTP request with hc, honoring the optional min interval and
+// retrying on 429/5xx. The body is buffered once and rewound before every
+// attempt; the last attempt's response is returned as-is. Waits are cut short
+// when the request context ends.
+func (c *Client) do(hc *http.Client, req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+	}
 	if c.minGap > 0 {
 		since := time.Since(c.lastReq)
 		if since < c.minGap {
-			time.Sleep(c.minGap - since)
+			if err := sleepCtx(req.Context(), c.minGap-since); err != nil {
+				return nil, err
+			}
 		}
 	}
-	var resp *http.Response
-	var err error
 	backoff := 200 * time.Millisecond
-	for attempt := 0; attempt < 3; attempt++ {
-		resp, err = c.http.Do(req)
+	for attempt := 0; ; attempt++ {
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := hc.Do(req)
 		c.lastReq = time.Now()
 		if err != nil {
 			return nil, err
 		}
-		if resp.StatusCode != 429 && resp.StatusCode/100 != 5 {
+		if (resp.StatusCode != 429 && resp.StatusCode/100 != 5) || attempt == maxAttempts-1 {
 			return resp, nil
 		}
-		io.Copy(io.Discard, resp.Body)
+		_, _ = io.Copy(io.Discard, resp.Body)
 		resp.Body.Close()
-		time.Sleep(backoff + time.Duration(attempt)*100*time.Millisecond)
+		if err := sleepCtx(req.Context(), backoff+time.Duration(attempt)*100*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepCtx waits for d or until ctx is done, returning ctx.Err() in the latter case.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
-	return c.http.Do(req)
 }
@@ -3,6 +3,7 @@ package openai
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -45,3 +46,57 @@ func TestChatRetriesOn429(t *testing.T) {
 		t.Fatalf("unexpected: %q done=%v err=%v", s, done, err)
 	}
 }
+
+func TestRetryResendsFullBody(t *testing.T) {
+	var calls int32
+	var bodies []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": "ok"}}}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", srv.URL+"/v1")
+
+	st, err := NewFromEnv().Chat(context.Background(), "m", []llm.Message{{Role: llm.RoleUser, Content: "hello body"}}, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if len(bodies) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(bodies))
+	}
+	for i, b := range bodies {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal([]byte(b), &req); err != nil || req.Model != "m" || len(req.Messages) != 1 || req.Messages[0].Content != "hello body" {
+			t.Fatalf("attempt %d carried body %q (err=%v)", i+1, b, err)
+		}
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", srv.URL)
+	if _, err := NewFromEnv().ListModels(context.Background()); err == nil {
+		t.Fatalf("expected error after retries")
+	}
+	if n := atomic.LoadInt32(&calls); n != maxAttempts {
+		t.Fatalf("attempts=%d, want %d", n, maxAttempts)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"mycoder/internal/llm"
@@ -65,3 +66,41 @@ func TestEmbeddings(t *testing.T) {
 		t.Fatalf("unexpected embedding size: %v", vecs)
 	}
 }
+
+func TestDefaultBaseURLIsLocalhost(t *testing.T) {
+	t.Setenv("MYCODER_OPENAI_BASE_URL", "")
+	if got := NewFromEnv().BaseURL(); got != "http://localhost:1234/v1" {
+		t.Fatalf("default base URL=%q, want LM Studio localhost default", got)
+	}
+}
+
+func TestWithBaseURLListModels(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alt/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		calls++
+		if calls == 1 {
+			// first attempt is throttled; the client's retry should recover
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"b-model"},{"id":"a-model"}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", "http://127.0.0.1:1/v1")
+	c := NewFromEnv().WithBaseURL(srv.URL + "/alt/v1/")
+	ids, err := c.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "b-model" || ids[1] != "a-model" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	raw, err := c.ModelsRaw(context.Background())
+	if err != nil || !strings.Contains(string(raw), `"object":"list"`) {
+		t.Fatalf("raw=%s err=%v", raw, err)
+	}
+}
@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientTimeoutFromEnv(t *testing.T) {
+	t.Setenv("MYCODER_OPENAI_BASE_URL", "http://127.0.0.1:1/v1")
+	t.Setenv("MYCODER_LLM_TIMEOUT_MS", "1500")
+	t.Setenv("MYCODER_LLM_SHORT_TIMEOUT_MS", "")
+	c := NewFromEnv()
+	if c.http.Timeout != 1500*time.Millisecond {
+		t.Fatalf("chat timeout=%v, want 1.5s", c.http.Timeout)
+	}
+	// short timeout defaults to 30s but never exceeds the main timeout
+	if c.short.Timeout != 1500*time.Millisecond {
+		t.Fatalf("short timeout=%v, want capped at 1.5s", c.short.Timeout)
+	}
+
+	t.Setenv("MYCODER_LLM_TIMEOUT_MS", "")
+	t.Setenv("MYCODER_LLM_SHORT_TIMEOUT_MS", "250")
+	c = NewFromEnv()
+	if c.http.Timeout != defaultTimeout || c.short.Timeout != 250*time.Millisecond {
+		t.Fatalf("timeouts=%v/%v", c.http.Timeout, c.short.Timeout)
+	}
+}
+
+func TestListModelsRespectsContextDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", srv.URL)
+	c := NewFromEnv()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ListModels(ctx)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("context deadline not honored: %v", time.Since(start))
+	}
+}
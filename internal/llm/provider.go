@@ -32,3 +32,32 @@ type ChatStream interface {
 	Recv() (delta string, done bool, err error)
 	Close() error
 }
+
+type maxTokensKey struct{}
+
+// WithMaxTokens returns a context that caps the completion length of Chat
+// calls made with it; n <= 0 leaves the provider default in place.
+func WithMaxTokens(ctx context.Context, n int) context.Context {
+	if n <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, maxTokensKey{}, n)
+}
+
+// MaxTokens returns the cap set by WithMaxTokens, or 0 when none is set.
+func MaxTokens(ctx context.Context) int {
+	n, _ := ctx.Value(maxTokensKey{}).(int)
+	return n
+}
+
+// Usage holds token counts reported by the provider for one request.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// UsageReporter is optionally implemented by a ChatStream whose provider
+// returned token usage; ok is false when no usage was reported.
+type UsageReporter interface {
+	Usage() (u Usage, ok bool)
+}
@@ -6,9 +6,13 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"mycoder/internal/config"
 )
 
 type Level int
@@ -26,22 +30,34 @@ var nameToLevel = map[string]Level{"debug": Debug, "info": Info, "warn": Warn, "
 type Logger struct {
 	out    io.Writer
 	level  Level
+	text   bool
 	fields map[string]string
 	mu     sync.Mutex
 }
 
+// New returns a stderr logger configured by MYCODER_LOG_LEVEL
+// (debug|info|warn|error, default info) and MYCODER_LOG_FORMAT (json|text,
+// default json; text emits logfmt-style key=value lines).
 func New() *Logger {
 	lvl := Info
-	if v := strings.ToLower(os.Getenv("MYCODER_LOG_LEVEL")); v != "" {
+	if v := strings.ToLower(config.Get("MYCODER_LOG_LEVEL")); v != "" {
 		if l, ok := nameToLevel[v]; ok {
 			lvl = l
 		}
 	}
-	return &Logger{out: os.Stderr, level: lvl, fields: make(map[string]string)}
+	text := strings.EqualFold(config.Get("MYCODER_LOG_FORMAT"), "text")
+	return &Logger{out: os.Stderr, level: lvl, text: text, fields: make(map[string]string)}
+}
+
+// NewWriter returns a logger like New that writes to w instead of stderr.
+func NewWriter(w io.Writer) *Logger {
+	l := New()
+	l.out = w
+	return l
 }
 
 func (l *Logger) With(kv map[string]string) *Logger {
-	child := &Logger{out: l.out, level: l.level, fields: make(map[string]string)}
+	child := &Logger{out: l.out, level: l.level, text: l.text, fields: make(map[string]string)}
 	for k, v := range l.fields {
 		child.fields[k] = v
 	}
@@ -66,12 +82,65 @@ func (l *Logger) write(level Level, msg string, kv map[string]any) {
 		rec[k] = v
 	}
 	maskSecrets(rec)
+	var b []byte
+	if l.text {
+		b = encodeText(rec)
+	} else {
+		b, _ = json.Marshal(rec)
+	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	b, _ := json.Marshal(rec)
 	_, _ = l.out.Write(append(b, '\n'))
 }
 
+// encodeText renders rec as key=value pairs: ts, level and msg first, then the
+// remaining keys sorted. Values with spaces, quotes or '=' are quoted.
+func encodeText(rec map[string]any) []byte {
+	keys := make([]string, 0, len(rec))
+	for k := range rec {
+		if k != "ts" && k != "level" && k != "msg" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{"ts", "level", "msg"}, keys...)
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(textValue(rec[k]))
+	}
+	return []byte(sb.String())
+}
+
+func textValue(v any) string {
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case fmt.Stringer:
+		s = x.String()
+	case error:
+		s = x.Error()
+	case nil, bool, int, int64, float64:
+		s = fmt.Sprint(x)
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			s = fmt.Sprint(x)
+		} else {
+			s = string(b)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
 func (l *Logger) Debug(msg string, kv ...any) { l.write(Debug, msg, toMap(kv...)) }
 func (l *Logger) Info(msg string, kv ...any)  { l.write(Info, msg, toMap(kv...)) }
 func (l *Logger) Warn(msg string, kv ...any)  { l.write(Warn, msg, toMap(kv...)) }
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONFormat(t *testing.T) {
+	t.Setenv("MYCODER_LOG_FORMAT", "json")
+	t.Setenv("MYCODER_LOG_LEVEL", "")
+	var buf bytes.Buffer
+	NewWriter(&buf).With(map[string]string{"request_id": "r1"}).Info("http.req", "method", "GET", "path", "/healthz", "status", 200)
+	var rec map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+		t.Fatalf("not JSON: %v (%q)", err, buf.String())
+	}
+	for _, k := range []string{"ts", "level", "msg", "request_id", "method", "path", "status"} {
+		if _, ok := rec[k]; !ok {
+			t.Fatalf("missing key %q in %v", k, rec)
+		}
+	}
+	if rec["msg"] != "http.req" || rec["level"] != "info" || rec["status"] != float64(200) {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	t.Setenv("MYCODER_LOG_FORMAT", "text")
+	var buf bytes.Buffer
+	NewWriter(&buf).Warn("llm.unreachable", "url", "http://x", "error", "connection refused", "api_key", "sk-abcdefghijklmnop")
+	line := strings.TrimSpace(buf.String())
+	if strings.HasPrefix(line, "{") {
+		t.Fatalf("expected text output, got %q", line)
+	}
+	for _, want := range []string{"level=warn", "msg=llm.unreachable", `error="connection refused"`, "url=http://x", "api_key=sk-a***mnop"} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("missing %q in %q", want, line)
+		}
+	}
+	if !strings.HasPrefix(line, "ts=") {
+		t.Fatalf("ts should lead: %q", line)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	t.Setenv("MYCODER_LOG_LEVEL", "warn")
+	var buf bytes.Buffer
+	lg := NewWriter(&buf)
+	lg.Info("dropped")
+	lg.Debug("dropped")
+	lg.Error("kept")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "kept") {
+		t.Fatalf("level filter not applied: %q", out)
+	}
+}
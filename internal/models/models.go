@@ -24,6 +24,7 @@ const (
 	JobRunning   IndexJobStatus = "running"
 	JobCompleted IndexJobStatus = "completed"
 	JobFailed    IndexJobStatus = "failed"
+	JobCanceled  IndexJobStatus = "canceled" // streamed job whose client disconnected
 )
 
 type IndexJob struct {
@@ -34,6 +35,14 @@ type IndexJob struct {
 	StartedAt time.Time      `json:"startedAt"`
 	EndedAt   *time.Time     `json:"endedAt,omitempty"`
 	Stats     map[string]int `json:"stats,omitempty"`
+	Error     string         `json:"error,omitempty"` // set when Status is failed
+}
+
+// IndexStats summarizes what is stored for a project's lexical index.
+type IndexStats struct {
+	Documents int   `json:"documents"`
+	Chunks    int   `json:"chunks"`
+	Bytes     int64 `json:"bytes"`
 }
 
 type Document struct {
@@ -49,6 +58,9 @@ type SearchResult struct {
 	Preview   string  `json:"preview,omitempty"`
 	StartLine int     `json:"startLine,omitempty"`
 	EndLine   int     `json:"endLine,omitempty"`
+	// Content is the full file, attached only on request (/search?includeContent=1).
+	Content        string `json:"content,omitempty"`
+	ContentOmitted bool   `json:"contentOmitted,omitempty"` // file exceeded the content size limit
 }
 
 // Knowledge entities for curated, verified information.
@@ -65,6 +77,58 @@ type Knowledge struct {
 	Files      string  `json:"files,omitempty"`
 	Symbols    string  `json:"symbols,omitempty"`
 	Tags       string  `json:"tags,omitempty"`
+	ApprovedBy string  `json:"approvedBy,omitempty"`
+	ApprovedAt string  `json:"approvedAt,omitempty"`
+}
+
+// KnowledgeApproval is one entry of the knowledge approval audit log.
+type KnowledgeApproval struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"projectID"`
+	KnowledgeID string `json:"knowledgeID"`
+	Title       string `json:"title,omitempty"`
+	ApprovedBy  string `json:"approvedBy,omitempty"`
+	ApprovedAt  string `json:"approvedAt"`
+}
+
+// MCPCall is one audited /mcp/call invocation. Params holds the call's
+// param keys with redacted or truncated values.
+type MCPCall struct {
+	ID         int64             `json:"id"`
+	Tool       string            `json:"tool"`
+	Params     map[string]string `json:"params,omitempty"`
+	Outcome    string            `json:"outcome"` // ok|error|denied
+	Error      string            `json:"error,omitempty"`
+	Status     int               `json:"status"`
+	DurationMs int64             `json:"durationMs"`
+	CalledAt   string            `json:"calledAt"`
+}
+
+// Conversation is a stored chat history header.
+type Conversation struct {
+	ID           string `json:"id"`
+	ProjectID    string `json:"projectID"`
+	Title        string `json:"title,omitempty"`
+	Pinned       bool   `json:"pinned"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt,omitempty"`
+	MessageCount int    `json:"messageCount"`
+}
+
+// ConversationMessage is one stored turn of a conversation.
+type ConversationMessage struct {
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// KnowledgeUpdate carries optional field changes; nil fields are left as-is.
+type KnowledgeUpdate struct {
+	Title      *string  `json:"title,omitempty"`
+	Text       *string  `json:"text,omitempty"`
+	TrustScore *float64 `json:"trustScore,omitempty"`
+	Pinned     *bool    `json:"pinned,omitempty"`
 }
 
 // Symbol entity for code navigation and references.
@@ -15,57 +15,243 @@ func ApplyToContent(original string, hunks []UnifiedHunk) (string, int, int, err
 // ApplyOptions controls loose comparisons when applying hunks.
 type ApplyOptions struct {
 	IgnoreWhitespace bool
+	// Fuzz is the maximum number of lines a hunk may be shifted from its
+	// declared position when its context does not match there. 0 keeps strict matching.
+	Fuzz int
+}
+
+// HunkResult reports where a hunk was applied.
+type HunkResult struct {
+	Index    int `json:"index"`
+	OldStart int `json:"oldStart"`
+	// Offset is the number of lines the hunk was shifted from OldStart.
+	Offset int `json:"offset"`
+	Add    int `json:"add"`
+	Del    int `json:"del"`
+}
+
+// Reject describes a hunk that could not be applied, with the lines it
+// expected on the old side and what the content actually held there.
+type Reject struct {
+	Index    int         `json:"index"`
+	OldStart int         `json:"oldStart"`
+	OldCount int         `json:"oldCount"`
+	Reason   string      `json:"reason"`
+	Expected []string    `json:"expected"`
+	Actual   []string    `json:"actual"`
+	Hunk     UnifiedHunk `json:"-"`
+}
+
+// ApplyResult is the detailed outcome of applying hunks to a content.
+type ApplyResult struct {
+	Content string
+	Add     int
+	Del     int
+	Hunks   []HunkResult
+	Rejects []Reject
 }
 
 // ApplyToContentOpt applies hunks with options.
 func ApplyToContentOpt(original string, hunks []UnifiedHunk, opt ApplyOptions) (string, int, int, error) {
+	res, err := ApplyToContentDetailed(original, hunks, opt)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return res.Content, res.Add, res.Del, nil
+}
+
+// ApplyToContentDetailed applies hunks with options and reports per-hunk offsets.
+// It fails on the first hunk that does not apply.
+func ApplyToContentDetailed(original string, hunks []UnifiedHunk, opt ApplyOptions) (ApplyResult, error) {
+	return applyHunks(original, hunks, opt, false)
+}
+
+// ApplyToContentPartial applies every hunk that matches and records the
+// others in Rejects instead of aborting. Content reflects the applied hunks only.
+func ApplyToContentPartial(original string, hunks []UnifiedHunk, opt ApplyOptions) ApplyResult {
+	res, _ := applyHunks(original, hunks, opt, true)
+	return res
+}
+
+func applyHunks(original string, hunks []UnifiedHunk, opt ApplyOptions, partial bool) (ApplyResult, error) {
 	// split into lines without dropping trailing last empty line
 	// We operate on logical lines without the trailing '\n'. We'll rejoin with '\n'.
 	src := splitLines(original)
 	var out []string
 	cur := 1 // 1-based
+	shift := 0
 	totalAdd, totalDel := 0, 0
-	for _, h := range hunks {
-		// copy unchanged up to h.OldStart-1
-		if h.OldStart < cur {
-			return "", 0, 0, fmt.Errorf("overlapping hunks or invalid hunk start: have %d need %d", cur, h.OldStart)
+	var results []HunkResult
+	var rejects []Reject
+	for i, h := range hunks {
+		want := h.OldStart + shift
+		var start int
+		var err error
+		if want < cur {
+			// report where the hunk lands after earlier offsets, not its header line
+			err = fmt.Errorf("overlapping hunks or invalid hunk start: hunk at line %d (header line %d) overlaps lines before %d", want, h.OldStart, cur)
+		} else {
+			start, err = locateHunk(src, h, want, cur, opt)
 		}
-		for cur <= len(src) && cur < h.OldStart {
+		if err != nil {
+			if !partial {
+				return ApplyResult{}, err
+			}
+			rejects = append(rejects, newReject(src, i, h, want, err))
+			continue
+		}
+		shift = start - h.OldStart
+		hr := HunkResult{Index: i, OldStart: h.OldStart, Offset: shift}
+		// copy unchanged up to start-1
+		for cur <= len(src) && cur < start {
 			out = append(out, src[cur-1])
 			cur++
 		}
-		// apply hunk lines
+		// apply hunk lines (already verified by locateHunk)
 		for _, ln := range h.Lines {
 			switch ln.Kind {
 			case Context:
-				if cur > len(src) || !eqLineWithOpt(src[cur-1], ln.Content, opt) {
-					return "", 0, 0, errors.New("context mismatch (conflict)")
-				}
 				out = append(out, trimCR(src[cur-1]))
 				cur++
 			case Deleted:
-				if cur > len(src) || !eqLineWithOpt(src[cur-1], ln.Content, opt) {
-					return "", 0, 0, errors.New("delete target mismatch (conflict)")
-				}
 				cur++
-				totalDel++
+				hr.Del++
 			case Added:
 				out = append(out, ln.Content)
-				totalAdd++
+				hr.Add++
 			}
 		}
+		totalAdd += hr.Add
+		totalDel += hr.Del
+		results = append(results, hr)
 	}
 	// copy the rest
 	for cur <= len(src) {
 		out = append(out, trimCR(src[cur-1]))
 		cur++
 	}
-	// rejoin with newline if original had newline; if original ended with newline, keep it; else keep no extra
+	// rejoin with newline if original had newline; if original ended with newline, keep it; else keep no extra.
+	// Applied hunks carrying "\ No newline at end of file" markers override this.
 	res := strings.Join(out, "\n")
-	if hasTrailingNewline(original) {
+	if trailingNewline(original, hunks, results) && len(out) > 0 {
 		res += "\n"
 	}
-	return res, totalAdd, totalDel, nil
+	return ApplyResult{Content: res, Add: totalAdd, Del: totalDel, Hunks: results, Rejects: rejects}, nil
+}
+
+// trailingNewline decides whether the patched content ends with a newline.
+// A marker on a new-side line (context or added) means the result has none;
+// a marker only on a deleted line means the old last line was replaced by a
+// terminated one. Without markers the original state is kept, except that
+// content created from empty is newline-terminated as in a regular diff.
+func trailingNewline(original string, hunks []UnifiedHunk, applied []HunkResult) bool {
+	oldMarker, newMarker := false, false
+	for _, hr := range applied {
+		for _, ln := range hunks[hr.Index].Lines {
+			if !ln.NoEOL {
+				continue
+			}
+			if ln.Kind == Deleted {
+				oldMarker = true
+			} else {
+				newMarker = true
+			}
+		}
+	}
+	switch {
+	case newMarker:
+		return false
+	case oldMarker:
+		return true
+	case original == "":
+		return true
+	}
+	return hasTrailingNewline(original)
+}
+
+// newReject captures the expected old-side lines of h and the lines found at
+// its intended position in src.
+func newReject(src []string, index int, h UnifiedHunk, at int, err error) Reject {
+	rj := Reject{Index: index, OldStart: h.OldStart, OldCount: h.OldCount, Reason: err.Error(), Hunk: h}
+	for _, ln := range h.Lines {
+		if ln.Kind != Added {
+			rj.Expected = append(rj.Expected, ln.Content)
+		}
+	}
+	if at < 1 {
+		at = 1
+	}
+	for i := at; i < at+len(rj.Expected) && i <= len(src); i++ {
+		rj.Actual = append(rj.Actual, trimCR(src[i-1]))
+	}
+	return rj
+}
+
+// FormatRejects renders rejected hunks as a unified diff suitable for a .rej file.
+func FormatRejects(path string, rejects []Reject) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+	for _, rj := range rejects {
+		h := rj.Hunk
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldCount, h.NewStart, h.NewCount)
+		for _, ln := range h.Lines {
+			switch ln.Kind {
+			case Context:
+				b.WriteString(" ")
+			case Added:
+				b.WriteString("+")
+			case Deleted:
+				b.WriteString("-")
+			}
+			b.WriteString(ln.Content)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// locateHunk finds the 1-based line where the hunk's old side matches.
+// The declared position is tried first, then positions up to opt.Fuzz lines
+// away (nearest first), never before min.
+func locateHunk(src []string, h UnifiedHunk, want, min int, opt ApplyOptions) (int, error) {
+	err := matchHunkAt(src, h, want, opt)
+	if err == nil || opt.Fuzz <= 0 {
+		return want, err
+	}
+	for d := 1; d <= opt.Fuzz; d++ {
+		for _, s := range []int{want - d, want + d} {
+			if s < min || s < 1 {
+				continue
+			}
+			if matchHunkAt(src, h, s, opt) == nil {
+				return s, nil
+			}
+		}
+	}
+	return 0, err
+}
+
+// matchHunkAt verifies the hunk's context and deleted lines starting at line start.
+func matchHunkAt(src []string, h UnifiedHunk, start int, opt ApplyOptions) error {
+	cur := start
+	if cur < 1 {
+		cur = 1
+	}
+	for _, ln := range h.Lines {
+		switch ln.Kind {
+		case Context:
+			if cur > len(src) || !eqLineWithOpt(src[cur-1], ln.Content, opt) {
+				return errors.New("context mismatch (conflict)")
+			}
+			cur++
+		case Deleted:
+			if cur > len(src) || !eqLineWithOpt(src[cur-1], ln.Content, opt) {
+				return errors.New("delete target mismatch (conflict)")
+			}
+			cur++
+		}
+	}
+	return nil
 }
 
 func splitLines(s string) []string {
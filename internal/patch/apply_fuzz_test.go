@@ -0,0 +1,110 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyFuzzShiftedContext(t *testing.T) {
+	// the file gained a header line since the diff was generated
+	orig := "header\na\nb\nc\n"
+	hunks := []UnifiedHunk{{OldStart: 1, OldCount: 3, NewStart: 1, NewCount: 3, Lines: []UnifiedLine{
+		{Kind: Context, Content: "a"},
+		{Kind: Deleted, Content: "b"},
+		{Kind: Added, Content: "B"},
+		{Kind: Context, Content: "c"},
+	}}}
+	if _, _, _, err := ApplyToContentOpt(orig, hunks, ApplyOptions{}); err == nil {
+		t.Fatalf("expected strict apply to fail")
+	}
+	res, err := ApplyToContentDetailed(orig, hunks, ApplyOptions{Fuzz: 1})
+	if err != nil {
+		t.Fatalf("fuzzy apply: %v", err)
+	}
+	if res.Content != "header\na\nB\nc\n" {
+		t.Fatalf("out=%q", res.Content)
+	}
+	if len(res.Hunks) != 1 || res.Hunks[0].Offset != 1 {
+		t.Fatalf("expected offset +1, got %+v", res.Hunks)
+	}
+}
+
+func TestApplyFuzzOffsetCarriesToNextHunk(t *testing.T) {
+	orig := "x\n1\n2\n3\n4\n5\n6\n"
+	hunks := []UnifiedHunk{
+		{OldStart: 1, OldCount: 1, NewStart: 1, NewCount: 1, Lines: []UnifiedLine{
+			{Kind: Deleted, Content: "1"},
+			{Kind: Added, Content: "one"},
+		}},
+		{OldStart: 5, OldCount: 1, NewStart: 5, NewCount: 1, Lines: []UnifiedLine{
+			{Kind: Deleted, Content: "5"},
+			{Kind: Added, Content: "five"},
+		}},
+	}
+	res, err := ApplyToContentDetailed(orig, hunks, ApplyOptions{Fuzz: 2})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if res.Content != "x\none\n2\n3\n4\nfive\n6\n" {
+		t.Fatalf("out=%q", res.Content)
+	}
+	if res.Hunks[1].Offset != 1 {
+		t.Fatalf("expected second hunk offset +1, got %+v", res.Hunks)
+	}
+}
+
+func TestApplyPartialCollectsRejects(t *testing.T) {
+	orig := "a\nb\nc\nd\ne\n"
+	hunks := []UnifiedHunk{
+		{OldStart: 1, OldCount: 2, NewStart: 1, NewCount: 2, Lines: []UnifiedLine{
+			{Kind: Context, Content: "a"},
+			{Kind: Deleted, Content: "b"},
+			{Kind: Added, Content: "B"},
+		}},
+		{OldStart: 4, OldCount: 1, NewStart: 4, NewCount: 1, Lines: []UnifiedLine{
+			{Kind: Deleted, Content: "zzz"},
+			{Kind: Added, Content: "D"},
+		}},
+	}
+	res := ApplyToContentPartial(orig, hunks, ApplyOptions{})
+	if res.Content != "a\nB\nc\nd\ne\n" {
+		t.Fatalf("out=%q", res.Content)
+	}
+	if len(res.Hunks) != 1 || res.Hunks[0].Index != 0 || res.Hunks[0].Add != 1 || res.Hunks[0].Del != 1 {
+		t.Fatalf("applied hunks: %+v", res.Hunks)
+	}
+	if len(res.Rejects) != 1 {
+		t.Fatalf("rejects: %+v", res.Rejects)
+	}
+	rj := res.Rejects[0]
+	if rj.Index != 1 || rj.OldStart != 4 || len(rj.Expected) != 1 || rj.Expected[0] != "zzz" || len(rj.Actual) != 1 || rj.Actual[0] != "d" {
+		t.Fatalf("reject: %+v", rj)
+	}
+	rej := FormatRejects("x.txt", res.Rejects)
+	if rej != "--- a/x.txt\n+++ b/x.txt\n@@ -4,1 +4,1 @@\n-zzz\n+D\n" {
+		t.Fatalf("rej=%q", rej)
+	}
+}
+
+func TestApplyOverlapReportsShiftedStart(t *testing.T) {
+	orig := "h1\nh2\na\nb\nc\nd\n"
+	hunks := []UnifiedHunk{
+		{OldStart: 1, OldCount: 3, NewStart: 1, NewCount: 3, Lines: []UnifiedLine{
+			{Kind: Context, Content: "a"},
+			{Kind: Deleted, Content: "b"},
+			{Kind: Added, Content: "B"},
+			{Kind: Context, Content: "c"},
+		}},
+		// shifted by +2 like the first hunk, it would start at line 4, inside the first hunk
+		{OldStart: 2, OldCount: 1, NewStart: 2, NewCount: 1, Lines: []UnifiedLine{
+			{Kind: Context, Content: "b"},
+		}},
+	}
+	_, err := ApplyToContentDetailed(orig, hunks, ApplyOptions{Fuzz: 2})
+	if err == nil {
+		t.Fatalf("expected overlap error")
+	}
+	if !strings.Contains(err.Error(), "hunk at line 4 (header line 2)") {
+		t.Fatalf("error should report the shifted start: %v", err)
+	}
+}
@@ -28,6 +28,15 @@ func GenerateUnified(oldText, newText, path string, context int, ignoreCRLF bool
 	}
 	a := toLines(oldText)
 	b := toLines(newText)
+	// a last line without trailing newline only equals another such line
+	aNoEOL := len(a) > 0 && !hasTrailingNewline(oldText)
+	bNoEOL := len(b) > 0 && !hasTrailingNewline(newText)
+	same := func(i, j int) bool {
+		if a[i] != b[j] {
+			return false
+		}
+		return (aNoEOL && i == len(a)-1) == (bNoEOL && j == len(b)-1)
+	}
 	// LCS table
 	n, m := len(a), len(b)
 	dp := make([][]int, n+1)
@@ -36,7 +45,7 @@ func GenerateUnified(oldText, newText, path string, context int, ignoreCRLF bool
 	}
 	for i := n - 1; i >= 0; i-- {
 		for j := m - 1; j >= 0; j-- {
-			if a[i] == b[j] {
+			if same(i, j) {
 				dp[i][j] = dp[i+1][j+1] + 1
 			} else if dp[i+1][j] >= dp[i][j+1] {
 				dp[i][j] = dp[i+1][j]
@@ -47,30 +56,33 @@ func GenerateUnified(oldText, newText, path string, context int, ignoreCRLF bool
 	}
 	// backtrack to get edit script
 	type edit struct {
-		kind byte
-		s    string
+		kind  byte
+		s     string
+		noEOL bool
 	} // ' ' context, '-' del, '+' add
+	lastA := func(i int) bool { return aNoEOL && i == n-1 }
+	lastB := func(j int) bool { return bNoEOL && j == m-1 }
 	i, j := 0, 0
 	var script []edit
 	for i < n && j < m {
-		if a[i] == b[j] {
-			script = append(script, edit{' ', a[i]})
+		if same(i, j) {
+			script = append(script, edit{' ', a[i], lastA(i)})
 			i++
 			j++
 		} else if dp[i+1][j] >= dp[i][j+1] {
-			script = append(script, edit{'-', a[i]})
+			script = append(script, edit{'-', a[i], lastA(i)})
 			i++
 		} else {
-			script = append(script, edit{'+', b[j]})
+			script = append(script, edit{'+', b[j], lastB(j)})
 			j++
 		}
 	}
 	for i < n {
-		script = append(script, edit{'-', a[i]})
+		script = append(script, edit{'-', a[i], lastA(i)})
 		i++
 	}
 	for j < m {
-		script = append(script, edit{'+', b[j]})
+		script = append(script, edit{'+', b[j], lastB(j)})
 		j++
 	}
 	// group into hunks with context
@@ -141,6 +153,9 @@ func GenerateUnified(oldText, newText, path string, context int, ignoreCRLF bool
 				break
 			}
 		}
+		// header starts at the first leading context line
+		aStart -= cpre
+		bStart -= cpre
 		// main region
 		for t := L; t < R && t < len(script); t++ {
 			lines = append(lines, script[t])
@@ -175,6 +190,9 @@ func GenerateUnified(oldText, newText, path string, context int, ignoreCRLF bool
 			bld.WriteByte(e.kind)
 			bld.WriteString(e.s)
 			bld.WriteByte('\n')
+			if e.noEOL {
+				bld.WriteString(NoNewlineMarker + "\n")
+			}
 		}
 	}
 	return bld.String()
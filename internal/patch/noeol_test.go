@@ -0,0 +1,37 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNoNewlineRoundTrip(t *testing.T) {
+	cases := []struct{ name, old, new string }{
+		{"drop final newline", "a\nb\nc\n", "a\nb\nc"},
+		{"add final newline", "a\nb\nc", "a\nb\nc\n"},
+		{"change last line without newline", "a\nb\nc", "a\nb\nC"},
+		{"both lack newline, change earlier line", "a\nb\nc", "A\nb\nc"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := GenerateUnified(tc.old, tc.new, "f.txt", 3, false)
+			if diff == "" {
+				t.Fatalf("expected diff")
+			}
+			if !strings.Contains(diff, NoNewlineMarker) {
+				t.Fatalf("expected marker in diff:\n%s", diff)
+			}
+			files, err := ParseUnified(diff)
+			if err != nil || len(files) != 1 {
+				t.Fatalf("parse: %v", err)
+			}
+			out, _, _, err := ApplyToContent(tc.old, files[0].Hunks)
+			if err != nil {
+				t.Fatalf("apply: %v\n%s", err, diff)
+			}
+			if out != tc.new {
+				t.Fatalf("got %q want %q\n%s", out, tc.new, diff)
+			}
+		})
+	}
+}
@@ -15,9 +15,14 @@ const (
 	Deleted
 )
 
+// NoNewlineMarker follows a diff line that is not terminated by a newline.
+const NoNewlineMarker = "\\ No newline at end of file"
+
 type UnifiedLine struct {
 	Kind    LineKind
 	Content string
+	// NoEOL is set when the line was followed by NoNewlineMarker.
+	NoEOL bool
 }
 
 type UnifiedHunk struct {
@@ -102,6 +107,13 @@ func ParseUnified(diff string) ([]UnifiedFile, error) {
 					lines = append(lines, UnifiedLine{Kind: Context, Content: ""})
 					continue
 				}
+				if l[0] == '\\' {
+					// "\ No newline at end of file" applies to the previous line
+					if len(lines) > 0 {
+						lines[len(lines)-1].NoEOL = true
+					}
+					continue
+				}
 				switch l[0] {
 				case ' ':
 					lines = append(lines, UnifiedLine{Kind: Context, Content: l[1:]})
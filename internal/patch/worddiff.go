@@ -0,0 +1,87 @@
+package patch
+
+import "unicode"
+
+// Segment is a span of a line produced by WordDiff. Changed is true when the
+// span does not appear in the other line.
+type Segment struct {
+	Text    string
+	Changed bool
+}
+
+// WordDiff compares two lines word by word using a longest common subsequence
+// and returns the segments of each line, marking spans that differ.
+func WordDiff(oldLine, newLine string) (oldSegs, newSegs []Segment) {
+	a := splitWords(oldLine)
+	b := splitWords(newLine)
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			oldSegs = appendSegment(oldSegs, a[i], false)
+			newSegs = appendSegment(newSegs, b[j], false)
+			i++
+			j++
+		case j >= m || (i < n && dp[i+1][j] >= dp[i][j+1]):
+			oldSegs = appendSegment(oldSegs, a[i], true)
+			i++
+		default:
+			newSegs = appendSegment(newSegs, b[j], true)
+			j++
+		}
+	}
+	return oldSegs, newSegs
+}
+
+// appendSegment merges adjacent tokens with the same Changed flag.
+func appendSegment(segs []Segment, tok string, changed bool) []Segment {
+	if n := len(segs); n > 0 && segs[n-1].Changed == changed {
+		segs[n-1].Text += tok
+		return segs
+	}
+	return append(segs, Segment{Text: tok, Changed: changed})
+}
+
+// splitWords tokenizes a line into runs of word characters, runs of
+// whitespace, and single punctuation characters.
+func splitWords(s string) []string {
+	var toks []string
+	rs := []rune(s)
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+	for i := 0; i < len(rs); {
+		c := class(rs[i])
+		j := i + 1
+		if c != 0 {
+			for j < len(rs) && class(rs[j]) == c {
+				j++
+			}
+		}
+		toks = append(toks, string(rs[i:j]))
+		i = j
+	}
+	return toks
+}
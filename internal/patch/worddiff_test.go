@@ -0,0 +1,40 @@
+package patch
+
+import "testing"
+
+func TestWordDiffSimilarLines(t *testing.T) {
+	oldSegs, newSegs := WordDiff("return a + b, nil", "return a - b, err")
+	want := func(segs []Segment) []string {
+		var out []string
+		for _, s := range segs {
+			if s.Changed {
+				out = append(out, s.Text)
+			}
+		}
+		return out
+	}
+	if got := want(oldSegs); len(got) != 2 || got[0] != "+" || got[1] != "nil" {
+		t.Fatalf("old changed spans: %q", got)
+	}
+	if got := want(newSegs); len(got) != 2 || got[0] != "-" || got[1] != "err" {
+		t.Fatalf("new changed spans: %q", got)
+	}
+	// segments must reproduce the lines exactly
+	join := func(segs []Segment) string {
+		s := ""
+		for _, sg := range segs {
+			s += sg.Text
+		}
+		return s
+	}
+	if join(oldSegs) != "return a + b, nil" || join(newSegs) != "return a - b, err" {
+		t.Fatalf("segments do not rebuild lines: %q / %q", join(oldSegs), join(newSegs))
+	}
+}
+
+func TestWordDiffIdentical(t *testing.T) {
+	oldSegs, newSegs := WordDiff("same line", "same line")
+	if len(oldSegs) != 1 || oldSegs[0].Changed || len(newSegs) != 1 || newSegs[0].Changed {
+		t.Fatalf("unexpected segments: %+v %+v", oldSegs, newSegs)
+	}
+}
@@ -14,5 +14,5 @@ func NewBM25(s LexicalSearcher) *BM25Retriever { return &BM25Retriever{s: s} }
 func (r *BM25Retriever) Retrieve(ctx context.Context, projectID string, query string, k int) ([]Result, error) {
 	// ctx reserved for future store methods supporting context.
 	_ = ctx
-	return r.s.Search(projectID, query, k), nil
+	return r.s.Search(projectID, query, k)
 }
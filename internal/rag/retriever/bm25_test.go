@@ -8,7 +8,9 @@ import (
 
 type fakeSearch struct{ res []models.SearchResult }
 
-func (f fakeSearch) Search(projectID, query string, k int) []models.SearchResult { return f.res }
+func (f fakeSearch) Search(projectID, query string, k int) ([]models.SearchResult, error) {
+	return f.res, nil
+}
 
 func TestBM25Retriever(t *testing.T) {
 	want := []models.SearchResult{{Path: "a.txt", Score: 1.23}}
@@ -2,7 +2,7 @@ package retriever
 
 import (
 	"context"
-	"os"
+	"mycoder/internal/config"
 	"sort"
 	"strconv"
 )
@@ -17,7 +17,7 @@ type HybridRetriever struct {
 
 func NewHybrid(lex Retriever, knn Retriever) *HybridRetriever {
 	a := 0.5
-	if v := os.Getenv("MYCODER_HYBRID_ALPHA"); v != "" {
+	if v := config.Get("MYCODER_HYBRID_ALPHA"); v != "" {
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
 			a = f
 		}
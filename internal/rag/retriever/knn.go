@@ -2,9 +2,9 @@ package retriever
 
 import (
 	"context"
+	"mycoder/internal/config"
 	"mycoder/internal/llm"
 	"mycoder/internal/vectorstore"
-	"os"
 )
 
 // KNNRetriever uses a VectorStore and an Embedder to perform semantic search.
@@ -12,10 +12,14 @@ type KNNRetriever struct {
 	vs    vectorstore.VectorStore
 	emb   llm.Embedder
 	model string
+	// OnDimMismatch, when set, is called with the query dimension and the
+	// stored vector stats whenever no stored vectors share the query dimension
+	// (e.g. the embedding model changed without re-indexing).
+	OnDimMismatch func(queryDim int, stored []vectorstore.ModelStats)
 }
 
 func NewKNN(vs vectorstore.VectorStore, emb llm.Embedder) *KNNRetriever {
-	model := os.Getenv("MYCODER_EMBEDDING_MODEL")
+	model := config.Get("MYCODER_EMBEDDING_MODEL")
 	if model == "" {
 		model = "text-embedding-3-small"
 	}
@@ -28,6 +32,13 @@ func (r *KNNRetriever) Retrieve(ctx context.Context, projectID string, query str
 		// graceful fallback: no semantic results when embeddings unavailable
 		return nil, nil
 	}
+	if stored, ok := r.dimsMatch(ctx, projectID, len(vecs[0])); !ok {
+		// skip semantic search so hybrid retrieval falls back to lexical
+		if r.OnDimMismatch != nil {
+			r.OnDimMismatch(len(vecs[0]), stored)
+		}
+		return nil, nil
+	}
 	res, err := r.vs.Search(ctx, projectID, vecs[0], k)
 	if err != nil {
 		return nil, err
@@ -39,3 +50,22 @@ func (r *KNNRetriever) Retrieve(ctx context.Context, projectID string, query str
 	}
 	return out, nil
 }
+
+// dimsMatch reports false when the store holds vectors for the project
+// but none with the query dimension. Stores without stats are assumed to match.
+func (r *KNNRetriever) dimsMatch(ctx context.Context, projectID string, dim int) ([]vectorstore.ModelStats, bool) {
+	sr, ok := r.vs.(vectorstore.StatsReporter)
+	if !ok {
+		return nil, true
+	}
+	stats, err := sr.Stats(ctx, projectID)
+	if err != nil || len(stats) == 0 {
+		return stats, true
+	}
+	for _, s := range stats {
+		if s.Dim == dim {
+			return stats, true
+		}
+	}
+	return stats, false
+}
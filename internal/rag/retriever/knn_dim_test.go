@@ -0,0 +1,68 @@
+package retriever
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/store"
+	"mycoder/internal/vectorstore"
+)
+
+type dimEmbed struct{ dim int }
+
+func (e dimEmbed) Embeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	v := make([]float32, e.dim)
+	for i := range v {
+		v[i] = 0.01
+	}
+	return [][]float32{v}, nil
+}
+
+func TestKNNDimMismatchFallsBackToLexical(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := vectorstore.NewSQLite(st.DB())
+	vec := make([]float32, 384)
+	for i := range vec {
+		vec[i] = 0.01
+	}
+	ctx := context.Background()
+	if err := vs.Upsert(ctx, []vectorstore.UpsertItem{{ProjectID: "p", DocID: "vec.go", ChunkID: "c1", Vector: vec, Dim: 384, Model: "small"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// matching dimension still searches the store
+	if got, _ := NewKNN(vs, dimEmbed{dim: 384}).Retrieve(ctx, "p", "q", 5); len(got) != 1 || got[0].Path != "vec.go" {
+		t.Fatalf("384-dim query: %+v", got)
+	}
+
+	knn := NewKNN(vs, dimEmbed{dim: 768})
+	var queryDim, calls int
+	var stored []vectorstore.ModelStats
+	knn.OnDimMismatch = func(dim int, s []vectorstore.ModelStats) {
+		calls++
+		queryDim, stored = dim, s
+	}
+	got, err := knn.Retrieve(ctx, "p", "q", 5)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("768-dim query: got=%+v err=%v", got, err)
+	}
+	if calls != 1 || queryDim != 768 || len(stored) != 1 || stored[0].Dim != 384 || stored[0].Model != "small" {
+		t.Fatalf("mismatch hook: calls=%d dim=%d stored=%+v", calls, queryDim, stored)
+	}
+
+	lex := fakeRet{out: []Result{{Path: "lex.go", Score: 1.0}}}
+	res, err := NewHybridWithAlpha(lex, knn, 0.5).Retrieve(ctx, "p", "q", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Path != "lex.go" {
+		t.Fatalf("expected lexical-only results, got %+v", res)
+	}
+	if calls != 2 {
+		t.Fatalf("expected hook per mismatched query, calls=%d", calls)
+	}
+}
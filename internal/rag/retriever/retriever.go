@@ -16,5 +16,5 @@ type Retriever interface {
 // LexicalSearcher is the minimal capability needed from a backing store.
 // It mirrors the existing Store.Search(projectID, query, k).
 type LexicalSearcher interface {
-	Search(projectID, query string, k int) []models.SearchResult
+	Search(projectID, query string, k int) ([]models.SearchResult, error)
 }
@@ -48,3 +48,56 @@ func TestReadOnlyBlocksFSWrite(t *testing.T) {
 		t.Fatalf("expected 403 in read-only, got %d, body=%s", rr.Code, rr.Body.String())
 	}
 }
+
+func TestAuthMultipleLabeledTokens(t *testing.T) {
+	t.Setenv("MYCODER_API_TOKEN", "")
+	t.Setenv("MYCODER_API_TOKENS", "alice:tok-a, bob:tok-b")
+	api := NewAPI(store.New(), nil)
+	mux := api.mux()
+
+	req := httptest.NewRequest(http.MethodGet, "/projects", nil)
+	req.Header.Set("Authorization", "Bearer tok-b")
+	req, ai := withAuthInfo(req)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for listed token, got %d", rr.Code)
+	}
+	if ai.label != "bob" {
+		t.Fatalf("expected label bob in context, got %q", ai.label)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/projects?token=tok-a", nil)
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for query token, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/projects", nil)
+	req.Header.Set("Authorization", "Bearer tok-x")
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown token, got %d", rr.Code)
+	}
+}
+
+func TestAuthLegacyTokenAlongsideList(t *testing.T) {
+	t.Setenv("MYCODER_API_TOKEN", "legacy")
+	t.Setenv("MYCODER_API_TOKENS", "alice:tok-a")
+	api := NewAPI(store.New(), nil)
+	mux := api.mux()
+
+	req := httptest.NewRequest(http.MethodGet, "/projects", nil)
+	req.Header.Set("Authorization", "Bearer legacy")
+	req, ai := withAuthInfo(req)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for legacy token, got %d", rr.Code)
+	}
+	if authLabel(req.Context()) != "default" || ai.label != "default" {
+		t.Fatalf("expected default label, got %q", ai.label)
+	}
+}
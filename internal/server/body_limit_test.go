@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestBodyLimitRejectsOversizedBody(t *testing.T) {
+	t.Setenv("MYCODER_MAX_BODY_BYTES", "1024")
+	st := store.New()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	h := bodyLimitMiddleware(NewAPI(st, nil).mux())
+	body := `{"projectID":"` + p.ID + `","path":"big.txt","content":"` + strings.Repeat("x", 4096) + `"}`
+
+	// declared Content-Length is rejected before the handler runs
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/write", strings.NewReader(body)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	// unknown length (chunked): the handler's decode failure is reported as 413
+	req := httptest.NewRequest(http.MethodPost, "/fs/write", strings.NewReader(body))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "body_too_large") {
+		t.Fatalf("expected 413 body_too_large, got %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	// small bodies pass through
+	small := `{"projectID":"` + p.ID + `","path":"ok.txt","content":"hi"}`
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/write", bytes.NewReader([]byte(small))))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for small body, got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
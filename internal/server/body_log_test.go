@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mylog "mycoder/internal/log"
+	"mycoder/internal/store"
+)
+
+func TestBodyLoggingTruncatesAndRedacts(t *testing.T) {
+	t.Setenv("MYCODER_LOG_BODIES", "1")
+	t.Setenv("MYCODER_LOG_FORMAT", "json")
+	var buf bytes.Buffer
+	h := logMiddleware(bodyLogMiddleware(NewAPI(store.New(), nil).mux(), mylog.NewWriter(&buf)))
+
+	body := `{"name":"demo","rootPath":"/tmp/demo","apiKey":"sk-verysecretvalue123456","pad":"` + strings.Repeat("x", 3000) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/projects", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer topsecret-token")
+	req.Header.Set("X-Request-ID", "req-42")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler must still see the full body: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+
+	var rec map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if json.Unmarshal([]byte(line), &m) == nil && m["msg"] == "http.body" {
+			rec = m
+		}
+	}
+	if rec == nil {
+		t.Fatalf("no http.body entry in %q", buf.String())
+	}
+	if rec["req_id"] != "req-42" || rec["path"] != "/projects" {
+		t.Fatalf("unexpected entry: %v", rec)
+	}
+	reqBody, _ := rec["reqBody"].(string)
+	if !strings.HasPrefix(reqBody, `{"name":"demo"`) || len(reqBody) > logBodyMaxBytes || rec["reqBodyTruncated"] != true {
+		t.Fatalf("request body not truncated: len=%d truncated=%v", len(reqBody), rec["reqBodyTruncated"])
+	}
+	if strings.Contains(buf.String(), "sk-verysecretvalue") || strings.Contains(buf.String(), "topsecret-token") {
+		t.Fatalf("secrets leaked: %s", buf.String())
+	}
+	if resp, _ := rec["respBody"].(string); !strings.Contains(resp, "projectID") {
+		t.Fatalf("response body missing: %v", rec["respBody"])
+	}
+}
+
+func TestBodyLoggingOffByDefault(t *testing.T) {
+	t.Setenv("MYCODER_LOG_BODIES", "")
+	var buf bytes.Buffer
+	h := bodyLogMiddleware(NewAPI(store.New(), nil).mux(), mylog.NewWriter(&buf))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/projects", strings.NewReader(`{"name":"p","rootPath":"."}`)))
+	if buf.Len() != 0 {
+		t.Fatalf("expected no body logging, got %q", buf.String())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/store"
+)
+
+// blockingStream never yields until closed, like a stalled generation.
+type blockingStream struct{ closed chan struct{} }
+
+func (s *blockingStream) Recv() (string, bool, error) {
+	<-s.closed
+	return "", true, context.Canceled
+}
+
+func (s *blockingStream) Close() error {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	return nil
+}
+
+func TestNonStreamingChatAbortsOnCancel(t *testing.T) {
+	bs := &blockingStream{closed: make(chan struct{})}
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		return bs, nil
+	}}
+	mux := NewAPI(store.New(), prov).mux()
+	b, _ := json.Marshal(map[string]any{"messages": []map[string]any{{"role": "user", "content": "hi"}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	finished := make(chan struct{})
+	go func() {
+		mux.ServeHTTP(rr, req)
+		close(finished)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("handler did not return after cancel")
+	}
+	if rr.Code != statusClientClosedRequest {
+		t.Fatalf("code=%d, want %d", rr.Code, statusClientClosedRequest)
+	}
+	select {
+	case <-bs.closed:
+	default:
+		t.Fatalf("stream was not closed")
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	oai "mycoder/internal/llm/openai"
+	"mycoder/internal/store"
+)
+
+func TestChatMaxTokensReachesUpstreamBody(t *testing.T) {
+	var got []map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body)
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": "ok"}}}})
+	}))
+	defer upstream.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", upstream.URL)
+	mux := NewAPI(store.New(), oai.NewFromEnv()).mux()
+
+	post := func(req map[string]any) int {
+		b, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+		return rr.Code
+	}
+	msgs := []map[string]any{{"role": "user", "content": "hi"}}
+	if c := post(map[string]any{"messages": msgs, "maxTokens": 128}); c != http.StatusOK {
+		t.Fatalf("code=%d", c)
+	}
+	if c := post(map[string]any{"messages": msgs}); c != http.StatusOK {
+		t.Fatalf("code=%d", c)
+	}
+	if c := post(map[string]any{"messages": msgs, "maxTokens": -1}); c != http.StatusBadRequest {
+		t.Fatalf("negative maxTokens: code=%d", c)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", len(got))
+	}
+	if v, ok := got[0]["max_tokens"].(float64); !ok || v != 128 {
+		t.Fatalf("max_tokens not forwarded: %v", got[0])
+	}
+	if _, ok := got[1]["max_tokens"]; ok {
+		t.Fatalf("max_tokens should be omitted when zero: %v", got[1])
+	}
+}
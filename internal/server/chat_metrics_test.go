@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mycoder/internal/llm"
+	oai "mycoder/internal/llm/openai"
+	"mycoder/internal/store"
+)
+
+func TestChatStreamRecordsLatencyMetrics(t *testing.T) {
+	t.Setenv("MYCODER_METRICS_BUCKETS", "0.01,0.02,5")
+	old := metrics
+	t.Cleanup(func() { metrics = old })
+	metrics = newMetrics()
+
+	// first token arrives after ~30ms, then two more without delay
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		i := 0
+		return &mockChatStream{RecvFn: func() (string, bool, error) {
+			i++
+			switch i {
+			case 1:
+				time.Sleep(30 * time.Millisecond)
+				return "a", false, nil
+			case 2, 3:
+				return "b", false, nil
+			}
+			return "", true, nil
+		}}, nil
+	}}
+	api := NewAPI(store.New(), prov)
+	mux := api.mux()
+	b, _ := json.Marshal(map[string]any{"messages": []map[string]any{{"role": "user", "content": "hi"}}, "stream": true})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d", rr.Code)
+	}
+
+	metrics.mu.Lock()
+	events, ttft, dur := metrics.chatTokenEvents, metrics.chatTTFT, metrics.chatDuration
+	metrics.mu.Unlock()
+	if events != 3 {
+		t.Fatalf("token events=%d, want 3", events)
+	}
+	if ttft.total != 1 || ttft.sum < 0.03 {
+		t.Fatalf("unexpected ttft: total=%d sum=%f", ttft.total, ttft.sum)
+	}
+	if ttft.counts[0] != 0 || ttft.counts[1] != 0 || ttft.counts[2] != 1 {
+		t.Fatalf("ttft should land in the 5s bucket: %v", ttft.counts)
+	}
+	if dur.total != 1 || dur.sum < ttft.sum {
+		t.Fatalf("unexpected duration: total=%d sum=%f ttft=%f", dur.total, dur.sum, ttft.sum)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	for _, want := range []string{
+		"mycoder_chat_stream_token_events_total 3",
+		`mycoder_chat_ttft_seconds_bucket{le="0.02"} 0`,
+		`mycoder_chat_ttft_seconds_bucket{le="+Inf"} 1`,
+		"mycoder_chat_duration_seconds_count 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestChatTTFTIncludesPreLLMWork(t *testing.T) {
+	t.Setenv("MYCODER_CHAT_SUMMARY_ENABLE", "1")
+	t.Setenv("MYCODER_CHAT_SUMMARY_THRESHOLD_CHARS", "1")
+	old := metrics
+	t.Cleanup(func() { metrics = old })
+	metrics = newMetrics()
+
+	// the non-streaming summary call stands in for slow work before the chat call
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		if !stream {
+			time.Sleep(30 * time.Millisecond)
+			return &mockChatStream{RecvFn: func() (string, bool, error) { return "summary", true, nil }}, nil
+		}
+		i := 0
+		return &mockChatStream{RecvFn: func() (string, bool, error) {
+			i++
+			if i == 1 {
+				return "a", false, nil
+			}
+			return "", true, nil
+		}}, nil
+	}}
+	api := NewAPI(store.New(), prov)
+	b, _ := json.Marshal(map[string]any{"messages": []map[string]any{{"role": "user", "content": "hello there"}}, "stream": true})
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d", rr.Code)
+	}
+	metrics.mu.Lock()
+	ttft := metrics.chatTTFT
+	metrics.mu.Unlock()
+	if ttft.total != 1 || ttft.sum < 0.03 {
+		t.Fatalf("ttft should include pre-LLM work: total=%d sum=%f", ttft.total, ttft.sum)
+	}
+}
+
+func TestChatUsagePropagatesToResponseAndMetrics(t *testing.T) {
+	old := metrics
+	t.Cleanup(func() { metrics = old })
+	metrics = newMetrics()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"content": "hello"}}},
+			"usage":   map[string]any{"prompt_tokens": 42, "completion_tokens": 7, "total_tokens": 49},
+		})
+	}))
+	defer upstream.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", upstream.URL)
+
+	mux := NewAPI(store.New(), oai.NewFromEnv()).mux()
+	b, _ := json.Marshal(map[string]any{"messages": []map[string]any{{"role": "user", "content": "hi"}}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Content string    `json:"content"`
+		Usage   llm.Usage `json:"usage"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Content != "hello" || res.Usage.PromptTokens != 42 || res.Usage.CompletionTokens != 7 {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	for _, want := range []string{
+		`mycoder_chat_tokens_total{type="prompt"} 42`,
+		`mycoder_chat_tokens_total{type="completion"} 7`,
+		"mycoder_chat_stream_tokens_total 7",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/models"
+	"mycoder/internal/store"
+)
+
+func TestChatRetrievalOnlySkipsLLM(t *testing.T) {
+	calls := 0
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		calls++
+		return &mockChatStream{}, nil
+	}}
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("func Alpha() {}\n"), 0o644)
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "a.go", "func Alpha() {}\n")
+	mux := NewAPI(st, prov).mux()
+
+	b, _ := json.Marshal(map[string]any{
+		"messages":      []map[string]any{{"role": "user", "content": "Alpha"}},
+		"projectID":     p.ID,
+		"retrievalOnly": true,
+	})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if calls != 0 {
+		t.Fatalf("LLM called %d times in retrieval-only mode", calls)
+	}
+	var out struct {
+		Messages  []llm.Message         `json:"messages"`
+		Context   string                `json:"context"`
+		Citations []models.SearchResult `json:"citations"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.Context, "a.go") || !strings.Contains(out.Context, "func Alpha") {
+		t.Fatalf("context missing snippet: %q", out.Context)
+	}
+	if len(out.Citations) != 1 || out.Citations[0].Path != "a.go" {
+		t.Fatalf("citations=%+v", out.Citations)
+	}
+	if len(out.Messages) != 2 || out.Messages[0].Content != out.Context || out.Messages[1].Content != "Alpha" {
+		t.Fatalf("messages=%+v", out.Messages)
+	}
+}
+
+func TestChatRetrievalOnlyWithoutProvider(t *testing.T) {
+	st := store.New()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	mux := NewAPI(st, nil).mux()
+	b, _ := json.Marshal(map[string]any{
+		"messages":      []map[string]any{{"role": "user", "content": "hi"}},
+		"projectID":     p.ID,
+		"retrievalOnly": true,
+	})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"citations":[]`) {
+		t.Fatalf("expected empty citations: %s", rr.Body.String())
+	}
+}
+
+func TestChatRetrievalOnlyCitesWrittenSnippetsAndKnowledge(t *testing.T) {
+	t.Setenv("MYCODER_RAG_BUDGET_BYTES", "300")
+	dir := t.TempDir()
+	long := strings.Repeat("// Alpha "+strings.Repeat("x", 100)+"\n", 8)
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("func Alpha() {}\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "b.go"), []byte(long), 0o644)
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "a.go", "func Alpha() {}\n")
+	st.AddDocument(p.ID, "b.go", long)
+	if _, err := st.AddKnowledge(p.ID, "doc", "", "house rule", "prefer Alpha", 0.9, true); err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+
+	b, _ := json.Marshal(map[string]any{
+		"messages":      []map[string]any{{"role": "user", "content": "Alpha"}},
+		"projectID":     p.ID,
+		"retrievalOnly": true,
+	})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Context   string                `json:"context"`
+		Citations []models.SearchResult `json:"citations"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	// b.go does not fit the budget, so only a.go is cited
+	if len(out.Citations) != 1 || out.Citations[0].Path != "a.go" {
+		t.Fatalf("citations=%+v context=%q", out.Citations, out.Context)
+	}
+	if !strings.Contains(out.Context, "house rule") || !strings.Contains(out.Context, "func Alpha") {
+		t.Fatalf("context should hold knowledge and snippet: %q", out.Context)
+	}
+}
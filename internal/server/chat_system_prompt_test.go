@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/store"
+)
+
+func TestChatSystemPromptComesFirst(t *testing.T) {
+	var got []llm.Message
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		got = messages
+		return &mockChatStream{}, nil
+	}}
+	st := store.New()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	st.AddDocument(p.ID, "a.go", "func Alpha() {}\n")
+	mux := NewAPI(st, prov).mux()
+
+	b, _ := json.Marshal(map[string]any{
+		"messages":     []map[string]any{{"role": "user", "content": "Alpha"}},
+		"projectID":    p.ID,
+		"systemPrompt": "Follow the team coding standards.",
+	})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if len(got) < 3 {
+		t.Fatalf("expected system prompt, RAG context and user message, got %+v", got)
+	}
+	if got[0].Role != llm.RoleSystem || got[0].Content != "Follow the team coding standards." {
+		t.Fatalf("custom system prompt not first: %+v", got[0])
+	}
+	if got[1].Role != llm.RoleSystem || !strings.Contains(got[1].Content, "a.go") {
+		t.Fatalf("RAG context should follow the custom prompt: %+v", got[1])
+	}
+	if last := got[len(got)-1]; last.Role != llm.RoleUser || last.Content != "Alpha" {
+		t.Fatalf("user message should be last: %+v", last)
+	}
+}
+
+func TestChatProjectSystemFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".mycoder"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sysPath := filepath.Join(dir, ".mycoder", "system.md")
+	if err := os.WriteFile(sysPath, []byte("Use tabs.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got []llm.Message
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		got = messages
+		return &mockChatStream{}, nil
+	}}
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	mux := NewAPI(st, prov).mux()
+	chat := func(systemPrompt string) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{
+			"messages":     []map[string]any{{"role": "user", "content": "hi"}},
+			"projectID":    p.ID,
+			"systemPrompt": systemPrompt,
+		})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+		}
+	}
+
+	chat("")
+	if got[0].Role != llm.RoleSystem || got[0].Content != "Use tabs." {
+		t.Fatalf("project system prompt missing: %+v", got)
+	}
+
+	// per-request prompt is appended after the project one by default
+	chat("Answer in Korean.")
+	if got[0].Content != "Use tabs." || got[1].Content != "Answer in Korean." {
+		t.Fatalf("expected project then request prompt: %+v", got[:2])
+	}
+
+	// edits are picked up (mtime/size change invalidates the cache)
+	if err := os.WriteFile(sysPath, []byte("Use four spaces.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(2 * time.Second)
+	_ = os.Chtimes(sysPath, future, future)
+	chat("")
+	if got[0].Content != "Use four spaces." {
+		t.Fatalf("cache not invalidated: %+v", got[0])
+	}
+
+	t.Setenv("MYCODER_SYSTEM_PROMPT_MODE", "override")
+	chat("Only this.")
+	if got[0].Content != "Only this." || (len(got) > 1 && got[1].Content == "Use four spaces.") {
+		t.Fatalf("override should drop the project prompt: %+v", got)
+	}
+}
+
+func TestProjectSystemPromptMaxBytes(t *testing.T) {
+	t.Setenv("MYCODER_SYSTEM_PROMPT_MAX_BYTES", "10")
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".mycoder"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// "가" is three bytes, so the cut at 10 lands inside the fourth rune
+	if err := os.WriteFile(filepath.Join(dir, ".mycoder", "system.md"), []byte(strings.Repeat("가", 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := projectSystemPrompt(dir); got != "가가가" {
+		t.Fatalf("truncated prompt=%q", got)
+	}
+	// an invalid byte before the cut must not throw away the valid prefix
+	if err := os.WriteFile(filepath.Join(dir, ".mycoder", "system.md"), []byte("ab\xffcdefghijklmn"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := projectSystemPrompt(dir); got != "ab\xffcdefghi" {
+		t.Fatalf("truncated prompt with invalid byte=%q", got)
+	}
+}
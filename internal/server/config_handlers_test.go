@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mycoder/internal/config"
+	"mycoder/internal/store"
+)
+
+func TestConfigEndpointShowsOverridesAndMasksSecrets(t *testing.T) {
+	t.Setenv("MYCODER_RAG_BUDGET_BYTES", "4096")
+	t.Setenv("MYCODER_OPENAI_API_KEY", "sk-very-secret")
+	mux := NewAPI(store.New(), nil).mux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Settings []config.Setting `json:"settings"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	var budget, key *config.Setting
+	for i := range res.Settings {
+		switch res.Settings[i].Key {
+		case "MYCODER_RAG_BUDGET_BYTES":
+			budget = &res.Settings[i]
+		case "MYCODER_OPENAI_API_KEY":
+			key = &res.Settings[i]
+		}
+	}
+	if budget == nil || budget.Value != "4096" || budget.Source != "env" {
+		t.Fatalf("env override missing: %+v", budget)
+	}
+	if key == nil || key.Value != "****" || !key.Secret {
+		t.Fatalf("api key not masked: %+v", key)
+	}
+	if contains(rr.Body.String(), "sk-very-secret") {
+		t.Fatalf("secret leaked in response")
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mycoder/internal/models"
+	"mycoder/internal/store"
+)
+
+func TestConversationsListAndShow(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", t.TempDir(), nil)
+	other := st.CreateProject("q", t.TempDir(), nil)
+	conv, err := st.CreateConversation(p.ID, "refactor plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range [][2]string{{"user", "how do I split server.go?"}, {"assistant", "start with the handlers"}} {
+		if _, err := st.AddConversationMessage(conv.ID, m[0], m[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.CreateConversation(other.ID, "elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.AddConversationMessage("missing", "user", "x"); err == nil {
+		t.Fatalf("adding to an unknown conversation should fail")
+	}
+	mux := NewAPI(st, nil).mux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/conversations?projectID="+p.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var list struct {
+		Conversations []models.Conversation `json:"conversations"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Conversations) != 1 {
+		t.Fatalf("expected only the project's conversation, got %+v", list.Conversations)
+	}
+	if c := list.Conversations[0]; c.ID != conv.ID || c.Title != "refactor plan" || c.MessageCount != 2 || c.Pinned || c.UpdatedAt == "" {
+		t.Fatalf("unexpected listing: %+v", c)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/conversations/"+conv.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("show code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var show struct {
+		Conversation models.Conversation          `json:"conversation"`
+		Messages     []models.ConversationMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &show); err != nil {
+		t.Fatal(err)
+	}
+	if show.Conversation.ID != conv.ID || len(show.Messages) != 2 {
+		t.Fatalf("unexpected show: %+v", show)
+	}
+	if show.Messages[0].Role != "user" || show.Messages[1].Content != "start with the handlers" {
+		t.Fatalf("messages out of order: %+v", show.Messages)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/conversations/nope", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown id: code=%d", rr.Code)
+	}
+}
+
+func TestConversationsMemStoreEmpty(t *testing.T) {
+	mux := NewAPI(store.New(), nil).mux()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/conversations", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "{\"conversations\":[]}\n" {
+		t.Fatalf("code=%d body=%q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestConversationPinSurvivesCleanup(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", t.TempDir(), nil)
+	keep, _ := st.CreateConversation(p.ID, "keep")
+	drop, _ := st.CreateConversation(p.ID, "drop")
+	mux := NewAPI(st, nil).mux()
+
+	post := func(path string) int {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, nil))
+		return rr.Code
+	}
+	if code := post("/conversations/" + keep.ID + "/pin"); code != http.StatusOK {
+		t.Fatalf("pin code=%d", code)
+	}
+	if code := post("/conversations/" + drop.ID + "/pin"); code != http.StatusOK {
+		t.Fatalf("pin code=%d", code)
+	}
+	if code := post("/conversations/" + drop.ID + "/unpin"); code != http.StatusOK {
+		t.Fatalf("unpin code=%d", code)
+	}
+	if code := post("/conversations/missing/pin"); code != http.StatusNotFound {
+		t.Fatalf("pin unknown: code=%d", code)
+	}
+
+	// age both past the TTL, then run the cleaner
+	old := time.Now().AddDate(0, 0, -60).Format(time.RFC3339)
+	if _, err := st.DB().Exec(`UPDATE conversations SET created_at=?, updated_at=?`, old, old); err != nil {
+		t.Fatal(err)
+	}
+	n, err := st.CleanupConversations(30)
+	if err != nil || n != 1 {
+		t.Fatalf("cleanup removed %d (err=%v), want 1", n, err)
+	}
+	if _, _, found, _ := st.GetConversation(keep.ID); !found {
+		t.Fatalf("pinned conversation was cleaned up")
+	}
+	if _, _, found, _ := st.GetConversation(drop.ID); found {
+		t.Fatalf("unpinned conversation survived cleanup")
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mycoder/internal/store"
+)
+
+func TestCuratorMetricsAndCancel(t *testing.T) {
+	st := store.New()
+	p := st.CreateProject("cur", "/tmp/cur", nil)
+	_, _ = st.AddKnowledge(p.ID, "web", "https://stale.example", "stale", "x", 0.1, false)
+	_, _ = st.AddKnowledge(p.ID, "doc", "", "keep", "y", 0.9, false)
+
+	metrics.mu.Lock()
+	runs0, removed0 := metrics.curatorRuns, metrics.curatorRemoved
+	metrics.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runCurator(ctx, st, curatorConfig{interval: 10 * time.Millisecond, minTrust: 0.4})
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		metrics.mu.Lock()
+		runs := metrics.curatorRuns
+		metrics.mu.Unlock()
+		if runs-runs0 >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("curator did not run (runs=%d)", runs-runs0)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("curator did not stop after cancel")
+	}
+
+	metrics.mu.Lock()
+	removed := metrics.curatorRemoved - removed0
+	metrics.mu.Unlock()
+	if removed != 1 {
+		t.Fatalf("expected 1 removed item, got %d", removed)
+	}
+	rr := httptest.NewRecorder()
+	NewAPI(st, nil).mux().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "mycoder_curator_runs_total ") || !strings.Contains(body, "mycoder_curator_removed_total ") {
+		t.Fatalf("curator metrics missing:\n%s", body)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	oai "mycoder/internal/llm/openai"
+	"mycoder/internal/store"
+)
+
+func diagnostics(t *testing.T, api *API) map[string]any {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/diagnostics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("diagnostics code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestDiagnosticsComponents(t *testing.T) {
+	t.Setenv("MYCODER_DISABLE_EMBEDDINGS", "1")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/models") {
+			_, _ = w.Write([]byte(`{"data":[{"id":"m1"}]}`))
+			return
+		}
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", srv.URL)
+
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(st, oai.NewFromEnv())
+	res := diagnostics(t, api)
+	comp, _ := res["components"].(map[string]any)
+	want := map[string]bool{"llm": true, "embeddings": false, "storeWritable": true, "indexed": false}
+	for k, v := range want {
+		if b, ok := comp[k].(bool); !ok || b != v {
+			t.Fatalf("components[%s]=%v, want %v (%v)", k, comp[k], v, res)
+		}
+	}
+	if res["store"] != "sqlite" || res["llmURL"] != srv.URL {
+		t.Fatalf("unexpected details: %v", res)
+	}
+
+	p := st.CreateProject("diag", t.TempDir(), nil)
+	st.AddDocument(p.ID, "a.go", "package a")
+	if comp := diagnostics(t, api)["components"].(map[string]any); comp["indexed"] != true {
+		t.Fatalf("expected indexed after adding a document: %v", comp)
+	}
+}
+
+func TestDiagnosticsWithoutLLM(t *testing.T) {
+	res := diagnostics(t, NewAPI(store.New(), nil))
+	comp, _ := res["components"].(map[string]any)
+	if comp["llm"] != false || comp["embeddings"] != false || comp["storeWritable"] != true {
+		t.Fatalf("unexpected components: %v", comp)
+	}
+	if res["store"] != "memory" {
+		t.Fatalf("store=%v, want memory", res["store"])
+	}
+}
+
+func TestReadyzChecksStore(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "ready.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	_ = st.DB().Close()
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with a closed store, got %d", rr.Code)
+	}
+}
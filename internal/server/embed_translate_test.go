@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/store"
+)
+
+// chatEmbedder is a provider that translates every chat to a fixed reply and
+// records embedding inputs.
+type chatEmbedder struct {
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (c *chatEmbedder) Chat(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+	return &mockChatStream{RecvFn: func() (string, bool, error) { return "translated text", true, nil }}, nil
+}
+
+func (c *chatEmbedder) Embeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	c.mu.Lock()
+	c.inputs = append(c.inputs, inputs...)
+	c.mu.Unlock()
+	out := make([][]float32, len(inputs))
+	for i := range inputs {
+		out[i] = []float32{0.1, 0.2}
+	}
+	return out, nil
+}
+
+func TestNewAPIWiresTranslateFallback(t *testing.T) {
+	for _, k := range []string{"MYCODER_EMBED_TRANSLATE_FALLBACK", "MYCODER_EMBED_CACHE_DISABLE", "MYCODER_DISABLE_EMBEDDINGS"} {
+		old := os.Getenv(k)
+		k := k
+		t.Cleanup(func() { _ = os.Setenv(k, old) })
+	}
+	_ = os.Setenv("MYCODER_DISABLE_EMBEDDINGS", "")
+	_ = os.Setenv("MYCODER_EMBED_CACHE_DISABLE", "1")
+
+	prov := &chatEmbedder{}
+	_ = os.Setenv("MYCODER_EMBED_TRANSLATE_FALLBACK", "")
+	if a := NewAPI(store.New(), prov); a.tr != nil {
+		t.Fatalf("translator should be off without MYCODER_EMBED_TRANSLATE_FALLBACK")
+	}
+
+	_ = os.Setenv("MYCODER_EMBED_TRANSLATE_FALLBACK", "1")
+	a := NewAPI(store.New(), prov)
+	if a.tr == nil {
+		t.Fatalf("translator not wired")
+	}
+	pipe := a.newPipeline(context.Background())
+	pipe.Add("p", "d1", "README.md", "s1", "한국어 문서입니다")
+	if err := pipe.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	last := prov.inputs[len(prov.inputs)-1]
+	if last != "translated text" || strings.Contains(last, "한국어") {
+		t.Fatalf("expected translated input to the embedder, got %q", prov.inputs)
+	}
+}
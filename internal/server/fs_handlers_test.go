@@ -98,3 +98,198 @@ func TestFSPathOutsideProjectForbidden(t *testing.T) {
 		t.Fatalf("expected 403, got %d", rr.Code)
 	}
 }
+
+func TestFSSymlinkEscapeForbidden(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret.txt")
+	if err := os.WriteFile(secret, []byte("top secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linkdir")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("fs", dir, nil)
+	mux := api.mux()
+
+	// read through a file symlink
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "path": "link.txt"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/read", bytes.NewReader(b)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for read via symlink, got %d", rr.Code)
+	}
+	// write a new file below a directory symlink
+	b, _ = json.Marshal(map[string]any{"projectID": p.ID, "path": "linkdir/new.txt", "content": "x"})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/write", bytes.NewReader(b)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for write via symlinked dir, got %d", rr.Code)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Fatalf("file was written outside project root")
+	}
+}
+
+func TestFSDanglingSymlinkEscapeForbidden(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	target := filepath.Join(outside, "nonexistent")
+	if err := os.Symlink(target, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "newdir"), filepath.Join(dir, "dirlink")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("fs", dir, nil)
+	mux := api.mux()
+
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "path": "link", "content": "x"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/write", bytes.NewReader(b)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for write via dangling link, got %d", rr.Code)
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Fatalf("file was written outside project root")
+	}
+	b, _ = json.Marshal(map[string]any{"projectID": p.ID, "path": "dirlink/sub"})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/mkdir", bytes.NewReader(b)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for mkdir via dangling link, got %d", rr.Code)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "newdir")); err == nil {
+		t.Fatalf("directory was created outside project root")
+	}
+}
+
+func TestFSMove(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.txt"), []byte("moved"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "taken.txt"), []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("fs", dir, nil)
+	st.AddDocument(p.ID, "old.txt", "moved")
+	mux := api.mux()
+	move := func(from, to string) (int, map[string]any) {
+		b, _ := json.Marshal(map[string]any{"projectID": p.ID, "from": from, "to": to})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/move", bytes.NewReader(b)))
+		var res map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+
+	if code, res := move("old.txt", "sub/new.txt"); code != http.StatusOK || res["backup"] != nil {
+		t.Fatalf("move code=%d res=%v", code, res)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Fatalf("old path should be gone, err=%v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "sub", "new.txt")); err != nil || string(b) != "moved" {
+		t.Fatalf("new path content=%q err=%v", b, err)
+	}
+	if n := st.Stats()["documents"]; n != 0 {
+		t.Fatalf("old document should be dropped from the index, have %d", n)
+	}
+
+	// overwriting keeps a backup of the replaced file
+	code, res := move("sub/new.txt", "taken.txt")
+	if code != http.StatusOK {
+		t.Fatalf("overwrite code=%d res=%v", code, res)
+	}
+	bkp, _ := res["backup"].(string)
+	if b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(bkp))); bkp == "" || err != nil || string(b) != "original" {
+		t.Fatalf("backup %q content=%q err=%v", bkp, b, err)
+	}
+
+	if code, _ := move("missing.txt", "x.txt"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing source, got %d", code)
+	}
+	if code, _ := move("taken.txt", "../escape.txt"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for target outside project, got %d", code)
+	}
+}
+
+func TestFSMkdir(t *testing.T) {
+	dir := t.TempDir()
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("fs", dir, nil)
+	mux := api.mux()
+	mkdir := func(path string) (int, map[string]any) {
+		b, _ := json.Marshal(map[string]any{"projectID": p.ID, "path": path})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/mkdir", bytes.NewReader(b)))
+		var res map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+
+	if code, res := mkdir("a/b/c"); code != http.StatusOK || res["existed"] != false {
+		t.Fatalf("mkdir code=%d res=%v", code, res)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "a", "b", "c")); err != nil || !fi.IsDir() {
+		t.Fatalf("directory not created inside root: %v", err)
+	}
+	if code, res := mkdir("a/b"); code != http.StatusOK || res["existed"] != true {
+		t.Fatalf("second mkdir code=%d res=%v", code, res)
+	}
+	if code, _ := mkdir("../outside"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 outside root, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "outside")); !os.IsNotExist(err) {
+		t.Fatalf("nothing should be created outside the root")
+	}
+}
+
+func TestFSReadLineRange(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "r.txt"), []byte("one\ntwo\nthree\nfour\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("fs", dir, nil)
+	mux := api.mux()
+	read := func(body map[string]any) (int, map[string]any) {
+		body["projectID"], body["path"] = p.ID, "r.txt"
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/read", bytes.NewReader(b)))
+		var res map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+
+	code, res := read(map[string]any{"startLine": 2, "endLine": 3})
+	if code != http.StatusOK || res["content"] != "two\nthree\n" || res["totalLines"] != float64(4) {
+		t.Fatalf("range read code=%d res=%v", code, res)
+	}
+	if res["startLine"] != float64(2) || res["endLine"] != float64(3) {
+		t.Fatalf("unexpected bounds: %v", res)
+	}
+	// end past EOF is clamped; whole-file read is unchanged
+	if _, res := read(map[string]any{"startLine": 4, "endLine": 99}); res["content"] != "four\n" || res["endLine"] != float64(4) {
+		t.Fatalf("clamped read res=%v", res)
+	}
+	if _, res := read(map[string]any{}); res["content"] != "one\ntwo\nthree\nfour\n" || res["totalLines"] != nil {
+		t.Fatalf("whole read res=%v", res)
+	}
+	if code, _ := read(map[string]any{"startLine": 3, "endLine": 2}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for inverted range, got %d", code)
+	}
+}
@@ -105,3 +105,225 @@ func TestFSPatchUnifiedRollback(t *testing.T) {
 		t.Fatalf("rollback failed, got: %q", string(out))
 	}
 }
+
+func TestFSPatchUnifiedFuzz(t *testing.T) {
+	dir := t.TempDir()
+	// the file gained a line on top since the diff was made
+	orig := "new top\nline1\nline2\n"
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	mux := api.mux()
+	diff := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n line1\n-line2\n+line2 modified\n"
+
+	// strict: conflict
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(orig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "diffText": diff, "yes": true})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/patch/unified", bytes.NewReader(body)))
+	var res struct {
+		Ok    bool `json:"ok"`
+		Files []struct {
+			Conflict string `json:"conflict"`
+			Hunks    []struct {
+				Offset int `json:"offset"`
+			} `json:"hunks"`
+		} `json:"files"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Ok || len(res.Files) != 1 || res.Files[0].Conflict == "" {
+		t.Fatalf("expected strict conflict, body=%s", rr.Body.String())
+	}
+
+	// fuzz=1: applies with offset +1
+	body, _ = json.Marshal(map[string]any{"projectID": p.ID, "diffText": diff, "yes": true, "fuzz": 1})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/patch/unified", bytes.NewReader(body)))
+	res.Ok, res.Files = false, nil
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if !res.Ok || len(res.Files) != 1 || len(res.Files[0].Hunks) != 1 || res.Files[0].Hunks[0].Offset != 1 {
+		t.Fatalf("expected fuzzy apply with offset 1, body=%s", rr.Body.String())
+	}
+	out, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if string(out) != "new top\nline1\nline2 modified\n" {
+		t.Fatalf("content mismatch: %q", string(out))
+	}
+}
+
+func TestFSPatchUnifiedReportsRejectedHunks(t *testing.T) {
+	dir := t.TempDir()
+	orig := "a\nb\nc\nd\ne\nf\ng\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(orig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	mux := api.mux()
+	diff := "--- a/a.txt\n+++ b/a.txt\n" +
+		"@@ -1,2 +1,2 @@\n a\n-b\n+B\n" +
+		"@@ -6,2 +6,2 @@\n-nope\n+F\n g\n"
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "diffText": diff, "yes": true})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/patch/unified", bytes.NewReader(body)))
+	var res struct {
+		Ok    bool `json:"ok"`
+		Files []struct {
+			Conflict string `json:"conflict"`
+			Hunks    []struct {
+				Index, Add, Del int
+			} `json:"hunks"`
+			Rejects []struct {
+				Index    int      `json:"index"`
+				OldStart int      `json:"oldStart"`
+				Expected []string `json:"expected"`
+				Actual   []string `json:"actual"`
+			} `json:"rejects"`
+			RejectFile string `json:"rejectFile"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v body=%s", err, rr.Body.String())
+	}
+	if res.Ok || len(res.Files) != 1 {
+		t.Fatalf("unexpected body=%s", rr.Body.String())
+	}
+	f := res.Files[0]
+	if len(f.Hunks) != 1 || f.Hunks[0].Index != 0 || f.Hunks[0].Add != 1 || f.Hunks[0].Del != 1 {
+		t.Fatalf("good hunk stats: %+v", f.Hunks)
+	}
+	if len(f.Rejects) != 1 || f.Rejects[0].Index != 1 || f.Rejects[0].OldStart != 6 {
+		t.Fatalf("rejects: %+v", f.Rejects)
+	}
+	if f.Rejects[0].Expected[0] != "nope" || f.Rejects[0].Actual[0] != "f" {
+		t.Fatalf("reject context: %+v", f.Rejects[0])
+	}
+	if f.RejectFile == "" {
+		t.Fatalf("expected reject file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.RejectFile))); err != nil {
+		t.Fatalf("reject file: %v", err)
+	}
+	// file must remain untouched when any hunk is rejected
+	out, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if string(out) != orig {
+		t.Fatalf("should not change on conflict: %q", string(out))
+	}
+}
+
+func TestFSPatchUnifiedReportsRejectsForEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x\ny\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	mux := api.mux()
+	diff := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-nope\n+X\n y\n" +
+		"--- a/b.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n x\n-nope\n+Y\n" +
+		"--- a/c.txt\n+++ b/c.txt\n@@ -1,2 +1,2 @@\n-x\n+X\n y\n"
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "diffText": diff, "yes": true})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/patch/unified", bytes.NewReader(body)))
+	var res struct {
+		Ok    bool `json:"ok"`
+		Files []struct {
+			Path       string            `json:"path"`
+			Conflict   string            `json:"conflict"`
+			Rejects    []json.RawMessage `json:"rejects"`
+			RejectFile string            `json:"rejectFile"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v body=%s", err, rr.Body.String())
+	}
+	if res.Ok || len(res.Files) != 3 {
+		t.Fatalf("unexpected body=%s", rr.Body.String())
+	}
+	for _, f := range res.Files[:2] {
+		if f.Conflict == "" || len(f.Rejects) != 1 || f.RejectFile == "" {
+			t.Fatalf("expected rejects for %s: %+v", f.Path, f)
+		}
+	}
+	if res.Files[2].Conflict != "" {
+		t.Fatalf("clean file should not conflict: %+v", res.Files[2])
+	}
+	// nothing is written when any file conflicts
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		out, _ := os.ReadFile(filepath.Join(dir, name))
+		if string(out) != "x\ny\n" {
+			t.Fatalf("%s changed on conflict: %q", name, string(out))
+		}
+	}
+}
+
+func TestFSPatchListAfterApply(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("line1\nline2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	mux := api.mux()
+	diff := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,3 @@\n line1\n-line2\n+line2 modified\n+line3\n"
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "diffText": diff, "yes": true})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/patch/unified", bytes.NewReader(body)))
+	var applied struct {
+		PatchID string `json:"patchID"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &applied)
+	if applied.PatchID == "" {
+		t.Fatalf("expected patchID, body=%s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fs/patch/list?projectID="+p.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Patches []struct {
+			ID        string `json:"id"`
+			CreatedAt string `json:"createdAt"`
+			Applied   bool   `json:"applied"`
+			Files     int    `json:"files"`
+			TotalAdd  int    `json:"totalAdd"`
+			TotalDel  int    `json:"totalDel"`
+		} `json:"patches"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Patches) != 1 {
+		t.Fatalf("expected 1 patch, got %+v", res.Patches)
+	}
+	pt := res.Patches[0]
+	if pt.ID != applied.PatchID || !pt.Applied || pt.Files != 1 || pt.TotalAdd != 2 || pt.TotalDel != 1 || pt.CreatedAt == "" {
+		t.Fatalf("unexpected patch entry: %+v", pt)
+	}
+}
+
+func TestFSPatchUnifiedRejectsOutOfRangeFuzz(t *testing.T) {
+	st := store.New()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	mux := NewAPI(st, nil).mux()
+	diff := "--- a/a.txt\n+++ b/a.txt\n@@ -1,1 +1,1 @@\n-a\n+b\n"
+	for _, fuzz := range []int{-1, maxPatchFuzz + 1, 1 << 30} {
+		body, _ := json.Marshal(map[string]any{"projectID": p.ID, "diffText": diff, "dryRun": true, "fuzz": fuzz})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/patch/unified", bytes.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("fuzz=%d: expected 400, got %d body=%s", fuzz, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"mycoder/internal/store"
@@ -26,3 +28,40 @@ func TestFSPolicyBlocksWrite(t *testing.T) {
 		t.Fatalf("expected 403, got %d", rr.Code)
 	}
 }
+
+func TestFSPolicyBlocksRead(t *testing.T) {
+	os.Setenv("MYCODER_FS_DENY_REGEX", `\.env$`)
+	fsAllowRe, fsDenyRe = nil, nil
+	defer func() {
+		os.Unsetenv("MYCODER_FS_DENY_REGEX")
+		fsAllowRe, fsDenyRe = nil, nil
+	}()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ok.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	mux := api.mux()
+
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "path": ".env"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/read", bytes.NewReader(b)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for .env, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "SECRET") {
+		t.Fatalf("denied file content leaked: %s", rr.Body.String())
+	}
+
+	b, _ = json.Marshal(map[string]any{"projectID": p.ID, "path": "ok.txt"})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fs/read", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for ok.txt, got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestGzipLargeSearchResponse(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("gz", t.TempDir(), nil)
+	for i := 0; i < 20; i++ {
+		st.AddDocument(p.ID, fmt.Sprintf("f%02d.go", i), "needle "+strings.Repeat("payload ", 60)+"\n")
+	}
+	h := gzipMiddleware(api.mux())
+
+	req := httptest.NewRequest(http.MethodGet, "/search?q=needle&projectID="+p.ID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d", rr.Code)
+	}
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip encoding, headers=%v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var res struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(res.Results) == 0 {
+		t.Fatalf("expected results")
+	}
+}
+
+func TestGzipSkipsSmallAndUnsupported(t *testing.T) {
+	api := NewAPI(store.New(), nil)
+	h := gzipMiddleware(api.mux())
+
+	// tiny body stays uncompressed
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" || rr.Code != http.StatusOK {
+		t.Fatalf("small body should not be compressed: code=%d headers=%v", rr.Code, rr.Header())
+	}
+
+	// no Accept-Encoding
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("unexpected encoding without Accept-Encoding")
+	}
+}
+
+func TestGzipBypassesEventStream(t *testing.T) {
+	h := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(w, "event: token\ndata: %s\n\n", strings.Repeat("x", 50))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("event stream must not be compressed")
+	}
+	if !strings.Contains(rr.Body.String(), "event: token") {
+		t.Fatalf("unexpected body")
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestIndexRunDryRunListsFilesWithoutIngesting(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":      "package main\n",
+		"notes.md":     "# notes\n",
+		"lib/util.py":  "def f():\n    pass\n",
+		"lib/skip.tmp": "scratch\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	mux := NewAPI(st, nil).mux()
+
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "dryRun": true, "exclude": []string{"*.md", "lib/*.tmp"}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Files []struct {
+			Path string `json:"path"`
+			Size int    `json:"size"`
+			Lang string `json:"lang"`
+		} `json:"files"`
+		Count      int    `json:"count"`
+		TotalBytes int64  `json:"totalBytes"`
+		JobID      string `json:"jobID"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.JobID != "" {
+		t.Fatalf("dry run should not create a job: %s", rr.Body.String())
+	}
+	got := map[string]string{}
+	for _, f := range res.Files {
+		got[f.Path] = f.Lang
+	}
+	if _, ok := got["notes.md"]; ok {
+		t.Fatalf("excluded notes.md listed: %+v", res.Files)
+	}
+	if _, ok := got["lib/skip.tmp"]; ok {
+		t.Fatalf("excluded lib/skip.tmp listed: %+v", res.Files)
+	}
+	if got["main.go"] != "go" || got["lib/util.py"] != "py" {
+		t.Fatalf("unexpected files/langs: %+v", res.Files)
+	}
+	want := int64(len(files["main.go"]) + len(files["lib/util.py"]))
+	if res.Count != 2 || res.TotalBytes != want {
+		t.Fatalf("count=%d totalBytes=%d, want 2/%d", res.Count, res.TotalBytes, want)
+	}
+	if n := st.Stats()["jobs"]; n != 0 {
+		t.Fatalf("dry run created %d jobs", n)
+	}
+	if n := st.Stats()["documents"]; n != 0 {
+		t.Fatalf("dry run ingested %d documents", n)
+	}
+}
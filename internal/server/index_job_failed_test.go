@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mycoder/internal/models"
+	"mycoder/internal/store"
+)
+
+func TestIndexRunMissingRootMarksJobFailed(t *testing.T) {
+	st := store.New()
+	p := st.CreateProject("p", filepath.Join(t.TempDir(), "missing"), nil)
+	mux := NewAPI(st, nil).mux()
+
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "mode": "full"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("index run code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+
+	var job models.IndexJob
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/index/jobs/"+res["jobID"], nil))
+		_ = json.Unmarshal(rr.Body.Bytes(), &job)
+		if job.Status != models.JobPending && job.Status != models.JobRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != models.JobFailed {
+		t.Fatalf("status=%q, want failed", job.Status)
+	}
+	if job.Error == "" || job.Stats["errors"] != 1 {
+		t.Fatalf("expected error recorded on job, got %+v", job)
+	}
+}
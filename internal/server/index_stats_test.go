@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/store"
+	"mycoder/internal/vectorstore"
+)
+
+func TestIndexStatsCounts(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# notes\nhello\n"), 0o644)
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("stats", dir, nil)
+	mux := NewAPI(st, nil).mux()
+
+	stats := func() map[string]any {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/index/stats?projectID="+p.ID, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("stats code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var res map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return res
+	}
+	if res := stats(); res["documents"] != float64(0) || res["vectors"] != float64(0) {
+		t.Fatalf("empty project stats: %v", res)
+	}
+
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run/stream", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("index code=%d", rr.Code)
+	}
+	res := stats()
+	if res["documents"] != float64(2) {
+		t.Fatalf("documents=%v, want 2", res["documents"])
+	}
+	if c, _ := res["chunks"].(float64); c < 2 {
+		t.Fatalf("chunks=%v, want >= 2", res["chunks"])
+	}
+	if n, _ := res["bytes"].(float64); n <= 0 {
+		t.Fatalf("bytes=%v, want > 0", res["bytes"])
+	}
+	if res["vectors"] != float64(0) || res["embeddingsEnabled"] != false {
+		t.Fatalf("no embedder: expected zero vectors, got %v", res)
+	}
+
+	vs := vectorstore.NewSQLite(st.DB())
+	if err := vs.Upsert(context.Background(), []vectorstore.UpsertItem{
+		{ProjectID: p.ID, DocID: "main.go", ChunkID: "c1", Vector: []float32{1, 0, 0}, Dim: 3, Model: "tiny"},
+		{ProjectID: p.ID, DocID: "notes.md", ChunkID: "c2", Vector: []float32{0, 1, 0}, Dim: 3, Model: "tiny"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res = stats()
+	models, _ := res["vectorModels"].([]any)
+	if res["vectors"] != float64(2) || len(models) != 1 {
+		t.Fatalf("vector stats: %v", res)
+	}
+	if m := models[0].(map[string]any); m["model"] != "tiny" || m["dim"] != float64(3) {
+		t.Fatalf("vector model entry: %v", m)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/index/stats?projectID=missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown project, got %d", rr.Code)
+	}
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"mycoder/internal/models"
 	"mycoder/internal/store"
 )
 
@@ -42,4 +45,116 @@ func TestIndexRunStreamBasic(t *testing.T) {
 	if !strings.Contains(out, "event: completed") {
 		t.Fatalf("missing completed event")
 	}
+	if !strings.Contains(out, "event: start\ndata: {\"total\":2,\"startedAt\":") {
+		t.Fatalf("missing start event: %s", out)
+	}
+	if !strings.Contains(out, `"indexed":2,"total":2,"elapsedMs":`) {
+		t.Fatalf("progress should carry elapsedMs: %s", out)
+	}
+}
+
+func TestIndexRunStreamReportsSkippedBinary(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), []byte("abc\x00\x00def"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	mux := NewAPI(st, nil).mux()
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run/stream", bytes.NewReader(b)))
+	out := rr.Body.String()
+	if !strings.Contains(out, `data: {"documents":1,"skippedBinary":1,"skippedTotalCap":0}`) {
+		t.Fatalf("completed event should count skipped binary: %s", out)
+	}
+	var jobID string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			jobID = strings.TrimPrefix(line, "data: ")
+			break
+		}
+	}
+	job, ok := st.GetJob(jobID)
+	if !ok || job.Stats["skippedBinary"] != 1 {
+		t.Fatalf("job stats missing skippedBinary: %+v", job)
+	}
+}
+
+// cancelOnProgress cancels the request context once the first progress event is written.
+type cancelOnProgress struct {
+	*httptest.ResponseRecorder
+	cancel func()
+}
+
+func (c *cancelOnProgress) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("event: progress")) {
+		c.cancel()
+	}
+	return c.ResponseRecorder.Write(p)
+}
+
+func TestIndexRunStreamClientCancelMarksJob(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 3*ingestBatch; i++ {
+		_ = os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%03d.go", i)), []byte("package a\n"), 0o644)
+	}
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", dir, nil)
+	mux := NewAPI(st, nil).mux()
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelOnProgress{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/index/run/stream", bytes.NewReader(b)).WithContext(ctx))
+
+	out := w.Body.String()
+	if strings.Contains(out, "event: completed") {
+		t.Fatalf("canceled stream should not complete: %s", out)
+	}
+	jobID := strings.TrimSpace(strings.SplitN(strings.SplitN(out, "data: ", 2)[1], "\n", 2)[0])
+	job, ok := st.GetJob(jobID)
+	if !ok || job.Status != models.JobCanceled || job.EndedAt == nil {
+		t.Fatalf("job should be canceled, got %+v", job)
+	}
+	if n := job.Stats["documents"]; n <= 0 || n >= 3*ingestBatch {
+		t.Fatalf("documents=%d, want a partial count", n)
+	}
+}
+
+func TestIndexRunStreamTotalCapKeepsUnreadDocuments(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("0123456789\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", dir, nil)
+	mux := NewAPI(st, nil).mux()
+	run := func(body map[string]any) {
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run/stream", bytes.NewReader(b)))
+		if !strings.Contains(rr.Body.String(), "event: completed") {
+			t.Fatalf("index did not complete: %s", rr.Body.String())
+		}
+	}
+	run(map[string]any{"projectID": p.ID, "mode": "full"})
+	// the capped run reads only a.txt; b.txt and c.txt must survive the prune
+	run(map[string]any{"projectID": p.ID, "mode": "incremental", "maxTotalBytes": 15})
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if _, ok := st.GetDocument(p.ID, name); !ok {
+			t.Fatalf("%s was pruned by a capped incremental run", name)
+		}
+	}
 }
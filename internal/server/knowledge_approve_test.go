@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	"mycoder/internal/store"
@@ -34,3 +35,66 @@ func TestKnowledgeApprovePinsAndRaisesTrust(t *testing.T) {
 		}
 	}
 }
+
+func TestKnowledgeApproveRecordsAudit(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	k, _ := st.AddKnowledge(p.ID, "web", "u1", "Go tips", "x", 0.2, false)
+
+	body, _ := json.Marshal(map[string]any{"ProjectID": p.ID, "IDs": []string{k.ID, "kn-missing"}, "ApprovedBy": "alice"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/approve", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res["approved"] != float64(1) {
+		t.Fatalf("expected only the existing item approved, got %v", res)
+	}
+
+	got, ok, _ := st.GetKnowledge(k.ID)
+	if !ok || got.ApprovedBy != "alice" || got.ApprovedAt == "" {
+		t.Fatalf("audit fields not stored: %+v", got)
+	}
+	list, _ := st.ListKnowledge(p.ID, 0)
+	if len(list) != 1 || list[0].ApprovedBy != "alice" {
+		t.Fatalf("list missing audit fields: %+v", list)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge/approvals?projectID="+p.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("approvals code=%d", rr.Code)
+	}
+	var log struct {
+		Approvals []map[string]any
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &log)
+	if len(log.Approvals) != 1 || log.Approvals[0]["knowledgeID"] != k.ID || log.Approvals[0]["approvedBy"] != "alice" || log.Approvals[0]["title"] != "Go tips" {
+		t.Fatalf("unexpected approval log: %v", log.Approvals)
+	}
+}
+
+func TestKnowledgeApproveDefaultsToTokenLabel(t *testing.T) {
+	t.Setenv("MYCODER_API_TOKENS", "bob:tok-b")
+	st := store.New()
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	k, _ := st.AddKnowledge(p.ID, "doc", "", "t", "x", 0.2, false)
+	body, _ := json.Marshal(map[string]any{"ProjectID": p.ID, "IDs": []string{k.ID}})
+	req := httptest.NewRequest(http.MethodPost, "/knowledge/approve", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer tok-b")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if approvals, _ := st.ListKnowledgeApprovals(p.ID); len(approvals) != 1 || approvals[0].ApprovedBy != "bob" {
+		t.Fatalf("expected approval by token label bob, got %+v", approvals)
+	}
+}
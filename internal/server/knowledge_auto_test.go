@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -148,3 +149,114 @@ func TestKnowledgePromoteAutoEmptyFiles(t *testing.T) {
 		t.Fatalf("expected 400, got %d", rr.Code)
 	}
 }
+
+func TestKnowledgePromoteAutoReportsFilesRead(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(store.New(), nil)
+	mux := api.mux()
+	p := api.store.CreateProject("test", dir, nil)
+
+	body := []byte(`{"projectID":"` + p.ID + `","files":["a.go","missing.md","../outside.go"]}`)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/promote/auto", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("promote-auto failed: %d", rr.Code)
+	}
+	var res struct {
+		ID           string   `json:"id"`
+		FilesRead    []string `json:"filesRead"`
+		FilesMissing []string `json:"filesMissing"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ID == "" || len(res.FilesRead) != 1 || res.FilesRead[0] != "a.go" {
+		t.Fatalf("unexpected filesRead: %+v", res)
+	}
+	if strings.Join(res.FilesMissing, ",") != "missing.md,../outside.go" {
+		t.Fatalf("unexpected filesMissing: %v", res.FilesMissing)
+	}
+}
+
+func TestKnowledgePromoteAutoBudgetFields(t *testing.T) {
+	dir := t.TempDir()
+	var src strings.Builder
+	src.WriteString("package big\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&src, "func F%04d() {}\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "big.go"), []byte(src.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(store.New(), nil)
+	mux := api.mux()
+	p := api.store.CreateProject("test", dir, nil)
+
+	promote := func(extra string) (string, int) {
+		body := []byte(`{"projectID":"` + p.ID + `","files":["big.go"]` + extra + `}`)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/promote/auto", bytes.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("promote-auto %d: %s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Text      string         `json:"text"`
+			FileBytes map[string]int `json:"fileBytes"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return res.Text, res.FileBytes["big.go"]
+	}
+
+	_, small := promote("")
+	if small == 0 || small > 800 {
+		t.Fatalf("default per-file cap: included %d bytes", small)
+	}
+	text, large := promote(`,"budgetBytes":12000,"perFileBytes":10000`)
+	if large <= small || large > 10000 {
+		t.Fatalf("expected more content with larger budget: %d vs %d", large, small)
+	}
+	// head plus a middle excerpt, not just the first N bytes
+	if !strings.Contains(text, "package big") || !strings.Contains(text, "func F0500()") || strings.Contains(text, "func F0999()") {
+		t.Fatalf("excerpt should keep the head and middle of the file")
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/promote/auto", strings.NewReader(`{"projectID":"`+p.ID+`","files":["big.go"],"budgetBytes":-1}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("negative budget: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestFileExcerptLineAligned(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789\n", 100))
+	if got := fileExcerpt(data[:50], 100); got != string(data[:50]) {
+		t.Fatalf("small file should be returned whole")
+	}
+	got := fileExcerpt(data, 200)
+	if len(got) > 200 || !strings.Contains(got, kpElision) {
+		t.Fatalf("excerpt len=%d: %q", len(got), got)
+	}
+	for _, part := range strings.Split(got, kpElision) {
+		if !strings.HasPrefix(part, "0") {
+			t.Fatalf("part not line aligned: %q", part)
+		}
+	}
+}
+
+func TestKnowledgePromoteAutoRejectsWhenNoFileReadable(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("test", t.TempDir(), nil)
+	body := []byte(`{"projectID":"` + p.ID + `","files":["missing.md","gone.md"]}`)
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/promote/auto", bytes.NewReader(body)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "missing.md, gone.md") {
+		t.Fatalf("expected 400 naming the files, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	if ks, _ := st.ListKnowledge(p.ID, 0); len(ks) != 0 {
+		t.Fatalf("no knowledge should be created, got %d", len(ks))
+	}
+}
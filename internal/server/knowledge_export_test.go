@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestKnowledgeExportImportRoundTrip(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+	src := st.CreateProject("src", t.TempDir(), nil)
+	dst := st.CreateProject("dst", t.TempDir(), nil)
+	_, _ = st.AddKnowledge(src.ID, "web", "https://go.dev", "Go site", "go docs", 0.8, false)
+	_, _ = st.PromoteKnowledge(src.ID, "Card", "card body", "a.go", "abc123", "a.go", "A", true)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge/export?projectID="+src.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	exported := rr.Body.Bytes()
+	if n := len(strings.Split(strings.TrimSpace(string(exported)), "\n")); n != 2 {
+		t.Fatalf("expected 2 JSONL lines, got %d", n)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/import?projectID="+dst.ID, bytes.NewReader(exported)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("import code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]int
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res["imported"] != 2 {
+		t.Fatalf("expected 2 imported, got %v", res)
+	}
+
+	titles := func(pid string) []string {
+		list, _ := st.ListKnowledge(pid, 0)
+		var out []string
+		for _, k := range list {
+			out = append(out, k.Title)
+		}
+		sort.Strings(out)
+		return out
+	}
+	if a, b := titles(src.ID), titles(dst.ID); strings.Join(a, ",") != strings.Join(b, ",") {
+		t.Fatalf("titles differ: %v vs %v", a, b)
+	}
+	list, _ := st.ListKnowledge(dst.ID, 0)
+	for _, k := range list {
+		if k.Title == "Card" && (k.CommitSHA != "abc123" || k.Symbols != "A" || !k.Pinned) {
+			t.Fatalf("fields not preserved: %+v", k)
+		}
+	}
+
+	// malformed line is rejected
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/import?projectID="+dst.ID, strings.NewReader("{not json}\n")))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed line, got %d", rr.Code)
+	}
+}
+
+func TestKnowledgeImportUnknownRowProject(t *testing.T) {
+	st := store.New()
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	body := `{"projectID":"` + p.ID + `","sourceType":"doc","text":"ok"}` + "\n" +
+		`{"projectID":"missing","sourceType":"doc","text":"orphan"}` + "\n"
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/import", strings.NewReader(body)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	// nothing is imported when any row names an unknown project
+	if list, _ := st.ListKnowledge(p.ID, 0); len(list) != 0 {
+		t.Fatalf("expected no rows, got %d", len(list))
+	}
+	if list, _ := st.ListKnowledge("missing", 0); len(list) != 0 {
+		t.Fatalf("orphan rows created: %d", len(list))
+	}
+}
+
+func TestKnowledgeImportValidatesTrustScore(t *testing.T) {
+	st := store.New()
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	body := `{"projectID":"` + p.ID + `","sourceType":"doc","text":"ok","trustScore":0.5}` + "\n" +
+		`{"projectID":"` + p.ID + `","sourceType":"doc","text":"bad","trustScore":1.5}` + "\n"
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/import", strings.NewReader(body)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "line 2") {
+		t.Fatalf("expected 400 for line 2, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	if list, _ := st.ListKnowledge(p.ID, 0); len(list) != 0 {
+		t.Fatalf("expected no rows, got %d", len(list))
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestKnowledgeGetAndDelete(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(st, nil)
+	mux := api.mux()
+	p := st.CreateProject("kn", t.TempDir(), nil)
+	k, err := st.PromoteKnowledge(p.ID, "Card", "card body", "a.go", "abc123", "a.go,b.go", "A,B", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge/"+k.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var got map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+	if got["text"] != "card body" || got["commitSHA"] != "abc123" || got["files"] != "a.go,b.go" || got["symbols"] != "A,B" {
+		t.Fatalf("unexpected item: %v", got)
+	}
+
+	// missing ID
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge/kn-missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing id, got %d", rr.Code)
+	}
+
+	// read-only blocks delete
+	api.readOnly = true
+	b, _ := json.Marshal(map[string]any{"id": k.ID})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/delete", bytes.NewReader(b)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 in read-only mode, got %d", rr.Code)
+	}
+	api.readOnly = false
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/delete", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if _, ok, _ := st.GetKnowledge(k.ID); ok {
+		t.Fatalf("expected item to be gone")
+	}
+	// deleting again is a 404 (also via DELETE)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/knowledge/"+k.ID, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 on second delete, got %d", rr.Code)
+	}
+}
+
+func TestKnowledgeDeleteMemStore(t *testing.T) {
+	st := store.New()
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("kn", "/tmp/kn", nil)
+	k, _ := st.AddKnowledge(p.ID, "doc", "", "T", "x", 0.5, false)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/knowledge/"+k.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete code=%d", rr.Code)
+	}
+	if list, _ := st.ListKnowledge(p.ID, 0); len(list) != 0 {
+		t.Fatalf("expected empty list, got %d", len(list))
+	}
+}
+
+func TestKnowledgeUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		st   func(t *testing.T) Store
+	}{
+		{"mem", func(t *testing.T) Store { return store.New() }},
+		{"sqlite", func(t *testing.T) Store {
+			st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return st
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			st := tc.st(t)
+			mux := NewAPI(st, nil).mux()
+			p := st.CreateProject("kn", t.TempDir(), nil)
+			k, _ := st.AddKnowledge(p.ID, "doc", "", "Summary", "wrong summary", 0.6, false)
+			b, _ := json.Marshal(map[string]any{"id": k.ID, "text": "corrected summary", "pinned": true})
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/update", bytes.NewReader(b)))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("update code=%d body=%s", rr.Code, rr.Body.String())
+			}
+			list, _ := st.ListKnowledge(p.ID, 0)
+			if len(list) != 1 || list[0].Text != "corrected summary" || !list[0].Pinned {
+				t.Fatalf("list does not reflect update: %+v", list[0])
+			}
+			// untouched fields keep their values
+			if list[0].Title != "Summary" || list[0].TrustScore != 0.6 {
+				t.Fatalf("unexpected field change: %+v", list[0])
+			}
+			b, _ = json.Marshal(map[string]any{"id": "kn-missing", "title": "x"})
+			rr = httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/update", bytes.NewReader(b)))
+			if rr.Code != http.StatusNotFound {
+				t.Fatalf("expected 404, got %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestKnowledgeDecaySQLite(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(st, nil)
+	mux := api.mux()
+	p := st.CreateProject("kn", t.TempDir(), nil)
+	k, _ := st.AddKnowledge(p.ID, "web", "https://a.example", "A", "a", 0.5, false)
+	pinned, _ := st.AddKnowledge(p.ID, "web", "https://b.example", "B", "b", 0.9, true)
+	decay := func(body map[string]any) (int, map[string]any) {
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/knowledge/decay", bytes.NewReader(b)))
+		var res map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+	code, res := decay(map[string]any{"projectID": p.ID, "rate": 0.2, "afterDays": 0})
+	if code != http.StatusOK || res["updated"] != float64(1) {
+		t.Fatalf("decay code=%d res=%v", code, res)
+	}
+	if got, _, _ := st.GetKnowledge(k.ID); got.TrustScore < 0.29 || got.TrustScore > 0.31 {
+		t.Fatalf("expected trust 0.3 after decay, got %v", got.TrustScore)
+	}
+	if got, _, _ := st.GetKnowledge(pinned.ID); got.TrustScore != 0.9 {
+		t.Fatalf("pinned item must not decay, got %v", got.TrustScore)
+	}
+	// defaults come from env; fresh items are skipped with the default 30 days
+	t.Setenv("MYCODER_KNOWLEDGE_DECAY_RATE", "0.1")
+	if code, res := decay(map[string]any{"projectID": p.ID}); code != http.StatusOK || res["updated"] != float64(0) || res["rate"] != 0.1 || res["afterDays"] != float64(30) {
+		t.Fatalf("default decay code=%d res=%v", code, res)
+	}
+	api.readOnly = true
+	if code, _ := decay(map[string]any{"projectID": p.ID}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 in read-only mode, got %d", code)
+	}
+}
+
+func TestKnowledgeListPagination(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("kn", t.TempDir(), nil)
+	for i := 0; i < 25; i++ {
+		_, _ = st.AddKnowledge(p.ID, "doc", "", fmt.Sprintf("item-%02d", i), "x", float64(i)/100, false)
+	}
+	seen := map[string]bool{}
+	last := 2.0
+	for offset := 0; offset < 30; offset += 10 {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/knowledge?projectID=%s&limit=10&offset=%d", p.ID, offset), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("list code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Knowledge []struct {
+				ID         string
+				TrustScore float64
+			}
+			Total int
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		if res.Total != 25 {
+			t.Fatalf("expected total 25, got %d", res.Total)
+		}
+		want := 10
+		if offset == 20 {
+			want = 5
+		}
+		if len(res.Knowledge) != want {
+			t.Fatalf("offset %d: expected %d items, got %d", offset, want, len(res.Knowledge))
+		}
+		for _, k := range res.Knowledge {
+			if seen[k.ID] {
+				t.Fatalf("item %s returned twice", k.ID)
+			}
+			if k.TrustScore > last {
+				t.Fatalf("trust ordering broken at %s", k.ID)
+			}
+			seen[k.ID], last = true, k.TrustScore
+		}
+	}
+	if len(seen) != 25 {
+		t.Fatalf("expected 25 distinct items, got %d", len(seen))
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge?projectID="+p.ID+"&limit=-1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative limit, got %d", rr.Code)
+	}
+}
+
+func TestKnowledgeGetReportsStoreErrors(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+	_ = st.DB().Close()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge/kn-1", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 on a closed store, got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	oai "mycoder/internal/llm/openai"
+	mylog "mycoder/internal/log"
+)
+
+func TestProbeLLMWarnsWhenUnreachable(t *testing.T) {
+	// nothing listens on port 1
+	t.Setenv("MYCODER_OPENAI_BASE_URL", "http://127.0.0.1:1/v1")
+	var buf bytes.Buffer
+	ok := probeLLM(context.Background(), oai.NewFromEnv(), mylog.NewWriter(&buf), time.Second)
+	if ok {
+		t.Fatalf("expected probe to fail")
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"llm.unreachable"`) || !strings.Contains(out, `"level":"warn"`) {
+		t.Fatalf("expected warning, got %s", out)
+	}
+	if !strings.Contains(out, `"url":"http://127.0.0.1:1/v1"`) {
+		t.Fatalf("warning should include the URL tried: %s", out)
+	}
+}
+
+func TestProbeLLMReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"m1"}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("MYCODER_OPENAI_BASE_URL", srv.URL)
+	var buf bytes.Buffer
+	if !probeLLM(context.Background(), oai.NewFromEnv(), mylog.NewWriter(&buf), time.Second) {
+		t.Fatalf("expected probe to pass: %s", buf.String())
+	}
+}
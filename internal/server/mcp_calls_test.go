@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"mycoder/internal/models"
+	"mycoder/internal/store"
+)
+
+func TestMCPCallsAuditHistory(t *testing.T) {
+	old := os.Getenv("MYCODER_MCP_ALLOWED_TOOLS")
+	t.Cleanup(func() { _ = os.Setenv("MYCODER_MCP_ALLOWED_TOOLS", old) })
+	_ = os.Setenv("MYCODER_MCP_ALLOWED_TOOLS", "echo,time")
+
+	mux := NewAPI(store.New(), nil).mux()
+	call := func(body string) int {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mcp/call", bytes.NewBufferString(body)))
+		return rr.Code
+	}
+	long := strings.Repeat("x", 500)
+	if c := call(`{"name":"echo","params":{"text":"` + long + `","apiKey":"sk-123"}}`); c != http.StatusOK {
+		t.Fatalf("echo code=%d", c)
+	}
+	if c := call(`{"name":"echo","params":{}}`); c != http.StatusOK {
+		t.Fatalf("invalid echo code=%d", c)
+	}
+	if c := call(`{"name":"fs_read","params":{"projectID":"p","path":"a"}}`); c != http.StatusForbidden {
+		t.Fatalf("disallowed tool code=%d", c)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mcp/calls", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("calls code=%d", rr.Code)
+	}
+	var res struct {
+		Calls []models.MCPCall `json:"calls"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(res.Calls) != 3 {
+		t.Fatalf("expected 3 calls, got %+v", res.Calls)
+	}
+	// newest first
+	denied, invalid, ok := res.Calls[0], res.Calls[1], res.Calls[2]
+	if denied.Tool != "fs_read" || denied.Outcome != "denied" || denied.Status != http.StatusForbidden {
+		t.Fatalf("denied call: %+v", denied)
+	}
+	if invalid.Outcome != "error" || !strings.Contains(invalid.Error, "text") {
+		t.Fatalf("invalid call: %+v", invalid)
+	}
+	if ok.Tool != "echo" || ok.Outcome != "ok" {
+		t.Fatalf("ok call: %+v", ok)
+	}
+	if ok.Params["apiKey"] != "***" || strings.Contains(ok.Params["apiKey"], "sk-") {
+		t.Fatalf("secret param not redacted: %q", ok.Params["apiKey"])
+	}
+	if len(ok.Params["text"]) >= len(long) || !strings.Contains(ok.Params["text"], "(500 bytes)") {
+		t.Fatalf("long param not truncated: %q", ok.Params["text"])
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mcp/calls?tool=echo&limit=1", nil))
+	res.Calls = nil
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res.Calls) != 1 || res.Calls[0].Tool != "echo" {
+		t.Fatalf("filtered calls: %+v", res.Calls)
+	}
+}
@@ -2,9 +2,14 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"mycoder/internal/store"
@@ -79,6 +84,42 @@ func TestMCPCallValidation(t *testing.T) {
 	}
 }
 
+func TestMCPCallSchemaTypeAndEnum(t *testing.T) {
+	api := NewAPI(store.New(), nil)
+	mux := api.mux()
+	call := func(params map[string]any) map[string]any {
+		body, _ := json.Marshal(map[string]any{"name": "run_hooks", "params": params})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mcp/call", bytes.NewReader(body)))
+		var res map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return res
+	}
+	// wrong type
+	res := call(map[string]any{"projectID": "p1", "timeoutSec": "soon"})
+	if ok, _ := res["ok"].(bool); ok || res["error"] != "param timeoutSec must be number" {
+		t.Fatalf("expected type error, got %v", res)
+	}
+	if v, _ := res["validation"].([]any); len(v) != 1 {
+		t.Fatalf("expected one validation entry, got %v", res["validation"])
+	}
+	// out-of-enum value
+	res = call(map[string]any{"projectID": "p1", "runner": "gradle"})
+	if ok, _ := res["ok"].(bool); ok || res["error"] != "param runner must be one of make|npm|just" {
+		t.Fatalf("expected enum error, got %v", res)
+	}
+	// the raw runner is not reachable over MCP
+	res = call(map[string]any{"projectID": "p1", "runner": "raw"})
+	if ok, _ := res["ok"].(bool); ok {
+		t.Fatalf("expected raw runner to be rejected, got %v", res)
+	}
+	// numeric strings are coerced
+	params := map[string]any{"timeoutSec": "30"}
+	if errs := validateMCPParams([]mcpParam{{Name: "timeoutSec", Type: "number"}}, params); len(errs) != 0 || params["timeoutSec"] != float64(30) {
+		t.Fatalf("expected coercion, got errs=%v params=%v", errs, params)
+	}
+}
+
 func TestMCPAllowlistAndScope(t *testing.T) {
 	// allow only echo
 	t.Setenv("MYCODER_MCP_ALLOWED_TOOLS", "echo")
@@ -112,3 +153,176 @@ func TestMCPAllowlistAndScope(t *testing.T) {
 		t.Fatalf("expected 200 with scope, got %d", rr3.Code)
 	}
 }
+
+func TestMCPSearchAndFSTools(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.go"), []byte("package hello\n// greeting widget\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	p := st.CreateProject("mcp", dir, nil)
+	st.AddDocument(p.ID, "hello.go", "package hello\n// greeting widget\n")
+	api := NewAPI(st, nil)
+	mux := api.mux()
+
+	call := func(name string, params map[string]any) map[string]any {
+		body, _ := json.Marshal(map[string]any{"name": name, "params": params})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mcp/call", bytes.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: code=%d body=%s", name, rr.Code, rr.Body.String())
+		}
+		var res map[string]any
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := call("search", map[string]any{"q": "widget", "projectID": p.ID})
+	if ok, _ := res["ok"].(bool); !ok {
+		t.Fatalf("search failed: %v", res)
+	}
+	results, _ := res["result"].(map[string]any)["results"].([]any)
+	if len(results) == 0 || !strings.Contains(fmt.Sprint(results[0]), "hello.go") {
+		t.Fatalf("expected hello.go in search results, got %v", res["result"])
+	}
+
+	res = call("fs_read", map[string]any{"projectID": p.ID, "path": "hello.go"})
+	if content, _ := res["result"].(map[string]any)["content"].(string); !strings.Contains(content, "greeting") {
+		t.Fatalf("fs_read unexpected: %v", res)
+	}
+	res = call("fs_read", map[string]any{"projectID": p.ID, "path": "../x"})
+	if ok, _ := res["ok"].(bool); ok {
+		t.Fatalf("fs_read outside project should fail: %v", res)
+	}
+
+	res = call("fs_list", map[string]any{"projectID": p.ID})
+	if !strings.Contains(fmt.Sprint(res["result"]), "hello.go") {
+		t.Fatalf("fs_list missing hello.go: %v", res)
+	}
+
+	res = call("search", map[string]any{})
+	if ok, _ := res["ok"].(bool); ok || res["error"] != "missing param: q" {
+		t.Fatalf("expected missing q error, got %v", res)
+	}
+}
+
+func TestMCPRunHooksRespectsReadOnly(t *testing.T) {
+	t.Setenv("MYCODER_READONLY", "1")
+	st := store.New()
+	p := st.CreateProject("mcp", t.TempDir(), nil)
+	api := NewAPI(st, nil)
+	body, _ := json.Marshal(map[string]any{"name": "run_hooks", "params": map[string]any{"projectID": p.ID}})
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mcp/call", bytes.NewReader(body)))
+	var res map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if ok, _ := res["ok"].(bool); ok || res["error"] != "read-only mode" {
+		t.Fatalf("expected read-only rejection, got %v", res)
+	}
+}
+
+func TestMCPStdioToolsList(t *testing.T) {
+	api := NewAPI(store.New(), nil)
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":"c3","method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"nope"}`,
+	}, "\n"))
+	var out bytes.Buffer
+	if err := api.ServeMCP(context.Background(), in, &out, ""); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 responses (notification has none), got %d:\n%s", len(lines), out.String())
+	}
+	type rpcResp struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  map[string]any  `json:"result"`
+		Error   *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	var resps []rpcResp
+	for _, l := range lines {
+		var r rpcResp
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("bad response %q: %v", l, err)
+		}
+		if r.JSONRPC != "2.0" {
+			t.Fatalf("missing jsonrpc version: %s", l)
+		}
+		resps = append(resps, r)
+	}
+	if resps[0].Result["protocolVersion"] == nil || resps[0].Result["capabilities"] == nil {
+		t.Fatalf("initialize result incomplete: %v", resps[0].Result)
+	}
+	if string(resps[1].ID) != "2" {
+		t.Fatalf("tools/list id mismatch: %s", resps[1].ID)
+	}
+	tools, _ := resps[1].Result["tools"].([]any)
+	if len(tools) == 0 {
+		t.Fatalf("expected tools in list: %v", resps[1].Result)
+	}
+	first, _ := tools[0].(map[string]any)
+	if schema, _ := first["inputSchema"].(map[string]any); schema["type"] != "object" {
+		t.Fatalf("expected inputSchema object: %v", first)
+	}
+	if string(resps[2].ID) != `"c3"` || resps[2].Result["isError"] != false || !strings.Contains(fmt.Sprint(resps[2].Result["content"]), "hi") {
+		t.Fatalf("unexpected tools/call result: %s %v", resps[2].ID, resps[2].Result)
+	}
+	if resps[3].Error == nil || resps[3].Error.Code != -32601 {
+		t.Fatalf("expected method not found error, got %s", lines[3])
+	}
+}
+
+func TestMCPToolsJSONSchemaFormat(t *testing.T) {
+	mux := NewAPI(store.New(), nil).mux()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mcp/tools?format=jsonschema", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d", rr.Code)
+	}
+	var res struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			InputSchema struct {
+				Type       string                    `json:"type"`
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var echoFound bool
+	for _, tool := range res.Tools {
+		switch tool.Name {
+		case "echo":
+			echoFound = true
+			s := tool.InputSchema
+			if s.Type != "object" || s.Properties["text"]["type"] != "string" || len(s.Required) != 1 || s.Required[0] != "text" {
+				t.Fatalf("echo inputSchema invalid: %+v", s)
+			}
+		case "run_hooks":
+			if enum, _ := tool.InputSchema.Properties["runner"]["enum"].([]any); len(enum) != 3 {
+				t.Fatalf("run_hooks runner enum missing: %+v", tool.InputSchema.Properties["runner"])
+			}
+		}
+	}
+	if !echoFound {
+		t.Fatalf("echo tool not found")
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mcp/tools?format=yaml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format code=%d, want 400", rr.Code)
+	}
+}
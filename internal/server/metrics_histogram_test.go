@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mycoder/internal/store"
+)
+
+func TestMetricsDurationHistogram(t *testing.T) {
+	t.Setenv("MYCODER_METRICS_BUCKETS", "0.5, 0.1,bogus,1")
+	old := metrics
+	t.Cleanup(func() { metrics = old })
+	metrics = newMetrics()
+	if got := metrics.buckets; len(got) != 3 || got[0] != 0.1 || got[1] != 0.5 || got[2] != 1 {
+		t.Fatalf("unexpected buckets: %v", got)
+	}
+
+	metrics.mu.Lock()
+	for _, d := range []time.Duration{50 * time.Millisecond, 200 * time.Millisecond, 700 * time.Millisecond, 3 * time.Second, 80 * time.Millisecond} {
+		metrics.observeDuration("GET|/healthz", d.Seconds())
+	}
+	metrics.mu.Unlock()
+
+	api := NewAPI(store.New(), nil)
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "# TYPE mycoder_http_request_duration_seconds histogram") {
+		t.Fatalf("missing histogram TYPE line:\n%s", body)
+	}
+
+	prefix := `mycoder_http_request_duration_seconds_bucket{method="GET",path="/healthz",le="`
+	var les []string
+	var counts []int
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		rest := line[len(prefix):]
+		i := strings.Index(rest, `"}`)
+		n, err := strconv.Atoi(strings.TrimSpace(rest[i+2:]))
+		if err != nil {
+			t.Fatalf("bad bucket line %q", line)
+		}
+		les = append(les, rest[:i])
+		counts = append(counts, n)
+	}
+	if strings.Join(les, ",") != "0.1,0.5,1,+Inf" {
+		t.Fatalf("unexpected le labels: %v", les)
+	}
+	for i := 1; i < len(counts); i++ {
+		if counts[i] < counts[i-1] {
+			t.Fatalf("bucket counts not monotonic: %v", counts)
+		}
+	}
+	if want := []int{2, 3, 4, 5}; !equalInts(counts, want) {
+		t.Fatalf("bucket counts=%v want %v", counts, want)
+	}
+	if !strings.Contains(body, `mycoder_http_request_duration_seconds_count{method="GET",path="/healthz"} 5`) {
+		t.Fatalf("expected _count 5 matching +Inf bucket:\n%s", body)
+	}
+	if !strings.Contains(body, `mycoder_http_request_duration_seconds_sum{method="GET",path="/healthz"}`) {
+		t.Fatalf("missing _sum line")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
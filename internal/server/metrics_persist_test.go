@@ -0,0 +1,61 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestMetricsPersistResumesAfterReopen(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "db.sqlite")
+	st, err := store.NewSQLite(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newMetrics()
+	m.mu.Lock()
+	m.reqTotal["GET|/healthz|200"] = 7
+	m.observeDuration("GET|/healthz", 0.02)
+	m.chatRequests = 3
+	m.chatPromptTokens, m.chatCompletionTokens = 120, 45
+	m.embedCacheHits, m.embedCacheMisses = 5, 2
+	m.chatDuration.observe(m.buckets, 1.5)
+	m.mu.Unlock()
+	if err := persistMetrics(m, st); err != nil {
+		t.Fatal(err)
+	}
+	_ = st.DB().Close()
+
+	// "restart": reopen the store and restore into a fresh collector
+	st2, err := store.NewSQLite(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2 := newMetrics()
+	if err := restoreMetrics(m2, st2); err != nil {
+		t.Fatal(err)
+	}
+	m2.mu.Lock()
+	m2.chatRequests++ // counting continues from the saved value
+	m2.mu.Unlock()
+	if m2.chatRequests != 4 || m2.chatPromptTokens != 120 || m2.chatCompletionTokens != 45 {
+		t.Fatalf("chat counters not resumed: req=%d prompt=%d completion=%d", m2.chatRequests, m2.chatPromptTokens, m2.chatCompletionTokens)
+	}
+	if m2.embedCacheHits != 5 || m2.embedCacheMisses != 2 {
+		t.Fatalf("cache counters not resumed: hits=%d misses=%d", m2.embedCacheHits, m2.embedCacheMisses)
+	}
+	if m2.reqTotal["GET|/healthz|200"] != 7 || m2.durCount["GET|/healthz"] != 1 {
+		t.Fatalf("http series not resumed: %+v %+v", m2.reqTotal, m2.durCount)
+	}
+	if m2.chatDuration.total != 1 || m2.chatDuration.sum != 1.5 {
+		t.Fatalf("chat duration histogram not resumed: %+v", m2.chatDuration)
+	}
+
+	var sb strings.Builder
+	writeHistogram(&sb, "h", "", m2.buckets, m2.durBuckets["GET|/healthz"], m2.durSum["GET|/healthz"], m2.durCount["GET|/healthz"])
+	if !strings.Contains(sb.String(), `h_bucket{le="0.025"} 1`) {
+		t.Fatalf("duration bucket not restored:\n%s", sb.String())
+	}
+}
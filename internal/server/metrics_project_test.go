@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestMetricsPerProjectGauges(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1 := st.CreateProject("one", t.TempDir(), nil)
+	p2 := st.CreateProject("two", t.TempDir(), nil)
+	st.AddDocument(p1.ID, "a.go", "package a")
+	st.AddDocument(p1.ID, "b.go", "package b")
+	st.AddDocument(p2.ID, "c.go", "package c")
+	if _, err := st.AddKnowledge(p2.ID, "code", "c.go", "note", "text", 0.9, false); err != nil {
+		t.Fatal(err)
+	}
+
+	api := NewAPI(st, nil)
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	for _, want := range []string{
+		`mycoder_documents{project="` + p1.ID + `"} 2`,
+		`mycoder_documents{project="` + p2.ID + `"} 1`,
+		`mycoder_knowledge{project="` + p1.ID + `"} 0`,
+		`mycoder_knowledge{project="` + p2.ID + `"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestMetricsResetClearsCounters(t *testing.T) {
+	t.Setenv("MYCODER_METRICS_ALLOW_RESET", "1")
+	old := metrics
+	t.Cleanup(func() { metrics = old })
+	metrics = newMetrics()
+	metricsSampleRate = 1.0
+
+	api := NewAPI(store.New(), nil)
+	h := logMiddleware(api.mux())
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/metrics/reset", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("reset code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var snap struct {
+		Requests map[string]int `json:"requests"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Requests["GET|/healthz|200"] != 3 {
+		t.Fatalf("expected pre-reset snapshot with 3 healthz requests, got %v", snap.Requests)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	// the reset request itself is recorded after the handler returns
+	for k, v := range metrics.reqTotal {
+		if k != "POST|/metrics/reset|200" {
+			t.Fatalf("unexpected counter after reset: %s=%d", k, v)
+		}
+	}
+	if metrics.chatRequests != 0 || metrics.chatTokenEvents != 0 || metrics.embedCacheHits != 0 {
+		t.Fatalf("chat/embed counters not cleared")
+	}
+}
+
+func TestMetricsResetDisabledByDefault(t *testing.T) {
+	t.Setenv("MYCODER_METRICS_ALLOW_RESET", "")
+	api := NewAPI(store.New(), nil)
+	rr := httptest.NewRecorder()
+	api.mux().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/metrics/reset", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 when reset not allowed, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestProjectIgnoreAppliedOnIndex(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":        "package main\n",
+		"gen/api.go":     "package gen\n",
+		"gen/models.go":  "package gen\n",
+		"docs/readme.md": "# docs\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+
+	b, _ := json.Marshal(map[string]any{"name": "ign", "rootPath": dir, "ignore": []string{"gen/*"}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/projects", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("create code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var created struct{ ProjectID string }
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+
+	// ignore globs are persisted and returned
+	p, ok := st.GetProject(created.ProjectID)
+	if !ok || len(p.Ignore) != 1 || p.Ignore[0] != "gen/*" {
+		t.Fatalf("ignore not persisted: %+v", p)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/projects", nil))
+	if !strings.Contains(rr.Body.String(), `"ignore":["gen/*"]`) {
+		t.Fatalf("list should include ignore: %s", rr.Body.String())
+	}
+
+	// stored globs merge with the request's exclude list
+	b, _ = json.Marshal(map[string]any{"projectID": created.ProjectID, "exclude": []string{"docs/*"}})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run/stream", bytes.NewReader(b)))
+	if !strings.Contains(rr.Body.String(), `"documents":1`) {
+		t.Fatalf("expected only main.go to be indexed:\n%s", rr.Body.String())
+	}
+	if n := st.Stats()["documents"]; n != 1 {
+		t.Fatalf("documents=%d, want 1", n)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/store"
+)
+
+func newManyHitsProject(t *testing.T, n int) (*store.Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("f%d.go", i)
+		_ = os.WriteFile(filepath.Join(dir, name), []byte("func Alpha() {}\n"), 0o644)
+		st.AddDocument(p.ID, name, "func Alpha() {}\n")
+	}
+	return st, p.ID
+}
+
+func TestRAGMaxSnippetsKeepsBestRanked(t *testing.T) {
+	st, pid := newManyHitsProject(t, 6)
+	// trust pushes the alphabetically last file to the top
+	_, _ = st.AddKnowledge(pid, "code", "f5.go", "Alpha", "", 0.4, false)
+	api := NewAPI(st, nil)
+	msgs := []llm.Message{{Role: llm.RoleUser, Content: "Alpha"}}
+
+	_, all := api.ragContext(msgs, pid, ragOptions{K: 6})
+	if len(all.Citations) <= 2 {
+		t.Fatalf("expected broad retrieval without a cap, got %d", len(all.Citations))
+	}
+	_, res := api.ragContext(msgs, pid, ragOptions{K: 6, MaxSnippets: 1})
+	if len(res.Citations) != 1 || res.Citations[0].Path != "f5.go" {
+		t.Fatalf("citations=%+v", res.Citations)
+	}
+
+	t.Setenv("MYCODER_RAG_MAX_SNIPPETS", "2")
+	_, res = api.ragContext(msgs, pid, ragOptions{K: 6})
+	if len(res.Citations) != 2 {
+		t.Fatalf("env cap: citations=%+v", res.Citations)
+	}
+}
+
+func TestChatRetrievalMaxSnippets(t *testing.T) {
+	st, pid := newManyHitsProject(t, 6)
+	mux := NewAPI(st, nil).mux()
+	post := func(maxSnippets int) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]any{
+			"messages":      []map[string]any{{"role": "user", "content": "Alpha"}},
+			"projectID":     pid,
+			"retrieval":     map[string]any{"k": 6, "maxSnippets": maxSnippets},
+			"retrievalOnly": true,
+		})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+		return rr
+	}
+	rr := post(3)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Citations []json.RawMessage `json:"citations"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if len(out.Citations) != 3 {
+		t.Fatalf("want 3 citations, got %d", len(out.Citations))
+	}
+	if rr := post(-1); rr.Code != http.StatusBadRequest {
+		t.Fatalf("negative maxSnippets: code=%d", rr.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/store"
+)
+
+func TestRAGInjectStrategies(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("func Alpha() {}\n"), 0o644)
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "a.go", "func Alpha() {}\n")
+	msgs := []llm.Message{
+		{Role: llm.RoleUser, Content: "first"},
+		{Role: llm.RoleAssistant, Content: "ok"},
+		{Role: llm.RoleUser, Content: "Alpha"},
+	}
+
+	out, res := api.ragContext(msgs, p.ID, ragOptions{K: 1, Strategy: ragInjectSystem})
+	if len(out) != 4 || out[0].Role != llm.RoleSystem || out[0].Content != res.Context {
+		t.Fatalf("system: %+v", out)
+	}
+
+	out, res = api.ragContext(msgs, p.ID, ragOptions{K: 1, Strategy: ragInjectAppendUser})
+	if len(out) != 3 || out[2].Content != res.Context+"\n\nAlpha" || out[0].Content != "first" {
+		t.Fatalf("append_user: %+v", out)
+	}
+
+	out, res = api.ragContext(msgs, p.ID, ragOptions{K: 1, Strategy: ragInjectSeparate})
+	if len(out) != 4 || out[2].Role != llm.RoleUser || out[2].Content != res.Context || out[3].Content != "Alpha" {
+		t.Fatalf("separate: %+v", out)
+	}
+	if !strings.Contains(res.Context, "a.go") {
+		t.Fatalf("context missing hit: %q", res.Context)
+	}
+	// the caller's slice is left untouched
+	if msgs[2].Content != "Alpha" {
+		t.Fatalf("input mutated: %+v", msgs)
+	}
+}
+
+func TestChatRetrievalStrategyOverridesEnv(t *testing.T) {
+	t.Setenv("MYCODER_RAG_INJECT_STRATEGY", "append_user")
+	var got []llm.Message
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		got = messages
+		return &mockChatStream{}, nil
+	}}
+	st := store.New()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	st.AddDocument(p.ID, "a.go", "func Alpha() {}\n")
+	mux := NewAPI(st, prov).mux()
+	chat := func(strategy string) int {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{
+			"messages":  []map[string]any{{"role": "user", "content": "Alpha"}},
+			"projectID": p.ID,
+			"retrieval": map[string]any{"strategy": strategy},
+		})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(b)))
+		return rr.Code
+	}
+	if code := chat(""); code != http.StatusOK || len(got) != 1 || !strings.HasSuffix(got[0].Content, "\n\nAlpha") {
+		t.Fatalf("env strategy: code=%d %+v", code, got)
+	}
+	if code := chat("system"); code != http.StatusOK || len(got) != 2 || got[0].Role != llm.RoleSystem {
+		t.Fatalf("request strategy: code=%d %+v", code, got)
+	}
+	if code := chat("bogus"); code != http.StatusBadRequest {
+		t.Fatalf("unknown strategy: code=%d", code)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"mycoder/internal/llm"
+	"mycoder/internal/models"
 	"mycoder/internal/store"
 )
 
@@ -34,3 +35,38 @@ func TestWithRAGContextTrustRerank(t *testing.T) {
 		t.Fatalf("expected a.go before b.go in system context: %q", sys)
 	}
 }
+
+func TestWithRAGContextInjectsKnowledgeText(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("func A(){}\n"), 0o644)
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "a.go", "func A(){}\n")
+	_, _ = st.AddKnowledge(p.ID, "web", "https://low.example", "Low", "low trust body", 0.6, false)
+	_, _ = st.AddKnowledge(p.ID, "web", "https://go.dev/a", "A guide", "A must be called before B.", 0.95, false)
+	_, _ = st.AddKnowledge(p.ID, "code", "a.go", "Pinned card", "pinned body", 0.7, true)
+
+	msgs := []llm.Message{{Role: llm.RoleUser, Content: "func A"}}
+	out := api.withRAGContext(msgs, p.ID, 2)
+	var kn string
+	for _, m := range out {
+		if m.Role == llm.RoleSystem && strings.HasPrefix(m.Content, "Curated Knowledge") {
+			kn = m.Content
+		}
+	}
+	if !strings.Contains(kn, "A must be called before B.") {
+		t.Fatalf("expected knowledge text in context: %q", kn)
+	}
+	// pinned first, then by trust
+	ip, ia, il := strings.Index(kn, "pinned body"), strings.Index(kn, "A must be called"), strings.Index(kn, "low trust body")
+	if ip == -1 || il == -1 || !(ip < ia && ia < il) {
+		t.Fatalf("unexpected ordering: %q", kn)
+	}
+
+	// sub-budget trims texts but keeps titles
+	block := curatedKnowledgeBlock([]*models.Knowledge{{SourceType: "web", Title: "T", Text: strings.Repeat("x", 50), TrustScore: 0.9}}, 3, 10)
+	if !strings.Contains(block, "[web] T") || strings.Contains(block, strings.Repeat("x", 11)) {
+		t.Fatalf("expected trimmed text: %q", block)
+	}
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"mycoder/internal/store"
 )
@@ -130,3 +132,128 @@ func TestIPRateLimitPerClient(t *testing.T) {
 		t.Fatalf("B expected 200, got %d", rrB.Code)
 	}
 }
+
+func TestRateLimitHammerMatchesConfiguredRPS(t *testing.T) {
+	for _, k := range []string{"MYCODER_RATE_LIMIT_RPS", "MYCODER_RATE_LIMIT_PATH_RPS", "MYCODER_RATE_LIMIT_IP_RPS", "MYCODER_RATE_LIMIT_GLOBAL_RPS"} {
+		old := os.Getenv(k)
+		k := k
+		t.Cleanup(func() { _ = os.Setenv(k, old) })
+		_ = os.Setenv(k, "")
+	}
+	_ = os.Setenv("MYCODER_RATE_LIMIT_GLOBAL_RPS", "5")
+
+	api := NewAPI(store.New(), nil)
+	clock := time.Unix(1700000000, 0)
+	h := rateLimitMiddlewareClock(api.mux(), func() time.Time { return clock })
+
+	// hammer for ~1.2s of simulated time: expect the initial burst (5) plus
+	// 5 rps refill, not a halved rate on the throttled path
+	allowed, throttled := 0, 0
+	for step := 0; step < 240; step++ {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = "198.51.100.9:1"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		switch rr.Code {
+		case http.StatusOK:
+			allowed++
+		case http.StatusTooManyRequests:
+			throttled++
+		}
+		clock = clock.Add(5 * time.Millisecond)
+	}
+	if throttled == 0 {
+		t.Fatalf("expected some requests to be throttled")
+	}
+	// 240 steps span 1.195s: 5 burst + 5 whole refilled tokens
+	if allowed != 10 {
+		t.Fatalf("allowed=%d, want 10 (burst 5 + 5rps*1.195s)", allowed)
+	}
+}
+
+func TestTokenRateLimitIndependentBuckets(t *testing.T) {
+	for _, k := range []string{"MYCODER_RATE_LIMIT_RPS", "MYCODER_RATE_LIMIT_PATH_RPS", "MYCODER_RATE_LIMIT_IP_RPS", "MYCODER_RATE_LIMIT_GLOBAL_RPS", "MYCODER_RATE_LIMIT_TOKEN_RPS"} {
+		old := os.Getenv(k)
+		k := k
+		t.Cleanup(func() { _ = os.Setenv(k, old) })
+		_ = os.Setenv(k, "")
+	}
+	_ = os.Setenv("MYCODER_RATE_LIMIT_TOKEN_RPS", "1")
+	t.Setenv("MYCODER_API_TOKENS", "alice:alice-token,bob:bob-token")
+
+	api := NewAPI(store.New(), nil)
+	h := logMiddleware(rateLimitMiddleware(api.mux()))
+
+	// both clients share one proxy IP
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = "203.0.113.50:8080"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if c := do("alice-token"); c != http.StatusOK {
+		t.Fatalf("alice first expected 200, got %d", c)
+	}
+	if c := do("alice-token"); c != http.StatusTooManyRequests {
+		t.Fatalf("alice second expected 429, got %d", c)
+	}
+	if c := do("bob-token"); c != http.StatusOK {
+		t.Fatalf("bob expected independent bucket (200), got %d", c)
+	}
+	// no token falls back to the IP bucket
+	if c := do(""); c != http.StatusOK {
+		t.Fatalf("anonymous first expected 200, got %d", c)
+	}
+	if c := do(""); c != http.StatusTooManyRequests {
+		t.Fatalf("anonymous second expected 429, got %d", c)
+	}
+}
+
+func TestTokenRateLimitIgnoresUnknownTokens(t *testing.T) {
+	for _, k := range []string{"MYCODER_RATE_LIMIT_RPS", "MYCODER_RATE_LIMIT_PATH_RPS", "MYCODER_RATE_LIMIT_IP_RPS", "MYCODER_RATE_LIMIT_GLOBAL_RPS"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("MYCODER_RATE_LIMIT_TOKEN_RPS", "1")
+	t.Setenv("MYCODER_API_TOKENS", "alice:alice-token")
+
+	now := time.Unix(0, 0)
+	h := rateLimitMiddlewareClock(NewAPI(store.New(), nil).mux(), func() time.Time { return now })
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = "203.0.113.60:8080"
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	// made-up tokens all land in the client IP bucket
+	limited := 0
+	for i := 0; i < 20; i++ {
+		if do(fmt.Sprintf("random-%d", i)) == http.StatusTooManyRequests {
+			limited++
+		}
+	}
+	if limited != 19 {
+		t.Fatalf("expected 19 of 20 random-token requests limited, got %d", limited)
+	}
+	if c := do("alice-token"); c != http.StatusOK {
+		t.Fatalf("configured token expected its own bucket (200), got %d", c)
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	rl := newRateLimiter(1, func() time.Time { return now })
+	for i := 0; i < 100; i++ {
+		rl.allow(fmt.Sprintf("ip:%d", i))
+	}
+	now = now.Add(rateBucketIdleTTL)
+	rl.allow("ip:fresh")
+	if n := len(rl.buckets); n != 1 {
+		t.Fatalf("expected idle buckets evicted, %d left", n)
+	}
+}
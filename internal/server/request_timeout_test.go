@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mycoder/internal/llm"
+	"mycoder/internal/store"
+)
+
+func TestRequestTimeoutReturns503(t *testing.T) {
+	os.Setenv("MYCODER_REQUEST_TIMEOUT", "50ms")
+	defer os.Unsetenv("MYCODER_REQUEST_TIMEOUT")
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	h := timeoutMiddleware(slow)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
+
+func TestRequestTimeoutSkipsStreaming(t *testing.T) {
+	os.Setenv("MYCODER_REQUEST_TIMEOUT", "20ms")
+	defer os.Unsetenv("MYCODER_REQUEST_TIMEOUT")
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		if _, ok := w.(http.Flusher); !ok {
+			t.Errorf("streaming response writer should support flushing")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := timeoutMiddleware(slow)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/index/run/stream", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("stream path: expected 200, got %d", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("event-stream accept: expected 200, got %d", rr.Code)
+	}
+}
+
+func TestRequestTimeoutSkipsChatStreamBody(t *testing.T) {
+	os.Setenv("MYCODER_REQUEST_TIMEOUT", "1m")
+	defer os.Unsetenv("MYCODER_REQUEST_TIMEOUT")
+	n := 0
+	prov := &mockChatProvider{chatFn: func(ctx context.Context, model string, messages []llm.Message, stream bool, temperature float32) (llm.ChatStream, error) {
+		return &mockChatStream{RecvFn: func() (string, bool, error) {
+			n++
+			return fmt.Sprintf("tok%d", n), n == 2, nil
+		}}, nil
+	}}
+	h := timeoutMiddleware(NewAPI(store.New(), prov).mux())
+
+	body := `{"messages":[{"role":"user","content":"hi"}],"stream":true}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if !rr.Flushed {
+		t.Fatalf("stream:true chat response was not flushed")
+	}
+	if !strings.Contains(rr.Body.String(), "data: tok1") || !strings.Contains(rr.Body.String(), "event: done") {
+		t.Fatalf("body=%q", rr.Body.String())
+	}
+	// non-streaming chat bodies still get the deadline
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"stream":false}`))
+	if isStreamingRequest(req) {
+		t.Fatalf("stream:false body treated as streaming")
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != `{"stream":false}` {
+		t.Fatalf("body not replayed: %q", b)
+	}
+}
+
+func TestRequestTimeoutDisabled(t *testing.T) {
+	os.Setenv("MYCODER_REQUEST_TIMEOUT", "0")
+	defer os.Unsetenv("MYCODER_REQUEST_TIMEOUT")
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := r.Context().Deadline(); ok {
+			t.Errorf("unexpected deadline when timeout disabled")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	rr := httptest.NewRecorder()
+	timeoutMiddleware(slow).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/models"
+	"mycoder/internal/store"
+)
+
+func TestSearchIncludeContent(t *testing.T) {
+	t.Setenv("MYCODER_SEARCH_CONTENT_MAX_BYTES", "64")
+	dir := t.TempDir()
+	small := "package a\n// needle\n"
+	large := "package b\n// needle\n" + strings.Repeat("x", 100) + "\n"
+	_ = os.WriteFile(filepath.Join(dir, "small.go"), []byte(small), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "large.go"), []byte(large), 0o644)
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "small.go", small)
+	st.AddDocument(p.ID, "large.go", large)
+	mux := NewAPI(st, nil).mux()
+
+	type result struct {
+		Path           string `json:"path"`
+		Content        string `json:"content"`
+		ContentOmitted bool   `json:"contentOmitted"`
+	}
+	search := func(extra string) map[string]result {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&projectID="+p.ID+extra, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Results []result `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]result{}
+		for _, r := range res.Results {
+			out[r.Path] = r
+		}
+		return out
+	}
+
+	for path, r := range search("") {
+		if r.Content != "" || r.ContentOmitted {
+			t.Fatalf("%s: content attached without includeContent: %+v", path, r)
+		}
+	}
+	got := search("&includeContent=1")
+	if got["small.go"].Content != small {
+		t.Fatalf("small.go content=%q", got["small.go"].Content)
+	}
+	if l := got["large.go"]; l.Content != "" || !l.ContentOmitted {
+		t.Fatalf("large.go should be omitted: %+v", l)
+	}
+}
+
+func TestSearchIncludeContentHonorsFSPolicy(t *testing.T) {
+	t.Setenv("MYCODER_FS_DENY_REGEX", `\.env$`)
+	fsAllowRe, fsDenyRe = nil, nil
+	t.Cleanup(func() { fsAllowRe, fsDenyRe = nil, nil })
+	dir := t.TempDir()
+	secret := "needle SECRET=1\n"
+	code := "package a\n// needle\n"
+	_ = os.WriteFile(filepath.Join(dir, ".env"), []byte(secret), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte(code), 0o644)
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, ".env", secret)
+	st.AddDocument(p.ID, "a.go", code)
+	api := NewAPI(st, nil)
+	mux := api.mux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&includeContent=1&projectID="+p.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "SECRET=1\\n") {
+		t.Fatalf("denied file content leaked: %s", rr.Body.String())
+	}
+
+	// chunks of the same file share one copy of the content
+	results := []models.SearchResult{{Path: "a.go", StartLine: 1}, {Path: "a.go", StartLine: 2}, {Path: ".env"}}
+	api.attachContent(p.ID, results)
+	if results[0].Content != code || results[1].Content != "" || results[2].Content != "" {
+		t.Fatalf("unexpected content: %+v", results)
+	}
+}
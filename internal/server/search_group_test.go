@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestSearchGroupByFile(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", t.TempDir(), nil)
+	// needle at the top and bottom of a file long enough to span several chunks
+	var sb strings.Builder
+	sb.WriteString("needle first\n")
+	for i := 0; i < 400; i++ {
+		fmt.Fprintf(&sb, "filler line %d with some words\n", i)
+	}
+	sb.WriteString("needle last\n")
+	st.AddDocument(p.ID, "big.txt", sb.String())
+	st.AddDocument(p.ID, "small.txt", "one needle here\n")
+	mux := NewAPI(st, nil).mux()
+
+	get := func(extra string, out any) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&projectID="+p.ID+extra, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var chunks struct {
+		Results []struct {
+			Path string `json:"path"`
+		} `json:"results"`
+	}
+	get("", &chunks)
+	perFile := 0
+	for _, r := range chunks.Results {
+		if r.Path == "big.txt" {
+			perFile++
+		}
+	}
+	if perFile != 2 {
+		t.Fatalf("default mode should list each matching chunk, got %d for big.txt: %+v", perFile, chunks.Results)
+	}
+
+	var grouped struct {
+		Results []struct {
+			Path   string `json:"path"`
+			Ranges []struct {
+				StartLine int `json:"startLine"`
+				EndLine   int `json:"endLine"`
+			} `json:"ranges"`
+		} `json:"results"`
+	}
+	get("&groupByFile=1", &grouped)
+	if len(grouped.Results) != 2 {
+		t.Fatalf("expected one entry per file, got %+v", grouped.Results)
+	}
+	for _, r := range grouped.Results {
+		if r.Path != "big.txt" {
+			continue
+		}
+		if len(r.Ranges) != 2 || r.Ranges[0].StartLine != 1 || r.Ranges[1].StartLine <= r.Ranges[0].EndLine {
+			t.Fatalf("big.txt should carry two ordered ranges: %+v", r.Ranges)
+		}
+		return
+	}
+	t.Fatalf("big.txt missing from grouped results: %+v", grouped.Results)
+}
+
+func TestSearchGroupByFileHonorsK(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", t.TempDir(), nil)
+	for i := 0; i < 15; i++ {
+		st.AddDocument(p.ID, fmt.Sprintf("f%02d.txt", i), "needle\n")
+	}
+	mux := NewAPI(st, nil).mux()
+	count := func(query string) int {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&projectID="+p.ID+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var out struct {
+			Results []json.RawMessage `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		return len(out.Results)
+	}
+	if n := count("&groupByFile=1"); n != 10 {
+		t.Fatalf("default grouped files=%d, want 10", n)
+	}
+	if n := count("&groupByFile=1&k=3"); n != 3 {
+		t.Fatalf("k=3 grouped files=%d, want 3", n)
+	}
+	if n := count("&groupByFile=1&k=12"); n != 12 {
+		t.Fatalf("k=12 grouped files=%d, want 12", n)
+	}
+	if n := count("&k=4"); n != 4 {
+		t.Fatalf("k=4 chunk results=%d, want 4", n)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&k=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("k=0 code=%d, want 400", rr.Code)
+	}
+}
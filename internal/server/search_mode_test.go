@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestSearchLiteralAndRegexModes(t *testing.T) {
+	st := store.New()
+	p := st.CreateProject("p", t.TempDir(), nil)
+	st.AddDocument(p.ID, "call.go", "package x\n\nfunc run() {\n\ta.b()\n}\n")
+	st.AddDocument(p.ID, "other.go", "package x\n\nfunc ab() {}\n")
+	mux := NewAPI(st, nil).mux()
+
+	type result struct {
+		Path      string `json:"path"`
+		Preview   string `json:"preview"`
+		StartLine int    `json:"startLine"`
+	}
+	search := func(q, mode string) (int, []result) {
+		rr := httptest.NewRecorder()
+		u := "/search?projectID=" + p.ID + "&q=" + url.QueryEscape(q) + "&mode=" + mode
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u, nil))
+		var res struct {
+			Results []result `json:"results"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res.Results
+	}
+
+	code, res := search("a.b()", "literal")
+	if code != http.StatusOK || len(res) != 1 || res[0].Path != "call.go" {
+		t.Fatalf("literal: code=%d results=%+v", code, res)
+	}
+	if res[0].StartLine != 4 || res[0].Preview != "a.b()" {
+		t.Fatalf("literal should point at the matching line: %+v", res[0])
+	}
+
+	code, res = search(`\w+\.\w+\(\)`, "regex")
+	if code != http.StatusOK || len(res) != 1 || res[0].Path != "call.go" {
+		t.Fatalf("regex: code=%d results=%+v", code, res)
+	}
+	code, res = search(`(?m)^func a\w*\(`, "regex")
+	if code != http.StatusOK || len(res) != 1 || res[0].Path != "other.go" {
+		t.Fatalf("regex multiline: code=%d results=%+v", code, res)
+	}
+
+	if code, _ = search("a.(b", "regex"); code != http.StatusBadRequest {
+		t.Fatalf("invalid regex: code=%d, want 400", code)
+	}
+	if code, _ = search("x", "fuzzy"); code != http.StatusBadRequest {
+		t.Fatalf("unknown mode: code=%d, want 400", code)
+	}
+}
+
+func TestSearchFTSErrorsPropagate(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", t.TempDir(), nil)
+	st.AddDocument(p.ID, "main.go", "package main\n\nfunc main() {}\n")
+	mux := NewAPI(st, nil).mux()
+	search := func(q string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?projectID="+p.ID+"&q="+url.QueryEscape(q), nil))
+		return rr
+	}
+	if rr := search("((("); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unsearchable query: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if _, err := st.Search(p.ID, "(((", 5); !errors.Is(err, store.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+
+	// a broken database is a server error, not an empty result
+	_ = st.DB().Close()
+	if _, err := st.Search(p.ID, "main", 5); err == nil {
+		t.Fatalf("expected an error from a closed database")
+	}
+	if rr := search("main"); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("closed db: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mycoder/internal/store"
+)
+
+func TestSearchLinePreview(t *testing.T) {
+	dir := t.TempDir()
+	src := "package calc\n\n// Add sums two ints.\nfunc Add(a, b int) int {\n\treturn a + b // needle\n}\n\nfunc Sub(a, b int) int { return a - b }\n"
+	if err := os.WriteFile(filepath.Join(dir, "calc.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, "calc.go", src)
+	mux := NewAPI(st, nil).mux()
+
+	search := func(query string) []struct {
+		Path    string `json:"path"`
+		Preview string `json:"preview"`
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&projectID="+p.ID+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Results []struct {
+				Path    string `json:"path"`
+				Preview string `json:"preview"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Results) != 1 {
+			t.Fatalf("expected 1 result, got %+v", res.Results)
+		}
+		return res.Results
+	}
+
+	if got := search("")[0].Preview; strings.Contains(got, "func Add") {
+		t.Fatalf("default preview should stay the store snippet: %q", got)
+	}
+	got := search("&preview=lines&margin=1")[0].Preview
+	want := "func Add(a, b int) int {\n\treturn a + b // needle\n}"
+	if got != want {
+		t.Fatalf("line preview=%q, want %q", got, want)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&preview=tokens", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown preview mode: code=%d", rr.Code)
+	}
+}
+
+func TestSearchLinePreviewStaysInsideRoot(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "proj")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret.txt"), []byte("needle TOP-SECRET\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "other.txt"), []byte("needle LINKED-SECRET\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "other.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	st := store.New()
+	p := st.CreateProject("p", root, nil)
+	st.AddDocument(p.ID, "../secret.txt", "needle indexed")
+	st.AddDocument(p.ID, "link.txt", "needle indexed")
+	mux := NewAPI(st, nil).mux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&preview=lines&projectID="+p.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); strings.Contains(body, "SECRET") {
+		t.Fatalf("line preview read outside the project root: %s", body)
+	}
+}
+
+func TestSearchLinePreviewHonorsFSPolicy(t *testing.T) {
+	t.Setenv("MYCODER_FS_DENY_REGEX", `\.env$`)
+	fsAllowRe, fsDenyRe = nil, nil
+	t.Cleanup(func() { fsAllowRe, fsDenyRe = nil, nil })
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("# needle\nSECRET=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	p := st.CreateProject("p", dir, nil)
+	st.AddDocument(p.ID, ".env", "# needle\n")
+	mux := NewAPI(st, nil).mux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?q=needle&preview=lines&projectID="+p.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "SECRET") {
+		t.Fatalf("denied file leaked through preview: %s", rr.Body.String())
+	}
+}
@@ -1,11 +1,18 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"mycoder/internal/config"
 	"mycoder/internal/patch"
 	"net/http"
 	"net/url"
@@ -14,11 +21,13 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 import (
@@ -26,6 +35,8 @@ import (
 	"mycoder/internal/indexer"
 	"mycoder/internal/indexer/embedpipe"
 	"mycoder/internal/llm"
+	"mycoder/internal/llm/anthropic"
+	"mycoder/internal/llm/ollama"
 	oai "mycoder/internal/llm/openai"
 	mylog "mycoder/internal/log"
 	"mycoder/internal/models"
@@ -55,20 +66,27 @@ type Store interface {
 	// jobs
 	CreateIndexJob(projectID string, mode models.IndexMode) (*models.IndexJob, error)
 	SetJobStatus(id string, st models.IndexJobStatus, stats map[string]int) (*models.IndexJob, error)
+	SetJobError(id, msg string, stats map[string]int) (*models.IndexJob, error)
 	GetJob(id string) (*models.IndexJob, bool)
 	// docs/search
 	AddDocument(projectID, path, content string) *models.Document
-	Search(projectID, query string, k int) []models.SearchResult
+	Search(projectID, query string, k int) ([]models.SearchResult, error)
+	SearchRegexp(projectID string, re *regexp.Regexp, k int) []models.SearchResult
 	// metrics
 	Stats() map[string]int
 	// knowledge
 	AddKnowledge(projectID, sourceType, pathOrURL, title, text string, trust float64, pinned bool) (*models.Knowledge, error)
 	ListKnowledge(projectID string, minScore float64) ([]*models.Knowledge, error)
+	GetKnowledge(id string) (*models.Knowledge, bool, error)
+	ImportKnowledge(items []*models.Knowledge) (int, error)
+	UpdateKnowledge(id string, u models.KnowledgeUpdate) (bool, error)
+	DeleteKnowledge(id string) (bool, error)
 	VetKnowledge(projectID string) (int, error)
 	PromoteKnowledge(projectID, title, text, pathOrURL, commitSHA, filesCSV, symbolsCSV string, pin bool) (*models.Knowledge, error)
 	ReverifyKnowledge(projectID string) (int, error)
 	GCKnowledge(projectID string, minScore float64) (int, error)
-	ApproveKnowledge(projectID string, ids []string, pin bool, minTrust float64) (int, error)
+	ApproveKnowledge(projectID string, ids []string, pin bool, minTrust float64, approvedBy string) (int, error)
+	ListKnowledgeApprovals(projectID string) ([]*models.KnowledgeApproval, error)
 }
 
 type IncrementalStore interface {
@@ -76,16 +94,43 @@ type IncrementalStore interface {
 	PruneDocuments(projectID string, present []string) error
 }
 
+// batchUpserter is implemented by stores that can ingest many documents per
+// transaction (SQLite); the index handlers prefer it over per-file upserts.
+type batchUpserter interface {
+	UpsertDocuments(projectID string, docs []store.Doc) ([]*models.Document, error)
+}
+
+// indexStatser is implemented by stores that can summarize a project's index.
+type indexStatser interface {
+	IndexStats(projectID string) (models.IndexStats, error)
+}
+
+// documentDeleter is implemented by stores that can drop a single indexed document.
+type documentDeleter interface {
+	DeleteDocument(projectID, path string) error
+}
+
+// ConversationStore is implemented by stores that keep chat history (SQLite).
+type ConversationStore interface {
+	ListConversations(projectID string) ([]*models.Conversation, error)
+	GetConversation(id string) (*models.Conversation, []models.ConversationMessage, bool, error)
+	SetConversationPinned(id string, pinned bool) (bool, error)
+}
+
 type API struct {
 	store Store
 	llm   llm.ChatProvider
 	emb   llm.Embedder
 	vs    vectorstore.VectorStore
+	// tr translates Korean text before embedding (MYCODER_EMBED_TRANSLATE_FALLBACK=1)
+	tr embedpipe.Translator
+	// readOnly rejects mutating endpoints; read once from MYCODER_READONLY at construction
+	readOnly bool
 }
 
 func NewAPI(s Store, p llm.ChatProvider) *API {
 	lg := mylog.New()
-	a := &API{store: s, llm: p}
+	a := &API{store: s, llm: p, readOnly: config.Get("MYCODER_READONLY") == "1"}
 	if e, ok := any(p).(llm.Embedder); ok {
 		a.emb = e
 		lg.Info("embeddings.provider", "status", "found")
@@ -98,19 +143,19 @@ func NewAPI(s Store, p llm.ChatProvider) *API {
 	} else {
 		a.vs = vectorstore.NewFromEnv()
 	}
-	if a.emb != nil && os.Getenv("MYCODER_EMBED_CACHE_DISABLE") != "1" {
+	if a.emb != nil && config.Get("MYCODER_EMBED_CACHE_DISABLE") != "1" {
 		a.emb = newCachingEmbedder(a.emb)
 		lg.Info("embeddings.cache", "status", "enabled")
 	}
 	// embedding availability check and env opt-out
-	if os.Getenv("MYCODER_DISABLE_EMBEDDINGS") == "1" {
+	if config.Get("MYCODER_DISABLE_EMBEDDINGS") == "1" {
 		lg.Info("embeddings.disabled", "reason", "env_var_set")
 		a.emb = nil
 	} else if a.emb != nil {
 		// quick health check: tiny embedding with reasonable timeout for remote servers
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
-		embModel := os.Getenv("MYCODER_EMBEDDING_MODEL")
+		embModel := config.Get("MYCODER_EMBEDDING_MODEL")
 		lg.Info("embeddings.health_check", "model", embModel)
 		if _, err := a.emb.Embeddings(ctx, embModel, []string{"ping"}); err != nil {
 			lg.Warn("embeddings.disabled", "reason", err.Error(), "model", embModel)
@@ -119,9 +164,26 @@ func NewAPI(s Store, p llm.ChatProvider) *API {
 			lg.Info("embeddings.enabled", "model", embModel)
 		}
 	}
+	if config.Get("MYCODER_EMBED_TRANSLATE_FALLBACK") == "1" && a.emb != nil && p != nil {
+		a.tr = embedpipe.NewLLMTranslator(p)
+		lg.Info("embeddings.translate_fallback", "status", "enabled")
+	}
 	return a
 }
 
+// newPipeline returns an embedding pipeline for indexing, or nil when
+// embeddings are disabled.
+func (a *API) newPipeline(ctx context.Context) *embedpipe.Pipeline {
+	if a.emb == nil || a.vs == nil {
+		return nil
+	}
+	p := embedpipe.New(a.emb, a.vs).WithContext(ctx)
+	if a.tr != nil {
+		p.WithTranslator(a.tr)
+	}
+	return p
+}
+
 // capBuffer captures writes up to a fixed limit and marks truncation beyond it.
 type capBuffer struct {
 	b         []byte
@@ -164,10 +226,10 @@ var (
 
 func loadShellPolicy() {
 	shellPolicyOnce.Do(func() {
-		if v := os.Getenv("MYCODER_SHELL_ALLOW_REGEX"); v != "" {
+		if v := config.Get("MYCODER_SHELL_ALLOW_REGEX"); v != "" {
 			allowRe, _ = regexp.Compile(v)
 		}
-		if v := os.Getenv("MYCODER_SHELL_DENY_REGEX"); v != "" {
+		if v := config.Get("MYCODER_SHELL_DENY_REGEX"); v != "" {
 			denyRe, _ = regexp.Compile(v)
 		}
 	})
@@ -184,6 +246,66 @@ func shellAllowed(cmdline string) (bool, string) {
 	return true, ""
 }
 
+// policyLiteral matches long token-like runs inside a policy regex, which are
+// more likely embedded credentials than command names.
+var policyLiteral = regexp.MustCompile(`[A-Za-z0-9_\-]{20,}`)
+
+// maskPolicyPattern hides token-like literals in a configured pattern.
+func maskPolicyPattern(p string) string {
+	return policyLiteral.ReplaceAllString(p, "***")
+}
+
+// handleShellPolicy reports the shell allow/deny patterns: GET /shell/policy.
+// *Active is false when a pattern is unset or failed to compile (not enforced).
+func (a *API) handleShellPolicy(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	loadShellPolicy()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"allow":       maskPolicyPattern(config.Get("MYCODER_SHELL_ALLOW_REGEX")),
+		"deny":        maskPolicyPattern(config.Get("MYCODER_SHELL_DENY_REGEX")),
+		"allowActive": allowRe != nil,
+		"denyActive":  denyRe != nil,
+		"readOnly":    a.readOnly,
+	})
+}
+
+// handleShellPolicyCheck tests a candidate command without running it:
+// POST /shell/policy/check {cmd, args} -> {allowed, reason, cmdline}.
+func (a *API) handleShellPolicyCheck(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	var req struct {
+		Cmd  string   `json:"cmd"`
+		Args []string `json:"args"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+		return
+	}
+	if req.Cmd == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "cmd required")
+		return
+	}
+	// same commandline /shell/exec would build and check
+	cmdline := buildCmdline(req.Cmd, req.Args)
+	ok, reason := shellAllowed(cmdline)
+	if ok && a.readOnly {
+		ok, reason = false, "read-only mode"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"allowed": ok, "reason": reason, "cmdline": cmdline})
+}
+
 // FS policy (allow/deny regex) on relative path
 var (
 	fsPolicyOnce sync.Once
@@ -193,10 +315,10 @@ var (
 
 func loadFSPolicy() {
 	fsPolicyOnce.Do(func() {
-		if v := os.Getenv("MYCODER_FS_ALLOW_REGEX"); v != "" {
+		if v := config.Get("MYCODER_FS_ALLOW_REGEX"); v != "" {
 			fsAllowRe, _ = regexp.Compile(v)
 		}
-		if v := os.Getenv("MYCODER_FS_DENY_REGEX"); v != "" {
+		if v := config.Get("MYCODER_FS_DENY_REGEX"); v != "" {
 			fsDenyRe, _ = regexp.Compile(v)
 		}
 	})
@@ -206,12 +328,12 @@ func fsAllowed(rel string) (bool, string) {
 	loadFSPolicy()
 	// Late-binding for tests/env changes: re-read if unset
 	if fsAllowRe == nil {
-		if v := os.Getenv("MYCODER_FS_ALLOW_REGEX"); v != "" {
+		if v := config.Get("MYCODER_FS_ALLOW_REGEX"); v != "" {
 			fsAllowRe, _ = regexp.Compile(v)
 		}
 	}
 	if fsDenyRe == nil {
-		if v := os.Getenv("MYCODER_FS_DENY_REGEX"); v != "" {
+		if v := config.Get("MYCODER_FS_DENY_REGEX"); v != "" {
 			fsDenyRe, _ = regexp.Compile(v)
 		}
 	}
@@ -232,45 +354,200 @@ type metricsCollector struct {
 	// duration sum/count keyed by method|path
 	durSum   map[string]float64
 	durCount map[string]int
+	// duration histogram (non-cumulative counts per bucket) keyed by method|path
+	durBuckets map[string][]int
+	buckets    []float64
 	// chat-related
 	chatRequests int
 	chatTokens   int
+	// provider-reported token usage (non-streaming responses)
+	chatPromptTokens     int
+	chatCompletionTokens int
+	// streamed `event: token` emissions, time-to-first-token and total chat duration
+	chatTokenEvents int
+	chatTTFT        histogram
+	chatDuration    histogram
 	// embedding cache
 	embedCacheHits   int
 	embedCacheMisses int
 	embedCacheEvict  int
+	// background curator cycles and knowledge rows it removed
+	curatorRuns    int
+	curatorRemoved int
+	// KNN queries skipped because no stored vectors share the query dimension
+	vectorDimMismatch int
 }
 
-// Authorization: optional token via env MYCODER_API_TOKEN.
+// Authorization: optional tokens via env MYCODER_API_TOKENS (comma-separated
+// label:token pairs) and/or the legacy single MYCODER_API_TOKEN.
 // Accepts Authorization: Bearer <token> or query param ?token=...
 func authorize(w http.ResponseWriter, r *http.Request) bool {
-	tok := os.Getenv("MYCODER_API_TOKEN")
-	if tok == "" {
+	tokens := apiTokens()
+	if len(tokens) == 0 {
 		return true
 	}
 	hdr := r.Header.Get("Authorization")
-	if strings.HasPrefix(hdr, "Bearer ") && strings.TrimSpace(hdr[len("Bearer "):]) == tok {
-		return true
+	if strings.HasPrefix(hdr, "Bearer ") {
+		if label, ok := tokens[strings.TrimSpace(hdr[len("Bearer "):])]; ok {
+			setAuthLabel(r, label)
+			return true
+		}
 	}
-	if r.URL.Query().Get("token") == tok {
-		return true
+	if q := r.URL.Query().Get("token"); q != "" {
+		if label, ok := tokens[q]; ok {
+			setAuthLabel(r, label)
+			return true
+		}
 	}
 	writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid token")
 	return false
 }
 
-func isReadOnly() bool { return os.Getenv("MYCODER_READONLY") == "1" }
+// apiTokens returns the configured tokens mapped to their labels. The legacy
+// MYCODER_API_TOKEN is labeled "default"; list entries without a label are "unlabeled".
+func apiTokens() map[string]string {
+	tokens := map[string]string{}
+	if tok := config.Get("MYCODER_API_TOKEN"); tok != "" {
+		tokens[tok] = "default"
+	}
+	for _, part := range strings.Split(config.Get("MYCODER_API_TOKENS"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		label, tok := "unlabeled", part
+		if i := strings.IndexByte(part, ':'); i >= 0 {
+			label, tok = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		if tok != "" {
+			tokens[tok] = label
+		}
+	}
+	return tokens
+}
+
+// knownToken reports whether tok is one of the configured API tokens.
+func knownToken(tok string) bool {
+	_, ok := apiTokens()[tok]
+	return ok
+}
+
+// authInfo is placed in the request context by logMiddleware and filled in
+// by authorize, so the access log can report which token label was used.
+type authInfo struct{ label string }
+
+type authInfoKey struct{}
+
+func withAuthInfo(r *http.Request) (*http.Request, *authInfo) {
+	ai := &authInfo{}
+	return r.WithContext(context.WithValue(r.Context(), authInfoKey{}, ai)), ai
+}
+
+func setAuthLabel(r *http.Request, label string) {
+	if ai, ok := r.Context().Value(authInfoKey{}).(*authInfo); ok {
+		ai.label = label
+	}
+}
+
+// authLabel returns the label of the token that authorized the request, if any.
+func authLabel(ctx context.Context) string {
+	if ai, ok := ctx.Value(authInfoKey{}).(*authInfo); ok {
+		return ai.label
+	}
+	return ""
+}
+
+// requestToken extracts the API token from Authorization: Bearer or ?token=.
+func requestToken(r *http.Request) string {
+	hdr := r.Header.Get("Authorization")
+	if strings.HasPrefix(hdr, "Bearer ") {
+		if t := strings.TrimSpace(hdr[len("Bearer "):]); t != "" {
+			return t
+		}
+	}
+	return r.URL.Query().Get("token")
+}
 
 func newMetrics() *metricsCollector {
 	return &metricsCollector{
-		reqTotal: make(map[string]int),
-		durSum:   make(map[string]float64),
-		durCount: make(map[string]int),
+		reqTotal:   make(map[string]int),
+		durSum:     make(map[string]float64),
+		durCount:   make(map[string]int),
+		durBuckets: make(map[string][]int),
+		buckets:    metricsBuckets(),
 	}
 }
 
 var metrics = newMetrics()
 
+// default histogram upper bounds in seconds (Prometheus client defaults)
+var defaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricsBuckets parses MYCODER_METRICS_BUCKETS (comma-separated seconds).
+// Invalid entries are skipped; falls back to the defaults when none remain.
+func metricsBuckets() []float64 {
+	var out []float64
+	for _, part := range strings.Split(config.Get("MYCODER_METRICS_BUCKETS"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if f, err := strconv.ParseFloat(part, 64); err == nil && f > 0 {
+			out = append(out, f)
+		}
+	}
+	if len(out) == 0 {
+		return append([]float64(nil), defaultMetricsBuckets...)
+	}
+	sort.Float64s(out)
+	uniq := out[:1]
+	for _, f := range out[1:] {
+		if f != uniq[len(uniq)-1] {
+			uniq = append(uniq, f)
+		}
+	}
+	return uniq
+}
+
+// histogram holds non-cumulative per-bucket counts for a single unlabeled series.
+type histogram struct {
+	counts []int
+	sum    float64
+	total  int
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]int, len(bounds))
+	}
+	h.sum += v
+	h.total++
+	for i, ub := range bounds {
+		if v <= ub {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+// observeDuration records a request duration into the per-key histogram.
+// Caller must hold mu.
+func (m *metricsCollector) observeDuration(key string, secs float64) {
+	m.durSum[key] += secs
+	m.durCount[key]++
+	counts := m.durBuckets[key]
+	if counts == nil {
+		counts = make([]int, len(m.buckets))
+		m.durBuckets[key] = counts
+	}
+	for i, ub := range m.buckets {
+		if secs <= ub {
+			counts[i]++
+			break
+		}
+	}
+}
+
 // sampling for metrics recording (0..1)
 var (
 	metricsSampleRate = 1.0
@@ -279,7 +556,7 @@ var (
 
 func shouldSample() bool {
 	samplerOnce.Do(func() {
-		if v := os.Getenv("MYCODER_METRICS_SAMPLE_RATE"); v != "" {
+		if v := config.Get("MYCODER_METRICS_SAMPLE_RATE"); v != "" {
 			if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
 				metricsSampleRate = f
 			}
@@ -306,110 +583,135 @@ func (a *API) mux() *http.ServeMux {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("/readyz", a.handleReadyz)
 	mux.HandleFunc("/projects", a.handleProjects)
 	mux.HandleFunc("/index/run", a.handleIndexRun)
 	mux.HandleFunc("/index/run/stream", a.handleIndexRunStream)
 	mux.HandleFunc("/index/jobs/", a.handleIndexJob)
+	mux.HandleFunc("/index/stats", a.handleIndexStats)
+	mux.HandleFunc("/diagnostics", a.handleDiagnostics)
 	mux.HandleFunc("/search", a.handleSearch)
 	mux.HandleFunc("/metrics", a.handleMetrics)
+	mux.HandleFunc("/metrics/reset", a.handleMetricsReset)
+	mux.HandleFunc("/config", a.handleConfig)
 	mux.HandleFunc("/fs/read", a.handleFSRead)
 	mux.HandleFunc("/fs/write", a.handleFSWrite)
 	mux.HandleFunc("/fs/patch", a.handleFSPatch)
 	mux.HandleFunc("/fs/patch/unified", a.handleFSPatchUnified)
 	mux.HandleFunc("/fs/patch/unified/rollback", a.handleFSPatchUnifiedRollback)
+	mux.HandleFunc("/fs/patch/list", a.handleFSPatchList)
 	mux.HandleFunc("/fs/diff", a.handleFSDiff)
 	mux.HandleFunc("/fs/delete", a.handleFSDelete)
+	mux.HandleFunc("/fs/move", a.handleFSMove)
+	mux.HandleFunc("/fs/mkdir", a.handleFSMkdir)
 	mux.HandleFunc("/shell/exec", a.handleShellExec)
 	mux.HandleFunc("/shell/exec/stream", a.handleShellExecStream)
+	mux.HandleFunc("/shell/policy", a.handleShellPolicy)
+	mux.HandleFunc("/shell/policy/check", a.handleShellPolicyCheck)
 	mux.HandleFunc("/chat", a.handleChat)
+	mux.HandleFunc("/conversations", a.handleConversations)
+	mux.HandleFunc("/conversations/", a.handleConversationItem)
 	// knowledge curation
 	mux.HandleFunc("/knowledge", a.handleKnowledge)
+	mux.HandleFunc("/knowledge/", a.handleKnowledgeItem)
+	mux.HandleFunc("/knowledge/delete", a.handleKnowledgeDelete)
+	mux.HandleFunc("/knowledge/update", a.handleKnowledgeUpdate)
+	mux.HandleFunc("/knowledge/export", a.handleKnowledgeExport)
+	mux.HandleFunc("/knowledge/import", a.handleKnowledgeImport)
 	mux.HandleFunc("/knowledge/vet", a.handleKnowledgeVet)
 	mux.HandleFunc("/knowledge/promote", a.handleKnowledgePromote)
 	mux.HandleFunc("/knowledge/approve", a.handleKnowledgeApprove)
+	mux.HandleFunc("/knowledge/approvals", a.handleKnowledgeApprovals)
 	mux.HandleFunc("/knowledge/reverify", a.handleKnowledgeReverify)
 	mux.HandleFunc("/knowledge/pending", a.handleKnowledgePending)
 	mux.HandleFunc("/knowledge/gc", a.handleKnowledgeGC)
+	mux.HandleFunc("/knowledge/decay", a.handleKnowledgeDecay)
 	mux.HandleFunc("/knowledge/promote/auto", a.handleKnowledgePromoteAuto)
 	// tools/hooks
 	mux.HandleFunc("/tools/hooks", a.handleToolsHooks)
 	// mcp tools
 	mux.HandleFunc("/mcp/tools", a.handleMCPTools)
 	mux.HandleFunc("/mcp/call", a.handleMCPCall)
+	mux.HandleFunc("/mcp/calls", a.handleMCPCalls)
 	// web enrichment (optional)
 	mux.HandleFunc("/web/search", a.handleWebSearch)
 	mux.HandleFunc("/web/ingest", a.handleWebIngest)
 	return mux
 }
 
-// Run starts an HTTP server with a minimal health endpoint.
-func Run(addr string) error {
-	var st Store
-	if path := os.Getenv("MYCODER_SQLITE_PATH"); path != "" {
-		if sdb, err := store.NewSQLite(path); err == nil {
-			st = sdb
-		} else {
-			fmt.Fprintf(os.Stderr, "sqlite init failed: %v, falling back to memory\n", err)
-			st = store.New()
+// storeFromEnv opens the SQLite store at MYCODER_SQLITE_PATH, falling back to memory.
+func storeFromEnv() Store {
+	if path := config.Get("MYCODER_SQLITE_PATH"); path != "" {
+		sdb, err := store.NewSQLite(path)
+		if err == nil {
+			return sdb
 		}
-	} else {
-		st = store.New()
+		mylog.New().Warn("store.sqlite_init_failed", "path", path, "error", err.Error(), "fallback", "memory")
 	}
+	return store.New()
+}
+
+// NewHandler returns the API routes for st and prov wrapped in the same
+// logging, gzip, timeout, rate-limit and body-limit middleware that Run serves.
+func NewHandler(st Store, prov llm.ChatProvider) http.Handler {
+	mux := NewAPI(st, prov).mux()
+	return logMiddleware(gzipMiddleware(bodyLogMiddleware(timeoutMiddleware(rateLimitMiddleware(bodyLimitMiddleware(mux))), mylog.New())))
+}
+
+// Run starts an HTTP server with a minimal health endpoint.
+func Run(addr string) error {
+	st := storeFromEnv()
 	// select LLM provider
 	var prov llm.ChatProvider
-	switch strings.ToLower(os.Getenv("MYCODER_LLM_PROVIDER")) {
+	switch strings.ToLower(config.Get("MYCODER_LLM_PROVIDER")) {
 	case "", "openai":
 		prov = oai.NewFromEnv()
+	case "anthropic":
+		prov = anthropic.NewFromEnv()
+	case "ollama":
+		prov = ollama.NewFromEnv()
 	default:
 		prov = oai.NewFromEnv()
 	}
-	api := NewAPI(st, prov)
-	mux := api.mux()
-	// optional background curator (decay/reverify/gc)
-	if os.Getenv("MYCODER_CURATOR_DISABLE") == "" {
-		interval := 10 * time.Minute
-		if v := os.Getenv("MYCODER_CURATOR_INTERVAL"); v != "" {
-			if d, err := time.ParseDuration(v); err == nil {
-				interval = d
-			}
-		}
-		minTrust := 0.4
-		if v := os.Getenv("MYCODER_KNOWLEDGE_MIN_TRUST"); v != "" {
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				minTrust = f
-			}
-		}
-		decayRate := 0.01
-		if v := os.Getenv("MYCODER_KNOWLEDGE_DECAY_RATE"); v != "" {
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				decayRate = f
-			}
+	if config.Get("MYCODER_LLM_PROBE_DISABLE") == "" {
+		probeLLM(context.Background(), prov, mylog.New(), 3*time.Second)
+	}
+	handler := NewHandler(st, prov)
+	// background jobs stop when Run returns (shutdown or listen error)
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+	// optional metrics persistence so *_total counters survive restarts
+	// - MYCODER_METRICS_PERSIST=1 enables it (SQLite store only)
+	// - MYCODER_METRICS_PERSIST_INTERVAL: save interval (default 30s)
+	if mp, ok := st.(metricsPersister); ok && config.Get("MYCODER_METRICS_PERSIST") == "1" {
+		lg := mylog.New()
+		if err := restoreMetrics(metrics, mp); err != nil {
+			lg.Warn("metrics.restore_failed", "error", err.Error())
 		}
-		decayAfterDays := 30
-		if v := os.Getenv("MYCODER_KNOWLEDGE_DECAY_AFTER_DAYS"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil {
-				decayAfterDays = n
-			}
+		interval := config.GetDuration("MYCODER_METRICS_PERSIST_INTERVAL", 30*time.Second)
+		if interval <= 0 {
+			interval = 30 * time.Second
 		}
 		go func() {
 			t := time.NewTicker(interval)
 			defer t.Stop()
-			for range t.C {
-				for _, p := range st.ListProjects() {
-					if decayRate > 0 {
-						if ss, ok := st.(*store.SQLiteStore); ok {
-							_, _ = ss.DecayKnowledge(p.ID, decayRate, decayAfterDays)
-						}
-					}
-					_, _ = st.ReverifyKnowledge(p.ID)
-					_, _ = st.GCKnowledge(p.ID, minTrust)
-					// TTL-based GC for web knowledge via tags.ttlUntil
-					if ss, ok := st.(*store.SQLiteStore); ok {
-						_, _ = ss.GCKnowledgeTTL(p.ID)
+			for {
+				select {
+				case <-bgCtx.Done():
+					return
+				case <-t.C:
+					if err := persistMetrics(metrics, mp); err != nil {
+						lg.Warn("metrics.persist_failed", "error", err.Error())
 					}
 				}
 			}
 		}()
+		// final save on shutdown
+		defer func() { _ = persistMetrics(metrics, mp) }()
+	}
+	// optional background curator (decay/reverify/gc)
+	if config.Get("MYCODER_CURATOR_DISABLE") == "" {
+		go runCurator(bgCtx, st, curatorConfigFromEnv())
 	}
 
 	// optional background conversation cleanup (TTL/pin retention)
@@ -417,15 +719,15 @@ func Run(addr string) error {
 	// - MYCODER_CONV_CLEAN_DISABLE: if set, disables cleaner
 	// - MYCODER_CONV_TTL_DAYS: TTL in days for non-pinned conversations (default 30)
 	// - MYCODER_CONV_CLEAN_INTERVAL: interval for cleanup loop (default 24h)
-	if os.Getenv("MYCODER_CONV_CLEAN_DISABLE") == "" {
+	if config.Get("MYCODER_CONV_CLEAN_DISABLE") == "" {
 		ttlDays := 30
-		if v := os.Getenv("MYCODER_CONV_TTL_DAYS"); v != "" {
+		if v := config.Get("MYCODER_CONV_TTL_DAYS"); v != "" {
 			if n, err := strconv.Atoi(v); err == nil && n > 0 {
 				ttlDays = n
 			}
 		}
 		interval := 24 * time.Hour
-		if v := os.Getenv("MYCODER_CONV_CLEAN_INTERVAL"); v != "" {
+		if v := config.Get("MYCODER_CONV_CLEAN_INTERVAL"); v != "" {
 			if d, err := time.ParseDuration(v); err == nil && d > 0 {
 				interval = d
 			}
@@ -443,7 +745,7 @@ func Run(addr string) error {
 
 	srv := &http.Server{
 		Addr:              addr,
-		Handler:           logMiddleware(rateLimitMiddleware(mux)),
+		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
@@ -470,6 +772,96 @@ func Run(addr string) error {
 	}
 }
 
+// modelProber is implemented by providers that can list models; the listing
+// doubles as a cheap reachability check.
+type modelProber interface {
+	BaseURL() string
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// probeLLM does a quick model listing against the configured provider and logs
+// a warning with the base URL tried when it is unreachable. It never fails startup,
+// since local-only use without an LLM is valid. Reports whether the probe passed.
+func probeLLM(ctx context.Context, prov llm.ChatProvider, lg *mylog.Logger, timeout time.Duration) bool {
+	p, ok := prov.(modelProber)
+	if !ok {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	url := p.BaseURL()
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		lg.Warn("llm.unreachable", "url", url, "error", err.Error(),
+			"hint", "check the provider base URL (MYCODER_OPENAI_BASE_URL or MYCODER_OLLAMA_BASE_URL); chat/embeddings will fail until the provider is up (set MYCODER_LLM_PROBE_DISABLE=1 to skip this check)")
+		return false
+	}
+	lg.Info("llm.reachable", "url", url, "models", len(models))
+	return true
+}
+
+// curatorConfig holds the background curator policy.
+type curatorConfig struct {
+	interval       time.Duration
+	minTrust       float64
+	decayRate      float64
+	decayAfterDays int
+}
+
+func curatorConfigFromEnv() curatorConfig {
+	cfg := curatorConfig{
+		interval:       config.GetDuration("MYCODER_CURATOR_INTERVAL", 10*time.Minute),
+		minTrust:       config.GetFloat("MYCODER_KNOWLEDGE_MIN_TRUST", 0.4),
+		decayRate:      config.GetFloat("MYCODER_KNOWLEDGE_DECAY_RATE", 0.01),
+		decayAfterDays: config.GetInt("MYCODER_KNOWLEDGE_DECAY_AFTER_DAYS", 30),
+	}
+	if cfg.interval <= 0 {
+		cfg.interval = 10 * time.Minute
+	}
+	return cfg
+}
+
+// runCurator runs curatorCycle every cfg.interval until ctx is canceled.
+func runCurator(ctx context.Context, st Store, cfg curatorConfig) {
+	t := time.NewTicker(cfg.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			curatorCycle(st, cfg)
+		}
+	}
+}
+
+// curatorCycle decays, reverifies and garbage-collects knowledge for every
+// project, logging per-project counts and updating curator metrics.
+func curatorCycle(st Store, cfg curatorConfig) {
+	lg := mylog.New()
+	removedTotal := 0
+	for _, p := range st.ListProjects() {
+		decayed, reverified, removed := 0, 0, 0
+		ss, isSQLite := st.(*store.SQLiteStore)
+		if cfg.decayRate > 0 && isSQLite {
+			decayed, _ = ss.DecayKnowledge(p.ID, cfg.decayRate, cfg.decayAfterDays)
+		}
+		reverified, _ = st.ReverifyKnowledge(p.ID)
+		removed, _ = st.GCKnowledge(p.ID, cfg.minTrust)
+		// TTL-based GC for web knowledge via tags.ttlUntil
+		if isSQLite {
+			n, _ := ss.GCKnowledgeTTL(p.ID)
+			removed += n
+		}
+		removedTotal += removed
+		lg.Info("curator.cycle", "project", p.ID, "decayed", decayed, "reverified", reverified, "removed", removed)
+	}
+	metrics.mu.Lock()
+	metrics.curatorRuns++
+	metrics.curatorRemoved += removedTotal
+	metrics.mu.Unlock()
+}
+
 type statusRecorder struct {
 	http.ResponseWriter
 	status int
@@ -490,6 +882,13 @@ func (sr *statusRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush forwards to the underlying writer so SSE handlers can stream through the recorder.
+func (sr *statusRecorder) Flush() {
+	if fl, ok := sr.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
 // newRequestID returns a short, unique request identifier.
 func newRequestID() string {
 	var b [12]byte
@@ -527,18 +926,25 @@ func clientIP(r *http.Request) string {
 
 // rateLimiter provides simple token-bucket rate limiting by key.
 type rateLimiter struct {
-	mu      sync.Mutex
-	rps     float64
-	buckets map[string]*bucket
+	mu        sync.Mutex
+	rps       float64
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
 }
 
+// rateBucketIdleTTL is how long a bucket may sit unused before it is dropped.
+// A bucket refills completely within a second, so dropping an idle one does
+// not change any limit; it only keeps the map from growing with every key seen.
+const rateBucketIdleTTL = time.Minute
+
 type bucket struct {
 	tokens float64
 	last   time.Time
 }
 
-func newRateLimiter(rps float64) *rateLimiter {
-	return &rateLimiter{rps: rps, buckets: make(map[string]*bucket)}
+func newRateLimiter(rps float64, now func() time.Time) *rateLimiter {
+	return &rateLimiter{rps: rps, buckets: make(map[string]*bucket), now: now}
 }
 
 // allow reports whether a request with key is allowed now and, if not, the seconds until next token.
@@ -548,8 +954,16 @@ func (rl *rateLimiter) allow(key string) (bool, int) {
 	if rl.rps <= 0 {
 		return true, 0
 	}
+	now := rl.now()
+	if now.Sub(rl.lastSweep) >= rateBucketIdleTTL {
+		for k, b := range rl.buckets {
+			if now.Sub(b.last) >= rateBucketIdleTTL {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
 	b := rl.buckets[key]
-	now := time.Now()
 	if b == nil {
 		b = &bucket{tokens: rl.rps, last: now}
 		rl.buckets[key] = b
@@ -580,9 +994,14 @@ func minFloat(a, b float64) float64 {
 
 // rateLimitMiddleware enforces basic RPS limits across global, path, and client scopes.
 func rateLimitMiddleware(next http.Handler) http.Handler {
+	return rateLimitMiddlewareClock(next, time.Now)
+}
+
+// rateLimitMiddlewareClock is rateLimitMiddleware with the bucket clock supplied.
+func rateLimitMiddlewareClock(next http.Handler, now func() time.Time) http.Handler {
 	// read env once on first use
 	var once sync.Once
-	var gLimiter, pLimiter, iLimiter *rateLimiter
+	var gLimiter, pLimiter, iLimiter, tLimiter *rateLimiter
 	init := func() {
 		// fallbacks to MYCODER_RATE_LIMIT_RPS when specific not set
 		base := parseFloatEnv("MYCODER_RATE_LIMIT_RPS")
@@ -598,13 +1017,15 @@ func rateLimitMiddleware(next http.Handler) http.Handler {
 		if i == -1 {
 			i = base
 		}
-		gLimiter = newRateLimiter(g)
-		pLimiter = newRateLimiter(p)
-		iLimiter = newRateLimiter(i)
+		gLimiter = newRateLimiter(g, now)
+		pLimiter = newRateLimiter(p, now)
+		iLimiter = newRateLimiter(i, now)
+		// per-token scope is opt-in only (no fallback to the base RPS)
+		tLimiter = newRateLimiter(parseFloatEnv("MYCODER_RATE_LIMIT_TOKEN_RPS"), now)
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		once.Do(init)
-		if (gLimiter == nil || gLimiter.rps <= 0) && (pLimiter == nil || pLimiter.rps <= 0) && (iLimiter == nil || iLimiter.rps <= 0) {
+		if (gLimiter == nil || gLimiter.rps <= 0) && (pLimiter == nil || pLimiter.rps <= 0) && (iLimiter == nil || iLimiter.rps <= 0) && (tLimiter == nil || tLimiter.rps <= 0) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -612,29 +1033,25 @@ func rateLimitMiddleware(next http.Handler) http.Handler {
 		globalKey := "global"
 		pathKey := "path:" + normalizePath(r.URL.Path)
 		ipKey := "ip:" + clientIP(r)
-
-		// deny if any scope exceeds
-		if gLimiter != nil && gLimiter.rps > 0 {
-			if ok, _ := gLimiter.allow(globalKey); !ok {
-				_, wait := gLimiter.allow(globalKey)
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", wait))
-				w.WriteHeader(http.StatusTooManyRequests)
-				_, _ = w.Write([]byte("rate limit exceeded"))
-				return
-			}
+		// token scope keys by the presented API token when it is a configured
+		// one, falling back to the client IP so made-up tokens share a bucket
+		tokenKey := ipKey
+		if tok := requestToken(r); tok != "" && knownToken(tok) {
+			sum := sha256.Sum256([]byte(tok))
+			tokenKey = "token:" + hex.EncodeToString(sum[:8])
 		}
-		if pLimiter != nil && pLimiter.rps > 0 {
-			if ok, _ := pLimiter.allow(pathKey); !ok {
-				_, wait := pLimiter.allow(pathKey)
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", wait))
-				w.WriteHeader(http.StatusTooManyRequests)
-				_, _ = w.Write([]byte("rate limit exceeded"))
-				return
+
+		// deny if any scope exceeds; allow is called once per scope since it
+		// refills/consumes tokens and already reports the wait time
+		scopes := []struct {
+			rl  *rateLimiter
+			key string
+		}{{gLimiter, globalKey}, {pLimiter, pathKey}, {iLimiter, ipKey}, {tLimiter, tokenKey}}
+		for _, sc := range scopes {
+			if sc.rl == nil || sc.rl.rps <= 0 {
+				continue
 			}
-		}
-		if iLimiter != nil && iLimiter.rps > 0 {
-			if ok, _ := iLimiter.allow(ipKey); !ok {
-				_, wait := iLimiter.allow(ipKey)
+			if ok, wait := sc.rl.allow(sc.key); !ok {
 				w.Header().Set("Retry-After", fmt.Sprintf("%d", wait))
 				w.WriteHeader(http.StatusTooManyRequests)
 				_, _ = w.Write([]byte("rate limit exceeded"))
@@ -645,94 +1062,622 @@ func rateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func parseFloatEnv(key string) float64 {
-	v := os.Getenv(key)
-	if v == "" {
-		return -1
-	}
-	if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
-		return f
-	}
-	return -1
-}
-
-func logMiddleware(next http.Handler) http.Handler {
+// timeoutMiddleware bounds each request by MYCODER_REQUEST_TIMEOUT (Go duration,
+// default 10m, 0 disables) and answers 503 when the deadline passes.
+// Streaming endpoints (path ending in /stream or Accept: text/event-stream) are
+// exempt since they need to flush and may legitimately run long.
+func timeoutMiddleware(next http.Handler) http.Handler {
+	d := 10 * time.Minute
+	if v := strings.TrimSpace(config.Get("MYCODER_REQUEST_TIMEOUT")); v != "" {
+		if v == "0" {
+			d = 0
+		} else if pd, err := time.ParseDuration(v); err == nil && pd >= 0 {
+			d = pd
+		}
+	}
+	if d <= 0 {
+		return next
+	}
+	body, _ := json.Marshal(apiError{Error: "timeout", Message: "request timed out", Code: http.StatusServiceUnavailable})
+	th := http.TimeoutHandler(next, d, string(body))
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		// request-id propagation: accept client-provided or generate
-		reqID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
-		if reqID == "" {
-			reqID = newRequestID()
-		}
-		w.Header().Set("X-Request-ID", reqID)
-		rec := &statusRecorder{ResponseWriter: w}
-		next.ServeHTTP(rec, r)
-		dur := time.Since(start)
-		lg := mylog.New()
-		lg.Info("http.req",
-			"req_id", reqID,
-			"method", r.Method,
-			"path", r.URL.Path,
-			"userAgent", r.UserAgent(),
-			"referer", r.Referer(),
-			"remoteIP", clientIP(r),
-			"status", rec.status,
-			"duration_ms", int(dur/time.Millisecond),
-			"bytes", rec.nbytes,
-		)
-		// metrics: requests and durations (with label normalization + sampling)
-		if shouldSample() {
-			path := normalizePath(r.URL.Path)
-			mkey := r.Method + "|" + path + "|" + fmt.Sprintf("%d", rec.status)
-			dkey := r.Method + "|" + path
-			metrics.mu.Lock()
-			metrics.reqTotal[mkey]++
-			metrics.durSum[dkey] += dur.Seconds()
-			metrics.durCount[dkey]++
-			metrics.mu.Unlock()
+		if isStreamingRequest(r) {
+			next.ServeHTTP(w, r)
+			return
 		}
+		th.ServeHTTP(w, r)
 	})
 }
 
-// Handlers
-func (a *API) handleProjects(w http.ResponseWriter, r *http.Request) {
-	if !authorize(w, r) {
-		return
-	}
-	switch r.Method {
-	case http.MethodGet:
-		list := a.store.ListProjects()
-		writeJSON(w, http.StatusOK, list)
-	case http.MethodPost:
-		if isReadOnly() {
-			writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
-			return
-		}
-		var req struct {
-			Name     string   `json:"name"`
-			RootPath string   `json:"rootPath"`
-			Ignore   []string `json:"ignore"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
-			return
+// gzipMiddleware compresses responses for clients sending Accept-Encoding: gzip.
+// Bodies smaller than MYCODER_GZIP_MIN_BYTES (default 1024), already-encoded
+// content, and event streams are passed through unchanged.
+func gzipMiddleware(next http.Handler) http.Handler {
+	minSize := 1024
+	if v := config.Get("MYCODER_GZIP_MIN_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			minSize = n
 		}
-		if req.Name == "" || req.RootPath == "" {
-			writeError(w, http.StatusBadRequest, "invalid_request", "name and rootPath required")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isStreamingRequest(r) {
+			next.ServeHTTP(w, r)
 			return
 		}
-		p := a.store.CreateProject(req.Name, req.RootPath, req.Ignore)
-		writeJSON(w, http.StatusOK, map[string]string{"projectID": p.ID})
-	default:
-		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
-	}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
 }
 
-func (a *API) handleIndexRun(w http.ResponseWriter, r *http.Request) {
-	if !authorize(w, r) {
+// gzipResponseWriter buffers the start of a response to decide whether it is
+// worth compressing, then either streams it through gzip or writes it raw.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	status      int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+	sent        bool // headers written downstream
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.status != 0 {
 		return
 	}
-	if r.Method != http.MethodPost {
+	g.status = code
+	h := g.Header()
+	ct := h.Get("Content-Type")
+	if h.Get("Content-Encoding") != "" || strings.HasPrefix(ct, "text/event-stream") || isCompressedContentType(ct) ||
+		code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		g.passthrough = true
+		g.sendHeader()
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.passthrough {
+		return g.ResponseWriter.Write(p)
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	g.buf = append(g.buf, p...)
+	if len(g.buf) >= g.minSize {
+		if err := g.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush compresses what is buffered so far, or switches to passthrough if
+// compression has not started, so flushing handlers still stream promptly.
+func (g *gzipResponseWriter) Flush() {
+	if g.status == 0 {
+		g.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case g.gz != nil:
+		_ = g.gz.Flush()
+	case !g.passthrough:
+		g.passthrough = true
+		g.sendHeader()
+		if len(g.buf) > 0 {
+			_, _ = g.ResponseWriter.Write(g.buf)
+			g.buf = nil
+		}
+	}
+	if fl, ok := g.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) startGzip() error {
+	h := g.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	g.sendHeader()
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	_, err := g.gz.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+func (g *gzipResponseWriter) sendHeader() {
+	if g.sent {
+		return
+	}
+	g.sent = true
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+}
+
+// close finishes the gzip stream or writes a small buffered body uncompressed.
+func (g *gzipResponseWriter) close() {
+	if g.gz != nil {
+		_ = g.gz.Close()
+		return
+	}
+	if g.status == 0 {
+		return
+	}
+	g.sendHeader()
+	if len(g.buf) > 0 {
+		_, _ = g.ResponseWriter.Write(g.buf)
+	}
+}
+
+// bodyLimitMiddleware caps request bodies at MYCODER_MAX_BODY_BYTES (default
+// 32MiB, 0 disables) and answers 413 when a request exceeds it. Bodies declaring
+// a larger Content-Length are rejected up front; otherwise the read fails once the
+// cap is crossed and the handler's resulting error response is replaced by a 413.
+func bodyLimitMiddleware(next http.Handler) http.Handler {
+	limit := int64(config.GetInt("MYCODER_MAX_BODY_BYTES", 32<<20))
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		lb := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+		r.Body = lb
+		next.ServeHTTP(&bodyLimitWriter{ResponseWriter: w, body: lb, limit: limit}, r)
+	})
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	writeError(w, http.StatusRequestEntityTooLarge, "body_too_large", fmt.Sprintf("request body exceeds %d bytes", limit))
+}
+
+// limitedBody records whether the wrapped MaxBytesReader hit its cap.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimitWriter turns a handler's error response into a 413 when the request
+// body was cut off, so "malformed request body" is not reported for a size problem.
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	body     *limitedBody
+	limit    int64
+	replaced bool
+}
+
+func (bw *bodyLimitWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && bw.body.exceeded {
+		bw.replaced = true
+		bw.ResponseWriter.Header().Del("Content-Length")
+		writeBodyTooLarge(bw.ResponseWriter, bw.limit)
+		return
+	}
+	bw.ResponseWriter.WriteHeader(code)
+}
+
+func (bw *bodyLimitWriter) Write(p []byte) (int, error) {
+	if bw.replaced {
+		return len(p), nil
+	}
+	return bw.ResponseWriter.Write(p)
+}
+
+func (bw *bodyLimitWriter) Flush() {
+	if fl, ok := bw.ResponseWriter.(http.Flusher); ok && !bw.replaced {
+		fl.Flush()
+	}
+}
+
+// logBodyMaxBytes caps how much of each request/response body bodyLogMiddleware records.
+const logBodyMaxBytes = 2048
+
+// bodyLogMiddleware logs truncated request and response bodies as `http.body`
+// when MYCODER_LOG_BODIES=1 (off by default). Auth headers and secret-looking
+// JSON fields are redacted; the entry carries the request id of the access log.
+func bodyLogMiddleware(next http.Handler, lg *mylog.Logger) http.Handler {
+	if config.Get("MYCODER_LOG_BODIES") != "1" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			// peek the head, then hand the handler the full stream again
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, logBodyMaxBytes+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+		}
+		cw := &bodyCaptureWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		reqID := w.Header().Get("X-Request-ID")
+		if reqID == "" {
+			reqID = r.Header.Get("X-Request-ID")
+		}
+		reqText, reqCut := truncateLogBody(reqBody)
+		respText, respCut := truncateLogBody(cw.buf.Bytes())
+		lg.Info("http.body",
+			"req_id", reqID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"headers", redactedHeaders(r.Header),
+			"reqBody", reqText,
+			"reqBodyTruncated", reqCut,
+			"status", cw.status,
+			"respBody", respText,
+			"respBodyTruncated", respCut || cw.total > logBodyMaxBytes,
+		)
+	})
+}
+
+// loggedBody is a body excerpt; as a distinct type it bypasses the logger's
+// whole-value secret heuristic, so redactBody handles secrets instead.
+type loggedBody string
+
+func truncateLogBody(b []byte) (loggedBody, bool) {
+	cut := len(b) > logBodyMaxBytes
+	if cut {
+		b = b[:logBodyMaxBytes]
+	}
+	return loggedBody(redactBody(string(b))), cut
+}
+
+var secretJSONField = regexp.MustCompile(`(?i)("[a-z_]*(?:token|password|secret|api_?key|authorization)[a-z_]*"\s*:\s*)"[^"]*"`)
+
+func redactBody(s string) string {
+	return secretJSONField.ReplaceAllString(s, `$1"***"`)
+}
+
+// redactedHeaders flattens request headers for logging, masking credentials.
+func redactedHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		switch strings.ToLower(k) {
+		case "authorization", "proxy-authorization", "cookie", "x-api-key":
+			out[k] = "***"
+		default:
+			out[k] = strings.Join(v, ", ")
+		}
+	}
+	return out
+}
+
+// bodyCaptureWriter keeps the first logBodyMaxBytes of the response.
+type bodyCaptureWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	total  int
+	status int
+}
+
+func (cw *bodyCaptureWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *bodyCaptureWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if room := logBodyMaxBytes - cw.buf.Len(); room > 0 {
+		cw.buf.Write(p[:min(room, len(p))])
+	}
+	cw.total += len(p)
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *bodyCaptureWriter) Flush() {
+	if fl, ok := cw.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+func isCompressedContentType(ct string) bool {
+	ct = strings.ToLower(ct)
+	for _, p := range []string{"image/", "video/", "audio/", "application/zip", "application/gzip", "application/x-gzip", "application/octet-stream"} {
+		if strings.HasPrefix(ct, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isStreamingRequest(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/stream") || strings.Contains(r.Header.Get("Accept"), "text/event-stream") || bodyRequestsStream(r)
+}
+
+// streamBodyRoutes switch to SSE when the JSON body carries "stream":true.
+var streamBodyRoutes = map[string]bool{"/chat": true}
+
+// peekedBody replays a request body that was read to find its stream flag.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+	stream bool
+}
+
+// bodyRequestsStream reports whether a POST to a stream-capable route asks
+// for SSE in its body. The body is read up to MYCODER_MAX_BODY_BYTES and put
+// back for the handler; later calls reuse the first answer.
+func bodyRequestsStream(r *http.Request) bool {
+	if r.Method != http.MethodPost || !streamBodyRoutes[r.URL.Path] || r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	if pb, ok := r.Body.(*peekedBody); ok {
+		return pb.stream
+	}
+	var src io.Reader = r.Body
+	if limit := int64(config.GetInt("MYCODER_MAX_BODY_BYTES", 32<<20)); limit > 0 {
+		src = io.LimitReader(r.Body, limit+1)
+	}
+	head, _ := io.ReadAll(src)
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	_ = json.Unmarshal(head, &req)
+	r.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body, stream: req.Stream}
+	return req.Stream
+}
+
+func parseFloatEnv(key string) float64 {
+	v := config.Get(key)
+	if v == "" {
+		return -1
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+		return f
+	}
+	return -1
+}
+
+func logMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// request-id propagation: accept client-provided or generate
+		reqID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if reqID == "" {
+			reqID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", reqID)
+		rec := &statusRecorder{ResponseWriter: w}
+		r, ai := withAuthInfo(r)
+		next.ServeHTTP(rec, r)
+		dur := time.Since(start)
+		lg := mylog.New()
+		kv := []any{
+			"req_id", reqID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"userAgent", r.UserAgent(),
+			"referer", r.Referer(),
+			"remoteIP", clientIP(r),
+			"status", rec.status,
+			"duration_ms", int(dur / time.Millisecond),
+			"bytes", rec.nbytes,
+		}
+		if ai.label != "" {
+			kv = append(kv, "auth", ai.label)
+		}
+		lg.Info("http.req", kv...)
+		// metrics: requests and durations (with label normalization + sampling)
+		if shouldSample() {
+			path := normalizePath(r.URL.Path)
+			mkey := r.Method + "|" + path + "|" + fmt.Sprintf("%d", rec.status)
+			dkey := r.Method + "|" + path
+			metrics.mu.Lock()
+			metrics.reqTotal[mkey]++
+			metrics.observeDuration(dkey, dur.Seconds())
+			metrics.mu.Unlock()
+		}
+	})
+}
+
+// Handlers
+func (a *API) handleProjects(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list := a.store.ListProjects()
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		if a.readOnly {
+			writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+			return
+		}
+		var req struct {
+			Name     string   `json:"name"`
+			RootPath string   `json:"rootPath"`
+			Ignore   []string `json:"ignore"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+			return
+		}
+		if req.Name == "" || req.RootPath == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "name and rootPath required")
+			return
+		}
+		p := a.store.CreateProject(req.Name, req.RootPath, req.Ignore)
+		writeJSON(w, http.StatusOK, map[string]string{"projectID": p.ID})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+	}
+}
+
+// handleIndexStats reports document/chunk/vector counts for a project so users
+// can tell whether embeddings were generated (zero vectors means lexical-only RAG).
+func (a *API) handleIndexStats(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	pid := r.URL.Query().Get("projectID")
+	if pid == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID required")
+		return
+	}
+	if _, ok := a.store.GetProject(pid); !ok {
+		writeError(w, http.StatusNotFound, "not_found", "project not found")
+		return
+	}
+	var st models.IndexStats
+	if s, ok := a.store.(indexStatser); ok {
+		var err error
+		if st, err = s.IndexStats(pid); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+	}
+	vectors := 0
+	byModel := []vectorstore.ModelStats{}
+	if sr, ok := a.vs.(vectorstore.StatsReporter); ok {
+		ms, err := sr.Stats(r.Context(), pid)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		for _, m := range ms {
+			vectors += m.Count
+		}
+		if ms != nil {
+			byModel = ms
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"projectID":         pid,
+		"documents":         st.Documents,
+		"chunks":            st.Chunks,
+		"bytes":             st.Bytes,
+		"vectors":           vectors,
+		"vectorModels":      byModel,
+		"embeddingsEnabled": a.emb != nil,
+		"embeddingModel":    config.Get("MYCODER_EMBEDDING_MODEL"),
+	})
+}
+
+// handleReadyz answers 200 once the server can serve requests, i.e. its store
+// responds, and 503 otherwise. Unlike /healthz it checks dependencies.
+func (a *API) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if ss, ok := a.store.(*store.SQLiteStore); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		if err := ss.DB().PingContext(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not ready: " + err.Error()))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ready"))
+}
+
+// handleDiagnostics reports server-side component status for `mycoder doctor`:
+// LLM reachability, embeddings, store writability and whether anything is indexed.
+func (a *API) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	llmOK, llmURL, llmErr := false, "", ""
+	if a.llm != nil {
+		llmOK = true
+		if p, ok := a.llm.(modelProber); ok {
+			llmURL = p.BaseURL()
+			ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+			if _, err := p.ListModels(ctx); err != nil {
+				llmOK, llmErr = false, err.Error()
+			}
+			cancel()
+		}
+	}
+	storeKind, dbPath, writable, storeErr := "memory", "", true, ""
+	if ss, ok := a.store.(*store.SQLiteStore); ok {
+		storeKind, dbPath = "sqlite", ss.Path()
+		if err := checkWritable(dbPath); err != nil {
+			writable, storeErr = false, err.Error()
+		}
+	}
+	stats := a.store.Stats()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"components": map[string]bool{
+			"llm":           llmOK,
+			"embeddings":    a.emb != nil,
+			"vectorStore":   a.vs != nil,
+			"storeWritable": writable,
+			"indexed":       stats["documents"] > 0,
+		},
+		"llmURL":         llmURL,
+		"llmError":       llmErr,
+		"embeddingModel": config.Get("MYCODER_EMBEDDING_MODEL"),
+		"store":          storeKind,
+		"sqlitePath":     dbPath,
+		"storeError":     storeErr,
+		"projects":       stats["projects"],
+		"documents":      stats["documents"],
+		"readOnly":       a.readOnly,
+	})
+}
+
+// checkWritable verifies the database file and its directory (journal/WAL files) accept writes.
+func checkWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mycoder-diag-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// indexExcludes merges the project's stored ignore globs with the request's exclude list.
+func indexExcludes(p *models.Project, exclude []string) []string {
+	if len(p.Ignore) == 0 {
+		return exclude
+	}
+	return append(append([]string(nil), p.Ignore...), exclude...)
+}
+
+func (a *API) handleIndexRun(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
@@ -741,8 +1686,10 @@ func (a *API) handleIndexRun(w http.ResponseWriter, r *http.Request) {
 		Mode      models.IndexMode `json:"mode"`
 		MaxFiles  int              `json:"maxFiles"`
 		MaxBytes  int64            `json:"maxBytes"`
+		MaxTotal  int64            `json:"maxTotalBytes"`
 		Include   []string         `json:"include"`
 		Exclude   []string         `json:"exclude"`
+		DryRun    bool             `json:"dryRun"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
@@ -755,6 +1702,32 @@ func (a *API) handleIndexRun(w http.ResponseWriter, r *http.Request) {
 	if req.Mode == "" {
 		req.Mode = models.IndexFull
 	}
+	if req.DryRun {
+		p, ok := a.store.GetProject(req.ProjectID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "project not found")
+			return
+		}
+		opt := indexer.Options{MaxFiles: req.MaxFiles, MaxFileSize: req.MaxBytes, MaxTotalBytes: req.MaxTotal, Include: req.Include, Exclude: indexExcludes(p, req.Exclude)}
+		docs, skipped, err := indexer.IndexWithStats(p.RootPath, opt)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		type dryRunFile struct {
+			Path string `json:"path"`
+			Size int    `json:"size"`
+			Lang string `json:"lang"`
+		}
+		files := make([]dryRunFile, 0, len(docs))
+		var total int64
+		for _, d := range docs {
+			files = append(files, dryRunFile{Path: d.Path, Size: len(d.Content), Lang: d.Lang})
+			total += int64(len(d.Content))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"files": files, "count": len(files), "totalBytes": total, "skippedBinary": skipped.SkippedBinary, "skippedTotalCap": skipped.SkippedTotalCap})
+		return
+	}
 	job, err := a.store.CreateIndexJob(req.ProjectID, req.Mode)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
@@ -772,28 +1745,23 @@ func (a *API) handleIndexRun(w http.ResponseWriter, r *http.Request) {
 			if req.MaxBytes > 0 {
 				opt.MaxFileSize = req.MaxBytes
 			}
+			opt.MaxTotalBytes = req.MaxTotal
 			if len(req.Include) > 0 {
 				opt.Include = req.Include
 			}
-			if len(req.Exclude) > 0 {
-				opt.Exclude = req.Exclude
+			opt.Exclude = indexExcludes(p, req.Exclude)
+			docs, skipped, err := indexer.IndexWithStats(p.RootPath, opt)
+			if err != nil {
+				mylog.New().Error("index.failed", "job", id, "project", p.ID, "root", p.RootPath, "err", err.Error())
+				_, _ = a.store.SetJobError(id, err.Error(), map[string]int{"documents": 0, "errors": 1})
+				return
 			}
-			docs, _ := indexer.Index(p.RootPath, opt)
 			// incremental if supported
-			var pipe *embedpipe.Pipeline
-			if a.emb != nil && a.vs != nil {
-				pipe = embedpipe.New(a.emb, a.vs)
-			}
+			pipe := a.newPipeline(context.Background())
 			if inc, ok := a.store.(IncrementalStore); ok {
-				present := make([]string, 0, len(docs))
-				for _, d := range docs {
-					doc := inc.UpsertDocument(p.ID, d.Path, d.Content, d.SHA, d.Lang, d.MTime)
-					if pipe != nil {
-						pipe.Add(p.ID, doc.ID, d.Path, d.SHA, d.Content)
-					}
-					present = append(present, d.Path)
-				}
-				_ = inc.PruneDocuments(p.ID, present)
+				present := upsertDocs(context.Background(), inc, p.ID, docs, pipe, nil)
+				// files left out by the total cap were not read, not deleted
+				_ = inc.PruneDocuments(p.ID, append(present, skipped.CappedPaths...))
 				if pipe != nil {
 					_ = pipe.Flush(context.Background())
 				}
@@ -806,11 +1774,11 @@ func (a *API) handleIndexRun(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 			}
-			stats := map[string]int{"documents": len(docs)}
+			stats := map[string]int{"documents": len(docs), "skippedBinary": skipped.SkippedBinary, "skippedTotalCap": skipped.SkippedTotalCap}
 			_, _ = a.store.SetJobStatus(id, models.JobCompleted, stats)
 			return
 		}
-		_, _ = a.store.SetJobStatus(id, models.JobFailed, map[string]int{"documents": 0})
+		_, _ = a.store.SetJobError(id, "project not found", map[string]int{"documents": 0, "errors": 1})
 	}(job.ID)
 
 	writeJSON(w, http.StatusOK, map[string]string{"jobID": job.ID})
@@ -830,6 +1798,7 @@ func (a *API) handleIndexRunStream(w http.ResponseWriter, r *http.Request) {
 		Mode      models.IndexMode `json:"mode"`
 		MaxFiles  int              `json:"maxFiles"`
 		MaxBytes  int64            `json:"maxBytes"`
+		MaxTotal  int64            `json:"maxTotalBytes"`
 		Include   []string         `json:"include"`
 		Exclude   []string         `json:"exclude"`
 	}
@@ -876,53 +1845,56 @@ func (a *API) handleIndexRunStream(w http.ResponseWriter, r *http.Request) {
 	if req.MaxBytes > 0 {
 		opt.MaxFileSize = req.MaxBytes
 	}
+	opt.MaxTotalBytes = req.MaxTotal
 	if len(req.Include) > 0 {
 		opt.Include = req.Include
 	}
-	if len(req.Exclude) > 0 {
-		opt.Exclude = req.Exclude
-	}
-	docs, err := indexer.Index(p.RootPath, opt)
+	opt.Exclude = indexExcludes(p, req.Exclude)
+	docs, skipped, err := indexer.IndexWithStats(p.RootPath, opt)
 	if err != nil {
+		mylog.New().Error("index.failed", "job", job.ID, "project", p.ID, "root", p.RootPath, "err", err.Error())
+		_, _ = a.store.SetJobError(job.ID, err.Error(), map[string]int{"documents": 0, "errors": 1})
 		send("error", jsonEscape(err.Error()))
 		return
 	}
 	total := len(docs)
 	if total == 0 {
-		_, _ = a.store.SetJobStatus(job.ID, models.JobCompleted, map[string]int{"documents": 0})
-		send("completed", `{"documents":0}`)
+		_, _ = a.store.SetJobStatus(job.ID, models.JobCompleted, map[string]int{"documents": 0, "skippedBinary": skipped.SkippedBinary, "skippedTotalCap": skipped.SkippedTotalCap})
+		send("completed", fmt.Sprintf(`{"documents":0,"skippedBinary":%d,"skippedTotalCap":%d}`, skipped.SkippedBinary, skipped.SkippedTotalCap))
 		return
 	}
-	// ingestion phase with progress, respect client cancel
+	// ingestion phase with progress, respect client cancel; the start event and
+	// elapsedMs let clients derive a rate and ETA
 	reqCtx := r.Context()
+	started := time.Now()
+	send("start", fmt.Sprintf(`{"total":%d,"startedAt":%q}`, total, started.UTC().Format(time.RFC3339Nano)))
+	progress := func(n int) {
+		send("progress", fmt.Sprintf(`{"indexed":%d,"total":%d,"elapsedMs":%d}`, n, total, time.Since(started).Milliseconds()))
+	}
 	ingested := 0
-	var pipe *embedpipe.Pipeline
-	if a.emb != nil && a.vs != nil {
-		pipe = embedpipe.New(a.emb, a.vs)
+	// a disconnected client ends the job as canceled instead of leaving it running
+	canceled := func() bool {
+		if reqCtx.Err() == nil {
+			return false
+		}
+		_, _ = a.store.SetJobStatus(job.ID, models.JobCanceled, map[string]int{"documents": ingested, "skippedBinary": skipped.SkippedBinary, "skippedTotalCap": skipped.SkippedTotalCap})
+		return true
 	}
+	pipe := a.newPipeline(reqCtx)
 	if inc, ok := a.store.(IncrementalStore); ok {
-		present := make([]string, 0, total)
-		for _, d := range docs {
-			if reqCtx.Err() != nil {
-				return
-			}
-			doc := inc.UpsertDocument(p.ID, d.Path, d.Content, d.SHA, d.Lang, d.MTime)
-			if pipe != nil {
-				pipe.Add(p.ID, doc.ID, d.Path, d.SHA, d.Content)
-			}
-			present = append(present, d.Path)
-			ingested++
-			if ingested%10 == 0 || ingested == total {
-				send("progress", fmt.Sprintf(`{"indexed":%d,"total":%d}`, ingested, total))
-			}
+		present := upsertDocs(reqCtx, inc, p.ID, docs, pipe, progress)
+		ingested = len(present)
+		if canceled() {
+			return
 		}
-		_ = inc.PruneDocuments(p.ID, present)
+		// files left out by the total cap were not read, not deleted
+		_ = inc.PruneDocuments(p.ID, append(present, skipped.CappedPaths...))
 		if pipe != nil {
 			_ = pipe.Flush(reqCtx)
 		}
 	} else {
 		for _, d := range docs {
-			if reqCtx.Err() != nil {
+			if canceled() {
 				return
 			}
 			a.store.AddDocument(p.ID, d.Path, d.Content)
@@ -933,14 +1905,62 @@ func (a *API) handleIndexRunStream(w http.ResponseWriter, r *http.Request) {
 			}
 			ingested++
 			if ingested%10 == 0 || ingested == total {
-				send("progress", fmt.Sprintf(`{"indexed":%d,"total":%d}`, ingested, total))
+				progress(ingested)
 			}
 		}
 	}
-	stats := map[string]int{"documents": total}
+	if canceled() {
+		return
+	}
+	stats := map[string]int{"documents": total, "skippedBinary": skipped.SkippedBinary, "skippedTotalCap": skipped.SkippedTotalCap}
 	_, _ = a.store.SetJobStatus(job.ID, models.JobCompleted, stats)
 	// completed
-	send("completed", fmt.Sprintf(`{"documents":%d}`, total))
+	send("completed", fmt.Sprintf(`{"documents":%d,"skippedBinary":%d,"skippedTotalCap":%d}`, total, skipped.SkippedBinary, skipped.SkippedTotalCap))
+}
+
+// ingestBatch is how many files the index handlers hand to the store at once;
+// cancellation and progress are checked between batches.
+const ingestBatch = 50
+
+// upsertDocs writes docs through the store's batch path when available (one
+// transaction per batch) and feeds the embedding pipeline. progress, if set,
+// receives the running count after each batch. It returns the paths written,
+// stopping early when ctx is canceled.
+func upsertDocs(ctx context.Context, inc IncrementalStore, projectID string, docs []indexer.FileDoc, pipe *embedpipe.Pipeline, progress func(n int)) []string {
+	bu, batched := inc.(batchUpserter)
+	present := make([]string, 0, len(docs))
+	for start := 0; start < len(docs); start += ingestBatch {
+		if ctx.Err() != nil {
+			return present
+		}
+		part := docs[start:min(start+ingestBatch, len(docs))]
+		var stored []*models.Document
+		if batched {
+			batch := make([]store.Doc, len(part))
+			for i, d := range part {
+				batch[i] = store.Doc(d)
+			}
+			var err error
+			stored, err = bu.UpsertDocuments(projectID, batch)
+			if err != nil {
+				mylog.New().Warn("index.batch_upsert.failed", "project", projectID, "files", len(part), "stored", len(stored), "err", err.Error())
+			}
+		}
+		// per-file path for stores without batching, or to finish a failed batch
+		for _, d := range part[len(stored):] {
+			stored = append(stored, inc.UpsertDocument(projectID, d.Path, d.Content, d.SHA, d.Lang, d.MTime))
+		}
+		for i, d := range part {
+			if pipe != nil {
+				pipe.Add(projectID, stored[i].ID, d.Path, d.SHA, d.Content)
+			}
+			present = append(present, d.Path)
+		}
+		if progress != nil {
+			progress(len(present))
+		}
+	}
+	return present
 }
 
 func (a *API) handleIndexJob(w http.ResponseWriter, r *http.Request) {
@@ -981,6 +2001,14 @@ func writeError(w http.ResponseWriter, status int, errStr, message string) {
 	writeJSON(w, status, apiError{Error: errStr, Message: message, Code: status})
 }
 
+const (
+	// maxSearchK caps the k parameter of /search.
+	maxSearchK = 100
+	// searchGroupOverfetch is how many chunks per requested file
+	// groupByFile fetches before grouping.
+	searchGroupOverfetch = 5
+)
+
 func (a *API) handleSearch(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
@@ -995,11 +2023,230 @@ func (a *API) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	k := 10
+	if v := r.URL.Query().Get("k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid_request", "k must be a positive integer")
+			return
+		}
+		k = n
+	}
+	if k > maxSearchK {
+		k = maxSearchK
+	}
+	files := k
+	group := r.URL.Query().Get("groupByFile") == "1"
+	if group {
+		// over-fetch chunks so grouping still yields up to k files
+		k *= searchGroupOverfetch
+	}
 	pid := r.URL.Query().Get("projectID")
-	results := a.store.Search(pid, q, k)
+	var results []models.SearchResult
+	switch r.URL.Query().Get("mode") {
+	case "", "fts":
+		var err error
+		if results, err = a.store.Search(pid, q, k); err != nil {
+			if errors.Is(err, store.ErrInvalidQuery) {
+				writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			} else {
+				writeError(w, http.StatusInternalServerError, "internal_error", "search failed: "+err.Error())
+			}
+			return
+		}
+	case "literal":
+		results = a.store.SearchRegexp(pid, regexp.MustCompile(regexp.QuoteMeta(q)), k)
+	case "regex":
+		if len(q) > maxSearchPatternLen {
+			writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("regex longer than %d bytes", maxSearchPatternLen))
+			return
+		}
+		re, err := regexp.Compile(q)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid regex: "+err.Error())
+			return
+		}
+		results = a.store.SearchRegexp(pid, re, k)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", "mode must be fts|literal|regex")
+		return
+	}
+	switch r.URL.Query().Get("preview") {
+	case "", "fts":
+	case "lines":
+		margin := 2
+		if v := r.URL.Query().Get("margin"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "invalid_request", "margin must be a non-negative integer")
+				return
+			}
+			margin = n
+		}
+		// line previews need the project root; without projectID keep the FTS snippet
+		if _, ok := a.store.GetProject(pid); ok {
+			a.linePreviews(pid, q, margin, results)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", "preview must be fts|lines")
+		return
+	}
+	if r.URL.Query().Get("includeContent") == "1" {
+		a.attachContent(pid, results)
+	}
+	if group {
+		writeJSON(w, http.StatusOK, map[string]any{"results": groupResultsByFile(results, files)})
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"results": results})
 }
 
+// attachContent sets Content on the first result of each file to the full
+// file, read through resolveProjectPath; later chunks of the same file carry
+// none. Files over MYCODER_SEARCH_CONTENT_MAX_BYTES (default 64KiB) are flagged
+// ContentOmitted instead, and files hidden by the fs policy are skipped.
+// Without a project nothing is attached.
+func (a *API) attachContent(projectID string, results []models.SearchResult) {
+	if projectID == "" {
+		return
+	}
+	limit := int64(config.GetInt("MYCODER_SEARCH_CONTENT_MAX_BYTES", 64*1024))
+	seen := map[string]bool{}
+	for i := range results {
+		res := &results[i]
+		if seen[res.Path] {
+			continue
+		}
+		seen[res.Path] = true
+		if ok, _ := fsAllowed(res.Path); !ok {
+			continue
+		}
+		_, full, ok := a.resolveProjectPath(projectID, res.Path)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(full)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if info.Size() > limit {
+			res.ContentOmitted = true
+		} else if b, err := os.ReadFile(full); err == nil {
+			res.Content = string(b)
+		}
+	}
+}
+
+type lineRange struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+type fileSearchResult struct {
+	Path           string      `json:"path"`
+	Score          float64     `json:"score"`
+	Preview        string      `json:"preview,omitempty"`
+	Ranges         []lineRange `json:"ranges"`
+	Content        string      `json:"content,omitempty"`
+	ContentOmitted bool        `json:"contentOmitted,omitempty"`
+}
+
+// groupResultsByFile folds per-chunk results into one entry per file, keeping
+// the store's ranking: each file takes the score and preview of its first
+// (best) chunk and collects all matching line ranges in line order.
+func groupResultsByFile(results []models.SearchResult, limit int) []fileSearchResult {
+	out := []fileSearchResult{}
+	idx := map[string]int{}
+	for _, res := range results {
+		i, ok := idx[res.Path]
+		if !ok {
+			if len(out) >= limit {
+				continue
+			}
+			i = len(out)
+			idx[res.Path] = i
+			out = append(out, fileSearchResult{Path: res.Path, Score: res.Score, Preview: res.Preview, Content: res.Content, ContentOmitted: res.ContentOmitted})
+		}
+		if res.StartLine > 0 {
+			out[i].Ranges = append(out[i].Ranges, lineRange{StartLine: res.StartLine, EndLine: res.EndLine})
+		}
+	}
+	for i := range out {
+		sort.Slice(out[i].Ranges, func(a, b int) bool { return out[i].Ranges[a].StartLine < out[i].Ranges[b].StartLine })
+	}
+	return out
+}
+
+// maxSearchPatternLen bounds regex search patterns.
+const maxSearchPatternLen = 1024
+
+// searchPreviewMaxLines caps line previews so a long chunk does not dump a whole file.
+const searchPreviewMaxLines = 20
+
+// linePreviews replaces each result's preview with the source lines around the
+// match: the first line of the chunk containing a query term, plus margin lines
+// either side. Files are read through resolveProjectPath; results whose file
+// is hidden by the fs policy or cannot be read keep their original preview.
+func (a *API) linePreviews(projectID, query string, margin int, results []models.SearchResult) {
+	var terms []string
+	for _, t := range strings.Fields(strings.ToLower(query)) {
+		t = strings.Trim(t, `"'*()^:+-`)
+		switch t {
+		case "", "and", "or", "not", "near":
+			continue
+		}
+		terms = append(terms, t)
+	}
+	files := map[string][]string{}
+	for i := range results {
+		res := &results[i]
+		lines, ok := files[res.Path]
+		if !ok {
+			if allowed, _ := fsAllowed(res.Path); !allowed {
+				files[res.Path] = nil
+				continue
+			}
+			if _, full, ok := a.resolveProjectPath(projectID, filepath.FromSlash(res.Path)); ok {
+				if data, err := os.ReadFile(full); err == nil {
+					lines = strings.Split(string(data), "\n")
+				}
+			}
+			files[res.Path] = lines
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		start, end := res.StartLine, res.EndLine
+		if start <= 0 || start > len(lines) {
+			start = 1
+		}
+		if end < start || end > len(lines) {
+			end = start
+		}
+		hit := start
+	scan:
+		for ln := start; ln <= end; ln++ {
+			l := strings.ToLower(lines[ln-1])
+			for _, t := range terms {
+				if strings.Contains(l, t) {
+					hit = ln
+					break scan
+				}
+			}
+		}
+		s, e := hit-margin, hit+margin
+		if s < 1 {
+			s = 1
+		}
+		if e > len(lines) {
+			e = len(lines)
+		}
+		if e-s+1 > searchPreviewMaxLines {
+			e = s + searchPreviewMaxLines - 1
+		}
+		res.Preview = strings.Join(lines[s-1:e], "\n")
+	}
+}
+
 // Web enrichment (optional)
 type webResult struct {
 	Title   string  `json:"title"`
@@ -1027,29 +2274,188 @@ func (a *API) handleWebSearch(w http.ResponseWriter, r *http.Request) {
 	if req.Limit <= 0 {
 		req.Limit = 5
 	}
+	provider, err := webSearchProviderFromEnv()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "not_configured", err.Error())
+		return
+	}
+	results, err := provider.Search(r.Context(), req.Query, req.Limit)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "upstream_error", err.Error())
+		return
+	}
+	if results == nil {
+		results = []webResult{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results, "provider": provider.Name()})
+}
+
+// WebSearchProvider performs web searches for /web/search.
+type WebSearchProvider interface {
+	Name() string
+	Search(ctx context.Context, query string, limit int) ([]webResult, error)
+}
+
+// webSearchProviderFromEnv selects the provider from MYCODER_WEB_SEARCH_PROVIDER
+// (mock|json|searxng). MYCODER_WEB_SEARCH_MOCK=1 still forces the mock.
+func webSearchProviderFromEnv() (WebSearchProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(config.Get("MYCODER_WEB_SEARCH_PROVIDER")))
+	if config.Get("MYCODER_WEB_SEARCH_MOCK") == "1" {
+		name = "mock"
+	}
+	endpoint := strings.TrimSpace(config.Get("MYCODER_WEB_SEARCH_URL"))
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch name {
+	case "mock":
+		return mockWebSearch{}, nil
+	case "json", "searxng":
+		if endpoint == "" {
+			return nil, fmt.Errorf("MYCODER_WEB_SEARCH_URL required for provider %s", name)
+		}
+		if name == "searxng" {
+			return &searxngWebSearch{baseURL: strings.TrimRight(endpoint, "/"), client: client}, nil
+		}
+		return &jsonWebSearch{endpoint: endpoint, apiKey: config.Get("MYCODER_WEB_SEARCH_API_KEY"), client: client}, nil
+	case "":
+		return nil, errors.New("web search not configured")
+	default:
+		return nil, fmt.Errorf("unknown web search provider: %s", name)
+	}
+}
+
+// mockWebSearch returns deterministic results without network access (tests).
+type mockWebSearch struct{}
+
+func (mockWebSearch) Name() string { return "mock" }
+
+func (mockWebSearch) Search(_ context.Context, query string, limit int) ([]webResult, error) {
 	var results []webResult
-	// mock provider controlled by env (no external network in tests)
-	if os.Getenv("MYCODER_WEB_SEARCH_MOCK") == "1" {
-		for i := 1; i <= req.Limit; i++ {
-			results = append(results, webResult{
-				Title:   fmt.Sprintf("Result %d for %s", i, req.Query),
-				URL:     fmt.Sprintf("https://example.com/%s/%d", strings.ReplaceAll(req.Query, " ", "-"), i),
-				Snippet: "This is a mock snippet.",
-				Score:   1.0 - float64(i-1)*0.1,
-			})
+	for i := 1; i <= limit; i++ {
+		results = append(results, webResult{
+			Title:   fmt.Sprintf("Result %d for %s", i, query),
+			URL:     fmt.Sprintf("https://example.com/%s/%d", strings.ReplaceAll(query, " ", "-"), i),
+			Snippet: "This is a mock snippet.",
+			Score:   1.0 - float64(i-1)*0.1,
+		})
+	}
+	return results, nil
+}
+
+// jsonWebSearch calls a generic endpoint: GET <url>?q=<query>&limit=<n> that
+// answers {"results":[{title,url,snippet,score}]}.
+type jsonWebSearch struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func (p *jsonWebSearch) Name() string { return "json" }
+
+func (p *jsonWebSearch) Search(ctx context.Context, query string, limit int) ([]webResult, error) {
+	u, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MYCODER_WEB_SEARCH_URL: %w", err)
+	}
+	qs := u.Query()
+	qs.Set("q", query)
+	qs.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = qs.Encode()
+	var body struct {
+		Results []webResult `json:"results"`
+	}
+	if err := getWebSearchJSON(ctx, p.client, u.String(), p.apiKey, &body); err != nil {
+		return nil, err
+	}
+	return normalizeWebResults(body.Results, limit), nil
+}
+
+// searxngWebSearch queries a SearXNG instance via its JSON output format.
+type searxngWebSearch struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *searxngWebSearch) Name() string { return "searxng" }
+
+func (p *searxngWebSearch) Search(ctx context.Context, query string, limit int) ([]webResult, error) {
+	u := p.baseURL + "/search?" + url.Values{"q": {query}, "format": {"json"}}.Encode()
+	var body struct {
+		Results []struct {
+			Title   string  `json:"title"`
+			URL     string  `json:"url"`
+			Content string  `json:"content"`
+			Score   float64 `json:"score"`
+		} `json:"results"`
+	}
+	if err := getWebSearchJSON(ctx, p.client, u, "", &body); err != nil {
+		return nil, err
+	}
+	results := make([]webResult, 0, len(body.Results))
+	for _, r := range body.Results {
+		results = append(results, webResult{Title: r.Title, URL: r.URL, Snippet: r.Content, Score: r.Score})
+	}
+	return normalizeWebResults(results, limit), nil
+}
+
+func getWebSearchJSON(ctx context.Context, client *http.Client, u, apiKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("web search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("web search backend returned %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(out); err != nil {
+		return fmt.Errorf("invalid web search response: %w", err)
+	}
+	return nil
+}
+
+// normalizeWebResults drops entries without a URL, truncates to limit and
+// scales scores into 0..1 (rank-based when the backend sends none).
+func normalizeWebResults(in []webResult, limit int) []webResult {
+	out := make([]webResult, 0, len(in))
+	maxScore := 0.0
+	for _, r := range in {
+		if strings.TrimSpace(r.URL) == "" {
+			continue
+		}
+		if r.Score > maxScore {
+			maxScore = r.Score
+		}
+		out = append(out, r)
+		if len(out) >= limit {
+			break
 		}
-	} else {
-		writeError(w, http.StatusServiceUnavailable, "not_configured", "web search not configured")
-		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+	for i := range out {
+		switch {
+		case maxScore <= 0:
+			out[i].Score = 1.0 - float64(i)*0.1
+			if out[i].Score < 0.1 {
+				out[i].Score = 0.1
+			}
+		case maxScore > 1:
+			out[i].Score = out[i].Score / maxScore
+		}
+	}
+	return out
 }
 
 func (a *API) handleWebIngest(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -1080,14 +2486,32 @@ func (a *API) handleWebIngest(w http.ResponseWriter, r *http.Request) {
 		req.MinScore = 0.0
 	}
 	seen := map[string]bool{}
-	added := 0
+	if req.Dedupe {
+		// URLs ingested by earlier calls count as duplicates too
+		existing, err := a.store.ListKnowledge(req.ProjectID, 0)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		for _, k := range existing {
+			if k.SourceType == "web" && k.PathOrURL != "" {
+				seen[strings.ToLower(k.PathOrURL)] = true
+			}
+		}
+	}
+	added, skippedDuplicate, skippedLowScore := 0, 0, 0
 	for _, r0 := range req.Results {
-		if r0.URL == "" || r0.Score < req.MinScore {
+		if r0.URL == "" {
+			continue
+		}
+		if r0.Score < req.MinScore {
+			skippedLowScore++
 			continue
 		}
 		key := strings.ToLower(r0.URL)
 		if req.Dedupe {
 			if seen[key] {
+				skippedDuplicate++
 				continue
 			}
 			seen[key] = true
@@ -1138,7 +2562,7 @@ func (a *API) handleWebIngest(w http.ResponseWriter, r *http.Request) {
 			}
 			sys := llm.Message{Role: llm.RoleSystem, Content: "Summarize these web search results into a concise brief (bullet points)."}
 			usr := llm.Message{Role: llm.RoleUser, Content: b.String()}
-			st, err := a.llm.Chat(r.Context(), os.Getenv("MYCODER_CHAT_MODEL"), []llm.Message{sys, usr}, false, 0)
+			st, err := a.llm.Chat(r.Context(), config.Get("MYCODER_CHAT_MODEL"), []llm.Message{sys, usr}, false, 0)
 			if err == nil {
 				defer st.Close()
 				var buf strings.Builder
@@ -1174,19 +2598,255 @@ func (a *API) handleWebIngest(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	writeJSON(w, http.StatusOK, map[string]int{"added": added})
+	writeJSON(w, http.StatusOK, map[string]int{"added": added, "skippedDuplicate": skippedDuplicate, "skippedLowScore": skippedLowScore})
+}
+
+func domainFromURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	h := strings.TrimSpace(u.Host)
+	if i := strings.IndexByte(h, ':'); i >= 0 {
+		h = h[:i]
+	}
+	return h
+}
+
+// snapshot returns a JSON-friendly copy of the in-process counters.
+// Caller must hold mu.
+func (m *metricsCollector) snapshot() map[string]any {
+	reqs := make(map[string]int, len(m.reqTotal))
+	for k, v := range m.reqTotal {
+		reqs[k] = v
+	}
+	durs := make(map[string]any, len(m.durSum))
+	for k, sum := range m.durSum {
+		durs[k] = map[string]any{"sum": sum, "count": m.durCount[k]}
+	}
+	return map[string]any{
+		"requests":             reqs,
+		"durations":            durs,
+		"chatRequests":         m.chatRequests,
+		"chatTokens":           m.chatTokens,
+		"chatPromptTokens":     m.chatPromptTokens,
+		"chatCompletionTokens": m.chatCompletionTokens,
+		"chatTokenEvents":      m.chatTokenEvents,
+		"embedCacheHits":       m.embedCacheHits,
+		"embedCacheMisses":     m.embedCacheMisses,
+		"embedCacheEvict":      m.embedCacheEvict,
+		"curatorRuns":          m.curatorRuns,
+		"curatorRemoved":       m.curatorRemoved,
+		"vectorDimMismatch":    m.vectorDimMismatch,
+	}
+}
+
+// reset zeroes all counters and histograms, keeping the bucket layout.
+// Caller must hold mu.
+func (m *metricsCollector) reset() {
+	m.reqTotal = make(map[string]int)
+	m.durSum = make(map[string]float64)
+	m.durCount = make(map[string]int)
+	m.durBuckets = make(map[string][]int)
+	m.chatRequests, m.chatTokens, m.chatTokenEvents = 0, 0, 0
+	m.chatPromptTokens, m.chatCompletionTokens = 0, 0
+	m.chatTTFT, m.chatDuration = histogram{}, histogram{}
+	m.embedCacheHits, m.embedCacheMisses, m.embedCacheEvict = 0, 0, 0
+	m.curatorRuns, m.curatorRemoved = 0, 0
+	m.vectorDimMismatch = 0
+}
+
+// metricsPersister is implemented by stores that can keep metrics counters
+// across restarts (SQLite).
+type metricsPersister interface {
+	SaveMetricCounters(values map[string]float64) error
+	LoadMetricCounters() (map[string]float64, error)
+}
+
+// counterValues flattens every cumulative series (counters and histogram
+// bucket/sum/count) into name→value for persistence. Caller must hold mu.
+func (m *metricsCollector) counterValues() map[string]float64 {
+	out := map[string]float64{
+		"chat_requests":          float64(m.chatRequests),
+		"chat_tokens":            float64(m.chatTokens),
+		"chat_prompt_tokens":     float64(m.chatPromptTokens),
+		"chat_completion_tokens": float64(m.chatCompletionTokens),
+		"chat_token_events":      float64(m.chatTokenEvents),
+		"embed_cache_hits":       float64(m.embedCacheHits),
+		"embed_cache_misses":     float64(m.embedCacheMisses),
+		"embed_cache_evictions":  float64(m.embedCacheEvict),
+		"curator_runs":           float64(m.curatorRuns),
+		"curator_removed":        float64(m.curatorRemoved),
+		"vector_dim_mismatch":    float64(m.vectorDimMismatch),
+	}
+	for k, v := range m.reqTotal {
+		out["req:"+k] = float64(v)
+	}
+	for k := range m.durSum {
+		putHistogram(out, "dur:"+k, m.buckets, m.durBuckets[k], m.durSum[k], m.durCount[k])
+	}
+	putHistogram(out, "chat_ttft", m.buckets, m.chatTTFT.counts, m.chatTTFT.sum, m.chatTTFT.total)
+	putHistogram(out, "chat_duration", m.buckets, m.chatDuration.counts, m.chatDuration.sum, m.chatDuration.total)
+	return out
+}
+
+func putHistogram(out map[string]float64, name string, bounds []float64, counts []int, sum float64, total int) {
+	if total == 0 {
+		return
+	}
+	out[name+"#sum"] = sum
+	out[name+"#count"] = float64(total)
+	for i, ub := range bounds {
+		if i < len(counts) && counts[i] > 0 {
+			out[name+"#le="+strconv.FormatFloat(ub, 'g', -1, 64)] = float64(counts[i])
+		}
+	}
+}
+
+// restoreCounters adds persisted values back onto the collector. Histogram
+// buckets whose bound is no longer configured are dropped (they still count
+// toward +Inf via count). Caller must hold mu.
+func (m *metricsCollector) restoreCounters(vals map[string]float64) {
+	scalars := map[string]*int{
+		"chat_requests":          &m.chatRequests,
+		"chat_tokens":            &m.chatTokens,
+		"chat_prompt_tokens":     &m.chatPromptTokens,
+		"chat_completion_tokens": &m.chatCompletionTokens,
+		"chat_token_events":      &m.chatTokenEvents,
+		"embed_cache_hits":       &m.embedCacheHits,
+		"embed_cache_misses":     &m.embedCacheMisses,
+		"embed_cache_evictions":  &m.embedCacheEvict,
+		"curator_runs":           &m.curatorRuns,
+		"curator_removed":        &m.curatorRemoved,
+		"vector_dim_mismatch":    &m.vectorDimMismatch,
+	}
+	for name, v := range vals {
+		if p, ok := scalars[name]; ok {
+			*p += int(v)
+			continue
+		}
+		if k, ok := strings.CutPrefix(name, "req:"); ok {
+			m.reqTotal[k] += int(v)
+			continue
+		}
+		series, field, ok := strings.Cut(name, "#")
+		if !ok {
+			continue
+		}
+		switch {
+		case series == "chat_ttft":
+			m.restoreHistogramField(field, v, &m.chatTTFT.sum, &m.chatTTFT.total, &m.chatTTFT.counts)
+		case series == "chat_duration":
+			m.restoreHistogramField(field, v, &m.chatDuration.sum, &m.chatDuration.total, &m.chatDuration.counts)
+		case strings.HasPrefix(series, "dur:"):
+			k := strings.TrimPrefix(series, "dur:")
+			sum, total, counts := m.durSum[k], m.durCount[k], m.durBuckets[k]
+			m.restoreHistogramField(field, v, &sum, &total, &counts)
+			m.durSum[k], m.durCount[k], m.durBuckets[k] = sum, total, counts
+		}
+	}
+}
+
+func (m *metricsCollector) restoreHistogramField(field string, v float64, sum *float64, total *int, counts *[]int) {
+	if *counts == nil {
+		*counts = make([]int, len(m.buckets))
+	}
+	switch {
+	case field == "sum":
+		*sum += v
+	case field == "count":
+		*total += int(v)
+	case strings.HasPrefix(field, "le="):
+		ub, err := strconv.ParseFloat(strings.TrimPrefix(field, "le="), 64)
+		if err != nil {
+			return
+		}
+		for i, b := range m.buckets {
+			if b == ub {
+				(*counts)[i] += int(v)
+				return
+			}
+		}
+	}
 }
 
-func domainFromURL(raw string) string {
-	u, err := url.Parse(raw)
+// persistMetrics saves the collector's counters to p.
+func persistMetrics(m *metricsCollector, p metricsPersister) error {
+	m.mu.Lock()
+	vals := m.counterValues()
+	m.mu.Unlock()
+	return p.SaveMetricCounters(vals)
+}
+
+// restoreMetrics loads counters saved by persistMetrics into m.
+func restoreMetrics(m *metricsCollector, p metricsPersister) error {
+	vals, err := p.LoadMetricCounters()
 	if err != nil {
-		return ""
+		return err
 	}
-	h := strings.TrimSpace(u.Host)
-	if i := strings.IndexByte(h, ':'); i >= 0 {
-		h = h[:i]
+	m.mu.Lock()
+	m.restoreCounters(vals)
+	m.mu.Unlock()
+	return nil
+}
+
+// POST /metrics/reset: clears in-process metrics (only when MYCODER_METRICS_ALLOW_RESET=1)
+// and returns the snapshot taken just before the reset.
+func (a *API) handleMetricsReset(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
 	}
-	return h
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	if config.Get("MYCODER_METRICS_ALLOW_RESET") != "1" {
+		writeError(w, http.StatusForbidden, "forbidden", "metrics reset disabled (set MYCODER_METRICS_ALLOW_RESET=1)")
+		return
+	}
+	metrics.mu.Lock()
+	snap := metrics.snapshot()
+	metrics.reset()
+	metrics.mu.Unlock()
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// GET /config: effective server settings (env > config file > defaults) with
+// secrets masked.
+func (a *API) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"settings": config.Effective()})
+}
+
+// maxProjectMetricSeries caps the number of per-project label series in /metrics.
+const maxProjectMetricSeries = 50
+
+// writeHistogram emits _bucket/_sum/_count lines for one histogram series.
+// counts holds non-cumulative per-bucket counts aligned with bounds.
+func writeHistogram(w io.Writer, name, labels string, bounds []float64, counts []int, sum float64, total int) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	cum := 0
+	for i, ub := range bounds {
+		if i < len(counts) {
+			cum += counts[i]
+		}
+		io.WriteString(w, fmt.Sprintf("%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, strconv.FormatFloat(ub, 'g', -1, 64), cum))
+	}
+	io.WriteString(w, fmt.Sprintf("%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, total))
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	io.WriteString(w, fmt.Sprintf("%s_sum%s %f\n", name, labels, sum))
+	io.WriteString(w, fmt.Sprintf("%s_count%s %d\n", name, labels, total))
 }
 
 func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
@@ -1210,6 +2870,11 @@ func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 		return 0
 	}
+	// per-project counts (SQLite only), capped to keep label cardinality bounded
+	var perProject []store.ProjectCounts
+	if ss, ok := a.store.(*store.SQLiteStore); ok {
+		perProject, _ = ss.ProjectStats(maxProjectMetricSeries)
+	}
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
 	// minimal exposition format
 	// project/doc/job/knowledge gauges
@@ -1220,6 +2885,9 @@ func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, "# HELP mycoder_documents Number of indexed documents.\n")
 	io.WriteString(w, "# TYPE mycoder_documents gauge\n")
 	io.WriteString(w, fmt.Sprintf("mycoder_documents %d\n", val("documents")))
+	for _, pc := range perProject {
+		io.WriteString(w, fmt.Sprintf("mycoder_documents{project=\"%s\"} %d\n", pc.ProjectID, pc.Documents))
+	}
 
 	io.WriteString(w, "# HELP mycoder_jobs Number of index jobs.\n")
 	io.WriteString(w, "# TYPE mycoder_jobs gauge\n")
@@ -1228,6 +2896,9 @@ func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, "# HELP mycoder_knowledge Number of knowledge items.\n")
 	io.WriteString(w, "# TYPE mycoder_knowledge gauge\n")
 	io.WriteString(w, fmt.Sprintf("mycoder_knowledge %d\n", val("knowledge")))
+	for _, pc := range perProject {
+		io.WriteString(w, fmt.Sprintf("mycoder_knowledge{project=\"%s\"} %d\n", pc.ProjectID, pc.Knowledge))
+	}
 
 	// http request metrics (counters and duration sum/count)
 	metrics.mu.Lock()
@@ -1240,22 +2911,42 @@ func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
 			io.WriteString(w, fmt.Sprintf("mycoder_http_requests_total{method=\"%s\",path=\"%s\",status=\"%s\"} %d\n", method, path, status, v))
 		}
 	}
-	// durations
-	for key, sum := range metrics.durSum {
-		cnt := metrics.durCount[key]
+	// durations (histogram with cumulative buckets)
+	dkeys := make([]string, 0, len(metrics.durSum))
+	for key := range metrics.durSum {
+		dkeys = append(dkeys, key)
+	}
+	sort.Strings(dkeys)
+	if len(dkeys) > 0 {
+		io.WriteString(w, "# HELP mycoder_http_request_duration_seconds HTTP request duration.\n")
+		io.WriteString(w, "# TYPE mycoder_http_request_duration_seconds histogram\n")
+	}
+	for _, key := range dkeys {
 		parts := strings.Split(key, "|")
-		if len(parts) == 2 {
-			method, path := parts[0], parts[1]
-			io.WriteString(w, "# TYPE mycoder_http_request_duration_seconds summary\n")
-			io.WriteString(w, fmt.Sprintf("mycoder_http_request_duration_seconds_sum{method=\"%s\",path=\"%s\"} %f\n", method, path, sum))
-			io.WriteString(w, fmt.Sprintf("mycoder_http_request_duration_seconds_count{method=\"%s\",path=\"%s\"} %d\n", method, path, cnt))
+		if len(parts) != 2 {
+			continue
 		}
+		labels := fmt.Sprintf("method=\"%s\",path=\"%s\"", parts[0], parts[1])
+		writeHistogram(w, "mycoder_http_request_duration_seconds", labels, metrics.buckets, metrics.durBuckets[key], metrics.durSum[key], metrics.durCount[key])
 	}
 	// chat metrics stubs
 	io.WriteString(w, "# TYPE mycoder_chat_requests_total counter\n")
 	io.WriteString(w, fmt.Sprintf("mycoder_chat_requests_total %d\n", metrics.chatRequests))
 	io.WriteString(w, "# TYPE mycoder_chat_stream_tokens_total counter\n")
 	io.WriteString(w, fmt.Sprintf("mycoder_chat_stream_tokens_total %d\n", metrics.chatTokens))
+	io.WriteString(w, "# HELP mycoder_chat_tokens_total Provider-reported chat token usage by type.\n")
+	io.WriteString(w, "# TYPE mycoder_chat_tokens_total counter\n")
+	io.WriteString(w, fmt.Sprintf("mycoder_chat_tokens_total{type=\"prompt\"} %d\n", metrics.chatPromptTokens))
+	io.WriteString(w, fmt.Sprintf("mycoder_chat_tokens_total{type=\"completion\"} %d\n", metrics.chatCompletionTokens))
+	io.WriteString(w, "# HELP mycoder_chat_stream_token_events_total Streamed token events emitted to clients.\n")
+	io.WriteString(w, "# TYPE mycoder_chat_stream_token_events_total counter\n")
+	io.WriteString(w, fmt.Sprintf("mycoder_chat_stream_token_events_total %d\n", metrics.chatTokenEvents))
+	io.WriteString(w, "# HELP mycoder_chat_ttft_seconds Time from chat request receipt (including retrieval) to first streamed token.\n")
+	io.WriteString(w, "# TYPE mycoder_chat_ttft_seconds histogram\n")
+	writeHistogram(w, "mycoder_chat_ttft_seconds", "", metrics.buckets, metrics.chatTTFT.counts, metrics.chatTTFT.sum, metrics.chatTTFT.total)
+	io.WriteString(w, "# HELP mycoder_chat_duration_seconds Total chat request duration, including retrieval.\n")
+	io.WriteString(w, "# TYPE mycoder_chat_duration_seconds histogram\n")
+	writeHistogram(w, "mycoder_chat_duration_seconds", "", metrics.buckets, metrics.chatDuration.counts, metrics.chatDuration.sum, metrics.chatDuration.total)
 	io.WriteString(w, "# HELP mycoder_embed_cache_hits_total Embedding cache hits.\n")
 	io.WriteString(w, "# TYPE mycoder_embed_cache_hits_total counter\n")
 	io.WriteString(w, fmt.Sprintf("mycoder_embed_cache_hits_total %d\n", metrics.embedCacheHits))
@@ -1265,6 +2956,15 @@ func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, "# HELP mycoder_embed_cache_evictions_total Embedding cache evictions (TTL).\n")
 	io.WriteString(w, "# TYPE mycoder_embed_cache_evictions_total counter\n")
 	io.WriteString(w, fmt.Sprintf("mycoder_embed_cache_evictions_total %d\n", metrics.embedCacheEvict))
+	io.WriteString(w, "# HELP mycoder_curator_runs_total Background curator cycles completed.\n")
+	io.WriteString(w, "# TYPE mycoder_curator_runs_total counter\n")
+	io.WriteString(w, fmt.Sprintf("mycoder_curator_runs_total %d\n", metrics.curatorRuns))
+	io.WriteString(w, "# HELP mycoder_curator_removed_total Knowledge items removed by the curator (trust and TTL GC).\n")
+	io.WriteString(w, "# TYPE mycoder_curator_removed_total counter\n")
+	io.WriteString(w, fmt.Sprintf("mycoder_curator_removed_total %d\n", metrics.curatorRemoved))
+	io.WriteString(w, "# HELP mycoder_vector_dim_mismatch_total KNN queries skipped because stored vectors have a different dimension.\n")
+	io.WriteString(w, "# TYPE mycoder_vector_dim_mismatch_total counter\n")
+	io.WriteString(w, fmt.Sprintf("mycoder_vector_dim_mismatch_total %d\n", metrics.vectorDimMismatch))
 	metrics.mu.Unlock()
 
 	// build info
@@ -1280,7 +2980,7 @@ func (a *API) handleKnowledge(w http.ResponseWriter, r *http.Request) {
 	}
 	switch r.Method {
 	case http.MethodPost:
-		if isReadOnly() {
+		if a.readOnly {
 			writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 			return
 		}
@@ -1309,23 +3009,352 @@ func (a *API) handleKnowledge(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid_request", "projectID required")
 			return
 		}
+		filters := map[string]string{}
+		for _, t := range r.URL.Query()["tag"] {
+			key, val, ok := strings.Cut(t, ":")
+			if !ok || strings.TrimSpace(key) == "" {
+				writeError(w, http.StatusBadRequest, "invalid_request", "tag must be key:value")
+				return
+			}
+			filters[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		limit, offset := 0, 0
+		for name, dst := range map[string]*int{"limit": &limit, "offset": &offset} {
+			if v := r.URL.Query().Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					writeError(w, http.StatusBadRequest, "invalid_request", name+" must be a non-negative integer")
+					return
+				}
+				*dst = n
+			}
+		}
 		min := 0.0
 		list, err := a.store.ListKnowledge(pid, min)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string]any{"knowledge": list})
+		if len(filters) > 0 {
+			kept := make([]*models.Knowledge, 0, len(list))
+			for _, k := range list {
+				if knowledgeTagsMatch(k.Tags, filters) {
+					kept = append(kept, k)
+				}
+			}
+			list = kept
+		}
+		total := len(list)
+		if offset > total {
+			offset = total
+		}
+		list = list[offset:]
+		if limit > 0 && limit < len(list) {
+			list = list[:limit]
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"knowledge": list, "total": total, "offset": offset, "limit": limit})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+	}
+}
+
+// knowledgeTagsMatch reports whether the JSON tags object holds every key:value filter.
+func knowledgeTagsMatch(raw string, filters map[string]string) bool {
+	if strings.TrimSpace(raw) == "" {
+		return false
+	}
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+		return false
+	}
+	for k, v := range filters {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// handleKnowledgeItem serves GET/DELETE /knowledge/{id}.
+func (a *API) handleKnowledgeItem(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/knowledge/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not_found", "knowledge not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		k, ok, err := a.store.GetKnowledge(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "knowledge not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, k)
+	case http.MethodDelete:
+		a.deleteKnowledge(w, id)
 	default:
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 	}
 }
 
+// handleKnowledgeDelete serves POST /knowledge/delete {id}.
+func (a *API) handleKnowledgeDelete(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+		return
+	}
+	if strings.TrimSpace(req.ID) == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "id required")
+		return
+	}
+	a.deleteKnowledge(w, req.ID)
+}
+
+// handleKnowledgeUpdate serves POST /knowledge/update; only provided fields change.
+func (a *API) handleKnowledgeUpdate(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+		models.KnowledgeUpdate
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+		return
+	}
+	if strings.TrimSpace(req.ID) == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "id required")
+		return
+	}
+	if req.Text != nil && strings.TrimSpace(*req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "text must not be empty")
+		return
+	}
+	if req.TrustScore != nil && (*req.TrustScore < 0 || *req.TrustScore > 1) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "trustScore must be within 0..1")
+		return
+	}
+	ok, err := a.store.UpdateKnowledge(req.ID, req.KnowledgeUpdate)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	k, found, err := a.store.GetKnowledge(req.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if !ok || !found {
+		writeError(w, http.StatusNotFound, "not_found", "knowledge not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, k)
+}
+
+// handleKnowledgeExport streams a project's knowledge rows as JSONL.
+func (a *API) handleKnowledgeExport(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	pid := r.URL.Query().Get("projectID")
+	if pid == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID required")
+		return
+	}
+	list, err := a.store.ListKnowledge(pid, 0.0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "knowledge-"+pid+".jsonl"))
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for _, k := range list {
+		if err := enc.Encode(k); err != nil {
+			return
+		}
+	}
+}
+
+// handleKnowledgeImport bulk-inserts JSONL knowledge rows. ?projectID= remaps
+// every row to that project; otherwise each row keeps its own projectID.
+func (a *API) handleKnowledgeImport(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	target := r.URL.Query().Get("projectID")
+	if target != "" {
+		if _, ok := a.store.GetProject(target); !ok {
+			writeError(w, http.StatusNotFound, "not_found", "project not found")
+			return
+		}
+	}
+	// every row is validated before anything is written
+	var items []*models.Knowledge
+	seen := map[string]bool{}
+	var order []string
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 8<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var k models.Knowledge
+		if err := json.Unmarshal(raw, &k); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json", fmt.Sprintf("line %d: %v", line, err))
+			return
+		}
+		if target != "" {
+			k.ProjectID = target
+		}
+		if k.ProjectID == "" || k.SourceType == "" || k.Text == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("line %d: projectID, sourceType, text required", line))
+			return
+		}
+		if k.TrustScore < 0 || k.TrustScore > 1 {
+			writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("line %d: trustScore must be between 0 and 1", line))
+			return
+		}
+		if !seen[k.ProjectID] {
+			seen[k.ProjectID] = true
+			order = append(order, k.ProjectID)
+		}
+		items = append(items, &k)
+	}
+	if err := sc.Err(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	// rows keeping their own projectID must name a known project
+	for _, pid := range order {
+		if _, ok := a.store.GetProject(pid); !ok {
+			writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("project not found: %s", pid))
+			return
+		}
+	}
+	imported, err := a.store.ImportKnowledge(items)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"imported": imported})
+}
+
+func (a *API) deleteKnowledge(w http.ResponseWriter, id string) {
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	ok, err := a.store.DeleteKnowledge(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "knowledge not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deleted": id})
+}
+
+// handleKnowledgeDecay runs one decay pass on demand. rate/afterDays default to
+// the curator settings (MYCODER_KNOWLEDGE_DECAY_RATE/_AFTER_DAYS). Decay needs
+// the SQLite store; the memory store reports zero updates.
+func (a *API) handleKnowledgeDecay(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	var req struct {
+		ProjectID string   `json:"projectID"`
+		Rate      *float64 `json:"rate"`
+		AfterDays *int     `json:"afterDays"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+		return
+	}
+	if req.ProjectID == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID required")
+		return
+	}
+	cfg := curatorConfigFromEnv()
+	rate, afterDays := cfg.decayRate, cfg.decayAfterDays
+	if req.Rate != nil {
+		rate = *req.Rate
+	}
+	if req.AfterDays != nil {
+		afterDays = *req.AfterDays
+	}
+	if rate < 0 || rate > 1 || afterDays < 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "rate must be within 0..1 and afterDays >= 0")
+		return
+	}
+	n := 0
+	if ss, ok := a.store.(*store.SQLiteStore); ok {
+		var err error
+		if n, err = ss.DecayKnowledge(req.ProjectID, rate, afterDays); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"updated": n, "rate": rate, "afterDays": afterDays})
+}
+
 func (a *API) handleKnowledgeVet(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -1354,7 +3383,7 @@ func (a *API) handleKnowledgePromote(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -1386,7 +3415,7 @@ func (a *API) handleKnowledgeReverify(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -1415,7 +3444,7 @@ func (a *API) handleKnowledgeGC(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -1448,7 +3477,7 @@ func (a *API) handleKnowledgePromoteAuto(w http.ResponseWriter, r *http.Request)
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -1457,10 +3486,12 @@ func (a *API) handleKnowledgePromoteAuto(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var req struct {
-		ProjectID string
-		Files     []string
-		Title     string
-		Pin       bool
+		ProjectID    string
+		Files        []string
+		Title        string
+		Pin          bool
+		BudgetBytes  int `json:"budgetBytes"`
+		PerFileBytes int `json:"perFileBytes"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
@@ -1474,6 +3505,10 @@ func (a *API) handleKnowledgePromoteAuto(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "invalid_request", "files required")
 		return
 	}
+	if req.BudgetBytes < 0 || req.PerFileBytes < 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "budgetBytes and perFileBytes must be non-negative")
+		return
+	}
 	// read snippets from files (cap budget)
 	p, ok := a.store.GetProject(req.ProjectID)
 	if !ok {
@@ -1481,43 +3516,53 @@ func (a *API) handleKnowledgePromoteAuto(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var b strings.Builder
-	budget := 4000
-	if v := os.Getenv("MYCODER_KP_BUDGET_BYTES"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			budget = n
-		}
+	// request fields override the env defaults (MYCODER_KP_BUDGET_BYTES, MYCODER_KP_FILE_BYTES)
+	budget := req.BudgetBytes
+	if budget == 0 {
+		budget = config.GetInt("MYCODER_KP_BUDGET_BYTES", defaultKPBudgetBytes)
+	}
+	perFile := req.PerFileBytes
+	if perFile == 0 {
+		perFile = config.GetInt("MYCODER_KP_FILE_BYTES", defaultKPFileBytes)
+	}
+	if budget <= 0 {
+		budget = defaultKPBudgetBytes
+	}
+	if perFile <= 0 {
+		perFile = defaultKPFileBytes
 	}
+	// filesRead/filesMissing let callers see which inputs actually fed the summary
+	filesRead, filesMissing := []string{}, []string{}
+	fileBytes := map[string]int{}
 	for _, rel := range req.Files {
 		_, full, ok := a.resolveProjectPath(req.ProjectID, rel)
 		if !ok {
+			filesMissing = append(filesMissing, rel)
 			continue
 		}
 		data, err := os.ReadFile(full)
 		if err != nil {
+			filesMissing = append(filesMissing, rel)
 			continue
 		}
 		header := fmt.Sprintf("\n=== %s ===\n", rel)
 		if budget-len(header) <= 0 {
 			break
 		}
-		b.WriteString(header)
-		budget -= len(header)
-		// include up to max bytes per file
-		max := 800
-		if v := os.Getenv("MYCODER_KP_FILE_BYTES"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 0 {
-				max = n
-			}
-		}
-		if max > len(data) {
-			max = len(data)
-		}
-		chunk := string(data[:max])
-		if budget-len(chunk) <= 0 {
+		// the last file may get a smaller share of what is left of the budget
+		chunk := fileExcerpt(data, min(perFile, budget-len(header)))
+		if chunk == "" {
 			break
 		}
+		b.WriteString(header)
 		b.WriteString(chunk)
-		budget -= len(chunk)
+		budget -= len(header) + len(chunk)
+		filesRead = append(filesRead, rel)
+		fileBytes[rel] = len(chunk)
+	}
+	if len(filesMissing) == len(req.Files) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "no readable files: "+strings.Join(filesMissing, ", "))
+		return
 	}
 	content := b.String()
 	summary := ""
@@ -1525,7 +3570,7 @@ func (a *API) handleKnowledgePromoteAuto(w http.ResponseWriter, r *http.Request)
 	if a.llm != nil && content != "" {
 		sys := llm.Message{Role: llm.RoleSystem, Content: "You are a senior engineer. Summarize the following code changes into a concise 'CodeCard' (purpose, approach, key decisions, trade-offs). Keep it under 800 chars."}
 		usr := llm.Message{Role: llm.RoleUser, Content: content}
-		st, err := a.llm.Chat(r.Context(), os.Getenv("MYCODER_CHAT_MODEL"), []llm.Message{sys, usr}, false, 0)
+		st, err := a.llm.Chat(r.Context(), config.Get("MYCODER_CHAT_MODEL"), []llm.Message{sys, usr}, false, 0)
 		if err == nil {
 			defer st.Close()
 			var buf strings.Builder
@@ -1555,7 +3600,53 @@ func (a *API) handleKnowledgePromoteAuto(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, k)
+	writeJSON(w, http.StatusOK, struct {
+		*models.Knowledge
+		FilesRead    []string       `json:"filesRead"`
+		FilesMissing []string       `json:"filesMissing"`
+		FileBytes    map[string]int `json:"fileBytes"`
+	}{k, filesRead, filesMissing, fileBytes})
+}
+
+// promote-auto input limits when neither the request nor env sets them
+const (
+	defaultKPBudgetBytes = 4000
+	defaultKPFileBytes   = 800
+)
+
+// kpElision separates the head and middle excerpts of a truncated file.
+const kpElision = "\n...\n"
+
+// fileExcerpt returns at most max bytes of data for summarizing. Files that
+// fit are returned whole; larger ones keep the head (package doc, imports,
+// top-level declarations) plus a window from the middle, both cut at line
+// boundaries where possible, rather than only the first max bytes.
+func fileExcerpt(data []byte, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if len(data) <= max {
+		return string(data)
+	}
+	if max < 4*len(kpElision) {
+		return string(data[:max])
+	}
+	room := max - len(kpElision)
+	headN := room * 3 / 5
+	head := data[:headN]
+	if i := bytes.LastIndexByte(head, '\n'); i > headN/2 {
+		head = head[:i+1]
+	}
+	midN := room - len(head)
+	start := (len(data) - midN) / 2
+	if start < len(head) {
+		start = len(head)
+	}
+	mid := data[start : start+midN]
+	if i := bytes.IndexByte(mid, '\n'); i >= 0 && i < len(mid)/2 {
+		mid = mid[i+1:]
+	}
+	return string(head) + kpElision + string(mid)
 }
 
 // POST /tools/hooks: run project hooks (fmt-check, test, lint) in project root.
@@ -1567,16 +3658,20 @@ func (a *API) handleToolsHooks(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
 	var req struct {
-		ProjectID  string            `json:"projectID"`
-		Targets    []string          `json:"targets"`
-		TimeoutSec int               `json:"timeoutSec"`
-		Env        map[string]string `json:"env"`
-		Artifact   string            `json:"artifactPath"`
+		ProjectID   string            `json:"projectID"`
+		Targets     []string          `json:"targets"`
+		TimeoutSec  int               `json:"timeoutSec"`
+		Env         map[string]string `json:"env"`
+		Artifact    string            `json:"artifactPath"`
+		Runner      string            `json:"runner"`  // make|npm|just|raw (auto-detected when empty)
+		Command     string            `json:"command"` // raw runner template, {target} is substituted
+		Parallel    bool              `json:"parallel"`
+		MaxParallel int               `json:"maxParallel"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProjectID == "" {
 		http.Error(w, "invalid json", http.StatusBadRequest)
@@ -1587,6 +3682,16 @@ func (a *API) handleToolsHooks(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "project not found", http.StatusBadRequest)
 		return
 	}
+	runner := strings.ToLower(strings.TrimSpace(req.Runner))
+	if runner == "" {
+		runner = detectHooksRunner(p.RootPath)
+	}
+	switch runner {
+	case "make", "npm", "just", "raw":
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", "runner must be one of make|npm|just|raw")
+		return
+	}
 	targets := req.Targets
 	if len(targets) == 0 {
 		targets = []string{"fmt-check", "test", "lint"}
@@ -1596,53 +3701,51 @@ func (a *API) handleToolsHooks(w http.ResponseWriter, r *http.Request) {
 	if req.TimeoutSec > 0 {
 		timeout = time.Duration(req.TimeoutSec) * time.Second
 	}
-	for _, t := range targets {
-		// use system make; run each target separately
-		ctx, cancel := context.WithTimeout(r.Context(), timeout)
-		cmd := exec.CommandContext(ctx, "/bin/zsh", "-lc", "make "+shellQuote(t))
-		cmd.Dir = p.RootPath
-		// apply env whitelist
-		allowed := map[string]bool{"GOFLAGS": true}
-		env := os.Environ()
-		for k, v := range req.Env {
-			if allowed[k] {
-				env = append(env, fmt.Sprintf("%s=%s", k, v))
-			}
-		}
-		cmd.Env = env
-		start := time.Now()
-		b, err := cmd.CombinedOutput()
-		dur := time.Since(start)
-		// capture context error before cancel
-		ctxErr := ctx.Err()
-		cancel()
-		ok := err == nil
-		rstr := string(b)
-		sug := hintFromOutput(t, rstr)
-		reason := detectHookReason(t, rstr, ok)
-		if !ok {
-			// augment with timeout/killed detection
-			if ctxErr == context.DeadlineExceeded || (err != nil && strings.Contains(strings.ToLower(err.Error()), "killed")) {
-				if sug == "" {
-					sug = "타임아웃이 발생했습니다. --timeout 값을 늘려보세요."
-				}
-				if reason == "" {
-					reason = "timeout"
-				}
+	sh, err := shellPath()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if runner == "raw" {
+		for _, t := range targets {
+			if ok, reason := shellAllowed(hooksCommand(runner, req.Command, t)); !ok {
+				writeError(w, http.StatusForbidden, "forbidden", reason)
+				return
 			}
 		}
-		out[t] = HooksResult{
-			Ok:         ok,
-			Output:     rstr,
-			Suggestion: sug,
-			Reason:     reason,
-			DurationMs: int(dur.Milliseconds()),
-			Lines:      countLines(rstr),
-			Bytes:      len(b),
+	}
+	env := shellEnv(req.Env)
+	hints := loadHookHints(p.RootPath)
+	run := func(t string) HooksResult {
+		return runHookTarget(r.Context(), sh, hooksCommand(runner, req.Command, t), p.RootPath, env, hints, t, timeout)
+	}
+	if req.Parallel {
+		// run all targets concurrently (bounded), collecting every result
+		limit := hooksMaxParallel(req.MaxParallel)
+		sem := make(chan struct{}, limit)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, t := range targets {
+			wg.Add(1)
+			go func(t string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				res := run(t)
+				mu.Lock()
+				out[t] = res
+				mu.Unlock()
+			}(t)
 		}
-		if !ok {
-			// stop on first failure to follow gate behavior
-			break
+		wg.Wait()
+	} else {
+		for _, t := range targets {
+			res := run(t)
+			out[t] = res
+			if !res.Ok {
+				// stop on first failure to follow gate behavior
+				break
+			}
 		}
 	}
 	// add synthetic summary across executed targets
@@ -1689,11 +3792,120 @@ func (a *API) handleToolsHooks(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, out)
 }
 
+// runHookTarget executes one hook command line in root and summarizes the result
+// with hints, failure reason and timeout detection.
+func runHookTarget(parent context.Context, sh, cmdline, root string, env []string, hints []hookHint, target string, timeout time.Duration) HooksResult {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	cmd := exec.CommandContext(ctx, sh, "-lc", cmdline)
+	cmd.Dir = root
+	cmd.Env = env
+	start := time.Now()
+	b, err := cmd.CombinedOutput()
+	dur := time.Since(start)
+	// capture context error before cancel
+	ctxErr := ctx.Err()
+	cancel()
+	ok := err == nil
+	rstr := string(b)
+	sug := hintFromOutput(target, rstr)
+	reason := detectHookReason(target, rstr, ok)
+	// project-defined hints take precedence over the built-in heuristics
+	if h, matched := matchHookHint(hints, rstr); matched && !ok {
+		if h.Suggestion != "" {
+			sug = h.Suggestion
+		}
+		if h.Reason != "" {
+			reason = h.Reason
+		}
+	}
+	if !ok {
+		// augment with timeout/killed detection
+		if ctxErr == context.DeadlineExceeded || (err != nil && strings.Contains(strings.ToLower(err.Error()), "killed")) {
+			if sug == "" {
+				sug = hintMsg("timeout")
+			}
+			if reason == "" {
+				reason = "timeout"
+			}
+		}
+	}
+	return HooksResult{
+		Ok:         ok,
+		Output:     rstr,
+		Suggestion: sug,
+		Reason:     reason,
+		DurationMs: int(dur.Milliseconds()),
+		Lines:      countLines(rstr),
+		Bytes:      len(b),
+	}
+}
+
+// defaultHooksParallel is the parallel hooks limit when nothing is configured.
+const defaultHooksParallel = 4
+
+// hooksMaxParallel resolves the parallel hooks limit: request value, then
+// MYCODER_HOOKS_MAX_PARALLEL, then 4. The request value is clamped to
+// MYCODER_HOOKS_MAX_PARALLEL, or to max(NumCPU, 4) when that is unset.
+func hooksMaxParallel(reqMax int) int {
+	def, ceiling := defaultHooksParallel, runtime.NumCPU()
+	if ceiling < defaultHooksParallel {
+		ceiling = defaultHooksParallel
+	}
+	if v := config.Get("MYCODER_HOOKS_MAX_PARALLEL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			def, ceiling = n, n
+		}
+	}
+	if reqMax <= 0 {
+		return def
+	}
+	if reqMax > ceiling {
+		return ceiling
+	}
+	return reqMax
+}
+
+// detectHooksRunner picks a hooks runner from project files, defaulting to make.
+func detectHooksRunner(root string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+	switch {
+	case exists("Makefile") || exists("makefile") || exists("GNUmakefile"):
+		return "make"
+	case exists("justfile") || exists("Justfile") || exists(".justfile"):
+		return "just"
+	case exists("package.json"):
+		return "npm"
+	}
+	return "make"
+}
+
+// hooksCommand builds the shell command line for a hook target. The raw runner
+// substitutes {target} into the template, or runs the target itself when no
+// template is given.
+func hooksCommand(runner, template, target string) string {
+	switch runner {
+	case "npm":
+		return "npm run --silent " + shellQuote(target)
+	case "just":
+		return "just " + shellQuote(target)
+	case "raw":
+		if strings.TrimSpace(template) == "" {
+			return target
+		}
+		return strings.ReplaceAll(template, "{target}", shellQuote(target))
+	}
+	return "make " + shellQuote(target)
+}
+
 // Minimal MCP-like tools registry (safe, demo-level)
 type mcpParam struct {
-	Name     string `json:"name"`
-	Type     string `json:"type"` // string|number|boolean
-	Required bool   `json:"required"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"` // string|number|boolean
+	Required bool     `json:"required"`
+	Enum     []string `json:"enum,omitempty"`
 }
 
 type mcpTool struct {
@@ -1704,7 +3916,7 @@ type mcpTool struct {
 }
 
 func allowedToolsFromEnv() map[string]bool {
-	v := strings.TrimSpace(os.Getenv("MYCODER_MCP_ALLOWED_TOOLS"))
+	v := strings.TrimSpace(config.Get("MYCODER_MCP_ALLOWED_TOOLS"))
 	if v == "" {
 		return nil
 	}
@@ -1727,7 +3939,7 @@ func mcpAuthorized(r *http.Request, tool string) (bool, string) {
 	if allow != nil && !allow[tool] {
 		return false, "tool not allowed"
 	}
-	if base := strings.TrimSpace(os.Getenv("MYCODER_MCP_REQUIRED_SCOPE")); base != "" {
+	if base := strings.TrimSpace(config.Get("MYCODER_MCP_REQUIRED_SCOPE")); base != "" {
 		want := base + ":" + tool
 		got := strings.TrimSpace(r.Header.Get("X-MYCODER-Scope"))
 		if got != want {
@@ -1742,9 +3954,25 @@ func (a *API) handleMCPTools(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
+	switch r.URL.Query().Get("format") {
+	case "":
+		writeJSON(w, http.StatusOK, map[string]any{"tools": mcpToolList()})
+	case "jsonschema":
+		writeJSON(w, http.StatusOK, map[string]any{"tools": mcpToolSchemas(mcpToolList())})
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", "format must be jsonschema")
+	}
+}
+
+// mcpToolList returns the tool registry filtered by MYCODER_MCP_ALLOWED_TOOLS.
+func mcpToolList() []mcpTool {
 	full := []mcpTool{
 		{Name: "echo", Description: "Echo back the provided text", Params: []string{"text"}, ParamsSchema: []mcpParam{{Name: "text", Type: "string", Required: true}}},
 		{Name: "time", Description: "Return server time RFC3339", Params: []string{}, ParamsSchema: []mcpParam{}},
+		{Name: "fs_read", Description: "Read a file under the project root", Params: []string{"projectID", "path"}, ParamsSchema: []mcpParam{{Name: "projectID", Type: "string", Required: true}, {Name: "path", Type: "string", Required: true}}},
+		{Name: "fs_list", Description: "List directory entries under the project root", Params: []string{"projectID", "path"}, ParamsSchema: []mcpParam{{Name: "projectID", Type: "string", Required: true}, {Name: "path", Type: "string", Required: false}}},
+		{Name: "search", Description: "Search indexed documents", Params: []string{"q", "projectID"}, ParamsSchema: []mcpParam{{Name: "q", Type: "string", Required: true}, {Name: "projectID", Type: "string", Required: false}}},
+		{Name: "run_hooks", Description: "Run project hooks (comma-separated targets)", Params: []string{"projectID", "targets", "timeoutSec", "runner"}, ParamsSchema: []mcpParam{{Name: "projectID", Type: "string", Required: true}, {Name: "targets", Type: "string", Required: false}, {Name: "timeoutSec", Type: "number", Required: false}, {Name: "runner", Type: "string", Required: false, Enum: []string{"make", "npm", "just"}}}},
 	}
 	// filter by allowlist if provided
 	allow := allowedToolsFromEnv()
@@ -1754,7 +3982,7 @@ func (a *API) handleMCPTools(w http.ResponseWriter, r *http.Request) {
 			tools = append(tools, t)
 		}
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"tools": tools})
+	return tools
 }
 
 func (a *API) handleMCPCall(w http.ResponseWriter, r *http.Request) {
@@ -1770,29 +3998,571 @@ func (a *API) handleMCPCall(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body or missing name")
 		return
 	}
-	if ok, reason := mcpAuthorized(r, req.Name); !ok {
+	if req.Params == nil {
+		req.Params = map[string]any{}
+	}
+	// audit every named call: keys and redacted values are captured before
+	// validation coerces them
+	params := redactMCPParams(req.Params)
+	start := time.Now()
+	cw := &captureWriter{header: http.Header{}}
+	a.runMCPTool(cw, r, req.Name, req.Params)
+	a.auditMCPCall(req.Name, params, cw, time.Since(start))
+	for k, v := range cw.header {
+		w.Header()[k] = v
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	w.WriteHeader(cw.status)
+	_, _ = w.Write(cw.buf.Bytes())
+}
+
+// runMCPTool applies tool policy and schema validation, then dispatches the call.
+func (a *API) runMCPTool(w http.ResponseWriter, r *http.Request, name string, params map[string]any) {
+	if ok, reason := mcpAuthorized(r, name); !ok {
 		writeError(w, http.StatusForbidden, "forbidden", reason)
 		return
 	}
-	switch req.Name {
+	for _, t := range mcpToolList() {
+		if t.Name != name {
+			continue
+		}
+		if errs := validateMCPParams(t.ParamsSchema, params); len(errs) > 0 {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": errs[0].Message, "validation": errs})
+			return
+		}
+		break
+	}
+	switch name {
 	case "echo":
 		// validate params
-		v, ok := req.Params["text"]
+		v, ok := params["text"]
 		if !ok {
 			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "missing param: text"})
 			return
 		}
-		s, ok := v.(string)
-		if !ok {
-			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "param text must be string"})
-			return
+		s, ok := v.(string)
+		if !ok {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "param text must be string"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": s})
+	case "time":
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": time.Now().Format(time.RFC3339)})
+	case "fs_read":
+		pid, err1 := mcpStringParam(params, "projectID", true)
+		path, err2 := mcpStringParam(params, "path", true)
+		if err := firstErr(err1, err2); err != nil {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": err.Error()})
+			return
+		}
+		a.mcpDispatch(w, r, a.handleFSRead, http.MethodPost, "/fs/read", map[string]any{"projectID": pid, "path": path})
+	case "fs_list":
+		pid, err1 := mcpStringParam(params, "projectID", true)
+		path, err2 := mcpStringParam(params, "path", false)
+		if err := firstErr(err1, err2); err != nil {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": err.Error()})
+			return
+		}
+		a.mcpFSList(w, r, pid, path)
+	case "search":
+		q, err1 := mcpStringParam(params, "q", true)
+		pid, err2 := mcpStringParam(params, "projectID", false)
+		if err := firstErr(err1, err2); err != nil {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": err.Error()})
+			return
+		}
+		target := "/search?" + url.Values{"q": {q}, "projectID": {pid}}.Encode()
+		a.mcpDispatch(w, r, a.handleSearch, http.MethodGet, target, nil)
+	case "run_hooks":
+		pid, err1 := mcpStringParam(params, "projectID", true)
+		targets, err2 := mcpStringParam(params, "targets", false)
+		if err := firstErr(err1, err2); err != nil {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": err.Error()})
+			return
+		}
+		body := map[string]any{"projectID": pid}
+		if list := splitCSV(targets); len(list) > 0 {
+			body["targets"] = list
+		}
+		if v, ok := params["timeoutSec"].(float64); ok && v > 0 {
+			body["timeoutSec"] = int(v)
+		}
+		if v, ok := params["runner"].(string); ok && v != "" {
+			// the raw runner executes targets as shell commands; not exposed over MCP
+			if strings.EqualFold(strings.TrimSpace(v), "raw") {
+				writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "runner raw is not allowed via MCP"})
+				return
+			}
+			body["runner"] = v
+		}
+		a.mcpDispatch(w, r, a.handleToolsHooks, http.MethodPost, "/tools/hooks", body)
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "unknown tool"})
+	}
+}
+
+// mcpCallLog is implemented by stores that keep the MCP call audit history.
+type mcpCallLog interface {
+	RecordMCPCall(c *models.MCPCall, keep int) error
+	ListMCPCalls(tool string, limit int) ([]*models.MCPCall, error)
+}
+
+const (
+	defaultMCPCallsKeep = 200
+	mcpParamMaxChars    = 64
+)
+
+var secretParamName = regexp.MustCompile(`(?i)token|password|secret|api_?key|authorization|credential`)
+
+// redactMCPParams renders params for the audit log: secret-looking names are
+// masked and long values cut to mcpParamMaxChars with their original length.
+func redactMCPParams(params map[string]any) map[string]string {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		if secretParamName.MatchString(k) {
+			out[k] = "***"
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		if r := []rune(s); len(r) > mcpParamMaxChars {
+			s = fmt.Sprintf("%s…(%d bytes)", string(r[:mcpParamMaxChars]), len(s))
+		}
+		out[k] = s
+	}
+	return out
+}
+
+// auditMCPCall logs one /mcp/call and appends it to the store's capped history
+// (MYCODER_MCP_CALLS_MAX, default 200) when the store supports it.
+func (a *API) auditMCPCall(tool string, params map[string]string, cw *captureWriter, d time.Duration) {
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c := &models.MCPCall{Tool: tool, Params: params, Status: status, DurationMs: d.Milliseconds(), CalledAt: time.Now().UTC().Format(time.RFC3339)}
+	var body struct {
+		OK      *bool  `json:"ok"`
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(cw.buf.Bytes(), &body)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		c.Outcome, c.Error = "denied", body.Message
+	case status >= 400:
+		c.Outcome, c.Error = "error", body.Message
+	case body.OK == nil || !*body.OK:
+		c.Outcome, c.Error = "error", body.Error
+	default:
+		c.Outcome = "ok"
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	mylog.New().Info("mcp.call", "tool", tool, "params", strings.Join(keys, ","), "outcome", c.Outcome, "status", status, "duration_ms", c.DurationMs)
+	if l, ok := a.store.(mcpCallLog); ok {
+		keep := config.GetInt("MYCODER_MCP_CALLS_MAX", defaultMCPCallsKeep)
+		if keep <= 0 {
+			keep = defaultMCPCallsKeep
+		}
+		if err := l.RecordMCPCall(c, keep); err != nil {
+			mylog.New().Warn("mcp.call.record_failed", "tool", tool, "error", err.Error())
+		}
+	}
+}
+
+// handleMCPCalls lists recent MCP calls: GET /mcp/calls?tool=&limit=50
+func (a *API) handleMCPCalls(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid_request", "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+	l, ok := a.store.(mcpCallLog)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"calls": []*models.MCPCall{}})
+		return
+	}
+	calls, err := l.ListMCPCalls(r.URL.Query().Get("tool"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"calls": calls})
+}
+
+// MCP JSON-RPC 2.0 over stdio (newline-delimited messages).
+const mcpProtocolVersion = "2024-11-05"
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// RunMCPStdio serves the MCP tool registry over stdin/stdout using the store
+// configured by env. token, when set, is sent as a Bearer token on tool calls.
+func RunMCPStdio(token string) error {
+	api := NewAPI(storeFromEnv(), nil)
+	return api.ServeMCP(context.Background(), os.Stdin, os.Stdout, token)
+}
+
+// ServeMCP reads JSON-RPC requests line by line from in and writes responses to
+// out until in is exhausted. Tool calls go through handleMCPCall so allowlist,
+// scope, auth, read-only and fs/shell policies apply as they do over HTTP.
+func (a *API) ServeMCP(ctx context.Context, in io.Reader, out io.Writer, token string) error {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(out)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			_ = enc.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: -32700, Message: "parse error"}})
+			continue
+		}
+		// notifications (no id) never get a response
+		if len(req.ID) == 0 {
+			continue
+		}
+		res, rerr := a.mcpRPC(ctx, req, token)
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: res, Error: rerr}
+		if rerr == nil && res == nil {
+			resp.Result = map[string]any{}
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func (a *API) mcpRPC(ctx context.Context, req rpcRequest, token string) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "mycoder", "version": version.Version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpToolSchemas(mcpToolList())}, nil
+	case "tools/call":
+		var p struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil || p.Name == "" {
+			return nil, &rpcError{Code: -32602, Message: "invalid params: name required"}
+		}
+		if p.Arguments == nil {
+			p.Arguments = map[string]any{}
+		}
+		b, _ := json.Marshal(map[string]any{"name": p.Name, "params": p.Arguments})
+		hr, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/mcp/call", bytes.NewReader(b))
+		hr.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			hr.Header.Set("Authorization", "Bearer "+token)
+		}
+		cw := &captureWriter{header: http.Header{}}
+		a.handleMCPCall(cw, hr)
+		var body map[string]any
+		_ = json.Unmarshal(cw.buf.Bytes(), &body)
+		ok, _ := body["ok"].(bool)
+		text := ""
+		if ok {
+			rb, _ := json.Marshal(body["result"])
+			text = string(rb)
+		} else if msg, _ := body["error"].(string); msg != "" {
+			text = msg
+			if m, _ := body["message"].(string); m != "" {
+				text = m
+			}
+		} else {
+			text = strings.TrimSpace(cw.buf.String())
+		}
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": text}},
+			"isError": !ok,
+		}, nil
+	}
+	return nil, &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+}
+
+// mcpToolSchemas lists tools in the MCP spec shape: {name, description, inputSchema}.
+func mcpToolSchemas(tools []mcpTool) []map[string]any {
+	list := make([]map[string]any, 0, len(tools))
+	for _, t := range tools {
+		list = append(list, map[string]any{"name": t.Name, "description": t.Description, "inputSchema": mcpInputSchema(t)})
+	}
+	return list
+}
+
+// mcpInputSchema converts paramsSchema into a JSON Schema object for MCP clients.
+func mcpInputSchema(t mcpTool) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, p := range t.ParamsSchema {
+		prop := map[string]any{"type": p.Type}
+		if len(p.Enum) > 0 {
+			prop["enum"] = p.Enum
+		}
+		props[p.Name] = prop
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+// mcpValidationError describes one schema violation in an MCP call.
+type mcpValidationError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// validateMCPParams checks params against the tool schema in place: required
+// presence, type (coercing numeric/boolean strings) and enum membership.
+func validateMCPParams(schema []mcpParam, params map[string]any) []mcpValidationError {
+	var errs []mcpValidationError
+	for _, p := range schema {
+		v, ok := params[p.Name]
+		if !ok || v == nil {
+			if p.Required {
+				errs = append(errs, mcpValidationError{Param: p.Name, Message: "missing param: " + p.Name})
+			}
+			continue
+		}
+		switch p.Type {
+		case "string":
+			if _, ok := v.(string); !ok {
+				errs = append(errs, mcpValidationError{Param: p.Name, Message: fmt.Sprintf("param %s must be string", p.Name)})
+				continue
+			}
+		case "number":
+			if sv, ok := v.(string); ok {
+				f, err := strconv.ParseFloat(strings.TrimSpace(sv), 64)
+				if err != nil {
+					errs = append(errs, mcpValidationError{Param: p.Name, Message: fmt.Sprintf("param %s must be number", p.Name)})
+					continue
+				}
+				params[p.Name] = f
+			} else if _, ok := v.(float64); !ok {
+				errs = append(errs, mcpValidationError{Param: p.Name, Message: fmt.Sprintf("param %s must be number", p.Name)})
+				continue
+			}
+		case "boolean":
+			if sv, ok := v.(string); ok {
+				b, err := strconv.ParseBool(strings.TrimSpace(sv))
+				if err != nil {
+					errs = append(errs, mcpValidationError{Param: p.Name, Message: fmt.Sprintf("param %s must be boolean", p.Name)})
+					continue
+				}
+				params[p.Name] = b
+			} else if _, ok := v.(bool); !ok {
+				errs = append(errs, mcpValidationError{Param: p.Name, Message: fmt.Sprintf("param %s must be boolean", p.Name)})
+				continue
+			}
+		}
+		if len(p.Enum) > 0 {
+			got := fmt.Sprint(params[p.Name])
+			found := false
+			for _, e := range p.Enum {
+				if e == got {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, mcpValidationError{Param: p.Name, Message: fmt.Sprintf("param %s must be one of %s", p.Name, strings.Join(p.Enum, "|"))})
+			}
+		}
+	}
+	return errs
+}
+
+// mcpStringParam extracts a string param, reporting missing required or mistyped values.
+func mcpStringParam(params map[string]any, name string, required bool) (string, error) {
+	v, ok := params[name]
+	if !ok || v == nil {
+		if required {
+			return "", fmt.Errorf("missing param: %s", name)
+		}
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("param %s must be string", name)
+	}
+	if required && strings.TrimSpace(s) == "" {
+		return "", fmt.Errorf("missing param: %s", name)
+	}
+	return s, nil
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitCSV(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// captureWriter buffers a handler response so MCP tools can reuse REST handlers.
+type captureWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (c *captureWriter) Header() http.Header { return c.header }
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+	}
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.buf.Write(p)
+}
+
+// mcpDispatch runs a REST handler with the caller's headers and context (so
+// authorize, read-only and policy checks apply unchanged) and wraps the JSON
+// response as an MCP result: {ok:true,result} on 2xx, else {ok:false,error}.
+func (a *API) mcpDispatch(w http.ResponseWriter, r *http.Request, h http.HandlerFunc, method, target string, body any) {
+	var rd io.Reader = http.NoBody
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	}
+	sub, err := http.NewRequestWithContext(r.Context(), method, target, rd)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	sub.Header = r.Header.Clone()
+	sub.Header.Set("Content-Type", "application/json")
+	if tok := r.URL.Query().Get("token"); tok != "" {
+		q := sub.URL.Query()
+		q.Set("token", tok)
+		sub.URL.RawQuery = q.Encode()
+	}
+	cw := &captureWriter{header: http.Header{}}
+	h(cw, sub)
+	if cw.status == http.StatusUnauthorized {
+		// surface auth failures as-is rather than as a tool error
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(cw.status)
+		_, _ = w.Write(cw.buf.Bytes())
+		return
+	}
+	var result any
+	_ = json.Unmarshal(cw.buf.Bytes(), &result)
+	if cw.status >= 200 && cw.status < 300 {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": result})
+		return
+	}
+	msg := strings.TrimSpace(cw.buf.String())
+	if m, ok := result.(map[string]any); ok {
+		if s, _ := m["message"].(string); s != "" {
+			msg = s
+		} else if s, _ := m["error"].(string); s != "" {
+			msg = s
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": msg, "status": cw.status})
+}
+
+// mcpFSList lists directory entries under the project root, skipping entries
+// rejected by the fs policy.
+func (a *API) mcpFSList(w http.ResponseWriter, r *http.Request, projectID, rel string) {
+	if !authorize(w, r) {
+		return
+	}
+	if strings.TrimSpace(rel) == "" {
+		rel = "."
+	}
+	_, full, ok := a.resolveProjectPath(projectID, rel)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "path outside project"})
+		return
+	}
+	ents, err := os.ReadDir(full)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	type entry struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+		Dir  bool   `json:"dir"`
+		Size int64  `json:"size"`
+	}
+	out := make([]entry, 0, len(ents))
+	for _, e := range ents {
+		p := filepath.ToSlash(filepath.Join(rel, e.Name()))
+		if ok, _ := fsAllowed(p); !ok {
+			continue
+		}
+		var size int64
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			size = info.Size()
 		}
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": s})
-	case "time":
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": time.Now().Format(time.RFC3339)})
-	default:
-		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "error": "unknown tool"})
+		out = append(out, entry{Name: e.Name(), Path: p, Dir: e.IsDir(), Size: size})
 	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": map[string]any{"path": rel, "entries": out}})
 }
 
 // saveHooksArtifact writes structured hooks results JSON to a project-relative path, ensuring confinement.
@@ -1821,52 +4591,156 @@ func saveHooksArtifact(root, projectID string, targets []string, results map[str
 	_ = json.NewEncoder(f).Encode(payload)
 }
 
+// hookHintMessages is the built-in suggestion catalog keyed by language, then message ID.
+var hookHintMessages = map[string]map[string]string{
+	"ko": {
+		"fmt":          "포맷팅을 적용하세요: make fmt",
+		"test-panic":   "패닉 발생 원인을 확인하세요. 스택트레이스를 따라 수정 후 go test ./... -v",
+		"test-race":    "데이터 레이스가 감지되었습니다: go test -race ./... 로 재현하고 동기화를 수정하세요",
+		"test-empty":   "테스트 대상이 비어있습니다. 패키지 경로나 빌드 태그를 확인하세요.",
+		"test-mod":     "모듈 의존성 누락: go mod tidy 또는 go get 으로 의존성을 정리하세요",
+		"test-fail":    "실패한 테스트를 확인하세요: go test ./... -v (필요 시 -run 으로 타겟팅)",
+		"lint-compile": "컴파일 오류(식별자/패키지)를 먼저 해결하세요: go build ./... 후 go vet ./...",
+		"lint-unused":  "미사용 코드 정리 필요: 사용하지 않는 변수/임포트를 제거하세요 (go vet ./..., go build ./...)",
+		"lint-static":  "정적 분석 경고를 수정하세요: 불필요 코드/할당 제거 후 다시 go vet 또는 golangci-lint 실행",
+		"lint":         "린트/정적 분석 경고를 수정하세요: go vet ./...",
+		"permission":   "권한 문제로 실패했습니다. 캐시/권한을 확인하거나 별도 환경에서 실행하세요.",
+		"timeout":      "타임아웃이 발생했습니다. --timeout 값을 늘려보세요.",
+		"oom":          "메모리 부족으로 실패했습니다. 테스트 병렬도/데이터 크기를 줄이거나 메모리를 확보하세요.",
+	},
+	"en": {
+		"fmt":          "Apply formatting: make fmt",
+		"test-panic":   "A panic occurred. Follow the stack trace, fix it, then rerun go test ./... -v",
+		"test-race":    "Data race detected: reproduce with go test -race ./... and fix the synchronization",
+		"test-empty":   "No test targets found. Check the package path or build tags.",
+		"test-mod":     "Missing module dependency: run go mod tidy or go get",
+		"test-fail":    "Check the failing tests: go test ./... -v (use -run to narrow down)",
+		"lint-compile": "Fix compile errors (identifiers/packages) first: go build ./... then go vet ./...",
+		"lint-unused":  "Remove unused code: drop unused variables/imports (go vet ./..., go build ./...)",
+		"lint-static":  "Fix static analysis warnings: remove dead code/assignments, then rerun go vet or golangci-lint",
+		"lint":         "Fix lint/static analysis warnings: go vet ./...",
+		"permission":   "Failed due to permissions. Check cache/file permissions or run in a separate environment.",
+		"timeout":      "The command timed out. Try increasing --timeout.",
+		"oom":          "Ran out of memory. Reduce test parallelism/data size or free up memory.",
+	},
+}
+
+// hintLang returns the hint language selected by MYCODER_HINT_LANG. When unset it
+// is inferred from LANG: Korean locales (and C/POSIX/unset) use ko, others en.
+func hintLang() string {
+	if v := strings.ToLower(strings.TrimSpace(config.Get("MYCODER_HINT_LANG"))); v != "" {
+		return v
+	}
+	lang := strings.ToLower(strings.TrimSpace(os.Getenv("LANG")))
+	if lang == "" || lang == "c" || lang == "posix" || strings.HasPrefix(lang, "c.") || strings.HasPrefix(lang, "ko") {
+		return "ko"
+	}
+	return "en"
+}
+
+// hintMsg looks up a built-in suggestion in the selected language, falling back to Korean.
+func hintMsg(id string) string {
+	if m, ok := hookHintMessages[hintLang()]; ok {
+		if msg, ok := m[id]; ok {
+			return msg
+		}
+	}
+	return hookHintMessages["ko"][id]
+}
+
+// hookHint is a project-defined mapping from an output pattern to a suggestion/reason.
+type hookHint struct {
+	re         *regexp.Regexp
+	Suggestion string `json:"suggestion"`
+	Reason     string `json:"reason"`
+}
+
+// loadHookHints reads <root>/.mycoder/hooks-hints.json, a JSON object mapping
+// regex patterns to {suggestion, reason}. Invalid patterns are skipped and
+// patterns are tried in sorted order so matching is deterministic.
+func loadHookHints(root string) []hookHint {
+	b, err := os.ReadFile(filepath.Join(root, ".mycoder", "hooks-hints.json"))
+	if err != nil {
+		return nil
+	}
+	var raw map[string]hookHint
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil
+	}
+	pats := make([]string, 0, len(raw))
+	for pat := range raw {
+		pats = append(pats, pat)
+	}
+	sort.Strings(pats)
+	out := make([]hookHint, 0, len(pats))
+	for _, pat := range pats {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			continue
+		}
+		h := raw[pat]
+		h.re = re
+		out = append(out, h)
+	}
+	return out
+}
+
+// matchHookHint returns the first project hint whose pattern matches output.
+func matchHookHint(hints []hookHint, output string) (hookHint, bool) {
+	for _, h := range hints {
+		if h.re.MatchString(output) {
+			return h, true
+		}
+	}
+	return hookHint{}, false
+}
+
 func hintFromOutput(target, output string) string {
 	lo := strings.ToLower(output)
 	switch target {
 	case "fmt-check":
 		if strings.Contains(lo, "files need formatting") || strings.Contains(lo, "gofmt") || strings.Contains(lo, "formatted") {
-			return "포맷팅을 적용하세요: make fmt"
+			return hintMsg("fmt")
 		}
 	case "test":
 		if strings.Contains(lo, "--- fail") || strings.Contains(lo, "fail\t") || strings.Contains(lo, "error") || strings.Contains(lo, "exit status") {
 			// common go test issues
 			if strings.Contains(lo, "panic:") {
-				return "패닉 발생 원인을 확인하세요. 스택트레이스를 따라 수정 후 go test ./... -v"
+				return hintMsg("test-panic")
 			}
 			if strings.Contains(lo, "data race") {
-				return "데이터 레이스가 감지되었습니다: go test -race ./... 로 재현하고 동기화를 수정하세요"
+				return hintMsg("test-race")
 			}
 			if strings.Contains(lo, "no go files in") || strings.Contains(lo, "build constraints exclude all go files") {
-				return "테스트 대상이 비어있습니다. 패키지 경로나 빌드 태그를 확인하세요."
+				return hintMsg("test-empty")
 			}
 			if strings.Contains(lo, "no required module provides package") || strings.Contains(lo, "cannot find module providing package") {
-				return "모듈 의존성 누락: go mod tidy 또는 go get 으로 의존성을 정리하세요"
+				return hintMsg("test-mod")
 			}
-			return "실패한 테스트를 확인하세요: go test ./... -v (필요 시 -run 으로 타겟팅)"
+			return hintMsg("test-fail")
 		}
 	case "lint":
 		if strings.Contains(lo, "vet") || strings.Contains(lo, "warning") || strings.Contains(lo, "error") || strings.Contains(lo, "undeclared name") || strings.Contains(lo, "unused ") || strings.Contains(lo, "golangci-lint") {
 			if strings.Contains(lo, "undeclared name") || strings.Contains(lo, "cannot find package") {
-				return "컴파일 오류(식별자/패키지)를 먼저 해결하세요: go build ./... 후 go vet ./..."
+				return hintMsg("lint-compile")
 			}
 			if strings.Contains(lo, "unused ") {
-				return "미사용 코드 정리 필요: 사용하지 않는 변수/임포트를 제거하세요 (go vet ./..., go build ./...)"
+				return hintMsg("lint-unused")
 			}
 			if strings.Contains(lo, "ineffassign") || strings.Contains(lo, "deadcode") {
-				return "정적 분석 경고를 수정하세요: 불필요 코드/할당 제거 후 다시 go vet 또는 golangci-lint 실행"
+				return hintMsg("lint-static")
 			}
-			return "린트/정적 분석 경고를 수정하세요: go vet ./..."
+			return hintMsg("lint")
 		}
 	}
 	if strings.Contains(lo, "operation not permitted") {
-		return "권한 문제로 실패했습니다. 캐시/권한을 확인하거나 별도 환경에서 실행하세요."
+		return hintMsg("permission")
 	}
 	if strings.Contains(lo, "timeout") || strings.Contains(lo, "signal: killed") {
-		return "타임아웃이 발생했습니다. --timeout 값을 늘려보세요."
+		return hintMsg("timeout")
 	}
 	if strings.Contains(lo, "fatal error: runtime: out of memory") {
-		return "메모리 부족으로 실패했습니다. 테스트 병렬도/데이터 크기를 줄이거나 메모리를 확보하세요."
+		return hintMsg("oom")
 	}
 	return ""
 }
@@ -1936,7 +4810,13 @@ func (a *API) handleFSRead(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	var req struct{ ProjectID, Path string }
+	var req struct {
+		ProjectID string
+		Path      string
+		// optional 1-based inclusive line range; whole file when both are 0
+		StartLine int `json:"startLine"`
+		EndLine   int `json:"endLine"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
 		return
@@ -1945,20 +4825,55 @@ func (a *API) handleFSRead(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid_request", "projectID and path required")
 		return
 	}
+	if req.StartLine < 0 || req.EndLine < 0 || (req.EndLine > 0 && req.EndLine < req.StartLine) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid line range")
+		return
+	}
 	root, full, ok := a.resolveProjectPath(req.ProjectID, req.Path)
 	_ = root
 	if !ok {
 		writeError(w, http.StatusForbidden, "forbidden", "path outside project")
 		return
 	}
+	if ok, reason := fsAllowed(req.Path); !ok {
+		writeError(w, http.StatusForbidden, "forbidden", reason)
+		return
+	}
 	b, err := os.ReadFile(full)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "not_found", err.Error())
 		return
 	}
+	if req.StartLine > 0 || req.EndLine > 0 {
+		content, start, end, total := sliceLines(string(b), req.StartLine, req.EndLine)
+		writeJSON(w, http.StatusOK, map[string]any{"path": req.Path, "content": content, "startLine": start, "endLine": end, "totalLines": total})
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"path": req.Path, "content": string(b)})
 }
 
+// sliceLines returns lines start..end (1-based, inclusive, clamped to the file)
+// with their line endings, plus the effective bounds and total line count.
+// start 0 means the first line and end 0 the last; a start past the end of the
+// file yields empty content.
+func sliceLines(s string, start, end int) (string, int, int, int) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+	if start <= 0 {
+		start = 1
+	}
+	if end <= 0 || end > total {
+		end = total
+	}
+	if start > end {
+		return "", start, end, total
+	}
+	return strings.Join(lines[start-1:end], ""), start, end, total
+}
+
 func (a *API) handleFSWrite(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
@@ -1967,7 +4882,7 @@ func (a *API) handleFSWrite(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -2008,7 +4923,7 @@ func (a *API) handleFSDelete(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -2037,6 +4952,148 @@ func (a *API) handleFSDelete(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
+// handleFSMove renames a file within a project. An existing target is copied to
+// .mycoder/backups/<id>/ first; the old path's document is dropped from the index
+// and the new path is picked up by the next index run.
+func (a *API) handleFSMove(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	var req struct {
+		ProjectID string `json:"projectID"`
+		From      string `json:"from"`
+		To        string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+		return
+	}
+	if req.ProjectID == "" || req.From == "" || req.To == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID, from and to required")
+		return
+	}
+	root, fromFull, ok := a.resolveProjectPath(req.ProjectID, req.From)
+	if !ok {
+		writeError(w, http.StatusForbidden, "forbidden", "path outside project")
+		return
+	}
+	_, toFull, ok := a.resolveProjectPath(req.ProjectID, req.To)
+	if !ok {
+		writeError(w, http.StatusForbidden, "forbidden", "path outside project")
+		return
+	}
+	for _, p := range []string{req.From, req.To} {
+		if ok, reason := fsAllowed(p); !ok {
+			writeError(w, http.StatusForbidden, "forbidden", reason)
+			return
+		}
+	}
+	if fromFull == toFull {
+		writeError(w, http.StatusBadRequest, "invalid_request", "from and to are the same path")
+		return
+	}
+	fi, err := os.Stat(fromFull)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	if fi.IsDir() {
+		writeError(w, http.StatusBadRequest, "invalid_request", "from must be a file")
+		return
+	}
+	backup := ""
+	if old, err := os.ReadFile(toFull); err == nil {
+		rel, _ := filepath.Rel(root, toFull)
+		backup = filepath.ToSlash(filepath.Join(".mycoder", "backups", fmt.Sprintf("mv-%d", time.Now().UnixNano()), rel))
+		bkp := filepath.Join(root, filepath.FromSlash(backup))
+		if err := os.MkdirAll(filepath.Dir(bkp), 0o755); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		if err := os.WriteFile(bkp, old, 0o644); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(toFull), 0o755); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if err := os.Rename(fromFull, toFull); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if dd, ok := a.store.(documentDeleter); ok {
+		rel, _ := filepath.Rel(root, fromFull)
+		if err := dd.DeleteDocument(req.ProjectID, filepath.ToSlash(rel)); err != nil {
+			mylog.New().Warn("fs.move.index_delete_failed", "path", rel, "error", err.Error())
+		}
+	}
+	res := map[string]any{"ok": true, "from": req.From, "to": req.To}
+	if backup != "" {
+		res["backup"] = backup
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleFSMkdir creates a directory (and parents) under the project root and
+// reports whether it already existed.
+func (a *API) handleFSMkdir(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	var req struct {
+		ProjectID string `json:"projectID"`
+		Path      string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
+		return
+	}
+	if req.ProjectID == "" || req.Path == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID and path required")
+		return
+	}
+	_, full, ok := a.resolveProjectPath(req.ProjectID, req.Path)
+	if !ok {
+		writeError(w, http.StatusForbidden, "forbidden", "path outside project")
+		return
+	}
+	if ok, reason := fsAllowed(req.Path); !ok {
+		writeError(w, http.StatusForbidden, "forbidden", reason)
+		return
+	}
+	if fi, err := os.Stat(full); err == nil {
+		if !fi.IsDir() {
+			writeError(w, http.StatusBadRequest, "invalid_request", "path exists and is not a directory")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "path": req.Path, "existed": true})
+		return
+	}
+	if err := os.MkdirAll(full, 0o755); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "path": req.Path, "existed": false})
+}
+
 func (a *API) resolveProjectPath(projectID, rel string) (string, string, bool) {
 	p, ok := a.store.GetProject(projectID)
 	if !ok {
@@ -2053,12 +5110,76 @@ func (a *API) resolveProjectPath(projectID, rel string) (string, string, bool) {
 	if err != nil {
 		return "", "", false
 	}
-	if !strings.HasPrefix(pf+string(os.PathSeparator), pr+string(os.PathSeparator)) && pf != pr {
+	if !pathWithin(pr, pf) {
+		return "", "", false
+	}
+	// re-verify containment after resolving symlinks so that links inside
+	// the project cannot point outside of it
+	rr, err := filepath.EvalSymlinks(pr)
+	if err != nil {
+		return "", "", false
+	}
+	rf, err := evalSymlinksPartial(pf)
+	if err != nil {
+		return "", "", false
+	}
+	if !pathWithin(rr, rf) {
 		return "", "", false
 	}
 	return root, full, true
 }
 
+// pathWithin reports whether p equals root or lies below it.
+func pathWithin(root, p string) bool {
+	return p == root || strings.HasPrefix(p+string(os.PathSeparator), root+string(os.PathSeparator))
+}
+
+// maxSymlinkHops bounds how many dangling links evalSymlinksPartial follows.
+const maxSymlinkHops = 40
+
+// evalSymlinksPartial resolves symlinks for p. When p (or some of its parents)
+// does not exist yet, the deepest existing ancestor is resolved and the
+// remaining components are appended unchanged. A dangling symlink on the way
+// is replaced by its target, so a link to a missing path outside the project
+// still resolves outside of it.
+func evalSymlinksPartial(p string) (string, error) {
+	rest := ""
+	cur := p
+	hops := 0
+	for {
+		r, err := filepath.EvalSymlinks(cur)
+		if err == nil {
+			if rest == "" {
+				return r, nil
+			}
+			return filepath.Join(r, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if fi, lerr := os.Lstat(cur); lerr == nil && fi.Mode()&os.ModeSymlink != 0 {
+			if hops++; hops > maxSymlinkHops {
+				return "", fmt.Errorf("too many links: %s", p)
+			}
+			target, rerr := os.Readlink(cur)
+			if rerr != nil {
+				return "", rerr
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(cur), target)
+			}
+			cur = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(cur), rest)
+		cur = parent
+	}
+}
+
 func (a *API) handleFSPatch(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
@@ -2067,7 +5188,7 @@ func (a *API) handleFSPatch(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -2126,6 +5247,10 @@ func (a *API) handleFSPatch(w http.ResponseWriter, r *http.Request) {
 
 // handleFSPatchUnified parses a unified diff and returns a dry-run summary.
 // For Phase 1, only dryRun is supported (no write side-effects).
+// maxPatchFuzz bounds how far (in lines) a unified patch hunk may drift from
+// its declared position; larger values make the hunk search degenerate.
+const maxPatchFuzz = 3
+
 func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
@@ -2139,6 +5264,8 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 		DiffText  string `json:"diffText"`
 		DryRun    bool   `json:"dryRun"`
 		Yes       bool   `json:"yes"`
+		// Fuzz allows hunks to apply up to N lines away from their declared position.
+		Fuzz int `json:"fuzz"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
@@ -2148,6 +5275,10 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid_request", "projectID and diffText required")
 		return
 	}
+	if req.Fuzz < 0 || req.Fuzz > maxPatchFuzz {
+		writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("fuzz must be between 0 and %d", maxPatchFuzz))
+		return
+	}
 	// parse unified diff
 	files, err := patch.ParseUnified(req.DiffText)
 	if err != nil {
@@ -2155,11 +5286,14 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	type fsum struct {
-		Path         string `json:"path"`
-		Add          int    `json:"add"`
-		Del          int    `json:"del"`
-		WrittenBytes int    `json:"writtenBytes"`
-		Conflict     string `json:"conflict,omitempty"`
+		Path         string             `json:"path"`
+		Add          int                `json:"add"`
+		Del          int                `json:"del"`
+		WrittenBytes int                `json:"writtenBytes"`
+		Conflict     string             `json:"conflict,omitempty"`
+		Hunks        []patch.HunkResult `json:"hunks,omitempty"`
+		Rejects      []patch.Reject     `json:"rejects,omitempty"`
+		RejectFile   string             `json:"rejectFile,omitempty"`
 	}
 	var list []fsum
 	totalAdd, totalDel := 0, 0
@@ -2201,11 +5335,11 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "dryRun": true, "files": list, "totalAdd": totalAdd, "totalDel": totalDel})
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
-	// Apply changes; stop on first conflict
+	// Check every file before writing anything so that all conflicts are reported at once
 	written := 0
 	// determine project root for backups
 	p, ok := a.store.GetProject(req.ProjectID)
@@ -2216,6 +5350,24 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 	// prepare backup dir
 	patchID := fmt.Sprintf("pt-%d-%d", time.Now().UnixNano(), rand.Intn(1000))
 	backupDir := filepath.Join(p.RootPath, ".mycoder", "patches", patchID, "files")
+	applyOpt := patch.ApplyOptions{
+		IgnoreWhitespace: strings.Contains(strings.ToLower(r.URL.RawQuery), "ignorews=1"),
+		Fuzz:             req.Fuzz,
+	}
+	if applyOpt.Fuzz <= 0 {
+		if v := config.Get("MYCODER_PATCH_FUZZ"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				applyOpt.Fuzz = min(n, maxPatchFuzz)
+			}
+		}
+	}
+	type fplan struct {
+		op, rel, full string
+		orig          []byte
+		content       string
+	}
+	plans := make([]fplan, len(files))
+	conflict := false
 	for i := range files {
 		f := &files[i]
 		// decide operation and target path
@@ -2235,8 +5387,8 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 		_, full, ok := a.resolveProjectPath(req.ProjectID, rel)
 		if !ok {
 			list[i].Conflict = "path outside project"
-			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "files": list, "totalAdd": totalAdd, "totalDel": totalDel})
-			return
+			conflict = true
+			continue
 		}
 		b, err := os.ReadFile(full)
 		if err != nil {
@@ -2244,48 +5396,65 @@ func (a *API) handleFSPatchUnified(w http.ResponseWriter, r *http.Request) {
 				b = []byte("")
 			} else {
 				list[i].Conflict = "file not found"
-				writeJSON(w, http.StatusOK, map[string]any{"ok": false, "files": list, "totalAdd": totalAdd, "totalDel": totalDel})
-				return
+				conflict = true
+				continue
+			}
+		}
+		// attempt every hunk so that all rejections are reported at once
+		res := patch.ApplyToContentPartial(string(b), f.Hunks, applyOpt)
+		list[i].Hunks = res.Hunks
+		if len(res.Rejects) > 0 {
+			list[i].Rejects = res.Rejects
+			list[i].Conflict = fmt.Sprintf("%d of %d hunks rejected: %s", len(res.Rejects), len(f.Hunks), res.Rejects[0].Reason)
+			rejRel := filepath.Join(".mycoder", "patches", patchID, "rejects", rel+".rej")
+			rejFull := filepath.Join(p.RootPath, rejRel)
+			if err := os.MkdirAll(filepath.Dir(rejFull), 0o755); err == nil {
+				if err := os.WriteFile(rejFull, []byte(patch.FormatRejects(rel, res.Rejects)), 0o644); err == nil {
+					list[i].RejectFile = filepath.ToSlash(rejRel)
+				}
 			}
+			conflict = true
+			continue
+		}
+		if res.Add != list[i].Add || res.Del != list[i].Del {
+			list[i].Conflict = "stats mismatch"
+			conflict = true
+			continue
 		}
+		plans[i] = fplan{op: op, rel: rel, full: full, orig: b, content: res.Content}
+	}
+	if conflict {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "files": list, "totalAdd": totalAdd, "totalDel": totalDel})
+		return
+	}
+	for i, pl := range plans {
 		// backup original content
-		bkp := filepath.Join(backupDir, rel)
+		bkp := filepath.Join(backupDir, pl.rel)
 		if err := os.MkdirAll(filepath.Dir(bkp), 0o755); err != nil {
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
-		if err := os.WriteFile(bkp, b, 0o644); err != nil {
+		if err := os.WriteFile(bkp, pl.orig, 0o644); err != nil {
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
-		newContent, addLines, delLines, err := patch.ApplyToContentOpt(string(b), f.Hunks, patch.ApplyOptions{IgnoreWhitespace: strings.Contains(strings.ToLower(r.URL.RawQuery), "ignorews=1")})
-		if err != nil {
-			list[i].Conflict = err.Error()
-			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "files": list, "totalAdd": totalAdd, "totalDel": totalDel})
-			return
-		}
-		if addLines != list[i].Add || delLines != list[i].Del {
-			list[i].Conflict = "stats mismatch"
-			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "files": list, "totalAdd": totalAdd, "totalDel": totalDel})
-			return
-		}
-		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		if err := os.MkdirAll(filepath.Dir(pl.full), 0o755); err != nil {
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
-		if op == "delete" {
-			if err := os.Remove(full); err != nil {
+		if pl.op == "delete" {
+			if err := os.Remove(pl.full); err != nil {
 				writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 				return
 			}
 			list[i].WrittenBytes = 0
 		} else {
-			if err := os.WriteFile(full, []byte(newContent), 0o644); err != nil {
+			if err := os.WriteFile(pl.full, []byte(pl.content), 0o644); err != nil {
 				writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 				return
 			}
-			list[i].WrittenBytes = len(newContent)
-			written += len(newContent)
+			list[i].WrittenBytes = len(pl.content)
+			written += len(pl.content)
 		}
 	}
 	// record patch if sqlite
@@ -2307,7 +5476,7 @@ func (a *API) handleFSPatchUnifiedRollback(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -2379,6 +5548,68 @@ func (a *API) handleFSPatchUnifiedRollback(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "restored": len(files), "writtenBytes": written})
 }
 
+// handleFSPatchList lists recorded unified patches for a project (newest first).
+// Patches are only recorded with the SQLite store; other stores return an empty list.
+func (a *API) handleFSPatchList(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	pid := r.URL.Query().Get("projectID")
+	if pid == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID required")
+		return
+	}
+	type item struct {
+		ID        string `json:"id"`
+		CreatedAt string `json:"createdAt"`
+		Applied   bool   `json:"applied"`
+		Files     int    `json:"files"`
+		TotalAdd  int    `json:"totalAdd"`
+		TotalDel  int    `json:"totalDel"`
+	}
+	out := []item{}
+	ss, ok := a.store.(*store.SQLiteStore)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"patches": out})
+		return
+	}
+	rows, err := ss.DB().Query(`SELECT id, applied, created_at, COALESCE(hunks,'') FROM patches WHERE project_id=? ORDER BY created_at DESC, id DESC`, pid)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it item
+		var applied int
+		var meta string
+		if err := rows.Scan(&it.ID, &applied, &it.CreatedAt, &meta); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		it.Applied = applied != 0
+		var m struct {
+			Files []struct {
+				Add int `json:"add"`
+				Del int `json:"del"`
+			} `json:"files"`
+		}
+		if json.Unmarshal([]byte(meta), &m) == nil {
+			it.Files = len(m.Files)
+			for _, f := range m.Files {
+				it.TotalAdd += f.Add
+				it.TotalDel += f.Del
+			}
+		}
+		out = append(out, it)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"patches": out})
+}
+
 // handleFSDiff returns a unified diff between the current file content and provided newContent.
 func (a *API) handleFSDiff(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
@@ -2419,7 +5650,7 @@ func (a *API) handleShellExec(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -2429,6 +5660,8 @@ func (a *API) handleShellExec(w http.ResponseWriter, r *http.Request) {
 		TimeoutSec     int
 		Cwd            string            `json:"cwd"`
 		Env            map[string]string `json:"env"`
+		MaxOutputBytes int               `json:"maxOutputBytes"`
+		Stdin          string            `json:"stdin"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
@@ -2449,44 +5682,50 @@ func (a *API) handleShellExec(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), to)
 	defer cancel()
-	// Build a zsh -lc commandline so users can use shell semantics.
+	// Build a `<shell> -lc` commandline so users can use shell semantics.
 	cmdline := buildCmdline(req.Cmd, req.Args)
-	cmd := exec.CommandContext(ctx, "/bin/zsh", "-lc", cmdline)
 	if ok, reason := shellAllowed(cmdline); !ok {
 		writeError(w, http.StatusForbidden, "forbidden", reason)
 		return
 	}
-	// resolve cwd under project root if provided
-	workdir := p.RootPath
-	if strings.TrimSpace(req.Cwd) != "" {
-		_, full, ok := a.resolveProjectPath(p.ID, req.Cwd)
-		if ok {
-			workdir = full
-		}
+	sh, err := shellPath()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	cmd := exec.CommandContext(ctx, sh, "-lc", cmdline)
+	// resolve cwd under project root if provided; empty means root
+	workdir, ok := a.shellWorkdir(p, req.Cwd)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_request", "cwd outside project")
+		return
 	}
 	cmd.Dir = workdir
 	// whitelist env pass-through
-	allowed := map[string]bool{"GOFLAGS": true, "GOWORK": true, "CGO_ENABLED": true}
-	env := os.Environ()
-	for k, v := range req.Env {
-		if allowed[k] {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
+	cmd.Env = shellEnv(req.Env)
+	if req.Stdin != "" {
+		cmd.Stdin = strings.NewReader(req.Stdin)
 	}
-	cmd.Env = env
-	cb := newCapBuffer(64 * 1024)
+	limit := shellOutputLimit(req.MaxOutputBytes)
+	cb := newCapBuffer(limit)
 	cmd.Stdout = cb
 	cmd.Stderr = cb
-	err := cmd.Run()
-	exit := 0
-	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			exit = ee.ExitCode()
-		} else {
-			exit = -1
-		}
-	}
-	writeJSON(w, http.StatusOK, map[string]any{"exitCode": exit, "output": string(cb.b), "truncated": cb.truncated, "outputBytes": cb.n, "outputLines": cb.lines})
+	// don't wait on grandchildren still holding the output pipe after a kill
+	cmd.WaitDelay = shellPipeGrace
+	err = cmd.Run()
+	exit, sig := exitStatus(err)
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	if timedOut {
+		exit = timeoutExitCode
+	}
+	res := map[string]any{"exitCode": exit, "output": string(cb.b), "truncated": cb.truncated, "outputBytes": cb.n, "outputLines": cb.lines, "outputLimit": limit, "timedOut": timedOut}
+	if sig != "" {
+		res["signal"] = sig
+	}
+	if cb.truncated {
+		res["message"] = fmt.Sprintf("output truncated at %d bytes", limit)
+	}
+	writeJSON(w, http.StatusOK, res)
 }
 
 func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
@@ -2497,7 +5736,7 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -2507,6 +5746,8 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 		TimeoutSec     int
 		Cwd            string            `json:"cwd"`
 		Env            map[string]string `json:"env"`
+		MaxOutputBytes int               `json:"maxOutputBytes"`
+		Stdin          string            `json:"stdin"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_json", "malformed request body")
@@ -2528,7 +5769,6 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), to)
 	defer cancel()
 	cmdline := buildCmdline(req.Cmd, req.Args)
-	cmd := exec.CommandContext(ctx, "/bin/zsh", "-lc", cmdline)
 	if ok, _ := shellAllowed(cmdline); !ok {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
@@ -2542,41 +5782,65 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	workdir := p.RootPath
-	if strings.TrimSpace(req.Cwd) != "" {
-		_, full, ok := a.resolveProjectPath(p.ID, req.Cwd)
-		if ok {
-			workdir = full
-		}
+	sh, err := shellPath()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	cmd := exec.CommandContext(ctx, sh, "-lc", cmdline)
+	workdir, ok := a.shellWorkdir(p, req.Cwd)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_request", "cwd outside project")
+		return
 	}
 	cmd.Dir = workdir
-	allowed := map[string]bool{"GOFLAGS": true, "GOWORK": true, "CGO_ENABLED": true}
-	env := os.Environ()
-	for k, v := range req.Env {
-		if allowed[k] {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
+	cmd.Env = shellEnv(req.Env)
+	if req.Stdin != "" {
+		cmd.Stdin = strings.NewReader(req.Stdin)
+	}
+	// own the pipes (rather than StdoutPipe) so Wait can run while output is
+	// still being read: a backgrounded child may keep them open after the
+	// shell itself has exited
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
 	}
-	cmd.Env = env
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
-	if err := cmd.Start(); err != nil {
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	defer stdout.Close()
+	defer stderr.Close()
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+	err = cmd.Start()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	fl, _ := w.(http.Flusher)
+	// stdout and stderr readers send concurrently; keep events whole
+	var sendMu sync.Mutex
 	send := func(event, data string) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
 		fmt.Fprintf(w, "event: %s\n", event)
 		fmt.Fprintf(w, "data: %s\n\n", data)
 		if fl != nil {
 			fl.Flush()
 		}
 	}
-	// streaming output limit (64KiB) across stdout/stderr
+	// streaming output limit across stdout/stderr
 	var mu sync.Mutex
-	limit := 64 * 1024
+	limit := shellOutputLimit(req.MaxOutputBytes)
+	limitMsg := fmt.Sprintf("output truncated at %d bytes", limit)
 	sent := 0
 	limited := false
 	lines := 0
@@ -2592,7 +5856,7 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 			if remain <= 0 {
 				limited = true
 				mu.Unlock()
-				send("limit", "output truncated")
+				send("limit", limitMsg)
 				cancel()
 				return
 			}
@@ -2601,7 +5865,7 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 				sent += len(part)
 				mu.Unlock()
 				send(kind, part)
-				send("limit", "output truncated")
+				send("limit", limitMsg)
 				cancel()
 				return
 			}
@@ -2610,36 +5874,181 @@ func (a *API) handleShellExecStream(w http.ResponseWriter, r *http.Request) {
 		mu.Unlock()
 		send(kind, data)
 	}
-	go streamReader(stdout, func(line string) { sendWithLimit("stdout", line) })
-	go streamReader(stderr, func(line string) { sendWithLimit("stderr", line) })
-	err := cmd.Wait()
-	code := 0
-	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			code = ee.ExitCode()
-		} else {
-			code = -1
+	// drain both pipes before the summary/exit events
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		streamReader(stdout, func(line string) { sendWithLimit("stdout", line) })
+	}()
+	go func() {
+		defer readers.Done()
+		streamReader(stderr, func(line string) { sendWithLimit("stderr", line) })
+	}()
+	var waitErr error
+	exited := make(chan struct{})
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+	drained := make(chan struct{})
+	go func() {
+		// children of the shell can hold the pipes open after it exits or is
+		// killed; stop reading shortly after either
+		select {
+		case <-drained:
+			return
+		case <-exited:
+		case <-ctx.Done():
 		}
+		select {
+		case <-drained:
+		case <-time.After(shellPipeGrace):
+			_ = stdout.Close()
+			_ = stderr.Close()
+		}
+	}()
+	readers.Wait()
+	close(drained)
+	<-exited
+	code, sig := exitStatus(waitErr)
+	// the output limit cancels ctx too; only a deadline counts as a timeout
+	if ctx.Err() == context.DeadlineExceeded {
+		code = timeoutExitCode
+		send("timeout", fmt.Sprintf(`{"timeoutSec":%d,"signal":%q}`, int(to/time.Second), sig))
 	}
 	// summary before exit
-	send("summary", fmt.Sprintf(`{"bytes":%d,"lines":%d,"limited":%v}`, sent, lines, limited))
+	send("summary", fmt.Sprintf(`{"bytes":%d,"lines":%d,"limited":%v,"limit":%d}`, sent, lines, limited, limit))
 	send("exit", fmt.Sprintf("%d", code))
 }
 
+// defaultShellMaxOutput is the shell exec output cap when nothing is configured.
+const defaultShellMaxOutput = 64 * 1024
+
+// shellOutputLimit resolves the output cap. MYCODER_SHELL_MAX_OUTPUT_BYTES
+// (default 64KiB) is the ceiling; a per-request value may only lower it.
+func shellOutputLimit(reqMax int) int {
+	max := defaultShellMaxOutput
+	if v := config.Get("MYCODER_SHELL_MAX_OUTPUT_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			max = n
+		}
+	}
+	if reqMax > 0 && reqMax < max {
+		return reqMax
+	}
+	return max
+}
+
+// shellPipeGrace is how long shell exec keeps reading output after the
+// command was canceled or killed.
+const shellPipeGrace = 2 * time.Second
+
+// maxStreamLine caps how much of a single unterminated line streamReader
+// buffers before emitting it anyway.
+const maxStreamLine = 1 << 20
+
+// streamReader calls fn once per complete line read from r, without the
+// trailing newline, so long lines and multi-byte runes are never split
+// across events. A final line without a newline is emitted at EOF.
 func streamReader(r io.Reader, fn func(string)) {
-	buf := make([]byte, 4096)
+	br := bufio.NewReaderSize(r, 64*1024)
+	var line []byte
 	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			fn(strings.TrimRight(string(buf[:n]), "\n"))
+		frag, err := br.ReadSlice('\n')
+		line = append(line, frag...)
+		if err == bufio.ErrBufferFull && len(line) < maxStreamLine {
+			continue
 		}
-		if err != nil {
+		if len(line) > 0 {
+			fn(strings.TrimRight(string(line), "\r\n"))
+			line = line[:0]
+		}
+		if err != nil && err != bufio.ErrBufferFull {
 			return
 		}
 	}
 }
 
-// buildCmdline concatenates command and args with basic shell-safe quoting for zsh -lc.
+// timeoutExitCode is reported when a command is killed by its deadline (as GNU timeout).
+const timeoutExitCode = 124
+
+// exitStatus maps a Wait error to an exit code and, when the process was
+// terminated by a signal, the signal name. Spawn failures report -1.
+func exitStatus(err error) (int, string) {
+	if err == nil {
+		return 0, ""
+	}
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		return -1, ""
+	}
+	if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ee.ExitCode(), ws.Signal().String()
+	}
+	return ee.ExitCode(), ""
+}
+
+// shellWorkdir resolves a shell cwd under the project root. An empty cwd means
+// the root; a cwd that cannot be resolved inside the project reports false.
+func (a *API) shellWorkdir(p *models.Project, cwd string) (string, bool) {
+	if strings.TrimSpace(cwd) == "" {
+		return p.RootPath, true
+	}
+	_, full, ok := a.resolveProjectPath(p.ID, cwd)
+	return full, ok
+}
+
+// defaultShellEnvAllow lists request env keys always passed through to commands.
+var defaultShellEnvAllow = []string{"GOFLAGS", "GOWORK", "CGO_ENABLED"}
+
+// shellEnvAllowed returns the env passthrough whitelist: built-in defaults merged
+// with MYCODER_SHELL_ENV_ALLOW (comma-separated). Sensitive keys such as PATH or
+// HOME are only honored when listed there explicitly.
+func shellEnvAllowed() map[string]bool {
+	allowed := make(map[string]bool, len(defaultShellEnvAllow))
+	for _, k := range defaultShellEnvAllow {
+		allowed[k] = true
+	}
+	for _, k := range strings.Split(config.Get("MYCODER_SHELL_ENV_ALLOW"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			allowed[k] = true
+		}
+	}
+	return allowed
+}
+
+// shellEnv builds the process environment for shell commands, appending only
+// whitelisted request entries to the server environment.
+func shellEnv(reqEnv map[string]string) []string {
+	allowed := shellEnvAllowed()
+	env := os.Environ()
+	for k, v := range reqEnv {
+		if allowed[k] {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	return env
+}
+
+// shellPath returns the interpreter used for `-lc` command lines: MYCODER_SHELL,
+// else /bin/zsh on darwin and /bin/sh elsewhere. It errors if the shell is missing.
+func shellPath() (string, error) {
+	sh := strings.TrimSpace(config.Get("MYCODER_SHELL"))
+	if sh == "" {
+		sh = "/bin/sh"
+		if runtime.GOOS == "darwin" {
+			sh = "/bin/zsh"
+		}
+	}
+	p, err := exec.LookPath(sh)
+	if err != nil {
+		return "", fmt.Errorf("shell %q not available (set MYCODER_SHELL): %v", sh, err)
+	}
+	return p, nil
+}
+
+// buildCmdline concatenates command and args with basic shell-safe quoting for `<shell> -lc`.
 func buildCmdline(cmd string, args []string) string {
 	parts := make([]string, 0, 1+len(args))
 	parts = append(parts, shellQuote(cmd))
@@ -2667,42 +6076,84 @@ func shellQuoteOrEmpty(s string) string {
 	return shellQuote(s)
 }
 
-// POST /chat: {messages:[{role,content}], model?, stream?, temperature?}
+// POST /chat: {messages:[{role,content}], model?, stream?, temperature?, maxTokens?, systemPrompt?, retrieval?:{k,strategy,maxSnippets}, retrievalOnly?}
 func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
+	// latency metrics (ttft, duration) are measured from request receipt, so
+	// they include RAG retrieval and summarization before the LLM call
+	start := time.Now()
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if a.llm == nil {
-		http.Error(w, "llm provider not configured", http.StatusServiceUnavailable)
-		return
-	}
 	var req struct {
-		Messages    []llm.Message `json:"messages"`
-		Model       string        `json:"model"`
-		Stream      bool          `json:"stream"`
-		Temperature float32       `json:"temperature"`
-		ProjectID   string        `json:"projectID"`
-		Retrieval   struct {
-			K int `json:"k"`
+		Messages     []llm.Message `json:"messages"`
+		Model        string        `json:"model"`
+		Stream       bool          `json:"stream"`
+		Temperature  float32       `json:"temperature"`
+		MaxTokens    int           `json:"maxTokens"`
+		SystemPrompt string        `json:"systemPrompt"`
+		ProjectID    string        `json:"projectID"`
+		Retrieval    struct {
+			K           int    `json:"k"`
+			Strategy    string `json:"strategy"`
+			MaxSnippets int    `json:"maxSnippets"`
 		} `json:"retrieval"`
+		// RetrievalOnly returns the assembled context without calling the LLM.
+		RetrievalOnly bool `json:"retrievalOnly"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
+	if a.llm == nil && !req.RetrievalOnly {
+		http.Error(w, "llm provider not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if req.MaxTokens < 0 {
+		http.Error(w, "maxTokens must be >= 0", http.StatusBadRequest)
+		return
+	}
+	if req.Retrieval.MaxSnippets < 0 {
+		http.Error(w, "retrieval.maxSnippets must be >= 0", http.StatusBadRequest)
+		return
+	}
+	if req.Retrieval.Strategy != "" && !validRAGStrategy(req.Retrieval.Strategy) {
+		http.Error(w, "retrieval.strategy must be system, append_user or separate", http.StatusBadRequest)
+		return
+	}
 	msgs := req.Messages
+	var rag ragResult
 	if req.ProjectID != "" {
 		k := req.Retrieval.K
 		if k <= 0 {
 			k = 5
 		}
-		msgs = a.withRAGContext(msgs, req.ProjectID, k)
+		msgs, rag = a.ragContext(msgs, req.ProjectID, ragOptions{K: k, Strategy: req.Retrieval.Strategy, MaxSnippets: req.Retrieval.MaxSnippets})
+	}
+	if req.RetrievalOnly {
+		// same assembly as below minus summarization, which would call the LLM
+		if sys := a.systemPrompts(req.ProjectID, req.SystemPrompt); len(sys) > 0 {
+			msgs = append(sys, msgs...)
+		}
+		if rag.Citations == nil {
+			rag.Citations = []models.SearchResult{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"messages":  slidingWindow(msgs),
+			"context":   rag.Context,
+			"citations": rag.Citations,
+		})
+		return
 	}
 	// optional: summarize conversation if too long (map-reduce style pre-summary)
 	msgs = a.maybeSummarize(msgs, req.ProjectID)
+	// project (.mycoder/system.md) and caller-supplied system prompts go first,
+	// ahead of the RAG context
+	if sys := a.systemPrompts(req.ProjectID, req.SystemPrompt); len(sys) > 0 {
+		msgs = append(sys, msgs...)
+	}
 	// debug: log first message role/size if enabled
-	if os.Getenv("MYCODER_RAG_DEBUG") == "1" {
+	if config.Get("MYCODER_RAG_DEBUG") == "1" {
 		role := "(none)"
 		size := 0
 		if len(msgs) > 0 {
@@ -2711,14 +6162,19 @@ func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
 		}
 		fmt.Fprintf(os.Stderr, "[rag-debug] messages=%d first_role=%s first_size=%d\n", len(msgs), role, size)
 	}
-	// metrics: count chat requests
+	// metrics: count chat requests; duration is recorded when the response ends
 	metrics.mu.Lock()
 	metrics.chatRequests++
 	metrics.mu.Unlock()
+	defer func() {
+		metrics.mu.Lock()
+		metrics.chatDuration.observe(metrics.buckets, time.Since(start).Seconds())
+		metrics.mu.Unlock()
+	}()
 
 	// apply sliding window after RAG context; keep system rules first
 	msgs = slidingWindow(msgs)
-	st, err := a.llm.Chat(r.Context(), req.Model, msgs, req.Stream, req.Temperature)
+	st, err := a.llm.Chat(llm.WithMaxTokens(r.Context(), req.MaxTokens), req.Model, msgs, req.Stream, req.Temperature)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
@@ -2728,6 +6184,7 @@ func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		fl, _ := w.(http.Flusher)
+		first := true
 		for {
 			delta, done, err := st.Recv()
 			if err != nil {
@@ -2742,6 +6199,11 @@ func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
 				fmt.Fprintf(w, "event: token\n")
 				fmt.Fprintf(w, "data: %s\n\n", jsonEscape(delta))
 				metrics.mu.Lock()
+				if first {
+					metrics.chatTTFT.observe(metrics.buckets, time.Since(start).Seconds())
+					first = false
+				}
+				metrics.chatTokenEvents++
 				metrics.chatTokens += len(delta) / 4
 				metrics.mu.Unlock()
 				if fl != nil {
@@ -2759,8 +6221,13 @@ func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 	var buf strings.Builder
 	for {
-		delta, done, err := st.Recv()
+		delta, done, err := recvCtx(r.Context(), st)
 		if err != nil {
+			if r.Context().Err() != nil {
+				// client went away; the deferred Close stops the upstream read
+				http.Error(w, "request canceled", statusClientClosedRequest)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusBadGateway)
 			return
 		}
@@ -2769,11 +6236,139 @@ func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
-	// approximate token count for non-streaming
+	// prefer provider-reported usage; fall back to the len/4 estimate
+	res := map[string]any{"content": buf.String()}
+	var usage llm.Usage
+	hasUsage := false
+	if ur, ok := st.(llm.UsageReporter); ok {
+		usage, hasUsage = ur.Usage()
+	}
 	metrics.mu.Lock()
-	metrics.chatTokens += len(buf.String()) / 4
+	if hasUsage {
+		metrics.chatPromptTokens += usage.PromptTokens
+		metrics.chatCompletionTokens += usage.CompletionTokens
+		metrics.chatTokens += usage.CompletionTokens
+		res["usage"] = usage
+	} else {
+		metrics.chatTokens += len(buf.String()) / 4
+	}
 	metrics.mu.Unlock()
-	writeJSON(w, http.StatusOK, map[string]any{"content": buf.String()})
+	writeJSON(w, http.StatusOK, res)
+}
+
+// projectSystemFile is the per-project system prompt, relative to the project root.
+const projectSystemFile = ".mycoder/system.md"
+
+// defaultSystemPromptMaxBytes caps how much of system.md is read unless
+// MYCODER_SYSTEM_PROMPT_MAX_BYTES says otherwise.
+const defaultSystemPromptMaxBytes = 32 * 1024
+
+// systemPromptCache keeps project system prompt files keyed by path and
+// re-reads a file only when its mtime or size changes.
+var systemPromptCache = struct {
+	mu      sync.Mutex
+	entries map[string]systemPromptEntry
+}{entries: make(map[string]systemPromptEntry)}
+
+type systemPromptEntry struct {
+	mtime time.Time
+	size  int64
+	limit int
+	text  string
+}
+
+// projectSystemPrompt returns the trimmed contents of root/.mycoder/system.md,
+// or "" when the file is missing or unreadable. Files larger than
+// MYCODER_SYSTEM_PROMPT_MAX_BYTES are truncated with a warning.
+func projectSystemPrompt(root string) string {
+	if root == "" {
+		return ""
+	}
+	path := filepath.Join(root, projectSystemFile)
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		return ""
+	}
+	limit := config.GetInt("MYCODER_SYSTEM_PROMPT_MAX_BYTES", defaultSystemPromptMaxBytes)
+	if limit <= 0 {
+		limit = defaultSystemPromptMaxBytes
+	}
+	systemPromptCache.mu.Lock()
+	defer systemPromptCache.mu.Unlock()
+	if e, ok := systemPromptCache.entries[path]; ok && e.mtime.Equal(fi.ModTime()) && e.size == fi.Size() && e.limit == limit {
+		return e.text
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, int64(limit)+1))
+	if err != nil {
+		return ""
+	}
+	if len(b) > limit {
+		// back off to a rune boundary so the cut does not split a multi-byte rune
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(b[cut]) {
+			cut--
+		}
+		b = b[:cut]
+		mylog.New().Warn("chat.system_prompt.truncated", "path", path, "size", fi.Size(), "maxBytes", limit)
+	}
+	text := strings.TrimSpace(string(b))
+	systemPromptCache.entries[path] = systemPromptEntry{mtime: fi.ModTime(), size: fi.Size(), limit: limit, text: text}
+	return text
+}
+
+// systemPrompts builds the leading system messages for a chat: the project's
+// system.md followed by the per-request prompt. With
+// MYCODER_SYSTEM_PROMPT_MODE=override a per-request prompt replaces the
+// project one instead of being appended after it.
+func (a *API) systemPrompts(projectID, requestPrompt string) []llm.Message {
+	var out []llm.Message
+	requestPrompt = strings.TrimSpace(requestPrompt)
+	override := strings.EqualFold(config.Get("MYCODER_SYSTEM_PROMPT_MODE"), "override")
+	if projectID != "" && (requestPrompt == "" || !override) {
+		if p, ok := a.store.GetProject(projectID); ok {
+			if text := projectSystemPrompt(p.RootPath); text != "" {
+				out = append(out, llm.Message{Role: llm.RoleSystem, Content: text})
+			}
+		}
+	}
+	if requestPrompt != "" {
+		out = append(out, llm.Message{Role: llm.RoleSystem, Content: requestPrompt})
+	}
+	return out
+}
+
+// statusClientClosedRequest is the non-standard status logged when the client
+// cancels before a response is ready.
+const statusClientClosedRequest = 499
+
+// recvCtx calls st.Recv but returns ctx.Err() as soon as ctx is done, so a
+// stalled provider cannot hold the handler after the client has gone. The
+// pending Recv is released when the caller closes the stream.
+func recvCtx(ctx context.Context, st llm.ChatStream) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", true, err
+	}
+	type result struct {
+		delta string
+		done  bool
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		d, done, err := st.Recv()
+		ch <- result{d, done, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", true, ctx.Err()
+	case res := <-ch:
+		return res.delta, res.done, res.err
+	}
 }
 
 func jsonEscape(s string) string {
@@ -2789,7 +6384,7 @@ func jsonEscape(s string) string {
 func slidingWindow(messages []llm.Message) []llm.Message {
 	// budget from env (chars), default ~6000 bytes
 	max := 6000
-	if v := os.Getenv("MYCODER_CHAT_MAX_CHARS"); v != "" {
+	if v := config.Get("MYCODER_CHAT_MAX_CHARS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			max = n
 		}
@@ -2835,8 +6430,62 @@ func slidingWindow(messages []llm.Message) []llm.Message {
 	return out
 }
 
+// ragResult is the retrieval context injected into a chat: the curated
+// knowledge block (if any) followed by the assembled context block, and the
+// locations of the snippets written within the byte budget.
+type ragResult struct {
+	Context   string                `json:"context"`
+	Citations []models.SearchResult `json:"citations"`
+}
+
+// RAG context injection strategies.
+const (
+	ragInjectSystem     = "system"      // a system message ahead of the conversation
+	ragInjectAppendUser = "append_user" // prepended to the last user message
+	ragInjectSeparate   = "separate"    // its own user message right before the last one
+)
+
+// ragOptions tunes a single ragContext call.
+type ragOptions struct {
+	K int
+	// Strategy is one of the ragInject* values; empty means
+	// MYCODER_RAG_INJECT_STRATEGY, then system.
+	Strategy string
+	// MaxSnippets caps how many retrieved snippets are injected, apart from
+	// K and the byte budget; 0 means MYCODER_RAG_MAX_SNIPPETS, then no cap.
+	MaxSnippets int
+}
+
+// validRAGStrategy reports whether s names an injection strategy.
+func validRAGStrategy(s string) bool {
+	switch s {
+	case ragInjectSystem, ragInjectAppendUser, ragInjectSeparate:
+		return true
+	}
+	return false
+}
+
+// ragStrategy resolves the injection strategy: per-request value, then
+// MYCODER_RAG_INJECT_STRATEGY, then system.
+func ragStrategy(req string) string {
+	if validRAGStrategy(req) {
+		return req
+	}
+	if v := config.Get("MYCODER_RAG_INJECT_STRATEGY"); validRAGStrategy(v) {
+		return v
+	}
+	return ragInjectSystem
+}
+
 // withRAGContext builds a simple context message using lexical search results for the latest user query.
 func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []llm.Message {
+	out, _ := a.ragContext(messages, projectID, ragOptions{K: k})
+	return out
+}
+
+// ragContext is withRAGContext that also reports what was injected.
+func (a *API) ragContext(messages []llm.Message, projectID string, opts ragOptions) ([]llm.Message, ragResult) {
+	k := opts.K
 	var q string
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == llm.RoleUser {
@@ -2845,7 +6494,7 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 		}
 	}
 	if strings.TrimSpace(q) == "" {
-		return messages
+		return messages, ragResult{}
 	}
 	// adjust retrieval K based on intent
 	intent := planner.Classify(q)
@@ -2856,10 +6505,20 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 		// build hybrid
 		lex := retriever.NewBM25(a.store)
 		knn := retriever.NewKNN(a.vs, a.emb)
+		knn.OnDimMismatch = func(dim int, stored []vectorstore.ModelStats) {
+			dims := make([]int, 0, len(stored))
+			for _, s := range stored {
+				dims = append(dims, s.Dim)
+			}
+			mylog.New().Warn("rag.knn.dim_mismatch", "project", projectID, "queryDim", dim, "storedDims", dims)
+			metrics.mu.Lock()
+			metrics.vectorDimMismatch++
+			metrics.mu.Unlock()
+		}
 		hyb := retriever.NewHybrid(lex, knn)
 		// retrieval timeout configurable via env; default 5s
 		rt := 5 * time.Second
-		if v := os.Getenv("MYCODER_RETRIEVAL_TIMEOUT_MS"); v != "" {
+		if v := config.Get("MYCODER_RETRIEVAL_TIMEOUT_MS"); v != "" {
 			if n, err := strconv.Atoi(v); err == nil && n > 0 {
 				rt = time.Duration(n) * time.Millisecond
 			}
@@ -2871,7 +6530,10 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 		}
 	}
 	if len(raw) == 0 {
-		raw = a.store.Search(projectID, q, k*2)
+		var err error
+		if raw, err = a.store.Search(projectID, q, k*2); err != nil {
+			mylog.New().Warn("rag.search_failed", "project", projectID, "error", err.Error())
+		}
 	}
 	if len(raw) == 0 {
 		// No hits: inject a concise project overview to orient the model
@@ -2880,9 +6542,9 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 			out := make([]llm.Message, 0, len(messages)+1)
 			out = append(out, sys)
 			out = append(out, messages...)
-			return out
+			return out, ragResult{Context: ov}
 		}
-		return messages
+		return messages, ragResult{}
 	}
 	// trustScore-aware rerank: adjust search score with knowledge trust per path
 	trust := make(map[string]float64)
@@ -2955,7 +6617,24 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 			break
 		}
 	}
-	if os.Getenv("MYCODER_RAG_DEBUG") == "1" {
+	// keep only the best-ranked snippets when an injection cap is set; the
+	// survivors stay in path order like the rest
+	maxSnippets := opts.MaxSnippets
+	if maxSnippets <= 0 {
+		maxSnippets = config.GetInt("MYCODER_RAG_MAX_SNIPPETS", 0)
+	}
+	if maxSnippets > 0 && len(hits) > maxSnippets {
+		rank := make(map[string]int, len(cand))
+		for i, c := range cand {
+			if _, ok := rank[c.s.Path]; !ok {
+				rank[c.s.Path] = i
+			}
+		}
+		sort.SliceStable(hits, func(i, j int) bool { return rank[hits[i].Path] < rank[hits[j].Path] })
+		hits = hits[:maxSnippets]
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].Path < hits[j].Path })
+	}
+	if config.Get("MYCODER_RAG_DEBUG") == "1" {
 		// log selected paths for context
 		fmt.Fprintf(os.Stderr, "[rag-debug] hits=%d\n", len(hits))
 		max := hits
@@ -2966,50 +6645,42 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 			fmt.Fprintf(os.Stderr, "[rag-debug] hit %s:%d-%d\n", h.Path, h.StartLine, h.EndLine)
 		}
 	}
-	// prepend curated knowledge heads (titles/links) if exists
+	// prepend curated knowledge (titles plus texts within a sub-budget) if exists
+	var knowledge string
 	if kn, err := a.store.ListKnowledge(projectID, 0.5); err == nil && len(kn) > 0 {
-		var kb strings.Builder
-		kb.WriteString("Curated Knowledge:\n")
-		max := 3
-		if len(kn) < max {
-			max = len(kn)
-		}
-		for i := 0; i < max; i++ {
-			kb.WriteString("- ")
-			if kn[i].Title != "" {
-				kb.WriteString(kn[i].Title)
-			} else {
-				kb.WriteString(kn[i].PathOrURL)
+		kbudget := 1200
+		if v := config.Get("MYCODER_RAG_KNOWLEDGE_BUDGET_BYTES"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				kbudget = n
 			}
-			kb.WriteString("\n")
 		}
-		sys := llm.Message{Role: llm.RoleSystem, Content: kb.String()}
-		messages = append([]llm.Message{sys}, messages...)
+		knowledge = curatedKnowledgeBlock(kn, 3, kbudget)
+		messages = append([]llm.Message{{Role: llm.RoleSystem, Content: knowledge}}, messages...)
 	}
 	var b strings.Builder
 	b.WriteString(ragInstruction(q))
 	b.WriteString("Context:\n")
 	// approximate token budget in bytes (dynamic line count per snippet)
 	budget := 3000
-	if v := os.Getenv("MYCODER_RAG_BUDGET_BYTES"); v != "" {
+	if v := config.Get("MYCODER_RAG_BUDGET_BYTES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			budget = n
 		}
 	}
 	avgLineBytes := 80 // heuristic; used to size maxLines per snippet
-	if v := os.Getenv("MYCODER_RAG_AVG_LINE_BYTES"); v != "" {
+	if v := config.Get("MYCODER_RAG_AVG_LINE_BYTES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			avgLineBytes = n
 		}
 	}
 	minLines := 6
-	if v := os.Getenv("MYCODER_RAG_MIN_LINES_PER_SNIPPET"); v != "" {
+	if v := config.Get("MYCODER_RAG_MIN_LINES_PER_SNIPPET"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			minLines = n
 		}
 	}
 	maxLinesCap := 24
-	if v := os.Getenv("MYCODER_RAG_MAX_LINES_CAP"); v != "" {
+	if v := config.Get("MYCODER_RAG_MAX_LINES_CAP"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			maxLinesCap = n
 		}
@@ -3018,6 +6689,8 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 	if p, ok := a.store.GetProject(projectID); ok {
 		root = p.RootPath
 	}
+	// cite only snippets whose code made it into the budget
+	cited := make([]models.SearchResult, 0, len(hits))
 	for _, h := range hits {
 		loc := h.Path
 		if h.StartLine > 0 {
@@ -3067,6 +6740,7 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 				if budget-len(block) > 0 {
 					b.WriteString(block)
 					budget -= len(block)
+					cited = append(cited, h)
 				}
 			}
 		}
@@ -3075,27 +6749,93 @@ func (a *API) withRAGContext(messages []llm.Message, projectID string, k int) []
 		}
 	}
 	ctxText := b.String()
-	// Strategy: default inject as system. Optional: prepend to last user when env set.
-	if os.Getenv("MYCODER_RAG_INJECT_STRATEGY") == "append_user" {
+	res := ragResult{Context: ctxText, Citations: cited}
+	if knowledge != "" {
+		res.Context = knowledge + "\n" + ctxText
+	}
+	// Strategy: default inject as system; append_user prepends to the last
+	// user message and separate adds its own message just before it.
+	switch ragStrategy(opts.Strategy) {
+	case ragInjectAppendUser:
 		out := make([]llm.Message, 0, len(messages))
 		out = append(out, messages...)
 		// find last user message
 		for i := len(out) - 1; i >= 0; i-- {
 			if out[i].Role == llm.RoleUser {
 				out[i].Content = ctxText + "\n\n" + out[i].Content
-				if os.Getenv("MYCODER_RAG_DEBUG") == "1" {
+				if config.Get("MYCODER_RAG_DEBUG") == "1" {
 					fmt.Fprintf(os.Stderr, "[rag-debug] injected into last user message, added=%d bytes\n", len(ctxText))
 				}
 				break
 			}
 		}
-		return out
+		return out, res
+	case ragInjectSeparate:
+		out := make([]llm.Message, 0, len(messages)+1)
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == llm.RoleUser {
+				out = append(out, messages[:i]...)
+				out = append(out, llm.Message{Role: llm.RoleUser, Content: ctxText})
+				out = append(out, messages[i:]...)
+				break
+			}
+		}
+		return out, res
 	}
 	sys := llm.Message{Role: llm.RoleSystem, Content: ctxText}
 	out := make([]llm.Message, 0, len(messages)+1)
 	out = append(out, sys)
 	out = append(out, messages...)
-	return out
+	return out, res
+}
+
+// curatedKnowledgeBlock renders up to max knowledge items, pinned first and then
+// by trust. Item texts share budget bytes; titles are always listed.
+func curatedKnowledgeBlock(kn []*models.Knowledge, max, budget int) string {
+	items := append([]*models.Knowledge(nil), kn...)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Pinned != items[j].Pinned {
+			return items[i].Pinned
+		}
+		return items[i].TrustScore > items[j].TrustScore
+	})
+	if len(items) > max {
+		items = items[:max]
+	}
+	var kb strings.Builder
+	kb.WriteString("Curated Knowledge (reviewed notes; prefer over guesses):\n")
+	for _, k := range items {
+		title := k.Title
+		if title == "" {
+			title = k.PathOrURL
+		}
+		fmt.Fprintf(&kb, "- [%s] %s", k.SourceType, title)
+		if k.PathOrURL != "" && k.PathOrURL != title {
+			fmt.Fprintf(&kb, " (%s)", k.PathOrURL)
+		}
+		fmt.Fprintf(&kb, " trust=%.2f", k.TrustScore)
+		if k.Pinned {
+			kb.WriteString(" pinned")
+		}
+		kb.WriteString("\n")
+		text := strings.TrimSpace(k.Text)
+		if text == "" || budget <= 0 {
+			continue
+		}
+		if len(text) > budget {
+			cut := budget
+			// keep multi-byte characters intact
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "…"
+		}
+		budget -= len(text)
+		kb.WriteString("  ")
+		kb.WriteString(strings.ReplaceAll(text, "\n", "\n  "))
+		kb.WriteString("\n")
+	}
+	return kb.String()
 }
 
 // readSnippet reads lines [start:end] with margins; clamps to file bounds.
@@ -3113,7 +6853,7 @@ func readSnippet(root, rel string, start, end, maxLines int) string {
 		end = start
 	}
 	margin := 2
-	if v := os.Getenv("MYCODER_RAG_SNIPPET_MARGIN_LINES"); v != "" {
+	if v := config.Get("MYCODER_RAG_SNIPPET_MARGIN_LINES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
 			margin = n
 		}
@@ -3150,7 +6890,7 @@ func fenceLangFor(path string) string {
 	case ".yml", ".yaml":
 		return "yaml"
 	default:
-		return ""
+		return indexer.SniffLang(path, nil)
 	}
 }
 
@@ -3159,11 +6899,11 @@ func fenceLangFor(path string) string {
 // - MYCODER_RAG_NEIGHBOR_ENABLE=1 to enable
 // - MYCODER_RAG_NEIGHBOR_MAX_LINES: max lines to scan on each side (default 80)
 func expandSnippetRange(root, rel string, start, end int) (int, int) {
-	if os.Getenv("MYCODER_RAG_NEIGHBOR_ENABLE") != "1" {
+	if config.Get("MYCODER_RAG_NEIGHBOR_ENABLE") != "1" {
 		return start, end
 	}
 	maxScan := 80
-	if v := os.Getenv("MYCODER_RAG_NEIGHBOR_MAX_LINES"); v != "" {
+	if v := config.Get("MYCODER_RAG_NEIGHBOR_MAX_LINES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			maxScan = n
 		}
@@ -3230,7 +6970,7 @@ func boundaryRegexForPath(path string) *regexp.Regexp {
 // ragInstruction returns a style-aware instruction for LLM behavior.
 // Controlled via env MYCODER_RAG_STYLE: "detailed" (default) or "concise".
 func ragInstruction(userQ string) string {
-	style := strings.ToLower(strings.TrimSpace(os.Getenv("MYCODER_RAG_STYLE")))
+	style := strings.ToLower(strings.TrimSpace(config.Get("MYCODER_RAG_STYLE")))
 	if style == "concise" {
 		return "You are a coding assistant. Use the following repo context and cite files with line ranges. Keep answers focused and accurate.\n\n"
 	}
@@ -3441,18 +7181,18 @@ type cachingEmbedder struct {
 
 func newCachingEmbedder(u llm.Embedder) llm.Embedder {
 	ttl := 3600
-	if v := os.Getenv("MYCODER_EMBED_CACHE_TTL_SEC"); v != "" {
+	if v := config.Get("MYCODER_EMBED_CACHE_TTL_SEC"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
 			ttl = n
 		}
 	}
-	gen := os.Getenv("MYCODER_EMBED_CACHE_GEN")
+	gen := config.Get("MYCODER_EMBED_CACHE_GEN")
 	return &cachingEmbedder{u: u, data: make(map[string][]float32), times: make(map[string]time.Time), ttlSec: ttl, gen: gen}
 }
 
 func (c *cachingEmbedder) Embeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
 	// check generation bump (env-driven invalidation)
-	if g := os.Getenv("MYCODER_EMBED_CACHE_GEN"); g != c.gen {
+	if g := config.Get("MYCODER_EMBED_CACHE_GEN"); g != c.gen {
 		c.mu.Lock()
 		if g != c.gen { // re-check after lock
 			purged := len(c.data)
@@ -3526,7 +7266,7 @@ func cacheKey(model, input, gen string) string {
 }
 
 func cacheMaxEntries() int {
-	if v := os.Getenv("MYCODER_EMBED_CACHE_MAX_ENTRIES"); v != "" {
+	if v := config.Get("MYCODER_EMBED_CACHE_MAX_ENTRIES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			return n
 		}
@@ -3568,7 +7308,7 @@ func (c *cachingEmbedder) evictOldest(n int) {
 //	MYCODER_CHAT_SUMMARY_ENABLE=1 to enable (default off)
 //	MYCODER_CHAT_SUMMARY_THRESHOLD_CHARS (default 8000)
 func (a *API) maybeSummarize(messages []llm.Message, projectID string) []llm.Message {
-	if os.Getenv("MYCODER_CHAT_SUMMARY_ENABLE") != "1" || a.llm == nil {
+	if config.Get("MYCODER_CHAT_SUMMARY_ENABLE") != "1" || a.llm == nil {
 		return messages
 	}
 	// compute total content size (exclude system)
@@ -3580,7 +7320,7 @@ func (a *API) maybeSummarize(messages []llm.Message, projectID string) []llm.Mes
 		sum += len(m.Content)
 	}
 	thr := 8000
-	if v := os.Getenv("MYCODER_CHAT_SUMMARY_THRESHOLD_CHARS"); v != "" {
+	if v := config.Get("MYCODER_CHAT_SUMMARY_THRESHOLD_CHARS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			thr = n
 		}
@@ -3614,7 +7354,7 @@ func (a *API) maybeSummarize(messages []llm.Message, projectID string) []llm.Mes
 	}
 	prompt := b.String()
 	// call LLM non-streaming with low temperature
-	st, err := a.llm.Chat(context.Background(), os.Getenv("MYCODER_CHAT_MODEL"), []llm.Message{{Role: llm.RoleUser, Content: prompt}}, false, 0.1)
+	st, err := a.llm.Chat(context.Background(), config.Get("MYCODER_CHAT_MODEL"), []llm.Message{{Role: llm.RoleUser, Content: prompt}}, false, 0.1)
 	if err != nil {
 		return messages
 	}
@@ -3646,7 +7386,7 @@ func (a *API) handleKnowledgeApprove(w http.ResponseWriter, r *http.Request) {
 	if !authorize(w, r) {
 		return
 	}
-	if isReadOnly() {
+	if a.readOnly {
 		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
 		return
 	}
@@ -3668,12 +7408,45 @@ func (a *API) handleKnowledgeApprove(w http.ResponseWriter, r *http.Request) {
 	if req.MinTrust == 0 {
 		req.MinTrust = 0.8
 	}
-	n, err := a.store.ApproveKnowledge(req.ProjectID, req.IDs, true, req.MinTrust)
+	// fall back to the label of the token that authorized the call
+	approvedBy := strings.TrimSpace(req.ApprovedBy)
+	if approvedBy == "" {
+		approvedBy = authLabel(r.Context())
+	}
+	if approvedBy == "" {
+		approvedBy = apiTokens()[requestToken(r)]
+	}
+	n, err := a.store.ApproveKnowledge(req.ProjectID, req.IDs, true, req.MinTrust, approvedBy)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"approved": n, "approvedBy": approvedBy})
+}
+
+// handleKnowledgeApprovals serves GET /knowledge/approvals: the approval audit log.
+func (a *API) handleKnowledgeApprovals(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	pid := r.URL.Query().Get("projectID")
+	if pid == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "projectID required")
+		return
+	}
+	list, err := a.store.ListKnowledgeApprovals(pid)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"approved": n})
+	if list == nil {
+		list = []*models.KnowledgeApproval{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"approvals": list})
 }
 
 // handleKnowledgePending lists unpinned knowledge items (optionally filter by sourceType and minTrust).
@@ -3718,3 +7491,83 @@ func (a *API) handleKnowledgePending(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"pending": out})
 }
+
+// handleConversations serves GET /conversations?projectID= (newest first).
+// Stores without conversation history return an empty list.
+func (a *API) handleConversations(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	out := []*models.Conversation{}
+	if cs, ok := a.store.(ConversationStore); ok {
+		list, err := cs.ListConversations(r.URL.Query().Get("projectID"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		out = list
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"conversations": out})
+}
+
+// handleConversationItem serves GET /conversations/{id} with its messages and
+// POST /conversations/{id}/pin|unpin, which protect a conversation from the TTL cleaner.
+func (a *API) handleConversationItem(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/conversations/"), "/"), "/")
+	cs, ok := a.store.(ConversationStore)
+	if id == "" || !ok {
+		writeError(w, http.StatusNotFound, "not_found", "conversation not found")
+		return
+	}
+	switch action {
+	case "":
+	case "pin", "unpin":
+		a.pinConversation(w, r, cs, id, action == "pin")
+		return
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "conversation not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	conv, msgs, found, err := cs.GetConversation(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "not_found", "conversation not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"conversation": conv, "messages": msgs})
+}
+
+func (a *API) pinConversation(w http.ResponseWriter, r *http.Request, cs ConversationStore, id string, pinned bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "")
+		return
+	}
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "forbidden", "read-only mode")
+		return
+	}
+	found, err := cs.SetConversationPinned(id, pinned)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "not_found", "conversation not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "pinned": pinned})
+}
@@ -18,8 +18,8 @@ func TestShellExecStreamLimit(t *testing.T) {
 	mux := api.mux()
 
 	// generate lots of output
-	script := "for i in {1..20000}; do echo 0123456789; done"
-	body := map[string]any{"projectID": p.ID, "cmd": "zsh", "args": []any{"-lc", script}, "timeoutSec": 10}
+	script := "i=0; while [ $i -lt 20000 ]; do echo 0123456789; i=$((i+1)); done"
+	body := map[string]any{"projectID": p.ID, "cmd": "sh", "args": []any{"-c", script}, "timeoutSec": 10}
 	b, _ := json.Marshal(body)
 	req := httptest.NewRequest(http.MethodPost, "/shell/exec/stream", bytes.NewReader(b))
 	rr := httptest.NewRecorder()
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"mycoder/internal/store"
+)
+
+func TestShellExecStreamLongLineIntact(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("shl", t.TempDir(), nil)
+	mux := api.mux()
+	script := "head -c 20000 /dev/zero | tr '\\0' a; echo; echo tail"
+	body := map[string]any{"projectID": p.ID, "cmd": "sh", "args": []string{"-c", script}, "timeoutSec": 5}
+	b, _ := json.Marshal(body)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec/stream", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var lines []string
+	for _, ev := range strings.Split(rr.Body.String(), "\n\n") {
+		if strings.HasPrefix(ev, "event: stdout\n") {
+			lines = append(lines, strings.TrimPrefix(ev, "event: stdout\ndata: "))
+		}
+	}
+	if len(lines) != 2 {
+		t.Fatalf("want 2 stdout events, got %d", len(lines))
+	}
+	if lines[0] != strings.Repeat("a", 20000) {
+		t.Fatalf("long line split or altered: len=%d", len(lines[0]))
+	}
+	if lines[1] != "tail" {
+		t.Fatalf("second line=%q", lines[1])
+	}
+}
+
+func TestStreamReaderKeepsRunesWhole(t *testing.T) {
+	var got []string
+	in := "한글 출력\r\nsecond\nno newline"
+	streamReader(iotest.OneByteReader(strings.NewReader(in)), func(s string) { got = append(got, s) })
+	want := []string{"한글 출력", "second", "no newline"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"mycoder/internal/store"
 )
@@ -62,3 +63,27 @@ func TestShellExecStreamSummaryEvent(t *testing.T) {
 		t.Fatalf("missing summary event: %q", out)
 	}
 }
+
+func TestShellExecStreamBackgroundChildDoesNotBlock(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("shs", t.TempDir(), nil)
+	mux := api.mux()
+	// the backgrounded sleep inherits stdout and keeps it open after sh exits
+	body := map[string]any{"projectID": p.ID, "cmd": "sh", "args": []string{"-c", "sleep 10 & echo hi"}, "timeoutSec": 8}
+	b, _ := json.Marshal(body)
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec/stream", bytes.NewReader(b)))
+	elapsed := time.Since(start)
+	out := rr.Body.String()
+	if elapsed > 5*time.Second {
+		t.Fatalf("request blocked on the background child for %v", elapsed)
+	}
+	if strings.Contains(out, "event: timeout") || !strings.Contains(out, "event: exit\ndata: 0\n") {
+		t.Fatalf("expected clean exit 0: %q", out)
+	}
+	if !strings.Contains(out, "data: hi\n") {
+		t.Fatalf("missing stdout: %q", out)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"mycoder/internal/store"
 )
@@ -91,9 +92,9 @@ func TestShellExecOutputLimit(t *testing.T) {
 	p := st.CreateProject("sh", t.TempDir(), nil)
 	mux := api.mux()
 
-	// Generate large output using zsh loop
-	script := "for i in {1..50000}; do echo 0123456789; done"
-	body := map[string]any{"projectID": p.ID, "cmd": "zsh", "args": []string{"-lc", script}, "timeoutSec": 10}
+	// Generate large output using a POSIX sh loop
+	script := "i=0; while [ $i -lt 50000 ]; do echo 0123456789; i=$((i+1)); done"
+	body := map[string]any{"projectID": p.ID, "cmd": "sh", "args": []string{"-c", script}, "timeoutSec": 10}
 	b, _ := json.Marshal(body)
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
@@ -121,3 +122,216 @@ func TestShellExecOutputLimit(t *testing.T) {
 		t.Fatalf("expected near cap length, got %d", len(res.Output))
 	}
 }
+
+func TestShellExecConfigurableOutputLimit(t *testing.T) {
+	t.Setenv("MYCODER_SHELL_MAX_OUTPUT_BYTES", "16")
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("sh", t.TempDir(), nil)
+	mux := api.mux()
+
+	run := func(body map[string]any) (out string, truncated bool, limit int, msg string) {
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("exec code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Output      string
+			Truncated   bool
+			OutputLimit int
+			Message     string
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Output, res.Truncated, res.OutputLimit, res.Message
+	}
+
+	// exactly at the limit: not truncated
+	out, tr, limit, _ := run(map[string]any{"projectID": p.ID, "cmd": "printf", "args": []string{"0123456789abcdef"}})
+	if out != "0123456789abcdef" || tr || limit != 16 {
+		t.Fatalf("at limit: out=%q truncated=%v limit=%d", out, tr, limit)
+	}
+	// one byte over: cut at the boundary
+	out, tr, _, msg := run(map[string]any{"projectID": p.ID, "cmd": "printf", "args": []string{"0123456789abcdefX"}})
+	if out != "0123456789abcdef" || !tr {
+		t.Fatalf("over limit: out=%q truncated=%v", out, tr)
+	}
+	if !strings.Contains(msg, "16 bytes") {
+		t.Fatalf("expected limit in message, got %q", msg)
+	}
+	// per-request override
+	out, tr, limit, _ = run(map[string]any{"projectID": p.ID, "cmd": "printf", "args": []string{"0123456789"}, "maxOutputBytes": 4})
+	if out != "0123" || !tr || limit != 4 {
+		t.Fatalf("request limit: out=%q truncated=%v limit=%d", out, tr, limit)
+	}
+	// a request cannot raise the operator's cap
+	out, tr, limit, _ = run(map[string]any{"projectID": p.ID, "cmd": "printf", "args": []string{"0123456789abcdefX"}, "maxOutputBytes": 1 << 30})
+	if out != "0123456789abcdef" || !tr || limit != 16 {
+		t.Fatalf("oversized request limit: out=%q truncated=%v limit=%d", out, tr, limit)
+	}
+}
+
+func TestShellExecConfiguredShell(t *testing.T) {
+	t.Setenv("MYCODER_SHELL", "/bin/sh")
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("sh", t.TempDir(), nil)
+	mux := api.mux()
+
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "cmd": "echo", "args": []string{"via-sh"}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("exec code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		ExitCode int
+		Output   string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 0 || !strings.Contains(res.Output, "via-sh") {
+		t.Fatalf("exit=%d output=%q", res.ExitCode, res.Output)
+	}
+
+	// a missing shell yields a clear error instead of a spawn failure exit code
+	t.Setenv("MYCODER_SHELL", "/nonexistent/shell")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "MYCODER_SHELL") {
+		t.Fatalf("expected 500 mentioning MYCODER_SHELL, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestShellExecEnvAllowList(t *testing.T) {
+	t.Setenv("MYCODER_SHELL_ENV_ALLOW", "NODE_ENV, PYTHONPATH")
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("sh", t.TempDir(), nil)
+	mux := api.mux()
+
+	body := map[string]any{
+		"projectID": p.ID,
+		"cmd":       "sh",
+		"args":      []string{"-c", "echo NODE_ENV=$NODE_ENV; echo SECRET=$MYCODER_TEST_SECRET"},
+		"env":       map[string]string{"NODE_ENV": "production", "MYCODER_TEST_SECRET": "leak"},
+	}
+	b, _ := json.Marshal(body)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("exec code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct{ Output string }
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Output, "NODE_ENV=production") {
+		t.Fatalf("expected whitelisted var to reach command: %q", res.Output)
+	}
+	if strings.Contains(res.Output, "leak") {
+		t.Fatalf("non-whitelisted var leaked: %q", res.Output)
+	}
+}
+
+func TestShellEnvAllowedProtectsPath(t *testing.T) {
+	t.Setenv("MYCODER_SHELL_ENV_ALLOW", "")
+	if a := shellEnvAllowed(); a["PATH"] || a["HOME"] || !a["GOFLAGS"] {
+		t.Fatalf("unexpected default whitelist: %v", a)
+	}
+	t.Setenv("MYCODER_SHELL_ENV_ALLOW", "PATH")
+	if a := shellEnvAllowed(); !a["PATH"] || a["HOME"] {
+		t.Fatalf("PATH should be allowed only when listed: %v", a)
+	}
+}
+
+func TestShellExecCwdOutsideProjectRejected(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	root := t.TempDir()
+	p := st.CreateProject("sh", root, nil)
+	mux := api.mux()
+
+	marker := filepath.Join(root, "ran.txt")
+	for _, path := range []string{"/shell/exec", "/shell/exec/stream"} {
+		body := map[string]any{"projectID": p.ID, "cmd": "touch", "args": []string{marker}, "cwd": "../../etc"}
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d body=%s", path, rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), "cwd outside project") {
+			t.Fatalf("%s: unexpected body %s", path, rr.Body.String())
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatalf("command should not have run at the project root")
+	}
+}
+
+func TestShellExecTimeoutReported(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("sh", t.TempDir(), nil)
+	mux := api.mux()
+
+	body := map[string]any{"projectID": p.ID, "cmd": "sleep", "args": []string{"5"}, "timeoutSec": 1}
+	b, _ := json.Marshal(body)
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("exec code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if el := time.Since(start); el > 4*time.Second {
+		t.Fatalf("timeout not enforced promptly: %v", el)
+	}
+	var res struct {
+		ExitCode int
+		TimedOut bool
+		Signal   string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || res.ExitCode != 124 {
+		t.Fatalf("expected timedOut with exit 124, got %+v", res)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec/stream", bytes.NewReader(b)))
+	out := rr.Body.String()
+	if !strings.Contains(out, "event: timeout") || !strings.Contains(out, "data: 124") {
+		t.Fatalf("expected timeout event and exit 124: %q", out)
+	}
+}
+
+func TestShellExecStdin(t *testing.T) {
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("sh", t.TempDir(), nil)
+	mux := api.mux()
+
+	body := map[string]any{"projectID": p.ID, "cmd": "cat", "stdin": "line one\nline two\n"}
+	b, _ := json.Marshal(body)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/shell/exec", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("exec code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		ExitCode int
+		Output   string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 0 || res.Output != "line one\nline two\n" {
+		t.Fatalf("expected stdin echoed, exit=%d output=%q", res.ExitCode, res.Output)
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"mycoder/internal/store"
 )
@@ -295,3 +298,220 @@ func TestToolsHooksEnvWhitelist(t *testing.T) {
 		t.Fatalf("expected GOFLAGS to contain -race, got: %s", res["show"].Output)
 	}
 }
+
+func TestToolsHooksNpmRunner(t *testing.T) {
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm not installed")
+	}
+	dir := t.TempDir()
+	pkg := `{"name":"hooks-demo","version":"1.0.0","scripts":{"check":"echo npm-check-ok"}}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(pkg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("npm", dir, nil)
+	mux := api.mux()
+
+	// runner is auto-detected from package.json
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "targets": []string{"check"}, "timeoutSec": 30})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tools/hooks", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/tools/hooks code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]struct {
+		Ok     bool
+		Output string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if !res["check"].Ok || !strings.Contains(res["check"].Output, "npm-check-ok") {
+		t.Fatalf("expected npm script output, got: %+v", res["check"])
+	}
+}
+
+func TestToolsHooksRawRunnerAndDetection(t *testing.T) {
+	dir := t.TempDir()
+	if got := detectHooksRunner(dir); got != "make" {
+		t.Fatalf("empty project should default to make, got %s", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "justfile"), []byte("x:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := detectHooksRunner(dir); got != "just" {
+		t.Fatalf("expected just, got %s", got)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("raw", dir, nil)
+	mux := api.mux()
+
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "targets": []string{"alpha"}, "runner": "raw", "command": "echo run-{target}"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tools/hooks", bytes.NewReader(body)))
+	var res map[string]struct {
+		Ok     bool
+		Output string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v body=%s", err, rr.Body.String())
+	}
+	if !res["alpha"].Ok || !strings.Contains(res["alpha"].Output, "run-alpha") {
+		t.Fatalf("expected raw template output, got: %+v", res["alpha"])
+	}
+}
+
+func TestToolsHooksParallelCollectsAll(t *testing.T) {
+	dir := t.TempDir()
+	mf := "a:\n\t@sleep 0.5; echo a-ok\n\n" +
+		"b:\n\t@sleep 0.5; echo b-fail; exit 1\n\n" +
+		"c:\n\t@sleep 0.5; echo c-ok\n"
+	if err := os.WriteFile(filepath.Join(dir, "Makefile"), []byte(mf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("par", dir, nil)
+	mux := api.mux()
+
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "targets": []string{"a", "b", "c"}, "parallel": true, "maxParallel": 3})
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tools/hooks", bytes.NewReader(body)))
+	elapsed := time.Since(start)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/tools/hooks code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]struct {
+		Ok     bool
+		Output string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	for _, tg := range []string{"a", "b", "c"} {
+		if _, ok := res[tg]; !ok {
+			t.Fatalf("missing result for %s: %+v", tg, res)
+		}
+	}
+	if !res["a"].Ok || res["b"].Ok || !res["c"].Ok {
+		t.Fatalf("unexpected ok flags: %+v", res)
+	}
+	sum := res["_summary"]
+	if sum.Ok || !strings.Contains(sum.Output, "targets=3 ok=2 fail=1") {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if elapsed > 1400*time.Millisecond {
+		t.Fatalf("targets did not run concurrently: %v", elapsed)
+	}
+}
+
+func TestToolsHooksCustomHintsFile(t *testing.T) {
+	dir := t.TempDir()
+	mf := "test:\n\t@echo 'WIDGET-E42: frobnicator misaligned' && exit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "Makefile"), []byte(mf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".mycoder"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hints := `{"WIDGET-E\\d+": {"suggestion": "run make realign", "reason": "widget-misaligned"}, "([": {"suggestion": "bad pattern is skipped"}}`
+	if err := os.WriteFile(filepath.Join(dir, ".mycoder", "hooks-hints.json"), []byte(hints), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("hints", dir, nil)
+	mux := api.mux()
+
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "targets": []string{"test"}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tools/hooks", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/tools/hooks code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res map[string]struct {
+		Ok         bool
+		Suggestion string
+		Reason     string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if res["test"].Suggestion != "run make realign" || res["test"].Reason != "widget-misaligned" {
+		t.Fatalf("expected custom hint to win over built-ins, got: %+v", res["test"])
+	}
+}
+
+func TestToolsHooksEnglishHints(t *testing.T) {
+	t.Setenv("MYCODER_HINT_LANG", "en")
+	dir := t.TempDir()
+	mf := "fmt-check:\n\t@echo 'files need formatting: a.go' && exit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "Makefile"), []byte(mf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New()
+	api := NewAPI(st, nil)
+	p := st.CreateProject("en", dir, nil)
+	mux := api.mux()
+
+	body, _ := json.Marshal(map[string]any{"projectID": p.ID, "targets": []string{"fmt-check"}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tools/hooks", bytes.NewReader(body)))
+	var res map[string]struct {
+		Suggestion string
+		Reason     string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if res["fmt-check"].Suggestion != "Apply formatting: make fmt" || res["fmt-check"].Reason != "fmt-mismatch" {
+		t.Fatalf("expected English fmt suggestion, got: %+v", res["fmt-check"])
+	}
+}
+
+func TestHintLangInferredFromLANG(t *testing.T) {
+	t.Setenv("MYCODER_HINT_LANG", "")
+	for lang, want := range map[string]string{"": "ko", "C.UTF-8": "ko", "ko_KR.UTF-8": "ko", "en_US.UTF-8": "en", "de_DE.UTF-8": "en"} {
+		t.Setenv("LANG", lang)
+		if got := hintLang(); got != want {
+			t.Fatalf("LANG=%q: hintLang=%s want %s", lang, got, want)
+		}
+	}
+	t.Setenv("LANG", "en_US.UTF-8")
+	if got := hintMsg("timeout"); got != "The command timed out. Try increasing --timeout." {
+		t.Fatalf("unexpected timeout message: %q", got)
+	}
+	// every built-in message has an English translation
+	for id := range hookHintMessages["ko"] {
+		if hookHintMessages["en"][id] == "" {
+			t.Fatalf("missing English message for %s", id)
+		}
+	}
+}
+
+func TestHooksMaxParallelClamp(t *testing.T) {
+	t.Setenv("MYCODER_HOOKS_MAX_PARALLEL", "")
+	ceiling := runtime.NumCPU()
+	if ceiling < 4 {
+		ceiling = 4
+	}
+	if n := hooksMaxParallel(0); n != 4 {
+		t.Fatalf("default=%d, want 4", n)
+	}
+	if n := hooksMaxParallel(2); n != 2 {
+		t.Fatalf("request below ceiling=%d, want 2", n)
+	}
+	if n := hooksMaxParallel(1 << 20); n != ceiling {
+		t.Fatalf("oversized request=%d, want %d", n, ceiling)
+	}
+	t.Setenv("MYCODER_HOOKS_MAX_PARALLEL", "3")
+	if n := hooksMaxParallel(1000); n != 3 {
+		t.Fatalf("request above env cap=%d, want 3", n)
+	}
+	if n := hooksMaxParallel(0); n != 3 {
+		t.Fatalf("env default=%d, want 3", n)
+	}
+}
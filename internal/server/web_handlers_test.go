@@ -3,10 +3,14 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"mycoder/internal/models"
 	"mycoder/internal/store"
 )
 
@@ -42,3 +46,188 @@ func TestWebSearchMockAndIngest(t *testing.T) {
 		t.Fatalf("expected 3 added, got %v", ir)
 	}
 }
+
+func TestWebSearchJSONProvider(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "go generics" || r.Header.Get("Authorization") != "Bearer k1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": []map[string]any{
+			{"title": "A", "url": "https://a.example/x", "snippet": "first", "score": 8},
+			{"title": "no url"},
+			{"title": "B", "url": "https://b.example/y", "snippet": "second", "score": 4},
+			{"title": "C", "url": "https://c.example/z", "snippet": "third", "score": 2},
+		}})
+	}))
+	defer backend.Close()
+	t.Setenv("MYCODER_WEB_SEARCH_PROVIDER", "json")
+	t.Setenv("MYCODER_WEB_SEARCH_URL", backend.URL)
+	t.Setenv("MYCODER_WEB_SEARCH_API_KEY", "k1")
+	mux := NewAPI(store.New(), nil).mux()
+	b, _ := json.Marshal(map[string]any{"query": "go generics", "limit": 2})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/web/search", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/web/search code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Provider string
+		Results  []webResult
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Provider != "json" || len(res.Results) != 2 {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+	if res.Results[0].URL != "https://a.example/x" || res.Results[0].Score != 1 || res.Results[1].Score != 0.5 {
+		t.Fatalf("unexpected results: %+v", res.Results)
+	}
+}
+
+func TestWebSearchSearXNGProviderAndErrors(t *testing.T) {
+	fail := false
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"results":[{"title":"S","url":"https://s.example","content":"snip"}]}`))
+	}))
+	defer backend.Close()
+	mux := NewAPI(store.New(), nil).mux()
+	search := func() *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]any{"query": "q"})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/web/search", bytes.NewReader(b)))
+		return rr
+	}
+	// unconfigured
+	if rr := search(); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without provider, got %d", rr.Code)
+	}
+	t.Setenv("MYCODER_WEB_SEARCH_PROVIDER", "searxng")
+	t.Setenv("MYCODER_WEB_SEARCH_URL", backend.URL+"/")
+	rr := search()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("searxng code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var res struct{ Results []webResult }
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res.Results) != 1 || res.Results[0].Snippet != "snip" || res.Results[0].Score != 1 {
+		t.Fatalf("unexpected results: %+v", res.Results)
+	}
+	fail = true
+	if rr := search(); rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 on backend failure, got %d", rr.Code)
+	}
+}
+
+func TestWebIngestDedupesAcrossCalls(t *testing.T) {
+	st := store.New()
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("web", "/tmp/web", nil)
+	ingest := func(results []map[string]any) map[string]int {
+		b, _ := json.Marshal(map[string]any{"projectID": p.ID, "results": results, "dedupe": true, "minScore": 0.5})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/web/ingest", bytes.NewReader(b)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("/web/ingest code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var out map[string]int
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return out
+	}
+	first := ingest([]map[string]any{
+		{"title": "Go", "url": "https://go.dev/doc", "score": 0.9},
+		{"title": "Low", "url": "https://low.example", "score": 0.1},
+	})
+	if first["added"] != 1 || first["skippedLowScore"] != 1 {
+		t.Fatalf("first ingest: %v", first)
+	}
+	second := ingest([]map[string]any{{"title": "Go again", "url": "https://GO.dev/doc", "score": 0.9}})
+	if second["added"] != 0 || second["skippedDuplicate"] != 1 {
+		t.Fatalf("second ingest should add nothing: %v", second)
+	}
+	ks, _ := st.ListKnowledge(p.ID, 0)
+	if len(ks) != 1 {
+		t.Fatalf("expected 1 knowledge row, got %d", len(ks))
+	}
+}
+
+// failingListStore fails knowledge listing while writes still succeed.
+type failingListStore struct {
+	*store.Store
+}
+
+func (failingListStore) ListKnowledge(string, float64) ([]*models.Knowledge, error) {
+	return nil, errors.New("list failed")
+}
+
+func TestWebIngestDedupeReportsListError(t *testing.T) {
+	st := failingListStore{store.New()}
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("web", "/tmp/web", nil)
+	b, _ := json.Marshal(map[string]any{"projectID": p.ID, "dedupe": true,
+		"results": []map[string]any{{"title": "Go", "url": "https://go.dev/doc", "score": 0.9}}})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/web/ingest", bytes.NewReader(b)))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when dedupe cannot list knowledge, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	if ks, _ := st.Store.ListKnowledge(p.ID, 0); len(ks) != 0 {
+		t.Fatalf("expected nothing ingested, got %d rows", len(ks))
+	}
+}
+
+func TestKnowledgeListTagFilter(t *testing.T) {
+	st, err := store.NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewAPI(st, nil).mux()
+	p := st.CreateProject("web", t.TempDir(), nil)
+	ing := map[string]any{"projectID": p.ID, "query": "go", "summarize": true, "results": []map[string]any{
+		{"title": "Go", "url": "https://go.dev/doc", "snippet": "docs", "score": 0.9},
+	}}
+	b, _ := json.Marshal(ing)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/web/ingest", bytes.NewReader(b)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/web/ingest code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	list := func(query string) []map[string]any {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge?projectID="+p.ID+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("list code=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var res struct{ Knowledge []map[string]any }
+		_ = json.Unmarshal(rr.Body.Bytes(), &res)
+		return res.Knowledge
+	}
+	if all := list(""); len(all) != 2 {
+		t.Fatalf("expected result + summary, got %d", len(all))
+	}
+	sums := list("&tag=kind:summary")
+	if len(sums) != 1 || sums[0]["title"] != "Web Summary: go" {
+		t.Fatalf("unexpected summary filter result: %v", sums)
+	}
+	if tags, _ := sums[0]["tags"].(string); !strings.Contains(tags, `"kind":"summary"`) {
+		t.Fatalf("expected tags in list output, got %v", sums[0]["tags"])
+	}
+	if got := list("&tag=domain:go.dev"); len(got) != 1 || got[0]["pathOrURL"] != "https://go.dev/doc" {
+		t.Fatalf("unexpected domain filter result: %v", got)
+	}
+	if got := list("&tag=kind:summary&tag=domain:go.dev"); len(got) != 0 {
+		t.Fatalf("expected no item matching both tags, got %v", got)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/knowledge?projectID="+p.ID+"&tag=bad", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed tag, got %d", rr.Code)
+	}
+}
@@ -13,7 +13,7 @@ import (
 // Manager handles schema versioning and basic seeding.
 type Manager struct{}
 
-const latestVersion = 3
+const latestVersion = 7
 
 func (m Manager) ensureTable(ctx context.Context, db *sql.DB) error {
 	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL);`)
@@ -158,13 +158,100 @@ func (m Manager) up(ctx context.Context, db *sql.DB, v int) error {
 			}
 		}
 		return nil
+	case 4:
+		// knowledge approval audit: last approver on the row plus an append-only log
+		for _, s := range []string{
+			`ALTER TABLE knowledge ADD COLUMN approved_by TEXT`,
+			`ALTER TABLE knowledge ADD COLUMN approved_at TEXT`,
+		} {
+			// columns survive a v4 rollback, so re-adding them may fail harmlessly
+			_, _ = db.ExecContext(ctx, s)
+		}
+		stmts := []string{
+			`CREATE TABLE IF NOT EXISTS knowledge_approvals (
+                id TEXT PRIMARY KEY,
+                project_id TEXT NOT NULL,
+                knowledge_id TEXT NOT NULL,
+                title TEXT,
+                approved_by TEXT,
+                approved_at TEXT NOT NULL,
+                FOREIGN KEY(project_id) REFERENCES projects(id)
+            );`,
+			`CREATE INDEX IF NOT EXISTS idx_knowledge_approvals_project ON knowledge_approvals(project_id, approved_at);`,
+		}
+		for i, s := range stmts {
+			if _, err := db.ExecContext(ctx, s); err != nil {
+				return fmt.Errorf("v4 step %d: %w", i, err)
+			}
+		}
+		return nil
+	case 5:
+		// cumulative metrics counters persisted across restarts (MYCODER_METRICS_PERSIST)
+		_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS metric_counters (
+            name TEXT PRIMARY KEY,
+            value REAL NOT NULL,
+            updated_at TEXT NOT NULL
+        );`)
+		if err != nil {
+			return fmt.Errorf("v5: %w", err)
+		}
+		return nil
+	case 6:
+		// per-project index ignore globs (JSON array), merged into exclude on index;
+		// the column survives a v6 rollback, so it is only added when missing
+		if err := addColumnIfMissing(ctx, db, "projects", "ignore_globs", "TEXT"); err != nil {
+			return fmt.Errorf("v6: %w", err)
+		}
+		return nil
+	case 7:
+		// capped MCP tool-call audit history (GET /mcp/calls)
+		_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS mcp_calls (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            tool TEXT NOT NULL,
+            params TEXT,
+            outcome TEXT NOT NULL,
+            error TEXT,
+            status INTEGER NOT NULL,
+            duration_ms INTEGER NOT NULL,
+            called_at TEXT NOT NULL
+        );`)
+		if err != nil {
+			return fmt.Errorf("v7: %w", err)
+		}
+		return nil
 	default:
 		return fmt.Errorf("unknown migration version %d", v)
 	}
 }
 
+// addColumnIfMissing adds table.column unless pragma_table_info already lists it.
+func addColumnIfMissing(ctx context.Context, db *sql.DB, table, column, typ string) error {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(1) FROM pragma_table_info(?) WHERE name=?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, typ))
+	return err
+}
+
 func (m Manager) down(ctx context.Context, db *sql.DB, v int) error {
 	switch v {
+	case 7:
+		_, _ = db.ExecContext(ctx, `DROP TABLE IF EXISTS mcp_calls;`)
+		return nil
+	case 6:
+		// ignore_globs stays (SQLite column drop needs a rebuild)
+		return nil
+	case 5:
+		_, _ = db.ExecContext(ctx, `DROP TABLE IF EXISTS metric_counters;`)
+		return nil
+	case 4:
+		// approved_by/approved_at columns stay (SQLite column drop needs a rebuild)
+		_, _ = db.ExecContext(ctx, `DROP TABLE IF EXISTS knowledge_approvals;`)
+		return nil
 	case 3:
 		// drop additive tables
 		stmts := []string{
@@ -31,8 +31,8 @@ func TestMigrationsVersioningAndTables(t *testing.T) {
 		t.Fatalf("unexpected version: %d", v)
 	}
 
-	// ensure v3 tables exist (embeddings/symbols/patches) by querying sqlite_master
-	mustHave := []string{"embeddings", "symbols", "patches"}
+	// ensure v3-v7 tables exist (embeddings/symbols/patches/knowledge_approvals/metric_counters/mcp_calls) by querying sqlite_master
+	mustHave := []string{"embeddings", "symbols", "patches", "knowledge_approvals", "metric_counters", "mcp_calls"}
 	for _, name := range mustHave {
 		var cnt int
 		if err := db.QueryRow(`SELECT COUNT(1) FROM sqlite_master WHERE type='table' AND name=?`, name).Scan(&cnt); err != nil || cnt == 0 {
@@ -40,9 +40,37 @@ func TestMigrationsVersioningAndTables(t *testing.T) {
 		}
 	}
 
+	// v6 adds projects.ignore_globs
+	var cols int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM pragma_table_info('projects') WHERE name='ignore_globs'`).Scan(&cols); err != nil || cols != 1 {
+		t.Fatalf("expected projects.ignore_globs column, err=%v", err)
+	}
+
 	// down one (if possible) then back up
 	_ = m.DownOne(context.Background(), db)
 	if err := m.UpToLatest(context.Background(), db); err != nil {
 		t.Fatalf("UpToLatest after down error: %v", err)
 	}
 }
+
+func TestAddColumnIfMissing(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "cols.db"))
+	if err != nil {
+		t.Skip("sqlite open:", err)
+	}
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE t (id TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	// adding twice is a no-op the second time
+	for i := 0; i < 2; i++ {
+		if err := addColumnIfMissing(ctx, db, "t", "extra", "TEXT"); err != nil {
+			t.Fatalf("add #%d: %v", i, err)
+		}
+	}
+	// real failures are reported instead of being treated as already migrated
+	_ = db.Close()
+	if err := addColumnIfMissing(ctx, db, "t", "other", "TEXT"); err == nil {
+		t.Fatal("expected an error on a closed database")
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"mycoder/internal/models"
+)
+
+type jobStore interface {
+	CreateProject(name, root string, ignore []string) *models.Project
+	CreateIndexJob(projectID string, mode models.IndexMode) (*models.IndexJob, error)
+	SetJobStatus(id string, st models.IndexJobStatus, stats map[string]int) (*models.IndexJob, error)
+	SetJobError(id, msg string, stats map[string]int) (*models.IndexJob, error)
+	GetJob(id string) (*models.IndexJob, bool)
+}
+
+func TestJobSnapshotsAndFailedError(t *testing.T) {
+	sq, err := NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sq.DB().Close()
+	for name, st := range map[string]jobStore{"mem": New(), "sqlite": sq} {
+		p := st.CreateProject("jobs", t.TempDir(), nil)
+		j, err := st.CreateIndexJob(p.ID, models.IndexFull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a poller must never observe failed without its error
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if g, _ := st.GetJob(j.ID); g.Status == models.JobFailed {
+					if g.Error == "" {
+						t.Errorf("%s: failed job without error", name)
+					}
+					return
+				}
+			}
+		}()
+		_, _ = st.SetJobStatus(j.ID, models.JobRunning, nil)
+		_, _ = st.SetJobError(j.ID, "boom", map[string]int{"errors": 1})
+		wg.Wait()
+
+		got, _ := st.GetJob(j.ID)
+		if got.Status != models.JobFailed || got.Error != "boom" || got.EndedAt == nil {
+			t.Fatalf("%s: job=%+v", name, got)
+		}
+		// snapshots do not alias the stored job
+		got.Status = models.JobRunning
+		got.Stats["errors"] = 99
+		again, _ := st.GetJob(j.ID)
+		if again.Status != models.JobFailed || again.Stats["errors"] != 1 {
+			t.Fatalf("%s: stored job changed through snapshot: %+v", name, again)
+		}
+	}
+}
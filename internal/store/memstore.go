@@ -3,6 +3,7 @@ package store
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -20,6 +21,9 @@ type Store struct {
 	seq      int64
 	// knowledge minimal in-memory
 	knowledge []*models.Knowledge
+	approvals []*models.KnowledgeApproval
+	mcpCalls  []*models.MCPCall
+	mcpSeq    int64 // MCP call IDs, kept apart from seq
 }
 
 func New() *Store {
@@ -74,10 +78,37 @@ func (s *Store) CreateIndexJob(projectID string, mode models.IndexMode) (*models
 	id := s.nextID("job")
 	j := &models.IndexJob{ID: id, ProjectID: projectID, Mode: mode, Status: models.JobPending, StartedAt: time.Now()}
 	s.jobs[id] = j
-	return j, nil
+	return cloneJob(j), nil
+}
+
+// cloneJob copies j, including its stats and end time, for handing out.
+func cloneJob(j *models.IndexJob) *models.IndexJob {
+	c := *j
+	if j.EndedAt != nil {
+		t := *j.EndedAt
+		c.EndedAt = &t
+	}
+	if j.Stats != nil {
+		c.Stats = make(map[string]int, len(j.Stats))
+		for k, v := range j.Stats {
+			c.Stats[k] = v
+		}
+	}
+	return &c
 }
 
 func (s *Store) SetJobStatus(id string, st models.IndexJobStatus, stats map[string]int) (*models.IndexJob, error) {
+	return s.setJob(id, st, "", stats)
+}
+
+// SetJobError marks a job failed and records the error message with its stats.
+func (s *Store) SetJobError(id, msg string, stats map[string]int) (*models.IndexJob, error) {
+	return s.setJob(id, models.JobFailed, msg, stats)
+}
+
+// setJob updates status, error, end time and stats in one critical section so
+// readers never see a failed job without its error.
+func (s *Store) setJob(id string, st models.IndexJobStatus, msg string, stats map[string]int) (*models.IndexJob, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	j, ok := s.jobs[id]
@@ -85,21 +116,26 @@ func (s *Store) SetJobStatus(id string, st models.IndexJobStatus, stats map[stri
 		return nil, errors.New("job not found")
 	}
 	j.Status = st
-	if st == models.JobCompleted || st == models.JobFailed {
+	j.Error = msg
+	if st == models.JobCompleted || st == models.JobFailed || st == models.JobCanceled {
 		now := time.Now()
 		j.EndedAt = &now
 	}
 	if stats != nil {
 		j.Stats = stats
 	}
-	return j, nil
+	return cloneJob(j), nil
 }
 
+// GetJob returns a snapshot of the job; later updates do not show through it.
 func (s *Store) GetJob(id string) (*models.IndexJob, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	j, ok := s.jobs[id]
-	return j, ok
+	if !ok {
+		return nil, false
+	}
+	return cloneJob(j), true
 }
 
 // Documents (for in-memory search/demo)
@@ -119,7 +155,7 @@ func (s *Store) AddDocument(projectID, path, content string) *models.Document {
 	return d
 }
 
-func (s *Store) Search(projectID, query string, k int) []models.SearchResult {
+func (s *Store) Search(projectID, query string, k int) ([]models.SearchResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	type scored struct{ res models.SearchResult }
@@ -175,7 +211,29 @@ func (s *Store) Search(projectID, query string, k int) []models.SearchResult {
 	for i := 0; i < k; i++ {
 		res = append(res, out[i].res)
 	}
-	return res
+	return res, nil
+}
+
+// SearchRegexp scans document contents with re, ranking by match count.
+func (s *Store) SearchRegexp(projectID string, re *regexp.Regexp, k int) []models.SearchResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.SearchResult
+	for _, d := range s.docs {
+		if projectID != "" && d.ProjectID != projectID {
+			continue
+		}
+		res, ok := scanText(re, d.Content, 1)
+		if !ok {
+			continue
+		}
+		res.Path = d.Path
+		out = append(out, res)
+		if len(out) >= scanMaxHits {
+			break
+		}
+	}
+	return rankScan(out, k)
 }
 
 // Incremental helpers (sha/lang ignored)
@@ -202,6 +260,35 @@ func (s *Store) PruneDocuments(projectID string, present []string) error {
 	return nil
 }
 
+// IndexStats counts a project's documents. The in-memory store searches whole
+// documents, so each one counts as a single chunk.
+func (s *Store) IndexStats(projectID string) (models.IndexStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var st models.IndexStats
+	for _, d := range s.docs {
+		if d.ProjectID != projectID {
+			continue
+		}
+		st.Documents++
+		st.Bytes += int64(len(d.Content))
+	}
+	st.Chunks = st.Documents
+	return st, nil
+}
+
+// DeleteDocument removes a single document; missing paths are not an error.
+func (s *Store) DeleteDocument(projectID, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := projectID + ":" + path
+	if id, ok := s.byPath[key]; ok {
+		delete(s.byPath, key)
+		delete(s.docs, id)
+	}
+	return nil
+}
+
 func (s *Store) Stats() map[string]int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -229,12 +316,76 @@ func (s *Store) ListKnowledge(projectID string, minScore float64) ([]*models.Kno
 	var out []*models.Knowledge
 	for _, k := range s.knowledge {
 		if k.ProjectID == projectID && k.TrustScore >= minScore {
-			out = append(out, k)
+			c := *k
+			out = append(out, &c)
 		}
 	}
 	return out, nil
 }
 
+// GetKnowledge returns a copy of one knowledge item by ID.
+func (s *Store) GetKnowledge(id string) (*models.Knowledge, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.knowledge {
+		if k.ID == id {
+			c := *k
+			return &c, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// ImportKnowledge appends copies of items, each to its own ProjectID, under fresh IDs.
+func (s *Store) ImportKnowledge(items []*models.Knowledge) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range items {
+		c := *k
+		c.ID = s.nextID("kn")
+		s.knowledge = append(s.knowledge, &c)
+	}
+	return len(items), nil
+}
+
+// UpdateKnowledge applies the non-nil fields of u; false when the item does not exist.
+func (s *Store) UpdateKnowledge(id string, u models.KnowledgeUpdate) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.knowledge {
+		if k.ID != id {
+			continue
+		}
+		if u.Title != nil {
+			k.Title = *u.Title
+		}
+		if u.Text != nil {
+			k.Text = *u.Text
+		}
+		if u.TrustScore != nil {
+			k.TrustScore = *u.TrustScore
+		}
+		if u.Pinned != nil {
+			k.Pinned = *u.Pinned
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// DeleteKnowledge removes one knowledge item by ID; false when it did not exist.
+func (s *Store) DeleteKnowledge(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.knowledge {
+		if k.ID == id {
+			s.knowledge = append(s.knowledge[:i], s.knowledge[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (s *Store) VetKnowledge(projectID string) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -286,13 +437,14 @@ func (s *Store) GCKnowledge(projectID string, minScore float64) (int, error) {
 	return removed, nil
 }
 
-func (s *Store) ApproveKnowledge(projectID string, ids []string, pin bool, minTrust float64) (int, error) {
+func (s *Store) ApproveKnowledge(projectID string, ids []string, pin bool, minTrust float64, approvedBy string) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	idset := map[string]struct{}{}
 	for _, id := range ids {
 		idset[id] = struct{}{}
 	}
+	now := time.Now().Format(time.RFC3339)
 	n := 0
 	for _, k := range s.knowledge {
 		if k.ProjectID != projectID {
@@ -305,8 +457,63 @@ func (s *Store) ApproveKnowledge(projectID string, ids []string, pin bool, minTr
 			if k.TrustScore < minTrust {
 				k.TrustScore = minTrust
 			}
+			k.ApprovedBy, k.ApprovedAt = approvedBy, now
+			s.approvals = append(s.approvals, &models.KnowledgeApproval{ID: s.nextID("ka"), ProjectID: projectID, KnowledgeID: k.ID, Title: k.Title, ApprovedBy: approvedBy, ApprovedAt: now})
 			n++
 		}
 	}
 	return n, nil
 }
+
+// ListKnowledgeApprovals returns the project's approval log, newest first.
+func (s *Store) ListKnowledgeApprovals(projectID string) ([]*models.KnowledgeApproval, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []*models.KnowledgeApproval
+	for i := len(s.approvals) - 1; i >= 0; i-- {
+		if s.approvals[i].ProjectID == projectID {
+			out = append(out, s.approvals[i])
+		}
+	}
+	return out, nil
+}
+
+// RecordMCPCall appends a copy of c to the MCP call history, keeping the
+// newest keep entries, and sets c.ID.
+func (s *Store) RecordMCPCall(c *models.MCPCall, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mcpSeq++
+	c.ID = s.mcpSeq
+	s.mcpCalls = append(s.mcpCalls, cloneMCPCall(c))
+	if keep > 0 && len(s.mcpCalls) > keep {
+		s.mcpCalls = append([]*models.MCPCall(nil), s.mcpCalls[len(s.mcpCalls)-keep:]...)
+	}
+	return nil
+}
+
+// ListMCPCalls returns up to limit recorded MCP calls, newest first, optionally
+// filtered by tool.
+func (s *Store) ListMCPCalls(tool string, limit int) ([]*models.MCPCall, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []*models.MCPCall{}
+	for i := len(s.mcpCalls) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if tool == "" || s.mcpCalls[i].Tool == tool {
+			out = append(out, cloneMCPCall(s.mcpCalls[i]))
+		}
+	}
+	return out, nil
+}
+
+// cloneMCPCall copies c, including its params, so stored calls are not shared.
+func cloneMCPCall(c *models.MCPCall) *models.MCPCall {
+	cp := *c
+	if c.Params != nil {
+		cp.Params = make(map[string]string, len(c.Params))
+		for k, v := range c.Params {
+			cp.Params[k] = v
+		}
+	}
+	return &cp
+}
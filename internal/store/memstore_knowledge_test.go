@@ -0,0 +1,27 @@
+package store
+
+import "testing"
+
+func TestMemKnowledgeGettersReturnCopies(t *testing.T) {
+	s := New()
+	p := s.CreateProject("p", t.TempDir(), nil)
+	k, err := s.AddKnowledge(p.ID, "doc", "a.md", "title", "text", 0.6, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, _ := s.GetKnowledge(k.ID)
+	if !ok {
+		t.Fatal("knowledge not found")
+	}
+	got.TrustScore = 0.1
+	got.Pinned = true
+	list, _ := s.ListKnowledge(p.ID, 0)
+	if len(list) != 1 {
+		t.Fatalf("list=%+v", list)
+	}
+	list[0].Text = "changed"
+	again, _, _ := s.GetKnowledge(k.ID)
+	if again.TrustScore != 0.6 || again.Pinned || again.Text != "text" {
+		t.Fatalf("stored item changed through a returned pointer: %+v", again)
+	}
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"mycoder/internal/models"
+)
+
+func TestMemMCPCallsAreCopiesWithOwnIDs(t *testing.T) {
+	s := New()
+	c := &models.MCPCall{Tool: "search", Params: map[string]string{"q": "x"}, Outcome: "ok"}
+	if err := s.RecordMCPCall(c, 10); err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != 1 {
+		t.Fatalf("first call ID=%d, want 1", c.ID)
+	}
+	// the caller's record and listed records do not alias the stored one
+	c.Outcome = "changed"
+	list, _ := s.ListMCPCalls("", 0)
+	list[0].Params["q"] = "mutated"
+	list[0].Tool = "mutated"
+	again, _ := s.ListMCPCalls("", 0)
+	if got := again[0]; got.Tool != "search" || got.Outcome != "ok" || got.Params["q"] != "x" {
+		t.Fatalf("stored call was mutated: %+v", got)
+	}
+
+	// MCP call IDs do not consume the shared ID sequence
+	p := s.CreateProject("p", t.TempDir(), nil)
+	if p.ID != "proj-1" {
+		t.Fatalf("project ID=%s, want proj-1", p.ID)
+	}
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"mycoder/internal/models"
+)
+
+// scanMaxHits bounds how many matching chunks a pattern scan collects before
+// ranking, so a broad regex cannot build an unbounded result set.
+const scanMaxHits = 1000
+
+// scanText scores text by match count and locates the first match.
+// line is offset by startLine (the 1-based line text begins at).
+func scanText(re *regexp.Regexp, text string, startLine int) (models.SearchResult, bool) {
+	locs := re.FindAllStringIndex(text, 100)
+	if len(locs) == 0 {
+		return models.SearchResult{}, false
+	}
+	if startLine <= 0 {
+		startLine = 1
+	}
+	first := locs[0][0]
+	line := startLine + strings.Count(text[:first], "\n")
+	ls := strings.LastIndexByte(text[:first], '\n') + 1
+	le := strings.IndexByte(text[first:], '\n')
+	if le < 0 {
+		le = len(text)
+	} else {
+		le += first
+	}
+	return models.SearchResult{
+		Score:     float64(len(locs)),
+		Preview:   strings.TrimSpace(text[ls:le]),
+		StartLine: line,
+		EndLine:   line,
+	}, true
+}
+
+// rankScan orders scan hits by score, then path and line, and keeps the top k.
+func rankScan(out []models.SearchResult, k int) []models.SearchResult {
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].StartLine < out[j].StartLine
+	})
+	if k > 0 && len(out) > k {
+		out = out[:k]
+	}
+	return out
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,17 +13,21 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	_ "modernc.org/sqlite"
 
+	"mycoder/internal/config"
+	mylog "mycoder/internal/log"
 	"mycoder/internal/models"
 	sqlm "mycoder/internal/storage/sqlite"
 )
 
 type SQLiteStore struct {
-	db  *sql.DB
-	mu  sync.Mutex
-	seq int64
+	db   *sql.DB
+	path string
+	mu   sync.Mutex
+	seq  int64
 	// jobs kept in memory for now
 	jobs map[string]*models.IndexJob
 }
@@ -33,18 +39,34 @@ func NewSQLite(path string) (*SQLiteStore, error) {
 	if err := os.MkdirAll(dirOf(path), 0o755); err != nil {
 		return nil, err
 	}
-	db, err := sql.Open("sqlite", path)
+	db, err := sql.Open("sqlite", sqliteDSN(path))
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(1)
+	// WAL lets readers proceed during a write; immediate transactions plus the
+	// busy timeout queue writers instead of failing with "database is locked".
+	db.SetMaxOpenConns(sqliteMaxConns)
 	// migration manager with versioning
 	if err := (sqlm.Manager{}).UpToLatest(context.Background(), db); err != nil {
 		return nil, err
 	}
 	// optional seed data
 	_ = (sqlm.Manager{}).Seed(context.Background(), db)
-	return &SQLiteStore{db: db, jobs: make(map[string]*models.IndexJob)}, nil
+	return &SQLiteStore{db: db, path: path, jobs: make(map[string]*models.IndexJob)}, nil
+}
+
+// sqliteMaxConns bounds the pool: one writer at a time plus concurrent readers.
+const sqliteMaxConns = 4
+
+// sqliteDSN applies per-connection pragmas: WAL journal, busy timeout
+// (MYCODER_SQLITE_BUSY_TIMEOUT_MS, default 5000) and BEGIN IMMEDIATE so
+// read-then-write transactions take the write lock up front.
+func sqliteDSN(path string) string {
+	busy := config.GetInt("MYCODER_SQLITE_BUSY_TIMEOUT_MS", 5000)
+	if busy < 0 {
+		busy = 0
+	}
+	return fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_txlock=immediate", path, busy)
 }
 
 func dirOf(path string) string {
@@ -60,6 +82,9 @@ func dirOf(path string) string {
 // Not part of the generic Store interface; use sparingly.
 func (s *SQLiteStore) DB() *sql.DB { return s.db }
 
+// Path returns the database file path the store was opened with.
+func (s *SQLiteStore) Path() string { return s.path }
+
 // WithTx provides a simple transaction wrapper that commits on nil error
 // and rolls back on error. The callback must not hold the tx beyond return.
 func (s *SQLiteStore) WithTx(fn func(*sql.Tx) error) error {
@@ -81,10 +106,18 @@ func (s *SQLiteStore) nextID(prefix string) string {
 	return fmt.Sprintf("%s-%d", prefix, s.seq)
 }
 
+// uniqueID returns prefix plus random hex. Unlike nextID it keeps no
+// in-process state, so rows written after a restart cannot reuse an ID.
+func uniqueID(prefix string) string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return prefix + "-" + hex.EncodeToString(b[:])
+}
+
 // Projects
 func (s *SQLiteStore) CreateProject(name, root string, ignore []string) *models.Project {
 	id := s.nextID("proj")
-	_, _ = s.db.Exec(`INSERT INTO projects(id,name,root_path,created_at) VALUES(?,?,?,?)`, id, name, root, time.Now().Format(time.RFC3339))
+	_, _ = s.db.Exec(`INSERT INTO projects(id,name,root_path,ignore_globs,created_at) VALUES(?,?,?,?,?)`, id, name, root, encodeIgnore(ignore), time.Now().Format(time.RFC3339))
 	return &models.Project{ID: id, Name: name, RootPath: root, Ignore: ignore, Created: time.Now()}
 }
 
@@ -155,7 +188,7 @@ func (s *SQLiteStore) ListExecutionLogs(runID string) ([]*models.ExecutionLog, e
 }
 
 func (s *SQLiteStore) ListProjects() []*models.Project {
-	rows, err := s.db.Query(`SELECT id,name,root_path,created_at FROM projects ORDER BY created_at DESC`)
+	rows, err := s.db.Query(`SELECT id,name,root_path,ignore_globs,created_at FROM projects ORDER BY created_at DESC`)
 	if err != nil {
 		return nil
 	}
@@ -163,11 +196,13 @@ func (s *SQLiteStore) ListProjects() []*models.Project {
 	var out []*models.Project
 	for rows.Next() {
 		var p models.Project
+		var ignore sql.NullString
 		var created string
-		if err := rows.Scan(&p.ID, &p.Name, &p.RootPath, &created); err == nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.RootPath, &ignore, &created); err == nil {
 			if t, _ := time.Parse(time.RFC3339, created); !t.IsZero() {
 				p.Created = t
 			}
+			p.Ignore = decodeIgnore(ignore.String)
 			out = append(out, &p)
 		}
 	}
@@ -175,18 +210,38 @@ func (s *SQLiteStore) ListProjects() []*models.Project {
 }
 
 func (s *SQLiteStore) GetProject(id string) (*models.Project, bool) {
-	row := s.db.QueryRow(`SELECT id,name,root_path,created_at FROM projects WHERE id=?`, id)
+	row := s.db.QueryRow(`SELECT id,name,root_path,ignore_globs,created_at FROM projects WHERE id=?`, id)
 	var p models.Project
+	var ignore sql.NullString
 	var created string
-	if err := row.Scan(&p.ID, &p.Name, &p.RootPath, &created); err != nil {
+	if err := row.Scan(&p.ID, &p.Name, &p.RootPath, &ignore, &created); err != nil {
 		return nil, false
 	}
 	if t, _ := time.Parse(time.RFC3339, created); !t.IsZero() {
 		p.Created = t
 	}
+	p.Ignore = decodeIgnore(ignore.String)
 	return &p, true
 }
 
+// encodeIgnore stores ignore globs as a JSON array; an empty list is NULL.
+func encodeIgnore(globs []string) any {
+	if len(globs) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(globs)
+	return string(b)
+}
+
+func decodeIgnore(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var globs []string
+	_ = json.Unmarshal([]byte(s), &globs)
+	return globs
+}
+
 // UpdateProjectName renames a project.
 func (s *SQLiteStore) UpdateProjectName(id, name string) error {
 	_, err := s.db.Exec(`UPDATE projects SET name=? WHERE id=?`, name, id)
@@ -237,10 +292,21 @@ func (s *SQLiteStore) CreateIndexJob(projectID string, mode models.IndexMode) (*
 	s.mu.Lock()
 	s.jobs[id] = j
 	s.mu.Unlock()
-	return j, nil
+	return cloneJob(j), nil
 }
 
 func (s *SQLiteStore) SetJobStatus(id string, st models.IndexJobStatus, stats map[string]int) (*models.IndexJob, error) {
+	return s.setJob(id, st, "", stats)
+}
+
+// SetJobError marks a job failed and records the error message with its stats.
+func (s *SQLiteStore) SetJobError(id, msg string, stats map[string]int) (*models.IndexJob, error) {
+	return s.setJob(id, models.JobFailed, msg, stats)
+}
+
+// setJob updates status, error, end time and stats in one critical section so
+// readers never see a failed job without its error.
+func (s *SQLiteStore) setJob(id string, st models.IndexJobStatus, msg string, stats map[string]int) (*models.IndexJob, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	j, ok := s.jobs[id]
@@ -248,21 +314,26 @@ func (s *SQLiteStore) SetJobStatus(id string, st models.IndexJobStatus, stats ma
 		return nil, errors.New("job not found")
 	}
 	j.Status = st
-	if st == models.JobCompleted || st == models.JobFailed {
+	j.Error = msg
+	if st == models.JobCompleted || st == models.JobFailed || st == models.JobCanceled {
 		now := time.Now()
 		j.EndedAt = &now
 	}
 	if stats != nil {
 		j.Stats = stats
 	}
-	return j, nil
+	return cloneJob(j), nil
 }
 
+// GetJob returns a snapshot of the job; later updates do not show through it.
 func (s *SQLiteStore) GetJob(id string) (*models.IndexJob, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	j, ok := s.jobs[id]
-	return j, ok
+	if !ok {
+		return nil, false
+	}
+	return cloneJob(j), true
 }
 
 // Documents / FTS5
@@ -289,63 +360,105 @@ func (s *SQLiteStore) AddDocument(projectID, path, content string) *models.Docum
 
 // IncrementalStore implementation
 func (s *SQLiteStore) UpsertDocument(projectID, path, content, sha, lang, mtime string) *models.Document {
-	tx, err := s.db.Begin()
+	var doc *models.Document
+	err := s.WithTx(func(tx *sql.Tx) error {
+		var err error
+		doc, err = s.upsertDocumentTx(tx, projectID, Doc{Path: path, Content: content, SHA: sha, Lang: lang, MTime: mtime})
+		return err
+	})
 	if err != nil {
 		return &models.Document{ID: "", ProjectID: projectID, Path: path}
 	}
-	defer tx.Rollback()
+	return doc
+}
+
+// Doc is one file for UpsertDocuments.
+type Doc struct {
+	Path    string
+	Content string
+	SHA     string
+	Lang    string
+	MTime   string
+}
 
+// upsertBatchSize is how many documents UpsertDocuments writes per transaction.
+const upsertBatchSize = 200
+
+// UpsertDocuments is the batch form of UpsertDocument: files are written in
+// transactions of up to upsertBatchSize, so indexing pays one commit (fsync)
+// per batch instead of per file. Documents are returned in input order; on
+// error, those from already committed batches are returned with it.
+func (s *SQLiteStore) UpsertDocuments(projectID string, docs []Doc) ([]*models.Document, error) {
+	out := make([]*models.Document, 0, len(docs))
+	for start := 0; start < len(docs); start += upsertBatchSize {
+		end := min(start+upsertBatchSize, len(docs))
+		err := s.WithTx(func(tx *sql.Tx) error {
+			for _, d := range docs[start:end] {
+				doc, err := s.upsertDocumentTx(tx, projectID, d)
+				if err != nil {
+					return fmt.Errorf("upsert %s: %w", d.Path, err)
+				}
+				out = append(out, doc)
+			}
+			return nil
+		})
+		if err != nil {
+			return out[:start], err
+		}
+	}
+	return out, nil
+}
+
+// upsertDocumentTx inserts or re-chunks one document inside tx, skipping
+// documents whose sha or mtime is unchanged.
+func (s *SQLiteStore) upsertDocumentTx(tx *sql.Tx, projectID string, d Doc) (*models.Document, error) {
 	// lookup existing document
 	var existingID, existingSHA string
 	var existingMTime string
-	_ = tx.QueryRow(`SELECT id, sha, mtime FROM documents WHERE project_id=? AND path=?`, projectID, path).Scan(&existingID, &existingSHA, &existingMTime)
+	_ = tx.QueryRow(`SELECT id, sha, mtime FROM documents WHERE project_id=? AND path=?`, projectID, d.Path).Scan(&existingID, &existingSHA, &existingMTime)
 	now := time.Now().Format(time.RFC3339)
+	id := existingID
 	if existingID == "" {
 		// insert new document
-		id := s.nextID("doc")
-		_, _ = tx.Exec(`INSERT INTO documents(id,project_id,path,sha,lang,mtime,created_at,updated_at) VALUES(?,?,?,?,?,?,?,?)`, id, projectID, path, sha, lang, mtime, now, now)
-		// index chunks (prefer code-aware when lang known)
-		var chunks []chunk
-		if lang == "go" || lang == "ts" || lang == "js" || lang == "py" {
-			chunks = chunkSmartWithLines(content, lang, 2000)
-		} else if lang == "md" || lang == "txt" {
-			chunks = chunkDocWithLines(content, 2000)
-		} else {
-			chunks = chunkTextWithLines(content, 2000)
-		}
-		for i, ch := range chunks {
-			chkID := s.nextID("chk")
-			_, _ = tx.Exec(`INSERT INTO chunks(id,doc_id,ord,text,token_count,start_line,end_line,created_at) VALUES(?,?,?,?,?,?,?,?)`, chkID, id, i, ch.Text, nil, ch.StartLine, ch.EndLine, now)
-			_, _ = tx.Exec(`INSERT INTO termindex(doc_id,ord,text) VALUES(?,?,?)`, id, i, ch.Text)
-		}
-		_ = tx.Commit()
-		return &models.Document{ID: id, ProjectID: projectID, Path: path}
-	}
-	// if sha unchanged, skip reindex
-	if (sha != "" && existingSHA == sha) || (mtime != "" && existingMTime == mtime) {
-		_ = tx.Commit()
-		return &models.Document{ID: existingID, ProjectID: projectID, Path: path}
-	}
-	// update sha/lang/updated_at
-	_, _ = tx.Exec(`UPDATE documents SET sha=?, lang=?, mtime=?, updated_at=? WHERE id=?`, sha, lang, mtime, now, existingID)
-	// reindex chunks: delete old entries then insert new
-	_, _ = tx.Exec(`DELETE FROM termindex WHERE doc_id=?`, existingID)
-	_, _ = tx.Exec(`DELETE FROM chunks WHERE doc_id=?`, existingID)
+		id = s.nextID("doc")
+		if _, err := tx.Exec(`INSERT INTO documents(id,project_id,path,sha,lang,mtime,created_at,updated_at) VALUES(?,?,?,?,?,?,?,?)`, id, projectID, d.Path, d.SHA, d.Lang, d.MTime, now, now); err != nil {
+			return nil, err
+		}
+	} else {
+		// if sha unchanged, skip reindex
+		if (d.SHA != "" && existingSHA == d.SHA) || (d.MTime != "" && existingMTime == d.MTime) {
+			return &models.Document{ID: existingID, ProjectID: projectID, Path: d.Path}, nil
+		}
+		// update sha/lang/updated_at, then drop old chunks before re-inserting
+		if _, err := tx.Exec(`UPDATE documents SET sha=?, lang=?, mtime=?, updated_at=? WHERE id=?`, d.SHA, d.Lang, d.MTime, now, existingID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM termindex WHERE doc_id=?`, existingID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM chunks WHERE doc_id=?`, existingID); err != nil {
+			return nil, err
+		}
+	}
+	// index chunks (prefer code-aware when lang known)
 	var chunks []chunk
-	if lang == "go" || lang == "ts" || lang == "js" || lang == "py" {
-		chunks = chunkSmartWithLines(content, lang, 2000)
-	} else if lang == "md" || lang == "txt" {
-		chunks = chunkDocWithLines(content, 2000)
+	if d.Lang == "go" || d.Lang == "ts" || d.Lang == "js" || d.Lang == "py" {
+		chunks = chunkSmartWithLines(d.Content, d.Lang, 2000)
+	} else if d.Lang == "md" || d.Lang == "txt" {
+		chunks = chunkDocWithLines(d.Content, 2000)
 	} else {
-		chunks = chunkTextWithLines(content, 2000)
+		chunks = chunkTextWithLines(d.Content, 2000)
 	}
 	for i, ch := range chunks {
 		chkID := s.nextID("chk")
-		_, _ = tx.Exec(`INSERT INTO chunks(id,doc_id,ord,text,token_count,start_line,end_line,created_at) VALUES(?,?,?,?,?,?,?,?)`, chkID, existingID, i, ch.Text, nil, ch.StartLine, ch.EndLine, now)
-		_, _ = tx.Exec(`INSERT INTO termindex(doc_id,ord,text) VALUES(?,?,?)`, existingID, i, ch.Text)
+		if _, err := tx.Exec(`INSERT INTO chunks(id,doc_id,ord,text,token_count,start_line,end_line,created_at) VALUES(?,?,?,?,?,?,?,?)`, chkID, id, i, ch.Text, nil, ch.StartLine, ch.EndLine, now); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`INSERT INTO termindex(doc_id,ord,text) VALUES(?,?,?)`, id, i, ch.Text); err != nil {
+			return nil, err
+		}
 	}
-	_ = tx.Commit()
-	return &models.Document{ID: existingID, ProjectID: projectID, Path: path}
+	return &models.Document{ID: id, ProjectID: projectID, Path: d.Path}, nil
 }
 
 // GetDocument returns a document metadata by project and path.
@@ -358,6 +471,17 @@ func (s *SQLiteStore) GetDocument(projectID, path string) (*models.Document, boo
 	return &d, true
 }
 
+// IndexStats counts a project's documents and chunks; Bytes is the chunk text size.
+func (s *SQLiteStore) IndexStats(projectID string) (models.IndexStats, error) {
+	var st models.IndexStats
+	if err := s.db.QueryRow(`SELECT COUNT(1) FROM documents WHERE project_id=?`, projectID).Scan(&st.Documents); err != nil {
+		return st, err
+	}
+	err := s.db.QueryRow(`SELECT COUNT(1), COALESCE(SUM(length(CAST(c.text AS BLOB))),0)
+		FROM chunks c JOIN documents d ON d.id=c.doc_id WHERE d.project_id=?`, projectID).Scan(&st.Chunks, &st.Bytes)
+	return st, err
+}
+
 // DeleteDocument deletes a document and its chunks/index entries.
 func (s *SQLiteStore) DeleteDocument(projectID, path string) error {
 	return s.WithTx(func(tx *sql.Tx) error {
@@ -418,17 +542,66 @@ func (s *SQLiteStore) PruneDocuments(projectID string, present []string) error {
 	return tx.Commit()
 }
 
-func (s *SQLiteStore) Search(projectID, query string, k int) []models.SearchResult {
+// ErrInvalidQuery reports a full-text query that cannot be run even after
+// sanitizing (e.g. it contains no searchable terms).
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Search runs an FTS5/BM25 query. A MATCH rejected as invalid FTS5 syntax is
+// retried once with the query reduced to quoted terms; other errors are
+// returned unchanged.
+func (s *SQLiteStore) Search(projectID, query string, k int) ([]models.SearchResult, error) {
 	if k <= 0 {
 		k = 10
 	}
 	// preview token window configurable via env
 	prevTok := 10
-	if v := os.Getenv("MYCODER_PREVIEW_SNIPPET_TOKENS"); v != "" {
+	if v := config.Get("MYCODER_PREVIEW_SNIPPET_TOKENS"); v != "" {
 		if n := atoiNoErr(v); n > 0 {
 			prevTok = n
 		}
 	}
+	out, err := s.matchSearch(projectID, query, k, prevTok)
+	if err == nil {
+		return out, nil
+	}
+	if !isFTSQueryError(err) {
+		return nil, err
+	}
+	// FTS5 syntax error (e.g. "func(" or an unbalanced quote): retry with the
+	// query reduced to quoted terms rather than failing a natural query
+	safe := ftsQuery(query)
+	mylog.New().Warn("store.search.match_error", "query", query, "error", err.Error(), "retry", safe)
+	if safe == "" {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	if safe == query {
+		return nil, err
+	}
+	out, err = s.matchSearch(projectID, safe, k, prevTok)
+	if err != nil {
+		mylog.New().Warn("store.search.match_error", "query", safe, "error", err.Error())
+		return nil, err
+	}
+	return out, nil
+}
+
+// ftsQueryErrors are the SQLite messages for a MATCH expression FTS5 cannot parse.
+var ftsQueryErrors = []string{"fts5: syntax error", "unterminated string", "no such column", "unknown special query"}
+
+// isFTSQueryError reports whether err comes from the query text rather than
+// the database.
+func isFTSQueryError(err error) bool {
+	msg := err.Error()
+	for _, m := range ftsQueryErrors {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSearch runs an FTS5 MATCH query ranked by bm25.
+func (s *SQLiteStore) matchSearch(projectID, match string, k, prevTok int) ([]models.SearchResult, error) {
 	var rows *sql.Rows
 	var err error
 	if projectID != "" {
@@ -440,7 +613,7 @@ func (s *SQLiteStore) Search(projectID, query string, k int) []models.SearchResu
             JOIN chunks c ON c.doc_id = termindex.doc_id AND c.ord = termindex.ord
             WHERE d.project_id = ? AND termindex MATCH ?
             ORDER BY score DESC LIMIT ?
-        `, prevTok), projectID, query, k)
+        `, prevTok), projectID, match, k)
 	} else {
 		rows, err = s.db.Query(fmt.Sprintf(`
             SELECT d.path, bm25(termindex) as score, snippet(termindex, 2, '[', ']', ' … ', %d) as preview,
@@ -450,10 +623,10 @@ func (s *SQLiteStore) Search(projectID, query string, k int) []models.SearchResu
             JOIN chunks c ON c.doc_id = termindex.doc_id AND c.ord = termindex.ord
             WHERE termindex MATCH ?
             ORDER BY score DESC LIMIT ?
-        `, prevTok), query, k)
+        `, prevTok), match, k)
 	}
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
 	var out []models.SearchResult
@@ -472,7 +645,62 @@ func (s *SQLiteStore) Search(projectID, query string, k int) []models.SearchResu
 			out = append(out, res)
 		}
 	}
-	return out
+	return out, rows.Err()
+}
+
+// ftsQuery reduces free text to FTS5-safe syntax: each run of letters, digits
+// or underscores becomes a quoted term, so operators and punctuation such as
+// "func(" or `"unbalanced` can't cause MATCH syntax errors. Terms are ANDed.
+func ftsQuery(q string) string {
+	terms := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for i, t := range terms {
+		terms[i] = `"` + t + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchRegexp scans chunk text with re instead of FTS MATCH, so punctuation and
+// literal substrings work. A literal prefix of re is pushed down as an instr()
+// filter to avoid reading every chunk.
+func (s *SQLiteStore) SearchRegexp(projectID string, re *regexp.Regexp, k int) []models.SearchResult {
+	if k <= 0 {
+		k = 10
+	}
+	query := `SELECT d.path, c.text, c.start_line FROM chunks c JOIN documents d ON d.id = c.doc_id WHERE 1=1`
+	var args []any
+	if projectID != "" {
+		query += ` AND d.project_id = ?`
+		args = append(args, projectID)
+	}
+	if prefix, _ := re.LiteralPrefix(); prefix != "" {
+		query += ` AND instr(c.text, ?) > 0`
+		args = append(args, prefix)
+	}
+	rows, err := s.db.Query(query+` ORDER BY d.path, c.ord`, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []models.SearchResult
+	for rows.Next() {
+		var path, text string
+		var start sql.NullInt64
+		if err := rows.Scan(&path, &text, &start); err != nil {
+			continue
+		}
+		res, ok := scanText(re, text, int(start.Int64))
+		if !ok {
+			continue
+		}
+		res.Path = path
+		out = append(out, res)
+		if len(out) >= scanMaxHits {
+			break
+		}
+	}
+	return rankScan(out, k)
 }
 
 // UpsertSymbols replaces symbols for a given project+path with the provided set.
@@ -753,7 +981,7 @@ func splitTokensWithOverlap(s string, maxTokens int, overlapRatio float64, start
 
 func chunkConfig(hint int) (maxTokens int, overlap float64) {
 	// env override
-	if v := os.Getenv("MYCODER_CHUNK_MAX_TOKENS"); v != "" {
+	if v := config.Get("MYCODER_CHUNK_MAX_TOKENS"); v != "" {
 		if n := atoiNoErr(v); n > 0 {
 			maxTokens = n
 		}
@@ -767,7 +995,7 @@ func chunkConfig(hint int) (maxTokens int, overlap float64) {
 		}
 	}
 	overlap = 0.10
-	if v := os.Getenv("MYCODER_CHUNK_OVERLAP_RATIO"); v != "" {
+	if v := config.Get("MYCODER_CHUNK_OVERLAP_RATIO"); v != "" {
 		if f := atofNoErr(v); f >= 0 && f <= 0.5 {
 			overlap = f
 		}
@@ -839,6 +1067,38 @@ func (s *SQLiteStore) Stats() map[string]int {
 	}
 }
 
+// ProjectCounts holds per-project document and knowledge totals.
+type ProjectCounts struct {
+	ProjectID string
+	Documents int
+	Knowledge int
+}
+
+// ProjectStats returns document/knowledge counts grouped by project, largest
+// first, capped at limit rows (limit<=0 means no cap).
+func (s *SQLiteStore) ProjectStats(limit int) ([]ProjectCounts, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.Query(`SELECT p.id,
+		(SELECT COUNT(1) FROM documents d WHERE d.project_id=p.id) AS docs,
+		(SELECT COUNT(1) FROM knowledge k WHERE k.project_id=p.id) AS kn
+		FROM projects p ORDER BY docs+kn DESC, p.id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ProjectCounts
+	for rows.Next() {
+		var pc ProjectCounts
+		if err := rows.Scan(&pc.ProjectID, &pc.Documents, &pc.Knowledge); err != nil {
+			return nil, err
+		}
+		out = append(out, pc)
+	}
+	return out, rows.Err()
+}
+
 // Knowledge minimal operations
 func (s *SQLiteStore) AddKnowledge(projectID, sourceType, pathOrURL, title, text string, trust float64, pinned bool) (*models.Knowledge, error) {
 	id := s.nextID("kn")
@@ -850,7 +1110,7 @@ func (s *SQLiteStore) AddKnowledge(projectID, sourceType, pathOrURL, title, text
 }
 
 func (s *SQLiteStore) ListKnowledge(projectID string, minScore float64) ([]*models.Knowledge, error) {
-	rows, err := s.db.Query(`SELECT id,source_type,path_or_url,title,text,trust_score,pinned FROM knowledge WHERE project_id=? AND trust_score>=? ORDER BY trust_score DESC, created_at DESC`, projectID, minScore)
+	rows, err := s.db.Query(`SELECT id,source_type,path_or_url,title,text,trust_score,pinned,COALESCE(commit_sha,''),COALESCE(files,''),COALESCE(symbols,''),COALESCE(tags,''),COALESCE(approved_by,''),COALESCE(approved_at,'') FROM knowledge WHERE project_id=? AND trust_score>=? ORDER BY trust_score DESC, created_at DESC`, projectID, minScore)
 	if err != nil {
 		return nil, err
 	}
@@ -859,7 +1119,7 @@ func (s *SQLiteStore) ListKnowledge(projectID string, minScore float64) ([]*mode
 	for rows.Next() {
 		var k models.Knowledge
 		var pinned int
-		if err := rows.Scan(&k.ID, &k.SourceType, &k.PathOrURL, &k.Title, &k.Text, &k.TrustScore, &pinned); err == nil {
+		if err := rows.Scan(&k.ID, &k.SourceType, &k.PathOrURL, &k.Title, &k.Text, &k.TrustScore, &pinned, &k.CommitSHA, &k.Files, &k.Symbols, &k.Tags, &k.ApprovedBy, &k.ApprovedAt); err == nil {
 			k.ProjectID = projectID
 			k.Pinned = pinned == 1
 			out = append(out, &k)
@@ -868,6 +1128,86 @@ func (s *SQLiteStore) ListKnowledge(projectID string, minScore float64) ([]*mode
 	return out, nil
 }
 
+// GetKnowledge returns one knowledge item by ID including commit/files/symbols/tags.
+func (s *SQLiteStore) GetKnowledge(id string) (*models.Knowledge, bool, error) {
+	var k models.Knowledge
+	var pinned int
+	err := s.db.QueryRow(`SELECT id,project_id,source_type,COALESCE(path_or_url,''),COALESCE(title,''),COALESCE(text,''),trust_score,pinned,COALESCE(commit_sha,''),COALESCE(files,''),COALESCE(symbols,''),COALESCE(tags,''),COALESCE(approved_by,''),COALESCE(approved_at,'') FROM knowledge WHERE id=?`, id).
+		Scan(&k.ID, &k.ProjectID, &k.SourceType, &k.PathOrURL, &k.Title, &k.Text, &k.TrustScore, &pinned, &k.CommitSHA, &k.Files, &k.Symbols, &k.Tags, &k.ApprovedBy, &k.ApprovedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	k.Pinned = pinned == 1
+	return &k, true, nil
+}
+
+// ImportKnowledge bulk-inserts items, each into its own ProjectID, under fresh IDs in one transaction.
+func (s *SQLiteStore) ImportKnowledge(items []*models.Knowledge) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	now := time.Now().Format(time.RFC3339)
+	for _, k := range items {
+		_, err := tx.Exec(`INSERT INTO knowledge(id,project_id,source_type,path_or_url,title,text,trust_score,pinned,commit_sha,files,symbols,tags,created_at) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+			s.nextID("kn"), k.ProjectID, k.SourceType, k.PathOrURL, k.Title, k.Text, k.TrustScore, boolToInt(k.Pinned), k.CommitSHA, k.Files, k.Symbols, nullIfEmpty(k.Tags), now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// UpdateKnowledge applies the non-nil fields of u; false when the item does not exist.
+func (s *SQLiteStore) UpdateKnowledge(id string, u models.KnowledgeUpdate) (bool, error) {
+	sets := []string{}
+	args := []any{}
+	if u.Title != nil {
+		sets = append(sets, "title=?")
+		args = append(args, *u.Title)
+	}
+	if u.Text != nil {
+		sets = append(sets, "text=?")
+		args = append(args, *u.Text)
+	}
+	if u.TrustScore != nil {
+		sets = append(sets, "trust_score=?")
+		args = append(args, *u.TrustScore)
+	}
+	if u.Pinned != nil {
+		sets = append(sets, "pinned=?")
+		args = append(args, boolToInt(*u.Pinned))
+	}
+	if len(sets) == 0 {
+		_, ok, err := s.GetKnowledge(id)
+		return ok, err
+	}
+	args = append(args, id)
+	res, err := s.db.Exec(`UPDATE knowledge SET `+strings.Join(sets, ",")+` WHERE id=?`, args...)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteKnowledge removes one knowledge item by ID; false when it did not exist.
+func (s *SQLiteStore) DeleteKnowledge(id string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM knowledge WHERE id=?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
 func (s *SQLiteStore) VetKnowledge(projectID string) (int, error) {
 	// improved vet scoring: text length, pinned boost, freshness boost; clamp at 1.0
 	res, err := s.db.Exec(`
@@ -981,6 +1321,120 @@ func boolToInt(b bool) int {
 	return 0
 }
 
+// nullIfEmpty maps "" to SQL NULL for optional text columns.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// CreateConversation starts an empty conversation for a project.
+func (s *SQLiteStore) CreateConversation(projectID, title string) (*models.Conversation, error) {
+	now := time.Now().Format(time.RFC3339)
+	c := &models.Conversation{ID: uniqueID("conv"), ProjectID: projectID, Title: title, CreatedAt: now, UpdatedAt: now}
+	_, err := s.db.Exec(`INSERT INTO conversations(id,project_id,title,pinned,created_at,updated_at) VALUES(?,?,?,0,?,?)`, c.ID, projectID, nullIfEmpty(title), now, now)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AddConversationMessage appends a message and bumps the conversation's updated_at.
+func (s *SQLiteStore) AddConversationMessage(convID, role, content string) (*models.ConversationMessage, error) {
+	now := time.Now().Format(time.RFC3339)
+	m := &models.ConversationMessage{ID: uniqueID("msg"), Role: role, Content: content, CreatedAt: now}
+	err := s.WithTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE conversations SET updated_at=? WHERE id=?`, now, convID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("conversation %s not found", convID)
+		}
+		_, err = tx.Exec(`INSERT INTO conversation_messages(id,conv_id,role,content,created_at) VALUES(?,?,?,?,?)`, m.ID, convID, role, content, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListConversations returns a project's conversations (all projects when
+// projectID is empty), most recently updated first, with message counts.
+func (s *SQLiteStore) ListConversations(projectID string) ([]*models.Conversation, error) {
+	query := `SELECT c.id, c.project_id, COALESCE(c.title,''), COALESCE(c.pinned,0), c.created_at, COALESCE(c.updated_at,''),
+        (SELECT COUNT(1) FROM conversation_messages m WHERE m.conv_id = c.id)
+        FROM conversations c`
+	var args []any
+	if projectID != "" {
+		query += ` WHERE c.project_id=?`
+		args = append(args, projectID)
+	}
+	rows, err := s.db.Query(query+` ORDER BY COALESCE(c.updated_at, c.created_at) DESC, c.rowid DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []*models.Conversation{}
+	for rows.Next() {
+		c := &models.Conversation{}
+		var pinned int
+		if err := rows.Scan(&c.ID, &c.ProjectID, &c.Title, &pinned, &c.CreatedAt, &c.UpdatedAt, &c.MessageCount); err != nil {
+			return nil, err
+		}
+		c.Pinned = pinned != 0
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetConversation returns a conversation and its messages in order.
+func (s *SQLiteStore) GetConversation(id string) (*models.Conversation, []models.ConversationMessage, bool, error) {
+	c := &models.Conversation{}
+	var pinned int
+	err := s.db.QueryRow(`SELECT id, project_id, COALESCE(title,''), COALESCE(pinned,0), created_at, COALESCE(updated_at,'') FROM conversations WHERE id=?`, id).
+		Scan(&c.ID, &c.ProjectID, &c.Title, &pinned, &c.CreatedAt, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+	c.Pinned = pinned != 0
+	rows, err := s.db.Query(`SELECT id, role, content, created_at FROM conversation_messages WHERE conv_id=? ORDER BY created_at, rowid`, id)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+	msgs := []models.ConversationMessage{}
+	for rows.Next() {
+		var m models.ConversationMessage
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, nil, false, err
+		}
+		msgs = append(msgs, m)
+	}
+	c.MessageCount = len(msgs)
+	return c, msgs, true, rows.Err()
+}
+
+// SetConversationPinned sets the pinned flag; pinned conversations are kept by
+// CleanupConversations. Reports whether the conversation exists.
+func (s *SQLiteStore) SetConversationPinned(id string, pinned bool) (bool, error) {
+	v := 0
+	if pinned {
+		v = 1
+	}
+	res, err := s.db.Exec(`UPDATE conversations SET pinned=? WHERE id=?`, v, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
 // CleanupConversations deletes non-pinned conversations older than ttlDays and their messages/summaries.
 func (s *SQLiteStore) CleanupConversations(ttlDays int) (int, error) {
 	if ttlDays <= 0 {
@@ -1023,17 +1477,130 @@ func (s *SQLiteStore) cleanupConversationIDs(ids []string) (int, error) {
 	return len(ids), nil
 }
 
-func (s *SQLiteStore) ApproveKnowledge(projectID string, ids []string, pin bool, minTrust float64) (int, error) {
+func (s *SQLiteStore) ApproveKnowledge(projectID string, ids []string, pin bool, minTrust float64, approvedBy string) (int, error) {
 	if len(ids) == 0 {
 		return 0, nil
 	}
+	now := time.Now().Format(time.RFC3339)
 	n := 0
-	for _, id := range ids {
-		_, err := s.db.Exec(`UPDATE knowledge SET pinned = CASE WHEN ? THEN 1 ELSE pinned END, trust_score = CASE WHEN trust_score < ? THEN ? ELSE trust_score END WHERE project_id=? AND id=?`, pin, minTrust, minTrust, projectID, id)
-		if err != nil {
-			return n, err
+	// approvals and their audit rows commit together
+	err := s.WithTx(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			res, err := tx.Exec(`UPDATE knowledge SET pinned = CASE WHEN ? THEN 1 ELSE pinned END, trust_score = CASE WHEN trust_score < ? THEN ? ELSE trust_score END, approved_by=?, approved_at=? WHERE project_id=? AND id=?`, pin, minTrust, minTrust, nullIfEmpty(approvedBy), now, projectID, id)
+			if err != nil {
+				return err
+			}
+			if affected, _ := res.RowsAffected(); affected == 0 {
+				continue
+			}
+			_, err = tx.Exec(`INSERT INTO knowledge_approvals(id,project_id,knowledge_id,title,approved_by,approved_at) SELECT ?,project_id,id,title,?,? FROM knowledge WHERE id=?`, uniqueID("ka"), nullIfEmpty(approvedBy), now, id)
+			if err != nil {
+				return err
+			}
+			n++
 		}
-		n++
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 	return n, nil
 }
+
+// ListKnowledgeApprovals returns the project's approval log, newest first.
+func (s *SQLiteStore) ListKnowledgeApprovals(projectID string) ([]*models.KnowledgeApproval, error) {
+	rows, err := s.db.Query(`SELECT id,knowledge_id,COALESCE(title,''),COALESCE(approved_by,''),approved_at FROM knowledge_approvals WHERE project_id=? ORDER BY approved_at DESC, rowid DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []*models.KnowledgeApproval{}
+	for rows.Next() {
+		a := &models.KnowledgeApproval{ProjectID: projectID}
+		if err := rows.Scan(&a.ID, &a.KnowledgeID, &a.Title, &a.ApprovedBy, &a.ApprovedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// SaveMetricCounters replaces the persisted metrics counters with values.
+func (s *SQLiteStore) SaveMetricCounters(values map[string]float64) error {
+	now := time.Now().Format(time.RFC3339)
+	return s.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM metric_counters`); err != nil {
+			return err
+		}
+		for name, v := range values {
+			if _, err := tx.Exec(`INSERT INTO metric_counters(name,value,updated_at) VALUES(?,?,?)`, name, v, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadMetricCounters returns the persisted metrics counters keyed by name.
+func (s *SQLiteStore) LoadMetricCounters() (map[string]float64, error) {
+	rows, err := s.db.Query(`SELECT name, value FROM metric_counters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]float64{}
+	for rows.Next() {
+		var name string
+		var v float64
+		if err := rows.Scan(&name, &v); err != nil {
+			return nil, err
+		}
+		out[name] = v
+	}
+	return out, rows.Err()
+}
+
+// RecordMCPCall inserts c into the MCP call history and prunes all but the
+// newest keep rows.
+func (s *SQLiteStore) RecordMCPCall(c *models.MCPCall, keep int) error {
+	params, _ := json.Marshal(c.Params)
+	return s.WithTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO mcp_calls(tool,params,outcome,error,status,duration_ms,called_at) VALUES(?,?,?,?,?,?,?)`,
+			c.Tool, string(params), c.Outcome, c.Error, c.Status, c.DurationMs, c.CalledAt)
+		if err != nil {
+			return err
+		}
+		c.ID, _ = res.LastInsertId()
+		if keep > 0 {
+			_, err = tx.Exec(`DELETE FROM mcp_calls WHERE id NOT IN (SELECT id FROM mcp_calls ORDER BY id DESC LIMIT ?)`, keep)
+		}
+		return err
+	})
+}
+
+// ListMCPCalls returns up to limit recorded MCP calls, newest first, optionally
+// filtered by tool.
+func (s *SQLiteStore) ListMCPCalls(tool string, limit int) ([]*models.MCPCall, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.Query(`SELECT id, tool, COALESCE(params,''), outcome, COALESCE(error,''), status, duration_ms, called_at
+        FROM mcp_calls WHERE (? = '' OR tool = ?) ORDER BY id DESC LIMIT ?`, tool, tool, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []*models.MCPCall{}
+	for rows.Next() {
+		c := &models.MCPCall{}
+		var params string
+		if err := rows.Scan(&c.ID, &c.Tool, &params, &c.Outcome, &c.Error, &c.Status, &c.DurationMs, &c.CalledAt); err != nil {
+			return nil, err
+		}
+		if params != "" {
+			_ = json.Unmarshal([]byte(params), &c.Params)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
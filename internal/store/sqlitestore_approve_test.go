@@ -0,0 +1,65 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/models"
+)
+
+func TestSQLiteApproveKnowledgeAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite")
+	st, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.CreateProject("p", t.TempDir(), nil)
+	k, err := st.AddKnowledge(p.ID, "doc", "", "t", "x", 0.2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := st.ApproveKnowledge(p.ID, []string{k.ID}, false, 0.5, "alice"); err != nil || n != 1 {
+		t.Fatalf("approve n=%d err=%v", n, err)
+	}
+	_ = st.DB().Close()
+
+	// a reopened store starts its in-memory counters over
+	st, err = NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.DB().Close()
+	for i := 0; i < 5; i++ {
+		if n, err := st.ApproveKnowledge(p.ID, []string{k.ID}, false, 0.5, "bob"); err != nil || n != 1 {
+			t.Fatalf("approve after restart #%d n=%d err=%v", i, n, err)
+		}
+	}
+	list, err := st.ListKnowledgeApprovals(p.ID)
+	if err != nil || len(list) != 6 {
+		t.Fatalf("approvals=%d err=%v", len(list), err)
+	}
+}
+
+func TestSQLiteImportKnowledgeIsAtomicAcrossProjects(t *testing.T) {
+	st, err := NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.DB().Close()
+	a := st.CreateProject("a", t.TempDir(), nil)
+	b := st.CreateProject("b", t.TempDir(), nil)
+	// make the insert for project b fail after project a's row went in
+	if _, err := st.DB().Exec(`CREATE TRIGGER fail_b BEFORE INSERT ON knowledge WHEN NEW.project_id = '` + b.ID + `' BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatal(err)
+	}
+	items := []*models.Knowledge{
+		{ProjectID: a.ID, SourceType: "doc", Text: "one", TrustScore: 0.5},
+		{ProjectID: b.ID, SourceType: "doc", Text: "two", TrustScore: 0.5},
+	}
+	if _, err := st.ImportKnowledge(items); err == nil {
+		t.Fatal("expected import error")
+	}
+	if list, _ := st.ListKnowledge(a.ID, 0); len(list) != 0 {
+		t.Fatalf("partial import left %d rows in project a", len(list))
+	}
+}
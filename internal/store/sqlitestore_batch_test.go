@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func smallDocs(n int, body string) []Doc {
+	docs := make([]Doc, n)
+	for i := range docs {
+		docs[i] = Doc{
+			Path:    fmt.Sprintf("pkg/f%03d.go", i),
+			Content: fmt.Sprintf("package pkg\n\n// F%d %s\nfunc F%d() int { return %d }\n", i, body, i, i),
+			SHA:     fmt.Sprintf("%s-%d", body, i),
+			Lang:    "go",
+		}
+	}
+	return docs
+}
+
+func TestUpsertDocumentsBatchMatchesPerFile(t *testing.T) {
+	batch, err := NewSQLite(filepath.Join(t.TempDir(), "batch.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := NewSQLite(filepath.Join(t.TempDir(), "single.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb := batch.CreateProject("b", t.TempDir(), nil)
+	ps := single.CreateProject("s", t.TempDir(), nil)
+	docs := smallDocs(100, "v1")
+
+	got, err := batch.UpsertDocuments(pb.ID, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(docs) || got[42].Path != docs[42].Path || got[42].ID == "" {
+		t.Fatalf("unexpected documents: %d, %+v", len(got), got[42])
+	}
+	for _, d := range docs {
+		single.UpsertDocument(ps.ID, d.Path, d.Content, d.SHA, d.Lang, d.MTime)
+	}
+	bs, _ := batch.IndexStats(pb.ID)
+	ss, _ := single.IndexStats(ps.ID)
+	if bs.Documents != 100 || bs.Chunks < 100 || bs != ss {
+		t.Fatalf("batch stats %+v, per-file stats %+v", bs, ss)
+	}
+
+	// re-running with a few changed files keeps ids and re-chunks only those
+	docs[7] = Doc{Path: docs[7].Path, Content: strings.Repeat("package pkg\n// changed\n", 3), SHA: "v2-7", Lang: "go"}
+	again, err := batch.UpsertDocuments(pb.ID, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[7].ID != got[7].ID || again[99].ID != got[99].ID {
+		t.Fatalf("ids changed on re-upsert")
+	}
+	if after, _ := batch.IndexStats(pb.ID); after.Documents != 100 || after.Chunks != bs.Chunks {
+		t.Fatalf("after re-upsert %+v, want %d docs / %d chunks", after, 100, bs.Chunks)
+	}
+	if res, _ := batch.Search(pb.ID, "changed", 5); len(res) == 0 || res[0].Path != docs[7].Path {
+		t.Fatalf("changed file not re-indexed: %+v", res)
+	}
+}
+
+// BenchmarkUpsertDocuments compares per-file transactions with the batch path.
+func BenchmarkUpsertDocuments(b *testing.B) {
+	docs := smallDocs(200, "bench")
+	b.Run("per-file", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			st, _ := NewSQLite(filepath.Join(b.TempDir(), "db.sqlite"))
+			p := st.CreateProject("p", ".", nil)
+			for _, d := range docs {
+				st.UpsertDocument(p.ID, d.Path, d.Content, d.SHA, d.Lang, d.MTime)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			st, _ := NewSQLite(filepath.Join(b.TempDir(), "db.sqlite"))
+			p := st.CreateProject("p", ".", nil)
+			if _, err := st.UpsertDocuments(p.ID, docs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
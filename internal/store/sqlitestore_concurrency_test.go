@@ -0,0 +1,74 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteConcurrentReadsDuringWrite(t *testing.T) {
+	st, err := NewSQLite(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mode string
+	if err := st.DB().QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode=%q err=%v, want wal", mode, err)
+	}
+	p := st.CreateProject("conc", t.TempDir(), nil)
+	st.AddDocument(p.ID, "seed.go", "package seed\nfunc Seed() {}\n")
+
+	// hold a write transaction open while readers and a second writer run
+	tx, err := st.DB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`INSERT INTO documents(id,project_id,path,created_at) VALUES('held',?,'held.go','now')`, p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			if err := st.DB().QueryRow(`SELECT COUNT(1) FROM documents WHERE project_id=?`, p.ID).Scan(&n); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := st.Search(p.ID, "Seed", 5); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	readersDone := make(chan struct{})
+	go func() { wg.Wait(); close(readersDone) }()
+	select {
+	case <-readersDone:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("readers blocked behind the open write transaction")
+	}
+
+	writerDone := make(chan error, 1)
+	go func() {
+		_, err := st.DB().Exec(`INSERT INTO documents(id,project_id,path,created_at) VALUES('queued',?,'queued.go','now')`, p.ID)
+		writerDone <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-writerDone; err != nil {
+		t.Fatalf("second writer should wait on busy_timeout, got %v", err)
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent read failed: %v", err)
+	}
+	if n := st.Stats()["documents"]; n != 3 {
+		t.Fatalf("documents=%d, want 3", n)
+	}
+}
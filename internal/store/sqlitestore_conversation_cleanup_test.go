@@ -43,3 +43,37 @@ func TestCleanupConversationsTTL(t *testing.T) {
 		t.Fatalf("expected recent pinned conversation to remain")
 	}
 }
+
+func TestConversationIDsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conv.db")
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.CreateConversation("p1", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddConversationMessage(first.ID, "user", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.db.Close()
+
+	// a reopened store starts its in-memory counters over
+	s, err = NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.db.Close()
+	second, err := s.CreateConversation("p1", "second")
+	if err != nil {
+		t.Fatalf("create after restart: %v", err)
+	}
+	if _, err := s.AddConversationMessage(second.ID, "user", "hello"); err != nil {
+		t.Fatalf("message after restart: %v", err)
+	}
+	list, err := s.ListConversations("p1")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("conversations=%d err=%v", len(list), err)
+	}
+}
@@ -31,7 +31,7 @@ func TestProjectAndDocumentCRUD(t *testing.T) {
 	if doc, ok := s.GetDocument(p.ID, "d.txt"); !ok || doc.Path != "d.txt" {
 		t.Fatalf("expected to get document d.txt, got ok=%v doc=%v", ok, doc)
 	}
-	if res := s.Search(p.ID, "hello", 10); len(res) == 0 {
+	if res, _ := s.Search(p.ID, "hello", 10); len(res) == 0 {
 		t.Fatalf("expected search hit for 'hello'")
 	}
 
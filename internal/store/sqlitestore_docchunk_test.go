@@ -27,7 +27,7 @@ func TestDocChunkerSplitsMarkdownByHeadings(t *testing.T) {
 		t.Fatalf("expected at least 2 chunks for headings, got %d", cnt)
 	}
 	// ensure search hits work
-	res := s.Search(p.ID, "Section", 5)
+	res, _ := s.Search(p.ID, "Section", 5)
 	if len(res) == 0 {
 		t.Fatalf("expected search hit for 'Section'")
 	}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"mycoder/internal/models"
+)
+
+func TestSQLiteMCPCallsCappedAndPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite")
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range []string{"echo", "time", "echo", "search"} {
+		if err := s.RecordMCPCall(&models.MCPCall{Tool: tool, Params: map[string]string{"q": "x"}, Outcome: "ok", Status: 200, CalledAt: "2026-01-01T00:00:00Z"}, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.DB().Close()
+
+	s, err = NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.DB().Close()
+	calls, err := s.ListMCPCalls("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0].Tool != "search" || calls[2].Tool != "time" {
+		t.Fatalf("expected newest 3 calls, got %+v", calls)
+	}
+	if calls[0].Params["q"] != "x" {
+		t.Fatalf("params not round-tripped: %+v", calls[0].Params)
+	}
+	echo, _ := s.ListMCPCalls("echo", 10)
+	if len(echo) != 1 {
+		t.Fatalf("expected 1 echo call after cap, got %d", len(echo))
+	}
+}
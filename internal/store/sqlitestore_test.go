@@ -1,8 +1,11 @@
 package store
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -21,19 +24,19 @@ func TestSQLiteSearchProjectFilter(t *testing.T) {
 	s.AddDocument(p2.ID, "b.txt", "alpha delta")
 
 	// query within p1 only
-	got := s.Search(p1.ID, "alpha", 10)
+	got, _ := s.Search(p1.ID, "alpha", 10)
 	if len(got) != 1 || got[0].Path != "a.txt" {
 		t.Fatalf("expected 1 result a.txt in p1, got %+v", got)
 	}
 
 	// global query (no project) should see both
-	got = s.Search("", "alpha", 10)
+	got, _ = s.Search("", "alpha", 10)
 	if len(got) < 2 {
 		t.Fatalf("expected >=2 results globally, got %d", len(got))
 	}
 
 	// wrong project filter
-	got = s.Search("nonexistent", "alpha", 10)
+	got, _ = s.Search("nonexistent", "alpha", 10)
 	if len(got) != 0 {
 		t.Fatalf("expected 0 results with wrong project filter, got %d", len(got))
 	}
@@ -51,13 +54,13 @@ func TestSQLiteUpsertUpdatesContent(t *testing.T) {
 
 	p := s.CreateProject("p", dir, nil)
 	s.UpsertDocument(p.ID, "a.txt", "alpha", "sha1", "txt", "")
-	got := s.Search(p.ID, "delta", 10)
+	got, _ := s.Search(p.ID, "delta", 10)
 	if len(got) != 0 {
 		t.Fatalf("expected 0 results before update, got %d", len(got))
 	}
 
 	s.UpsertDocument(p.ID, "a.txt", "alpha delta", "sha2", "txt", "")
-	got = s.Search(p.ID, "delta", 10)
+	got, _ = s.Search(p.ID, "delta", 10)
 	if len(got) == 0 {
 		t.Fatalf("expected results after update")
 	}
@@ -125,3 +128,72 @@ func TestKnowledgeOperations(t *testing.T) {
 		t.Errorf("expected trust score > 0.5 after vet, got %f", items[0].TrustScore)
 	}
 }
+
+func TestSQLiteSearchRegexpLiteral(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSQLite(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Skip("sqlite not available:", err)
+	}
+	p := s.CreateProject("p", dir, nil)
+	s.AddDocument(p.ID, "a.go", "package a\n\nfunc f() {\n\tx := a.b()\n\t_ = x\n}\n")
+	s.AddDocument(p.ID, "b.go", "package a\n\nvar ab = 1\n")
+
+	got := s.SearchRegexp(p.ID, regexp.MustCompile(regexp.QuoteMeta("a.b()")), 10)
+	if len(got) != 1 || got[0].Path != "a.go" || got[0].StartLine != 4 {
+		t.Fatalf("literal: %+v", got)
+	}
+	got = s.SearchRegexp(p.ID, regexp.MustCompile(`(?m)^(var|func) \w+`), 10)
+	if len(got) != 2 {
+		t.Fatalf("regex: expected both files, got %+v", got)
+	}
+	if got := s.SearchRegexp("other", regexp.MustCompile("a"), 10); len(got) != 0 {
+		t.Fatalf("project filter: %+v", got)
+	}
+}
+
+func TestSQLiteSearchSanitizesFTSSyntax(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSQLite(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Skip("sqlite not available:", err)
+	}
+	p := s.CreateProject("p", dir, nil)
+	s.AddDocument(p.ID, "main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")
+	s.AddDocument(p.ID, "notes.md", "release notes")
+
+	for _, q := range []string{"func(", `"unbalanced func`, "main()", `println("hi`} {
+		got, _ := s.Search(p.ID, q, 10)
+		if q == `"unbalanced func` {
+			if len(got) != 0 {
+				t.Fatalf("%q: all terms must match, got %+v", q, got)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0].Path != "main.go" {
+			t.Fatalf("%q: expected main.go, got %+v", q, got)
+		}
+	}
+	// valid FTS5 syntax is still passed through untouched
+	if got, _ := s.Search(p.ID, "release OR println", 10); len(got) != 2 {
+		t.Fatalf("OR query: expected 2 results, got %+v", got)
+	}
+	if got := ftsQuery(`func( "x" -y`); got != `"func" "x" "y"` {
+		t.Fatalf("ftsQuery=%q", got)
+	}
+}
+
+func TestSQLiteSearchReturnsDatabaseErrors(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSQLite(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Skip("sqlite not available:", err)
+	}
+	p := s.CreateProject("p", dir, nil)
+	s.AddDocument(p.ID, "main.go", "package main\n")
+	_ = s.DB().Close()
+	_, err = s.Search(p.ID, "func(", 10)
+	if err == nil || errors.Is(err, ErrInvalidQuery) || !strings.Contains(err.Error(), "database is closed") {
+		t.Fatalf("expected the database error unchanged, got %v", err)
+	}
+}
@@ -1,14 +1,14 @@
 package vectorstore
 
-import "os"
+import "mycoder/internal/config"
 
 // NewFromEnv creates a VectorStore based on env configuration.
 // MYCODER_VECTOR_PROVIDER: "noop"(default) | "pgvector"
 // PG DSN env: MYCODER_PGVECTOR_DSN
 func NewFromEnv() VectorStore {
-	switch os.Getenv("MYCODER_VECTOR_PROVIDER") {
+	switch config.Get("MYCODER_VECTOR_PROVIDER") {
 	case "pgvector":
-		return PGVector{DSN: os.Getenv("MYCODER_PGVECTOR_DSN")}
+		return PGVector{DSN: config.Get("MYCODER_PGVECTOR_DSN")}
 	default:
 		return Noop{}
 	}
@@ -76,6 +76,27 @@ func (s SQLiteVS) Search(ctx context.Context, projectID string, query []float32,
 	return results, nil
 }
 
+// Stats implements StatsReporter, grouping vectors by model and dimension.
+func (s SQLiteVS) Stats(ctx context.Context, projectID string) ([]ModelStats, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT COALESCE(model,''), COALESCE(dim,0), COUNT(1) FROM embeddings WHERE project_id=? GROUP BY model, dim ORDER BY COUNT(1) DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ModelStats
+	for rows.Next() {
+		var m ModelStats
+		if err := rows.Scan(&m.Model, &m.Dim, &m.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
 func (s SQLiteVS) DeleteByDoc(ctx context.Context, projectID, docID string) error {
 	if s.db == nil {
 		return nil
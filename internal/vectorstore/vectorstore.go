@@ -22,6 +22,18 @@ type Result struct {
 	Score   float64 // higher is better similarity
 }
 
+// ModelStats counts stored vectors for one embedding model and dimension.
+type ModelStats struct {
+	Model string `json:"model"`
+	Dim   int    `json:"dim"`
+	Count int    `json:"count"`
+}
+
+// StatsReporter is implemented by stores that can summarize a project's vectors.
+type StatsReporter interface {
+	Stats(ctx context.Context, projectID string) ([]ModelStats, error)
+}
+
 // VectorStore defines minimal operations for semantic search.
 type VectorStore interface {
 	Upsert(ctx context.Context, items []UpsertItem) error